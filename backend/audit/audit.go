@@ -0,0 +1,138 @@
+// Package audit records who changed what. Services call Record inside the
+// transaction making a change, so the event is saved if and only if the
+// change is. The actor and request are taken from the context the
+// transaction runs with.
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/encryption"
+	"coaching-app-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Entity types
+const (
+	EntityTeam         = "team"
+	EntityTeamMember   = "team_member"
+	EntityFeedback     = "feedback"
+	EntityUser         = "user"
+	EntityAPIKey       = "api_key"
+	EntityOrganization = "organization"
+)
+
+// Actions, recorded as "<entity type>.<action>", e.g. "team.deleted"
+const (
+	ActionCreated          = "created"
+	ActionUpdated          = "updated"
+	ActionDeleted          = "deleted"
+	ActionMerged           = "merged"
+	ActionRevoked          = "revoked"
+	ActionMemberAssigned   = "member_assigned"
+	ActionMemberUnassigned = "member_unassigned"
+	ActionCoachAdded       = "coach_added"
+	ActionCoachRemoved     = "coach_removed"
+	ActionExported         = "exported"
+	ActionErased           = "erased"
+)
+
+// Request identifies the API request a change is made in
+type Request struct {
+	ID string
+	IP string
+}
+
+type requestKey struct{}
+
+// maxRequestIDLength bounds request IDs taken from clients
+const maxRequestIDLength = 64
+
+// EnsureRequestID returns id if it is usable as a request ID, so IDs set by
+// clients or proxies can be followed across systems, and a new random ID
+// otherwise.
+func EnsureRequestID(id string) string {
+	if id != "" && len(id) <= maxRequestIDLength && strings.IndexFunc(id, invalidRequestIDRune) < 0 {
+		return id
+	}
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
+
+func invalidRequestIDRune(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.:", r))
+}
+
+func WithRequest(ctx context.Context, request Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, request)
+}
+
+func RequestFromContext(ctx context.Context) (Request, bool) {
+	request, ok := ctx.Value(requestKey{}).(Request)
+	return request, ok
+}
+
+// Change describes a change to one entity. Before is nil for creations and
+// After is nil for deletions; both are stored as JSON.
+type Change struct {
+	Action     string
+	EntityType string
+	EntityID   uint
+	Before     interface{}
+	After      interface{}
+	// Details adds context that is not part of the entity itself
+	Details interface{}
+}
+
+// Record saves an audit event for change using tx. Changes made outside a
+// request, such as at startup, have no actor.
+func Record(tx *gorm.DB, change Change) error {
+	event := models.AuditEvent{
+		Action:     change.EntityType + "." + change.Action,
+		EntityType: change.EntityType,
+		EntityID:   change.EntityID,
+	}
+
+	var err error
+	if event.Before, err = snapshot(change.Before); err != nil {
+		return err
+	}
+	if event.After, err = snapshot(change.After); err != nil {
+		return err
+	}
+	if event.Details, err = snapshot(change.Details); err != nil {
+		return err
+	}
+
+	if ctx := tx.Statement.Context; ctx != nil {
+		if principal, ok := auth.FromContext(ctx); ok {
+			if principal.APIKeyID != 0 {
+				event.ActorAPIKeyID = &principal.APIKeyID
+			} else {
+				event.ActorUserID = &principal.UserID
+			}
+		}
+		if request, ok := RequestFromContext(ctx); ok {
+			event.RequestID = request.ID
+			event.IP = request.IP
+		}
+	}
+
+	return tx.Create(&event).Error
+}
+
+// snapshot encodes value as JSON. Encrypted fields are left out, so the audit
+// log holds no plaintext of them.
+func snapshot(value interface{}) (models.JSON, error) {
+	if value == nil {
+		return nil, nil
+	}
+	return json.Marshal(encryption.Redact(value))
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oldSecret = "old-secret-old-secret-old-secret-0"
+	newSecret = "new-secret-new-secret-new-secret-0"
+)
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"Single key", "k1:" + oldSecret, false},
+		{"Several keys", "k2:" + newSecret + ", k1:" + oldSecret, false},
+		{"Empty", " , ", true},
+		{"Missing kid", oldSecret, true},
+		{"Short secret", "k1:short", true},
+		{"Duplicate kid", "k1:" + oldSecret + ",k1:" + newSecret, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeyring(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAccessTokens(t *testing.T) {
+	oldKeys, _ := ParseKeyring("k1:" + oldSecret)
+	rotatedKeys, _ := ParseKeyring("k2:" + newSecret + ",k1:" + oldSecret)
+	otherKeys, _ := ParseKeyring("k1:" + newSecret)
+
+	memberID := uint(7)
+	principal := Principal{OrganizationID: 3, UserID: 42, Email: "coach@example.com", TeamMemberID: &memberID}
+
+	oldIssuer := NewTokenIssuer(oldKeys, time.Minute, time.Hour)
+	token, expiresAt, err := oldIssuer.IssueAccessToken(principal)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	if time.Until(expiresAt) > time.Minute {
+		t.Errorf("Expected token to expire within a minute, got %v", expiresAt)
+	}
+
+	// Tokens signed with a previous key still verify after a rotation
+	parsed, err := NewTokenIssuer(rotatedKeys, time.Minute, time.Hour).ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	if parsed.UserID != 42 || parsed.OrganizationID != 3 || parsed.Email != principal.Email || parsed.TeamMemberID == nil || *parsed.TeamMemberID != 7 {
+		t.Errorf("Unexpected principal: %+v", parsed)
+	}
+
+	if _, err := NewTokenIssuer(otherKeys, time.Minute, time.Hour).ParseAccessToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a token signed with another secret to be rejected, got %v", err)
+	}
+
+	expired := NewTokenIssuer(oldKeys, time.Minute, time.Hour)
+	expired.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	stale, _, _ := expired.IssueAccessToken(principal)
+	if _, err := oldIssuer.ParseAccessToken(stale); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "42", "iss": issuer}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := oldIssuer.ParseAccessToken(unsigned); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an unsigned token to be rejected, got %v", err)
+	}
+}
+
+func TestPasswordsAndOpaqueTokens(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if strings.Contains(hash, "correct horse") || !CheckPassword(hash, "correct horse") || CheckPassword(hash, "wrong horse") {
+		t.Error("Expected the hash to verify only the original password")
+	}
+
+	a, _ := NewOpaqueToken()
+	b, _ := NewOpaqueToken()
+	if a == b || HashToken(a) == HashToken(b) || len(HashToken(a)) != 64 {
+		t.Error("Expected distinct random tokens with 64-character hashes")
+	}
+}
+
+func TestPrincipalContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("Expected no principal on an empty context")
+	}
+	ctx := WithPrincipal(context.Background(), Principal{UserID: 1})
+	if principal, ok := FromContext(ctx); !ok || principal.UserID != 1 {
+		t.Errorf("Expected principal from context, got %+v", principal)
+	}
+}
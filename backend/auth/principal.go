@@ -0,0 +1,29 @@
+package auth
+
+import "context"
+
+// Principal identifies the authenticated caller of a request and the
+// organization they belong to. Callers using an API key have an APIKeyID and
+// Scopes instead of a user and role.
+type Principal struct {
+	OrganizationID uint     `json:"organization_id"`
+	UserID         uint     `json:"user_id"`
+	Email          string   `json:"email"`
+	Role           string   `json:"role"`
+	TeamMemberID   *uint    `json:"team_member_id,omitempty"`
+	APIKeyID       uint     `json:"api_key_id,omitempty"`
+	Scopes         []string `json:"scopes,omitempty"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal stored in ctx, if any
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
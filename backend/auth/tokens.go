@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	issuer = "coaching-app-backend"
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid or expired token")
+	ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+)
+
+// Claims are the claims carried by an access token
+type Claims struct {
+	OrganizationID uint   `json:"org"`
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	TeamMemberID   *uint  `json:"team_member_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenIssuer signs and verifies access tokens
+type TokenIssuer struct {
+	keys            *Keyring
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	now             func() time.Time
+}
+
+func NewTokenIssuer(keys *Keyring, accessTTL, refreshTTL time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		keys:            keys,
+		AccessTokenTTL:  accessTTL,
+		RefreshTokenTTL: refreshTTL,
+		now:             time.Now,
+	}
+}
+
+// IssueAccessToken returns a signed access token for the principal and its
+// expiry time.
+func (i *TokenIssuer) IssueAccessToken(principal Principal) (string, time.Time, error) {
+	now := i.now()
+	expiresAt := now.Add(i.AccessTokenTTL)
+	claims := Claims{
+		OrganizationID: principal.OrganizationID,
+		Email:          principal.Email,
+		Role:           principal.Role,
+		TeamMemberID:   principal.TeamMemberID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   strconv.FormatUint(uint64(principal.UserID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	kid, key := i.keys.active()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// ParseAccessToken verifies a token's signature, issuer and lifetime and
+// returns the principal it was issued to.
+func (i *TokenIssuer) ParseAccessToken(raw string) (Principal, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := i.keys.lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(i.now),
+	)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: invalid subject", ErrInvalidToken)
+	}
+	return Principal{
+		OrganizationID: claims.OrganizationID,
+		UserID:         uint(userID),
+		Email:          claims.Email,
+		Role:           claims.Role,
+		TeamMemberID:   claims.TeamMemberID,
+	}, nil
+}
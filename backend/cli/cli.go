@@ -0,0 +1,229 @@
+// Package cli implements the commands of the backend binary, which operate
+// the deployment through the services, against the configured database:
+//
+//	coaching-app-backend [settings] [command] [flags] [arguments]
+//
+// Without a command, the binary serves the API. Commands acting on the data
+// of an organization take --org, the slug or ID of the organization, and act
+// for the default organization without it. Commands printing records take
+// --output, json or table. Changes are recorded in the audit log without an
+// actor, like those made at startup.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"coaching-app-backend/encryption"
+	"coaching-app-backend/models"
+	"coaching-app-backend/services"
+	"coaching-app-backend/tenant"
+
+	"gorm.io/gorm"
+)
+
+// Output formats
+const (
+	OutputJSON  = "json"
+	OutputTable = "table"
+)
+
+// ErrUsage is returned when a command is run with invalid arguments. The
+// problem and the usage have been written to Err by then.
+var ErrUsage = errors.New("invalid usage")
+
+// App holds what the commands run with
+type App struct {
+	DB *gorm.DB
+	// Keys are the encryption keys registered with DB
+	Keys *encryption.Keyring
+	// Out receives the output of commands and Err their usage
+	Out io.Writer
+	Err io.Writer
+	// Serve runs the API servers until ctx is done
+	Serve func(ctx context.Context) error
+}
+
+// Command is a command of the binary, named by one or two words
+type Command struct {
+	Name    string
+	Args    string
+	Summary string
+	run     func(ctx context.Context, app *App, args []string) error
+}
+
+// commands lists the commands in the order they are shown in the usage
+var commands []Command
+
+func init() {
+	commands = []Command{
+		{Name: "serve", Summary: "Migrate the database and serve the API until stopped (default)", run: runServe},
+		{Name: "migrate", Summary: "Create and update the database tables", run: runMigrate},
+		{Name: "seed", Summary: "Add sample teams and members", run: runSeed},
+		{Name: "members list", Args: "[--team ID]", Summary: "List team members with their teams", run: runMembersList},
+		{Name: "members create", Args: "--name NAME --email EMAIL [--picture URL]", Summary: "Create a team member", run: runMembersCreate},
+		{Name: "members merge", Args: "TARGET_ID SOURCE_ID", Summary: "Merge a duplicate member into another", run: runMembersMerge},
+		{Name: "teams list", Summary: "List teams with their member counts", run: runTeamsList},
+		{Name: "teams delete", Args: "ID", Summary: "Delete a team with its assignments", run: runTeamsDelete},
+		{Name: "assign", Args: "TEAM_ID MEMBER_ID", Summary: "Assign a member to a team", run: runAssign},
+		{Name: "feedback export", Args: "[--target-type TYPE --target-id ID]", Summary: "Export feedback as CSV, JSON or a table", run: runFeedbackExport},
+		{Name: "reencrypt", Args: "[--batch-size N] [--pause DURATION]", Summary: "Encrypt all feedback with the active encryption key", run: runReencrypt},
+		{Name: "check", Summary: "Check the database, schema, encryption keys and erasure logs", run: runCheck},
+		{Name: "help", Summary: "Show this help", run: runHelp},
+	}
+}
+
+// Lookup finds the command named by the first words of args, returning it
+// with the arguments that follow. Without arguments, it returns serve.
+func Lookup(args []string) (Command, []string, error) {
+	if len(args) == 0 {
+		return commands[0], nil, nil
+	}
+	for _, command := range commands {
+		words := strings.Fields(command.Name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == command.Name {
+			return command, args[len(words):], nil
+		}
+	}
+	return Command{}, nil, fmt.Errorf("unknown command %q", strings.Join(args, " "))
+}
+
+// Run runs the command named by args, or serve without arguments
+func (a *App) Run(ctx context.Context, args []string) error {
+	command, rest, err := Lookup(args)
+	if err != nil {
+		fmt.Fprintf(a.Err, "%v\n\n", err)
+		Usage(a.Err)
+		return ErrUsage
+	}
+	err = command.run(ctx, a, rest)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// Usage writes the list of commands to w
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: coaching-app-backend [settings] [command] [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, command := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", command.Name, command.Args, command.Summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run a command with --help for its flags, and the binary with --help for the settings.")
+}
+
+func runHelp(ctx context.Context, app *App, args []string) error {
+	Usage(app.Out)
+	return nil
+}
+
+// flagSet parses the flags of a command, including --org and --output when
+// the command takes them
+type flagSet struct {
+	*flag.FlagSet
+	app    *App
+	org    *string
+	output *string
+	// args are the positional arguments
+	args []string
+}
+
+func (a *App) flags(command, args string, tenant, output bool) *flagSet {
+	fs := &flagSet{FlagSet: flag.NewFlagSet(command, flag.ContinueOnError), app: a}
+	fs.SetOutput(a.Err)
+	fs.Usage = func() {
+		fmt.Fprintf(a.Err, "Usage: coaching-app-backend %s [flags] %s\n", command, args)
+		fs.PrintDefaults()
+	}
+	if tenant {
+		fs.org = fs.String("org", "", "slug or ID of the organization to act for (default: the default organization)")
+	}
+	if output {
+		fs.output = fs.String("output", OutputTable, "output format, json or table")
+	}
+	return fs
+}
+
+// parse parses args, expecting count positional arguments. Flags may come
+// before, between or after the positional arguments.
+func (fs *flagSet) parse(args []string, count int) error {
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return flag.ErrHelp
+			}
+			return ErrUsage
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		fs.args = append(fs.args, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(fs.args) != count {
+		return fs.fail("expected %d arguments, got %d", count, len(fs.args))
+	}
+	if fs.output != nil && *fs.output != OutputJSON && *fs.output != OutputTable {
+		return fs.fail("output must be json or table, got %q", *fs.output)
+	}
+	return nil
+}
+
+// fail reports a usage problem and returns ErrUsage
+func (fs *flagSet) fail(format string, args ...interface{}) error {
+	fmt.Fprintf(fs.app.Err, format+"\n", args...)
+	fs.Usage()
+	return ErrUsage
+}
+
+// id parses the positional argument i as a record ID
+func (fs *flagSet) id(i int, name string) (uint, error) {
+	id, err := strconv.ParseUint(fs.args[i], 10, 32)
+	if err != nil || id == 0 {
+		return 0, fs.fail("%s must be a positive number, got %q", name, fs.args[i])
+	}
+	return uint(id), nil
+}
+
+// context returns ctx acting for the organization named by --org
+func (fs *flagSet) context(ctx context.Context) (context.Context, error) {
+	if fs.org == nil || *fs.org == "" {
+		return tenant.WithOrganization(ctx, models.DefaultOrganizationID), nil
+	}
+	organizationID, err := services.NewOrganizationService(fs.app.DB).ResolveOrganization(ctx, *fs.org)
+	if err != nil {
+		return nil, fmt.Errorf("organization %q: %w", *fs.org, err)
+	}
+	return tenant.WithOrganization(ctx, organizationID), nil
+}
+
+// print writes value as indented JSON, or as a table with header and rows
+func (fs *flagSet) print(value interface{}, header []string, rows [][]string) error {
+	if *fs.output == OutputJSON {
+		encoder := json.NewEncoder(fs.app.Out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	return writeTable(fs.app.Out, header, rows)
+}
+
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,307 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"coaching-app-backend/database"
+	"coaching-app-backend/encryption"
+	"coaching-app-backend/models"
+	"coaching-app-backend/services"
+	"coaching-app-backend/tenant"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var testEncryptionKeys, _ = encryption.ParseKeyring("test:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	tenant.Register(db)
+	encryption.Register(db, testEncryptionKeys)
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	return db
+}
+
+// run runs the command of args, returning its output and usage
+func run(t *testing.T, db *gorm.DB, args ...string) (string, string, error) {
+	t.Helper()
+	var out, usage bytes.Buffer
+	app := &App{DB: db, Keys: testEncryptionKeys, Out: &out, Err: &usage}
+	err := app.Run(context.Background(), args)
+	return out.String(), usage.String(), err
+}
+
+// runJSON runs the command of args with JSON output, decoding it into v
+func runJSON(t *testing.T, db *gorm.DB, v interface{}, args ...string) {
+	t.Helper()
+	out, usage, err := run(t, db, append(args[:len(args):len(args)], "--output", "json")...)
+	if err != nil {
+		t.Fatalf("Expected %v to succeed, got %v: %s", args, err, usage)
+	}
+	if err := json.Unmarshal([]byte(out), v); err != nil {
+		t.Fatalf("Expected JSON output, got %v: %s", err, out)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+		rest     []string
+	}{
+		{nil, "serve", nil},
+		{[]string{"migrate"}, "migrate", nil},
+		{[]string{"members", "merge", "1", "2"}, "members merge", []string{"1", "2"}},
+		{[]string{"feedback", "export", "--output", "json"}, "feedback export", []string{"--output", "json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			command, rest, err := Lookup(tt.args)
+			if err != nil {
+				t.Fatalf("Expected %v to be found, got %v", tt.args, err)
+			}
+			if command.Name != tt.expected || strings.Join(rest, " ") != strings.Join(tt.rest, " ") {
+				t.Errorf("Expected %s with %v, got %s with %v", tt.expected, tt.rest, command.Name, rest)
+			}
+		})
+	}
+
+	for _, args := range [][]string{{"members"}, {"teams", "rename"}, {"reencrypt-all"}} {
+		if _, _, err := Lookup(args); err == nil {
+			t.Errorf("Expected %v to be unknown", args)
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	db := setupTestDB(t)
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"Unknown command", []string{"teams", "rename"}, `unknown command "teams rename"`},
+		{"Unknown flag", []string{"teams", "list", "--colour"}, "flag provided but not defined: -colour"},
+		{"Missing argument", []string{"members", "merge", "1"}, "expected 2 arguments, got 1"},
+		{"Extra argument", []string{"teams", "delete", "1", "--output", "json", "2"}, "flag provided but not defined: -output"},
+		{"Invalid ID", []string{"teams", "delete", "one"}, `ID must be a positive number, got "one"`},
+		{"Invalid output", []string{"teams", "list", "--output", "yaml"}, `output must be json or table, got "yaml"`},
+		{"Invalid member", []string{"members", "create", "--name", "Ann"}, "email"},
+		{"Target ID without type", []string{"feedback", "export", "--target-id", "1"}, "target-id requires target-type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, usage, err := run(t, db, tt.args...)
+			if !errors.Is(err, ErrUsage) {
+				t.Errorf("Expected ErrUsage, got %v", err)
+			}
+			if !strings.Contains(usage, tt.expected) || !strings.Contains(usage, "Usage:") {
+				t.Errorf("Expected %q with the usage, got %s", tt.expected, usage)
+			}
+		})
+	}
+
+	if _, _, err := run(t, db, "teams", "list", "--help"); err != nil {
+		t.Errorf("Expected --help to succeed, got %v", err)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	db := setupTestDB(t)
+
+	var results []SeedResult
+	runJSON(t, db, &results, "seed")
+	if len(results) != 5 {
+		t.Fatalf("Expected 2 teams and 3 members, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Status != string(services.BatchCreated) {
+			t.Errorf("Expected %s to be created, got %s", result.Name, result.Status)
+		}
+	}
+
+	// Seeding again keeps the records
+	runJSON(t, db, &results, "seed")
+	for _, result := range results {
+		if result.Status != string(services.BatchUnchanged) {
+			t.Errorf("Expected %s to be unchanged, got %s", result.Name, result.Status)
+		}
+	}
+
+	out, _, err := run(t, db, "members", "list")
+	if err != nil {
+		t.Fatalf("Failed to list members: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(out, "Jane Smith") || !strings.Contains(out, "Development Team, Design Team") {
+		t.Errorf("Expected a table of 3 members with their teams, got:\n%s", out)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	db := setupTestDB(t)
+
+	var created models.TeamMember
+	runJSON(t, db, &created, "members", "create", "--name", " Ann Lee ", "--email", "Ann@Example.com")
+	if created.ID == 0 || created.Name != "Ann Lee" || created.Email != "ann@example.com" {
+		t.Errorf("Expected a normalized member, got %+v", created)
+	}
+	var duplicate models.TeamMember
+	runJSON(t, db, &duplicate, "members", "create", "--name", "Ann", "--email", "ann.lee@example.com")
+
+	if _, _, err := run(t, db, "members", "create", "--name", "Other", "--email", "ann@example.com"); !errors.Is(err, services.ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken, got %v", err)
+	}
+
+	var team models.Team
+	db.Create(&models.Team{Name: "Platform"})
+	db.First(&team)
+	if out, _, err := run(t, db, "assign", formatID(team.ID), formatID(duplicate.ID)); err != nil || !strings.Contains(out, "Assigned") {
+		t.Fatalf("Expected the member to be assigned, got %v: %s", err, out)
+	}
+	if _, _, err := run(t, db, "assign", formatID(team.ID), "999"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a missing member to be reported, got %v", err)
+	}
+
+	var result services.MergeResult
+	runJSON(t, db, &result, "members", "merge", formatID(created.ID), formatID(duplicate.ID))
+	if result.MergedID != duplicate.ID || result.MovedAssignments != 1 {
+		t.Errorf("Expected the assignment to move onto the target, got %+v", result)
+	}
+
+	var members []models.TeamMember
+	runJSON(t, db, &members, "members", "list", "--team", formatID(team.ID))
+	if len(members) != 1 || members[0].ID != created.ID {
+		t.Errorf("Expected only the merged member in the team, got %+v", members)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	db := setupTestDB(t)
+	run(t, db, "seed")
+
+	var teams []models.Team
+	runJSON(t, db, &teams, "teams", "list")
+	if len(teams) != 2 || len(teams[0].Members) != 2 {
+		t.Fatalf("Expected the 2 sample teams with their members, got %+v", teams)
+	}
+
+	out, _, err := run(t, db, "teams", "delete", formatID(teams[0].ID))
+	if err != nil || !strings.Contains(out, "Deleted team") {
+		t.Fatalf("Expected the team to be deleted, got %v: %s", err, out)
+	}
+	if _, _, err := run(t, db, "teams", "delete", formatID(teams[0].ID)); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected deleting again to report the team missing, got %v", err)
+	}
+
+	var events int64
+	db.Model(&models.AuditEvent{}).Where("action = ?", "team.deleted").Count(&events)
+	if events != 1 {
+		t.Errorf("Expected the deletion to be audited, got %d events", events)
+	}
+}
+
+func TestOrganizations(t *testing.T) {
+	db := setupTestDB(t)
+	other, err := services.NewOrganizationService(db).CreateOrganization(services.OrganizationInput{Name: "Other", Slug: "other"})
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+
+	run(t, db, "seed")
+	var members []models.TeamMember
+	runJSON(t, db, &members, "members", "list", "--org", "other")
+	if len(members) != 0 {
+		t.Errorf("Expected the sample data to stay in the default organization, got %+v", members)
+	}
+
+	runJSON(t, db, &members, "seed", "--org", formatID(other.ID))
+	runJSON(t, db, &members, "members", "list", "--org", "other")
+	if len(members) != 3 {
+		t.Errorf("Expected the other organization's own members, got %+v", members)
+	}
+
+	if _, _, err := run(t, db, "teams", "list", "--org", "missing"); !errors.Is(err, tenant.ErrUnknownOrganization) {
+		t.Errorf("Expected ErrUnknownOrganization, got %v", err)
+	}
+}
+
+func TestFeedbackExport(t *testing.T) {
+	db := setupTestDB(t)
+	run(t, db, "seed")
+	feedback := services.NewFeedbackService(db)
+	for _, item := range []models.Feedback{
+		{Content: "Great sprint", TargetType: "team", TargetID: 1},
+		{Content: "Clear reviews", TargetType: "member", TargetID: 2},
+	} {
+		if err := feedback.CreateFeedback(&item); err != nil {
+			t.Fatalf("Failed to create feedback: %v", err)
+		}
+	}
+
+	out, _, err := run(t, db, "feedback", "export", "--columns", "id,content")
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if out != "\ufeffid,content\r\n1,Great sprint\r\n2,Clear reviews\r\n" {
+		t.Errorf("Expected the decrypted feedback as CSV, got:\n%s", out)
+	}
+
+	var items []models.Feedback
+	runJSON(t, db, &items, "feedback", "export", "--target-type", "member")
+	if len(items) != 1 || items[0].Content != "Clear reviews" {
+		t.Errorf("Expected the feedback about members, got %+v", items)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	db := setupTestDB(t)
+	run(t, db, "seed")
+	if err := services.NewFeedbackService(db).CreateFeedback(&models.Feedback{Content: "Great sprint", TargetType: "team", TargetID: 1}); err != nil {
+		t.Fatalf("Failed to create feedback: %v", err)
+	}
+
+	var results []CheckResult
+	runJSON(t, db, &results, "check")
+	for _, result := range results {
+		if result.Status != CheckOK {
+			t.Errorf("Expected %s to pass, got %+v", result.Name, result)
+		}
+	}
+
+	// Feedback encrypted with a key that was removed from the keyring
+	db.Model(&models.Feedback{}).Where("1 = 1").Update("content_key_id", "retired")
+	db.Migrator().DropColumn(&models.Team{}, "logo")
+	out, _, err := run(t, db, "check", "--output", "json")
+	if err == nil {
+		t.Fatal("Expected the check to fail")
+	}
+	json.Unmarshal([]byte(out), &results)
+	statuses := make(map[string]CheckResult)
+	for _, result := range results {
+		statuses[result.Name] = result
+	}
+	if result := statuses["schema"]; result.Status != CheckFailed || !strings.Contains(result.Detail, "column teams.logo") {
+		t.Errorf("Expected the missing column to be reported, got %+v", result)
+	}
+	if _, ok := statuses["encryption keys"]; ok {
+		t.Errorf("Expected later checks to be skipped on an outdated schema, got %+v", results)
+	}
+
+	run(t, db, "migrate")
+	out, _, err = run(t, db, "check")
+	if err == nil || !strings.Contains(out, "missing from the keyring: retired") {
+		t.Errorf("Expected the missing key to be reported, got %v:\n%s", err, out)
+	}
+}
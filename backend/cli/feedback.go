@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"coaching-app-backend/services"
+)
+
+// OutputCSV writes feedback exports in the format of the CSV export endpoint
+const OutputCSV = "csv"
+
+func runFeedbackExport(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("feedback export", "", true, false)
+	output := fs.String("output", OutputCSV, "output format, csv, json or table")
+	var filter services.FeedbackFilter
+	fs.StringVar(&filter.TargetType, "target-type", "", "only export feedback about a team or member")
+	fs.UintVar(&filter.TargetID, "target-id", 0, "only export feedback about this team or member, with --target-type")
+	columns := fs.String("columns", "", "comma-separated CSV columns (default: all)")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	switch *output {
+	case OutputCSV, OutputJSON, OutputTable:
+	default:
+		return fs.fail("output must be csv, json or table, got %q", *output)
+	}
+	if filter.TargetType != "" && filter.TargetType != "team" && filter.TargetType != "member" {
+		return fs.fail("target-type must be team or member, got %q", filter.TargetType)
+	}
+	if filter.TargetID != 0 && filter.TargetType == "" {
+		return fs.fail("target-id requires target-type")
+	}
+	ctx, err := fs.context(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *output == OutputCSV {
+		var fields []string
+		if *columns != "" {
+			fields = strings.Split(*columns, ",")
+		}
+		return services.NewCSVService(app.DB).WithContext(ctx).ExportFeedback(app.Out, filter, fields)
+	}
+
+	feedback, err := services.NewFeedbackService(app.DB).WithContext(ctx).FindFeedback(filter)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(feedback))
+	for i, item := range feedback {
+		author := ""
+		if item.AuthorID != nil {
+			author = formatID(*item.AuthorID)
+		}
+		rows[i] = []string{formatID(item.ID), item.TargetType, formatID(item.TargetID), author, item.CreatedAt.UTC().Format(time.RFC3339), item.Content}
+	}
+	fs.output = output
+	return fs.print(feedback, []string{"ID", "TARGET_TYPE", "TARGET_ID", "AUTHOR_ID", "CREATED_AT", "CONTENT"}, rows)
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"coaching-app-backend/models"
+	"coaching-app-backend/services"
+	"coaching-app-backend/validation"
+
+	"gorm.io/gorm"
+)
+
+func runMembersList(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("members list", "", true, true)
+	teamID := fs.Uint("team", 0, "only list the members of this team")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	ctx, err := fs.context(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *teamID != 0 {
+		if _, err := services.NewTeamService(app.DB).WithContext(ctx).GetTeamByID(*teamID); err != nil {
+			return notFound(err, "team", *teamID)
+		}
+	}
+	all, err := services.NewTeamMemberService(app.DB).WithContext(ctx).FindTeamMembers(services.QueryOptions{Include: []string{"teams"}})
+	if err != nil {
+		return err
+	}
+	members := []models.TeamMember{}
+	for _, member := range all {
+		if *teamID == 0 || inTeam(member, *teamID) {
+			members = append(members, member)
+		}
+	}
+
+	rows := make([][]string, len(members))
+	for i, member := range members {
+		teams := make([]string, len(member.Teams))
+		for j, team := range member.Teams {
+			teams[j] = team.Name
+		}
+		rows[i] = []string{formatID(member.ID), member.Name, member.Email, strings.Join(teams, ", ")}
+	}
+	return fs.print(members, []string{"ID", "NAME", "EMAIL", "TEAMS"}, rows)
+}
+
+func inTeam(member models.TeamMember, teamID uint) bool {
+	for _, team := range member.Teams {
+		if team.ID == teamID {
+			return true
+		}
+	}
+	return false
+}
+
+func runMembersCreate(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("members create", "", true, true)
+	var input services.MemberInput
+	fs.StringVar(&input.Name, "name", "", "name of the member")
+	fs.StringVar(&input.Email, "email", "", "email address of the member")
+	fs.StringVar(&input.Picture, "picture", "", "URL of the member's picture")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	if err := validation.Struct(&input); err != nil {
+		return fs.fail("%v", err)
+	}
+	ctx, err := fs.context(ctx)
+	if err != nil {
+		return err
+	}
+
+	member := input.TeamMember()
+	if err := services.NewTeamMemberService(app.DB).WithContext(ctx).CreateTeamMember(&member); err != nil {
+		return err
+	}
+	return fs.print(member, []string{"ID", "NAME", "EMAIL"}, [][]string{{formatID(member.ID), member.Name, member.Email}})
+}
+
+func runMembersMerge(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("members merge", "TARGET_ID SOURCE_ID", true, true)
+	if err := fs.parse(args, 2); err != nil {
+		return err
+	}
+	targetID, err := fs.id(0, "TARGET_ID")
+	if err != nil {
+		return err
+	}
+	sourceID, err := fs.id(1, "SOURCE_ID")
+	if err != nil {
+		return err
+	}
+	ctx, err = fs.context(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := services.NewTeamMemberService(app.DB).WithContext(ctx).MergeTeamMembers(targetID, sourceID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("team member %d or %d not found", targetID, sourceID)
+	}
+	if err != nil {
+		return err
+	}
+	return fs.print(result, []string{"MEMBER", "MERGED", "ASSIGNMENTS", "FEEDBACK", "AUTHORSHIP"}, [][]string{{
+		formatID(result.Member.ID),
+		formatID(result.MergedID),
+		strconv.Itoa(result.MovedAssignments),
+		strconv.FormatInt(result.MovedFeedback, 10),
+		strconv.FormatInt(result.MovedAuthorship, 10),
+	}})
+}
+
+// notFound turns a record not found error into one naming the record
+func notFound(err error, entity string, id uint) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%s %d not found", entity, id)
+	}
+	return err
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
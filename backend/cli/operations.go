@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"coaching-app-backend/database"
+	"coaching-app-backend/encryption"
+	"coaching-app-backend/models"
+	"coaching-app-backend/services"
+	"coaching-app-backend/tenant"
+)
+
+// Statuses of checks
+const (
+	CheckOK      = "ok"
+	CheckWarning = "warning"
+	CheckFailed  = "failed"
+)
+
+// CheckResult is the outcome of one check of the check command
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// SeedResult is a record the seed command added or found
+type SeedResult struct {
+	Type   string `json:"type"`
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// sampleTeams and sampleMembers are the sample data of seed, by team name
+var (
+	sampleTeams = []services.TeamInput{
+		{Name: "Development Team", Logo: "https://example.com/dev-logo.png"},
+		{Name: "Design Team", Logo: "https://example.com/design-logo.png"},
+	}
+	sampleMembers = []struct {
+		input services.TeamMemberInput
+		teams []string
+	}{
+		{services.TeamMemberInput{Name: "John Doe", Email: "john.doe@example.com", Picture: "https://example.com/john.jpg"}, []string{"Development Team"}},
+		{services.TeamMemberInput{Name: "Jane Smith", Email: "jane.smith@example.com", Picture: "https://example.com/jane.jpg"}, []string{"Development Team", "Design Team"}},
+		{services.TeamMemberInput{Name: "Bob Johnson", Email: "bob.johnson@example.com", Picture: "https://example.com/bob.jpg"}, []string{"Design Team"}},
+	}
+)
+
+func runServe(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("serve", "", false, false)
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	if app.Serve == nil {
+		return errors.New("serving is not available")
+	}
+	return app.Serve(ctx)
+}
+
+func runMigrate(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("migrate", "", false, false)
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	if err := database.Migrate(app.DB.WithContext(ctx)); err != nil {
+		return err
+	}
+	fmt.Fprintln(app.Out, "Database migrated")
+	return nil
+}
+
+// runSeed adds the sample teams and members, with their assignments. Records
+// that already exist, by team name and member email, are kept, so seeding
+// again changes nothing.
+func runSeed(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("seed", "", true, true)
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	ctx, err := fs.context(ctx)
+	if err != nil {
+		return err
+	}
+
+	teamService := services.NewTeamService(app.DB).WithContext(ctx)
+	existing, err := teamService.GetAllTeams()
+	if err != nil {
+		return err
+	}
+	teamIDs := make(map[string]uint)
+	for _, team := range existing {
+		if _, ok := teamIDs[team.Name]; !ok {
+			teamIDs[team.Name] = team.ID
+		}
+	}
+
+	var results []SeedResult
+	for _, input := range sampleTeams {
+		if id, ok := teamIDs[input.Name]; ok {
+			results = append(results, SeedResult{Type: "team", ID: id, Name: input.Name, Status: string(services.BatchUnchanged)})
+			continue
+		}
+		team := input.Team()
+		if err := teamService.CreateTeam(&team); err != nil {
+			return err
+		}
+		teamIDs[team.Name] = team.ID
+		results = append(results, SeedResult{Type: "team", ID: team.ID, Name: team.Name, Status: string(services.BatchCreated)})
+	}
+
+	inputs := make([]services.TeamMemberInput, len(sampleMembers))
+	for i, member := range sampleMembers {
+		inputs[i] = member.input
+		for _, name := range member.teams {
+			inputs[i].TeamIDs = append(inputs[i].TeamIDs, teamIDs[name])
+		}
+	}
+	memberResults, err := services.NewTeamMemberService(app.DB).WithContext(ctx).BatchUpsertTeamMembers(inputs, services.BatchTransactional)
+	if err != nil {
+		for _, result := range memberResults {
+			if result.Status == services.BatchError {
+				return fmt.Errorf("%w: %s: %s", err, result.Email, result.Error)
+			}
+		}
+		return err
+	}
+	for i, result := range memberResults {
+		results = append(results, SeedResult{Type: "member", ID: result.ID, Name: inputs[i].Name, Status: string(result.Status)})
+	}
+
+	rows := make([][]string, len(results))
+	for i, result := range results {
+		rows[i] = []string{result.Type, formatID(result.ID), result.Name, result.Status}
+	}
+	return fs.print(results, []string{"TYPE", "ID", "NAME", "STATUS"}, rows)
+}
+
+func runReencrypt(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("reencrypt", "", false, false)
+	batchSize := fs.Int("batch-size", 100, "records re-encrypted per transaction")
+	pause := fs.Duration("pause", 100*time.Millisecond, "time waited between batches")
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+
+	changed, err := encryption.Reencrypt(ctx, app.DB, app.Keys, &models.Feedback{}, encryption.ReencryptOptions{
+		BatchSize: *batchSize,
+		Pause:     *pause,
+	})
+	fmt.Fprintf(app.Out, "Re-encrypted %d feedback entries with key %q\n", changed, app.Keys.ActiveID())
+	return err
+}
+
+// runCheck checks that the deployment can serve: the database is reachable
+// and migrated, every encryption key feedback was encrypted with is
+// configured, and no erasure log has been tampered with. It fails if any
+// check fails.
+func runCheck(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("check", "", false, true)
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+
+	results := []CheckResult{checkDatabase(ctx, app)}
+	if results[0].Status == CheckOK {
+		results = append(results, checkSchema(ctx, app))
+	}
+	if results[len(results)-1].Status == CheckOK {
+		results = append(results, checkEncryptionKeys(ctx, app))
+		results = append(results, checkErasureLogs(ctx, app)...)
+	}
+
+	failed := 0
+	rows := make([][]string, len(results))
+	for i, result := range results {
+		if result.Status == CheckFailed {
+			failed++
+		}
+		rows[i] = []string{result.Name, result.Status, result.Detail}
+	}
+	if err := fs.print(results, []string{"CHECK", "STATUS", "DETAIL"}, rows); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
+
+func checkDatabase(ctx context.Context, app *App) CheckResult {
+	result := CheckResult{Name: "database", Status: CheckOK}
+	sqlDB, err := app.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		result.Status, result.Detail = CheckFailed, err.Error()
+	}
+	return result
+}
+
+func checkSchema(ctx context.Context, app *App) CheckResult {
+	result := CheckResult{Name: "schema", Status: CheckOK}
+	missing, err := database.CheckSchema(app.DB.WithContext(ctx))
+	switch {
+	case err != nil:
+		result.Status, result.Detail = CheckFailed, err.Error()
+	case len(missing) > 0:
+		result.Status = CheckFailed
+		result.Detail = "run migrate to add " + strings.Join(missing, ", ")
+	}
+	return result
+}
+
+// checkEncryptionKeys fails when feedback is encrypted with a key missing from
+// the keyring, and warns when feedback is not encrypted with the active key
+func checkEncryptionKeys(ctx context.Context, app *App) CheckResult {
+	result := CheckResult{Name: "encryption keys", Status: CheckOK}
+	// Without an organization in the context, every organization is counted
+	var counts []struct {
+		ContentKeyID string
+		Count        int64
+	}
+	err := app.DB.WithContext(ctx).Model(&models.Feedback{}).
+		Select("content_key_id, COUNT(*) AS count").Group("content_key_id").Order("content_key_id").
+		Scan(&counts).Error
+	if err != nil {
+		result.Status, result.Detail = CheckFailed, err.Error()
+		return result
+	}
+
+	var missing []string
+	var stale int64
+	for _, count := range counts {
+		if count.ContentKeyID != "" && !app.Keys.Has(count.ContentKeyID) {
+			missing = append(missing, count.ContentKeyID)
+		}
+		if count.ContentKeyID != app.Keys.ActiveID() {
+			stale += count.Count
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		result.Status = CheckFailed
+		result.Detail = "feedback is encrypted with keys missing from the keyring: " + strings.Join(missing, ", ")
+	case stale > 0 && app.Keys.ActiveID() != "":
+		result.Status = CheckWarning
+		result.Detail = fmt.Sprintf("%d feedback entries are not encrypted with the active key; run reencrypt", stale)
+	case app.Keys.ActiveID() == "":
+		result.Detail = "no encryption keys configured, feedback is stored unencrypted"
+	}
+	return result
+}
+
+// checkErasureLogs verifies the erasure log of every organization
+func checkErasureLogs(ctx context.Context, app *App) []CheckResult {
+	organizations, err := services.NewOrganizationService(app.DB).WithContext(ctx).GetAllOrganizations()
+	if err != nil {
+		return []CheckResult{{Name: "erasure log", Status: CheckFailed, Detail: err.Error()}}
+	}
+	results := make([]CheckResult, len(organizations))
+	for i, organization := range organizations {
+		results[i] = CheckResult{Name: "erasure log " + organization.Slug, Status: CheckOK}
+		log, err := services.NewPrivacyService(app.DB).WithContext(tenant.WithOrganization(ctx, organization.ID)).ErasureLog()
+		switch {
+		case err != nil:
+			results[i].Status, results[i].Detail = CheckFailed, err.Error()
+		case !log.Valid:
+			results[i].Status = CheckFailed
+			results[i].Detail = fmt.Sprintf("record %d does not match the chain", *log.BrokenAt)
+		default:
+			results[i].Detail = fmt.Sprintf("%d records", len(log.Records))
+		}
+	}
+	return results
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"coaching-app-backend/services"
+
+	"gorm.io/gorm"
+)
+
+func runTeamsList(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("teams list", "", true, true)
+	if err := fs.parse(args, 0); err != nil {
+		return err
+	}
+	ctx, err := fs.context(ctx)
+	if err != nil {
+		return err
+	}
+
+	teams, err := services.NewTeamService(app.DB).WithContext(ctx).FindTeams(services.QueryOptions{Include: []string{"members"}})
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(teams))
+	for i, team := range teams {
+		rows[i] = []string{formatID(team.ID), team.Name, strconv.Itoa(len(team.Members))}
+	}
+	return fs.print(teams, []string{"ID", "NAME", "MEMBERS"}, rows)
+}
+
+func runTeamsDelete(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("teams delete", "ID", true, false)
+	if err := fs.parse(args, 1); err != nil {
+		return err
+	}
+	id, err := fs.id(0, "ID")
+	if err != nil {
+		return err
+	}
+	ctx, err = fs.context(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Deleting a missing team succeeds, so it is looked up first to report
+	// mistyped IDs
+	teams := services.NewTeamService(app.DB).WithContext(ctx)
+	team, err := teams.GetTeamByID(id)
+	if err != nil {
+		return notFound(err, "team", id)
+	}
+	if err := teams.DeleteTeam(id); err != nil {
+		return err
+	}
+	fmt.Fprintf(app.Out, "Deleted team %d (%s)\n", team.ID, team.Name)
+	return nil
+}
+
+func runAssign(ctx context.Context, app *App, args []string) error {
+	fs := app.flags("assign", "TEAM_ID MEMBER_ID", true, false)
+	if err := fs.parse(args, 2); err != nil {
+		return err
+	}
+	teamID, err := fs.id(0, "TEAM_ID")
+	if err != nil {
+		return err
+	}
+	memberID, err := fs.id(1, "MEMBER_ID")
+	if err != nil {
+		return err
+	}
+	ctx, err = fs.context(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = services.NewAssignmentService(app.DB).WithContext(ctx).AssignMemberToTeam(teamID, memberID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("team %d or team member %d not found", teamID, memberID)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(app.Out, "Assigned team member %d to team %d\n", memberID, teamID)
+	return nil
+}
@@ -0,0 +1,507 @@
+// Package config loads the backend's settings. Each setting is read, in
+// increasing order of precedence, from its default, the YAML or TOML file
+// named by --config or CONFIG_FILE, its environment variable and its
+// command-line flag:
+//
+//	# config.yaml
+//	server:
+//	  port: 8080
+//	database:
+//	  max_open_conns: 50
+//	rate_limit:
+//	  routes:
+//	    POST /api/feedback: 20/1m
+//
+//	DB_MAX_OPEN_CONNS=50 coaching-app-backend --server.port=8080
+//
+// Settings are named "section.key" in flags, and as a key within its section
+// in files. Load checks every setting and reports all invalid ones at once.
+// Settings keeps track of where each value came from and hides secrets, for
+// showing the configuration in use.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/lifecycle"
+	"coaching-app-backend/logging"
+	"coaching-app-backend/middleware"
+	"coaching-app-backend/ratelimit"
+	"coaching-app-backend/services"
+	"coaching-app-backend/tracing"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of setting values
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Redacted replaces the values of secrets in Settings
+const Redacted = "[redacted]"
+
+// Config holds every setting of the backend
+type Config struct {
+	Server     Server     `key:"server"`
+	Database   Database   `key:"database"`
+	Log        Log        `key:"log"`
+	Metrics    Metrics    `key:"metrics"`
+	Tracing    Tracing    `key:"tracing"`
+	Auth       Auth       `key:"auth"`
+	CORS       CORS       `key:"cors"`
+	RateLimit  RateLimit  `key:"rate_limit"`
+	Encryption Encryption `key:"encryption"`
+	OIDC       OIDC       `key:"oidc"`
+	Privacy    Privacy    `key:"privacy"`
+
+	// sources records where each setting's value came from, by key
+	sources map[string]string
+}
+
+type Server struct {
+	Port                int            `key:"port" env:"SERVER_PORT" help:"HTTP port"`
+	GRPCPort            int            `key:"grpc_port" env:"GRPC_PORT" help:"gRPC port"`
+	TrustedProxies      []string       `key:"trusted_proxies" env:"TRUSTED_PROXIES" help:"Proxies whose X-Forwarded-For header is trusted"`
+	ReadHeaderTimeout   time.Duration  `key:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" help:"Time allowed to read request headers"`
+	ReadTimeout         time.Duration  `key:"read_timeout" env:"HTTP_READ_TIMEOUT" help:"Time allowed to read a whole request"`
+	WriteTimeout        time.Duration  `key:"write_timeout" env:"HTTP_WRITE_TIMEOUT" help:"Time allowed to write a response"`
+	IdleTimeout         time.Duration  `key:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" help:"Time a kept-alive connection waits for the next request"`
+	MaxHeaderBytes      ByteSize       `key:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" help:"Largest request line and headers accepted"`
+	BodyLimit           ByteSize       `key:"body_limit" env:"BODY_LIMIT" help:"Largest request body accepted"`
+	BodyLimitRoutes     RouteByteSizes `key:"body_limit_routes" env:"BODY_LIMIT_ROUTES" help:"Body limits of single routes, as \"METHOD /path=size\" entries"`
+	HSTSMaxAge          time.Duration  `key:"hsts_max_age" env:"HSTS_MAX_AGE" help:"How long browsers insist on HTTPS"`
+	IdempotencyKeyTTL   time.Duration  `key:"idempotency_key_ttl" env:"IDEMPOTENCY_KEY_TTL" help:"How long idempotency keys are remembered"`
+	ShutdownGracePeriod time.Duration  `key:"shutdown_grace_period" env:"SHUTDOWN_GRACE_PERIOD" help:"Time requests in flight get to finish on shutdown"`
+	ShutdownDrainDelay  time.Duration  `key:"shutdown_drain_delay" env:"SHUTDOWN_DRAIN_DELAY" help:"Time /ready fails before the servers stop accepting connections"`
+}
+
+type Database struct {
+	Host            string        `key:"host" env:"DB_HOST" help:"MySQL host"`
+	Port            int           `key:"port" env:"DB_PORT" help:"MySQL port"`
+	User            string        `key:"user" env:"DB_USER" help:"MySQL user"`
+	Password        string        `key:"password" env:"DB_PASSWORD" secret:"true" help:"MySQL password"`
+	Name            string        `key:"name" env:"DB_NAME" help:"MySQL database"`
+	MaxOpenConns    int           `key:"max_open_conns" env:"DB_MAX_OPEN_CONNS" help:"Most open connections; 0 is unlimited"`
+	MaxIdleConns    int           `key:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" help:"Most idle connections kept open"`
+	ConnMaxLifetime time.Duration `key:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" help:"Time after which connections are replaced; 0 keeps them"`
+	ConnMaxIdleTime time.Duration `key:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" help:"Time after which idle connections are closed; 0 keeps them"`
+}
+
+type Log struct {
+	Level              slog.Level    `key:"level" env:"LOG_LEVEL" help:"Lowest level logged: debug, info, warn or error"`
+	SlowQueryThreshold time.Duration `key:"slow_query_threshold" env:"LOG_SLOW_QUERY_THRESHOLD" help:"Queries taking longer are logged as warnings; 0 disables"`
+}
+
+type Metrics struct {
+	Token string `key:"token" env:"METRICS_TOKEN" secret:"true" help:"Bearer token required to read /metrics"`
+}
+
+type Tracing struct {
+	Exporter string `key:"exporter" env:"OTEL_TRACES_EXPORTER" oneof:"none,otlp,stdout,file" help:"Where spans are sent"`
+	Protocol string `key:"protocol" env:"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL,OTEL_EXPORTER_OTLP_PROTOCOL" oneof:",grpc,http/protobuf" help:"OTLP protocol"`
+	File     string `key:"file" env:"OTEL_TRACES_FILE" help:"File the file exporter appends spans to"`
+}
+
+type Auth struct {
+	JWTSigningKeys         string        `key:"jwt_signing_keys" env:"JWT_SIGNING_KEYS" secret:"true" help:"Keys signing access tokens, as \"id:secret\" entries, the first active"`
+	AccessTokenTTL         time.Duration `key:"access_token_ttl" env:"JWT_ACCESS_TOKEN_TTL" help:"Lifetime of access tokens"`
+	RefreshTokenTTL        time.Duration `key:"refresh_token_ttl" env:"JWT_REFRESH_TOKEN_TTL" help:"Lifetime of refresh tokens"`
+	BootstrapAdminEmail    string        `key:"bootstrap_admin_email" env:"BOOTSTRAP_ADMIN_EMAIL" help:"Admin account created at startup if missing"`
+	BootstrapAdminPassword string        `key:"bootstrap_admin_password" env:"BOOTSTRAP_ADMIN_PASSWORD" secret:"true" help:"Password of the bootstrap admin account"`
+}
+
+type CORS struct {
+	AllowedOrigins   []string      `key:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" help:"Origins allowed to call the API"`
+	AllowedMethods   []string      `key:"allowed_methods" env:"CORS_ALLOWED_METHODS" help:"Methods browsers may use in cross-origin requests"`
+	AllowedHeaders   []string      `key:"allowed_headers" env:"CORS_ALLOWED_HEADERS" help:"Request headers browsers may send in cross-origin requests"`
+	ExposedHeaders   []string      `key:"exposed_headers" env:"CORS_EXPOSED_HEADERS" help:"Response headers scripts may read"`
+	AllowCredentials bool          `key:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" help:"Whether browsers may send credentials"`
+	MaxAge           time.Duration `key:"max_age" env:"CORS_MAX_AGE" help:"How long browsers cache preflight responses"`
+}
+
+type RateLimit struct {
+	Read   ratelimit.Limit `key:"read" env:"RATE_LIMIT_READ" help:"Limit of reads per client, as requests/period; 0 disables"`
+	Write  ratelimit.Limit `key:"write" env:"RATE_LIMIT_WRITE" help:"Limit of writes per client, as requests/period; 0 disables"`
+	Routes RouteLimits     `key:"routes" env:"RATE_LIMIT_ROUTES" help:"Limits of single routes, as \"METHOD /path=requests/period\" entries"`
+	Store  string          `key:"store" env:"RATE_LIMIT_STORE" oneof:"memory,database" help:"Where buckets are kept"`
+}
+
+type Encryption struct {
+	Keys        string `key:"keys" env:"ENCRYPTION_KEYS" secret:"true" help:"Keys encrypting feedback, as \"id:base64key\" entries, the first active"`
+	KeyringFile string `key:"keyring_file" env:"ENCRYPTION_KEYRING_FILE" help:"File holding the encryption keys instead"`
+}
+
+type OIDC struct {
+	IssuerURL    string   `key:"issuer_url" env:"OIDC_ISSUER_URL" help:"Identity provider enabling single sign-on"`
+	ClientID     string   `key:"client_id" env:"OIDC_CLIENT_ID" help:"Client ID registered with the provider"`
+	ClientSecret string   `key:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true" help:"Client secret registered with the provider"`
+	RedirectURL  string   `key:"redirect_url" env:"OIDC_REDIRECT_URL" help:"Callback URL registered with the provider"`
+	Scopes       []string `key:"scopes" env:"OIDC_SCOPES" help:"Scopes requested besides openid"`
+	RoleClaim    string   `key:"role_claim" env:"OIDC_ROLE_CLAIM" help:"ID token claim mapped to roles"`
+	RoleMapping  string   `key:"role_mapping" env:"OIDC_ROLE_MAPPING" help:"Roles of claim values, as \"value:role\" entries"`
+}
+
+type Privacy struct {
+	ErasureAuthoredFeedback string `key:"erasure_authored_feedback" env:"ERASURE_AUTHORED_FEEDBACK" oneof:"redact,keep" help:"What erasing a member does to feedback they wrote"`
+	ErasureReceivedFeedback string `key:"erasure_received_feedback" env:"ERASURE_RECEIVED_FEEDBACK" oneof:"redact,keep" help:"What erasing a member does to feedback about them"`
+}
+
+// Default returns the settings used when nothing else is configured
+func Default() *Config {
+	cors := middleware.DefaultCORSConfig()
+	securityHeaders := middleware.DefaultSecurityHeadersConfig()
+	erasure := services.DefaultErasurePolicy()
+	return &Config{
+		Server: Server{
+			Port:                8080,
+			GRPCPort:            9090,
+			ReadHeaderTimeout:   5 * time.Second,
+			ReadTimeout:         30 * time.Second,
+			WriteTimeout:        60 * time.Second,
+			IdleTimeout:         120 * time.Second,
+			MaxHeaderBytes:      middleware.DefaultMaxHeaderBytes,
+			BodyLimit:           middleware.DefaultMaxBodyBytes,
+			HSTSMaxAge:          securityHeaders.HSTSMaxAge,
+			IdempotencyKeyTTL:   middleware.DefaultIdempotencyKeyTTL,
+			ShutdownGracePeriod: lifecycle.DefaultGracePeriod,
+			ShutdownDrainDelay:  lifecycle.DefaultDrainDelay,
+		},
+		Database: Database{
+			Host:         "localhost",
+			Port:         3306,
+			User:         "root",
+			Name:         "coaching_app",
+			MaxOpenConns: 100,
+			MaxIdleConns: 10,
+		},
+		Log: Log{
+			Level:              slog.LevelInfo,
+			SlowQueryThreshold: logging.DefaultSlowQueryThreshold,
+		},
+		Tracing: Tracing{Exporter: tracing.ExporterNone},
+		Auth: Auth{
+			AccessTokenTTL:  auth.DefaultAccessTokenTTL,
+			RefreshTokenTTL: auth.DefaultRefreshTokenTTL,
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{"http://localhost:3000"},
+			AllowedMethods: cors.AllowedMethods,
+			AllowedHeaders: cors.AllowedHeaders,
+			ExposedHeaders: cors.ExposedHeaders,
+			MaxAge:         cors.MaxAge,
+		},
+		RateLimit: RateLimit{
+			Read:  ratelimit.Limit{Requests: 300, Period: time.Minute},
+			Write: ratelimit.Limit{Requests: 60, Period: time.Minute},
+			Store: "memory",
+		},
+		OIDC: OIDC{
+			Scopes:    []string{"email", "profile"},
+			RoleClaim: "groups",
+		},
+		Privacy: Privacy{
+			ErasureAuthoredFeedback: string(erasure.AuthoredFeedback),
+			ErasureReceivedFeedback: string(erasure.ReceivedFeedback),
+		},
+	}
+}
+
+// Load reads the settings from args, the environment and the configuration
+// file, and checks them. Parsing args stops at the first argument that is not
+// a flag; the arguments from there on are returned.
+func Load(args []string) (*Config, []string, error) {
+	c := Default()
+	c.sources = make(map[string]string)
+	for _, s := range c.settings() {
+		c.sources[s.key] = SourceDefault
+	}
+
+	flags, path, rest, err := parseFlags(c, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	var errs []error
+	if path != "" {
+		values, err := readFile(c, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		errs = append(errs, c.apply(values, SourceFile+" "+path)...)
+	}
+	errs = append(errs, c.applyEnv()...)
+	errs = append(errs, c.apply(flags, SourceFlag)...)
+	errs = append(errs, c.validate()...)
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return c, rest, nil
+}
+
+// setting is one field of the configuration
+type setting struct {
+	key    string
+	field  reflect.StructField
+	value  reflect.Value
+	secret bool
+}
+
+// settings lists the fields of every section, in declaration order
+func (c *Config) settings() []setting {
+	var result []setting
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		sectionField := sections.Type().Field(i)
+		section := sectionField.Tag.Get("key")
+		if section == "" {
+			continue
+		}
+		fields := sections.Field(i)
+		for j := 0; j < fields.NumField(); j++ {
+			field := fields.Type().Field(j)
+			result = append(result, setting{
+				key:    section + "." + field.Tag.Get("key"),
+				field:  field,
+				value:  fields.Field(j),
+				secret: field.Tag.Get("secret") == "true",
+			})
+		}
+	}
+	return result
+}
+
+// apply sets the settings of values, by key, recording source as where they
+// came from
+func (c *Config) apply(values map[string]string, source string) []error {
+	var errs []error
+	for _, s := range c.settings() {
+		value, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s (from %s): %w", s.key, source, err))
+			continue
+		}
+		c.sources[s.key] = source
+	}
+	return errs
+}
+
+// applyEnv sets the settings whose environment variables are set. Settings
+// with several variables take the first one set.
+func (c *Config) applyEnv() []error {
+	var errs []error
+	for _, s := range c.settings() {
+		for _, name := range strings.Split(s.field.Tag.Get("env"), ",") {
+			value, ok := os.LookupEnv(name)
+			if name == "" || !ok || value == "" {
+				continue
+			}
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s (from %s %s): %w", s.key, SourceEnv, name, err))
+			} else {
+				c.sources[s.key] = SourceEnv + " " + name
+			}
+			break
+		}
+	}
+	return errs
+}
+
+// set parses value into the setting, checking it is one of the allowed values
+func (s setting) set(value string) error {
+	if allowed := s.field.Tag.Get("oneof"); allowed != "" {
+		value = strings.ToLower(strings.TrimSpace(value))
+		options := strings.Split(allowed, ",")
+		if !contains(options, value) {
+			return fmt.Errorf("%q must be one of %s", value, strings.Join(nonEmpty(options), ", "))
+		}
+	}
+	return parseValue(s.value, value)
+}
+
+// parseFlags parses a flag for every setting, named by its key, and --config.
+// It returns the values of the flags that were set.
+func parseFlags(c *Config, args []string) (map[string]string, string, []string, error) {
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	path := flags.String("config", "", "YAML or TOML file to read settings from (env CONFIG_FILE)")
+	values := make(map[string]string)
+	for _, s := range c.settings() {
+		key := s.key
+		usage := s.field.Tag.Get("help")
+		if env := s.field.Tag.Get("env"); env != "" {
+			usage += " (env " + strings.ReplaceAll(env, ",", ", ") + ")"
+		}
+		record := func(value string) error {
+			values[key] = value
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			flags.BoolFunc(key, usage, func(value string) error { return record(value) })
+		} else {
+			flags.Func(key, usage, record)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, "", nil, err
+	}
+	return values, *path, flags.Args(), nil
+}
+
+// readFile reads the settings of a YAML or TOML file, chosen by its
+// extension. Keys that are not settings are errors, so typos are not ignored.
+func readFile(c *Config, path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	document := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &document)
+	case ".toml":
+		err = toml.Unmarshal(content, &document)
+	default:
+		return nil, fmt.Errorf("configuration file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+
+	known := make(map[string]bool)
+	for _, s := range c.settings() {
+		known[s.key] = true
+	}
+	values := make(map[string]string)
+	var errs []error
+	for _, section := range sortedKeys(document) {
+		fields, ok := document[section].(map[string]interface{})
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: must be a section of settings", section))
+			continue
+		}
+		for _, name := range sortedKeys(fields) {
+			key := section + "." + name
+			if !known[key] {
+				errs = append(errs, fmt.Errorf("%s: unknown setting", key))
+				continue
+			}
+			value, err := fileValue(fields[name])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				continue
+			}
+			values[key] = value
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration file %s:\n%w", path, errors.Join(errs...))
+	}
+	return values, nil
+}
+
+// fileValue turns a value read from a file into the text of a setting. Lists
+// become comma-separated, and maps comma-separated "key=value" entries.
+func fileValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			text, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = text
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		entries := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			text, err := fileValue(v[key])
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, key+"="+text)
+		}
+		return strings.Join(entries, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func nonEmpty(values []string) []string {
+	var result []string
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Setting is the value of a setting in use and where it came from
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Settings lists every setting with its value, secrets replaced by Redacted
+// when they are set
+func (c *Config) Settings() []Setting {
+	var result []Setting
+	for _, s := range c.settings() {
+		value := formatValue(s.value)
+		if s.secret && value != "" {
+			value = Redacted
+		}
+		source := c.sources[s.key]
+		if source == "" {
+			source = SourceDefault
+		}
+		result = append(result, Setting{Key: s.key, Value: value, Source: source})
+	}
+	return result
+}
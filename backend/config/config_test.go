@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"coaching-app-backend/ratelimit"
+)
+
+// writeFile writes a configuration file named name to a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write configuration file: %v", err)
+	}
+	return path
+}
+
+// settingOf returns the setting with key
+func settingOf(t *testing.T, c *Config, key string) Setting {
+	t.Helper()
+	for _, s := range c.Settings() {
+		if s.Key == key {
+			return s
+		}
+	}
+	t.Fatalf("Expected a setting %s", key)
+	return Setting{}
+}
+
+func TestDefaults(t *testing.T) {
+	c, rest, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+	if len(rest) != 0 {
+		t.Errorf("Expected no remaining arguments, got %v", rest)
+	}
+	if c.Server.Port != 8080 || c.Server.GRPCPort != 9090 {
+		t.Errorf("Expected ports 8080 and 9090, got %d and %d", c.Server.Port, c.Server.GRPCPort)
+	}
+	if c.Database.MaxOpenConns != 100 || c.Database.MaxIdleConns != 10 {
+		t.Errorf("Expected a pool of 100 open and 10 idle connections, got %d and %d", c.Database.MaxOpenConns, c.Database.MaxIdleConns)
+	}
+	if c.RateLimit.Read != (ratelimit.Limit{Requests: 300, Period: time.Minute}) {
+		t.Errorf("Expected reads limited to 300/1m, got %s", c.RateLimit.Read)
+	}
+	for _, s := range c.Settings() {
+		if s.Source != SourceDefault {
+			t.Errorf("Expected %s to come from the default, got %s", s.Key, s.Source)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  port: 8000
+  grpc_port: 9000
+database:
+  host: db.internal
+  max_open_conns: 40
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("GRPC_PORT", "9500")
+	t.Setenv("DB_MAX_OPEN_CONNS", "50")
+	t.Setenv("CORS_EXPOSED_HEADERS", "X-Request-ID, X-Total-Count")
+
+	c, rest, err := Load([]string{"--database.max_open_conns=60", "reencrypt", "--batch-size=10"})
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		value  string
+		source string
+	}{
+		{"server.port", "8000", SourceFile + " " + path},
+		{"server.grpc_port", "9500", SourceEnv + " GRPC_PORT"},
+		{"database.host", "db.internal", SourceFile + " " + path},
+		{"database.max_open_conns", "60", SourceFlag},
+		{"database.max_idle_conns", "10", SourceDefault},
+		{"cors.exposed_headers", "X-Request-ID,X-Total-Count", SourceEnv + " CORS_EXPOSED_HEADERS"},
+		{"cors.allowed_methods", "GET,POST,PUT,PATCH,DELETE", SourceDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			s := settingOf(t, c, tt.key)
+			if s.Value != tt.value || s.Source != tt.source {
+				t.Errorf("Expected %s from %s, got %s from %s", tt.value, tt.source, s.Value, s.Source)
+			}
+		})
+	}
+	if strings.Join(rest, " ") != "reencrypt --batch-size=10" {
+		t.Errorf("Expected the command and its flags to remain, got %v", rest)
+	}
+}
+
+func TestFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"YAML", "config.yml", `
+rate_limit:
+  read: 100/1m
+  routes:
+    POST /api/feedback: 5/1m
+oidc:
+  scopes: [email, groups]
+`},
+		{"TOML", "config.toml", `
+[rate_limit]
+read = "100/1m"
+
+[rate_limit.routes]
+"POST /api/feedback" = "5/1m"
+
+[oidc]
+scopes = ["email", "groups"]
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, err := Load([]string{"--config", writeFile(t, tt.file, tt.content)})
+			if err != nil {
+				t.Fatalf("Failed to load: %v", err)
+			}
+			if c.RateLimit.Read != (ratelimit.Limit{Requests: 100, Period: time.Minute}) {
+				t.Errorf("Expected reads limited to 100/1m, got %s", c.RateLimit.Read)
+			}
+			if limit := c.RateLimit.Routes["POST /api/feedback"]; limit != (ratelimit.Limit{Requests: 5, Period: time.Minute}) {
+				t.Errorf("Expected feedback limited to 5/1m, got %s", limit)
+			}
+			if strings.Join(c.OIDC.Scopes, ",") != "email,groups" {
+				t.Errorf("Expected scopes email and groups, got %v", c.OIDC.Scopes)
+			}
+		})
+	}
+}
+
+func TestInvalidConfiguration(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		args     []string
+		expected []string
+	}{
+		{"Unknown key", "config.yaml", "server:\n  prot: 8000\n", nil, []string{"server.prot: unknown setting"}},
+		{"Unknown extension", "config.json", "{}", nil, []string{"must be .yaml, .yml or .toml"}},
+		{"Invalid value", "", "", []string{"--server.port=http"}, []string{`server.port (from flag): "http" must be a whole number`}},
+		{"Not one of", "", "", []string{"--rate_limit.store=redis"}, []string{`"redis" must be one of memory, database`}},
+		{"Empty list", "", "", []string{"--cors.allowed_methods="}, []string{"cors.allowed_methods: must list at least one method"}},
+		{"Every error reported", "", "", []string{"--server.port=0", "--database.max_idle_conns=200", "--auth.bootstrap_admin_email=admin@example.com"}, []string{
+			"server.port: must be between 1 and 65535, got 0",
+			"database.max_idle_conns: must not exceed database.max_open_conns (100), got 200",
+			"auth.bootstrap_admin_password: must be set with auth.bootstrap_admin_email",
+		}},
+		{"Dependent settings", "", "", []string{"--oidc.issuer_url=https://idp.example.com", "--tracing.exporter=file"}, []string{
+			"oidc.client_id: must be set with oidc.issuer_url",
+			"tracing.file: must be set for the file exporter",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"--config", writeFile(t, tt.file, tt.content)}, args...)
+			}
+			_, _, err := Load(args)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected %q, got %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestHelp(t *testing.T) {
+	_, _, err := Load([]string{"--help"})
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestSettingsRedactSecrets(t *testing.T) {
+	t.Setenv("DB_PASSWORD", "hunter2")
+	c, _, err := Load([]string{"--rate_limit.write=0"})
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	if s := settingOf(t, c, "database.password"); s.Value != Redacted || s.Source != SourceEnv+" DB_PASSWORD" {
+		t.Errorf("Expected the password redacted, got %+v", s)
+	}
+	if s := settingOf(t, c, "metrics.token"); s.Value != "" {
+		t.Errorf("Expected an unset secret to stay empty, got %+v", s)
+	}
+	if s := settingOf(t, c, "rate_limit.write"); s.Value != "0" {
+		t.Errorf("Expected a disabled limit shown as 0, got %+v", s)
+	}
+	if c.Database.Password != "hunter2" {
+		t.Errorf("Expected the password itself to be kept, got %q", c.Database.Password)
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/encryption"
+	"coaching-app-backend/services"
+	"coaching-app-backend/tracing"
+)
+
+// validate checks the settings that depend on each other or on a format of
+// their own, returning an error for every invalid one
+func (c *Config) validate() []error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	check(validPort(c.Server.GRPCPort), "server.grpc_port", "must be between 1 and 65535, got %d", c.Server.GRPCPort)
+	check(c.Server.Port != c.Server.GRPCPort, "server.grpc_port", "must differ from server.port")
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes", "must be positive")
+	check(c.Server.BodyLimit > 0, "server.body_limit", "must be positive")
+	check(c.Server.ShutdownGracePeriod > 0, "server.shutdown_grace_period", "must be positive")
+	check(c.Server.IdempotencyKeyTTL > 0, "server.idempotency_key_ttl", "must be positive")
+
+	check(validPort(c.Database.Port), "database.port", "must be between 1 and 65535, got %d", c.Database.Port)
+	check(c.Database.Host != "", "database.host", "must be set")
+	check(c.Database.Name != "", "database.name", "must be set")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns", "must not exceed database.max_open_conns (%d), got %d", c.Database.MaxOpenConns, c.Database.MaxIdleConns)
+
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.hsts_max_age", c.Server.HSTSMaxAge},
+		{"server.shutdown_drain_delay", c.Server.ShutdownDrainDelay},
+		{"database.conn_max_lifetime", c.Database.ConnMaxLifetime},
+		{"database.conn_max_idle_time", c.Database.ConnMaxIdleTime},
+		{"log.slow_query_threshold", c.Log.SlowQueryThreshold},
+		{"cors.max_age", c.CORS.MaxAge},
+	} {
+		check(d.value >= 0, d.key, "must not be negative")
+	}
+
+	check(c.Tracing.Exporter != tracing.ExporterFile || c.Tracing.File != "", "tracing.file", "must be set for the file exporter")
+
+	if c.Auth.JWTSigningKeys != "" {
+		if _, err := auth.ParseKeyring(c.Auth.JWTSigningKeys); err != nil {
+			errs = append(errs, fmt.Errorf("auth.jwt_signing_keys: %w", err))
+		}
+	}
+	check(c.Auth.AccessTokenTTL > 0, "auth.access_token_ttl", "must be positive")
+	check(c.Auth.RefreshTokenTTL > c.Auth.AccessTokenTTL, "auth.refresh_token_ttl", "must be longer than auth.access_token_ttl")
+	check(c.Auth.BootstrapAdminEmail == "" || c.Auth.BootstrapAdminPassword != "", "auth.bootstrap_admin_password", "must be set with auth.bootstrap_admin_email")
+
+	check(len(c.CORS.AllowedOrigins) > 0, "cors.allowed_origins", "must list at least one origin")
+	check(len(c.CORS.AllowedMethods) > 0, "cors.allowed_methods", "must list at least one method")
+
+	check(c.Encryption.Keys == "" || c.Encryption.KeyringFile == "", "encryption.keys", "must not be set with encryption.keyring_file")
+	if c.Encryption.Keys != "" {
+		if _, err := encryption.ParseKeyring(c.Encryption.Keys); err != nil {
+			errs = append(errs, fmt.Errorf("encryption.keys: %w", err))
+		}
+	}
+
+	if c.OIDC.IssuerURL != "" {
+		check(validURL(c.OIDC.IssuerURL), "oidc.issuer_url", "must be an absolute URL, got %q", c.OIDC.IssuerURL)
+		check(c.OIDC.ClientID != "", "oidc.client_id", "must be set with oidc.issuer_url")
+		check(validURL(c.OIDC.RedirectURL), "oidc.redirect_url", "must be an absolute URL with oidc.issuer_url, got %q", c.OIDC.RedirectURL)
+	}
+	if _, err := services.ParseOIDCRoleMapping(c.OIDC.RoleClaim, c.OIDC.RoleMapping); err != nil {
+		errs = append(errs, fmt.Errorf("oidc.role_mapping: %w", err))
+	}
+	return errs
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func validURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
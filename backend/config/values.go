@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"coaching-app-backend/logging"
+	"coaching-app-backend/middleware"
+	"coaching-app-backend/ratelimit"
+)
+
+// ByteSize is a size in bytes, written such as "512", "64KB" or "10MB"
+type ByteSize int64
+
+func (b ByteSize) String() string {
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if b != 0 && int64(b)%unit.size == 0 {
+			return fmt.Sprintf("%d%s", int64(b)/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(int64(b), 10)
+}
+
+// RouteByteSizes are sizes by "METHOD /path" route, written as comma-separated
+// "METHOD /path=size" entries
+type RouteByteSizes map[string]int64
+
+func (r RouteByteSizes) String() string {
+	entries := make([]string, 0, len(r))
+	for route, size := range r {
+		entries = append(entries, route+"="+ByteSize(size).String())
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// RouteLimits are rate limits by "METHOD /path" route, written as
+// comma-separated "METHOD /path=requests/period" entries
+type RouteLimits map[string]ratelimit.Limit
+
+func (r RouteLimits) String() string {
+	entries := make([]string, 0, len(r))
+	for route, limit := range r {
+		entries = append(entries, route+"="+limit.String())
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// parsers parse the settings of types other than strings, numbers, booleans
+// and lists
+var parsers = map[reflect.Type]func(string) (interface{}, error){
+	reflect.TypeOf(time.Duration(0)): func(s string) (interface{}, error) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q must be a duration such as 30s or 5m", s)
+		}
+		return d, nil
+	},
+	reflect.TypeOf(ByteSize(0)): func(s string) (interface{}, error) {
+		size, err := middleware.ParseByteSize(s)
+		return ByteSize(size), err
+	},
+	reflect.TypeOf(RouteByteSizes(nil)): func(s string) (interface{}, error) {
+		routes, err := middleware.ParseRouteBodyLimits(s)
+		return RouteByteSizes(routes), err
+	},
+	reflect.TypeOf(ratelimit.Limit{}): func(s string) (interface{}, error) {
+		return ratelimit.ParseLimit(s)
+	},
+	reflect.TypeOf(RouteLimits(nil)): func(s string) (interface{}, error) {
+		routes, err := middleware.ParseRouteLimits(s)
+		return RouteLimits(routes), err
+	},
+	reflect.TypeOf(slog.Level(0)): func(s string) (interface{}, error) {
+		return logging.ParseLevel(s)
+	},
+}
+
+// parseValue parses text into v according to its type
+func parseValue(v reflect.Value, text string) error {
+	text = strings.TrimSpace(text)
+	if parse, ok := parsers[v.Type()]; ok {
+		parsed, err := parse(text)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("%q must be a whole number", text)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%q must be true or false", text)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// Lists are separated by commas or spaces
+		items := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' })
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("settings of type %s are not supported", v.Type())
+	}
+	return nil
+}
+
+// formatValue writes v as the text it would be parsed from
+func formatValue(v reflect.Value) string {
+	if limit, ok := v.Interface().(ratelimit.Limit); ok && !limit.Enabled() {
+		return "0"
+	}
+	if stringer, ok := v.Interface().(fmt.Stringer); ok {
+		if v.Kind() == reflect.Map && v.Len() == 0 {
+			return ""
+		}
+		return stringer.String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
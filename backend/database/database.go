@@ -2,68 +2,114 @@ package database
 
 import (
 	"fmt"
-	"os"
 
+	"coaching-app-backend/config"
 	"coaching-app-backend/models"
+	"coaching-app-backend/tenant"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
-func Connect() (*gorm.DB, error) {
-	host := os.Getenv("DB_HOST")
-	if host == "" {
-		host = "localhost"
-	}
-
-	port := os.Getenv("DB_PORT")
-	if port == "" {
-		port = "3306"
-	}
+// Connect opens the configured MySQL database, logging its queries to logger
+func Connect(cfg config.Database, logger gormlogger.Interface) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
 
-	user := os.Getenv("DB_USER")
-	if user == "" {
-		user = "root"
-	}
-
-	password := os.Getenv("DB_PASSWORD")
-	if password == "" {
-		password = ""
-	}
-
-	dbname := os.Getenv("DB_NAME")
-	if dbname == "" {
-		dbname = "coaching_app"
-	}
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		user, password, host, port, dbname)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	if err := tenant.Register(db); err != nil {
+		return nil, fmt.Errorf("failed to register tenant scoping: %w", err)
+	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
 	}
 
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	return db, nil
 }
 
+// legacyUniqueIndexes made emails and identity provider subjects unique
+// across organizations, and idempotency keys across clients. They are
+// replaced by unique indexes per organization and per client.
+var legacyUniqueIndexes = []struct {
+	model interface{}
+	name  string
+}{
+	{&models.TeamMember{}, "email"},
+	{&models.TeamMember{}, "idx_team_members_email"},
+	{&models.User{}, "idx_users_email"},
+	{&models.User{}, "idx_users_oidc_subject"},
+	{&models.IdempotencyKey{}, "idx_idempotency_scope"},
+}
+
+// schemaModels are the models whose tables Migrate creates
+var schemaModels = []interface{}{
+	&models.Organization{},
+	&models.TeamMember{},
+	&models.Team{},
+	&models.TeamAssignment{},
+	&models.Feedback{},
+	&models.IdempotencyKey{},
+	&models.AuditEvent{},
+	&models.User{},
+	&models.RefreshToken{},
+	&models.TeamCoach{},
+	&models.APIKey{},
+	&models.OIDCLoginState{},
+	&models.RateLimitBucket{},
+	&models.ErasureRecord{},
+}
+
 func Migrate(db *gorm.DB) error {
-	err := db.AutoMigrate(
-		&models.TeamMember{},
-		&models.Team{},
-		&models.TeamAssignment{},
-		&models.Feedback{},
-	)
+	err := db.AutoMigrate(schemaModels...)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
+
+	for _, index := range legacyUniqueIndexes {
+		if db.Migrator().HasIndex(index.model, index.name) {
+			if err := db.Migrator().DropIndex(index.model, index.name); err != nil {
+				return fmt.Errorf("failed to drop index %s: %w", index.name, err)
+			}
+		}
+	}
+
+	// Existing rows default to this organization
+	organization := models.Organization{ID: models.DefaultOrganizationID, Name: "Default", Slug: "default"}
+	if err := db.FirstOrCreate(&organization, models.DefaultOrganizationID).Error; err != nil {
+		return fmt.Errorf("failed to create default organization: %w", err)
+	}
 	return nil
 }
+
+// CheckSchema lists the tables and columns Migrate would add, so an empty
+// result means the schema is up to date
+func CheckSchema(db *gorm.DB) ([]string, error) {
+	var missing []string
+	for _, model := range schemaModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		if !db.Migrator().HasTable(model) {
+			missing = append(missing, "table "+stmt.Schema.Table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !field.IgnoreMigration && !db.Migrator().HasColumn(model, field.DBName) {
+				missing = append(missing, "column "+stmt.Schema.Table+"."+field.DBName)
+			}
+		}
+	}
+	return missing, nil
+}
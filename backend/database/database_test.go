@@ -67,6 +67,65 @@ func TestDatabaseConstraints(t *testing.T) {
 	if result.Error == nil {
 		t.Error("Expected error for duplicate email constraint")
 	}
+
+	// Emails are unique per organization only
+	member3 := models.TeamMember{OrganizationID: 2, Name: "User 3", Email: "test@example.com"}
+	if err := db.Create(&member3).Error; err != nil {
+		t.Errorf("Expected the email to be available in another organization, got %v", err)
+	}
+}
+
+func TestMigrateCreatesDefaultOrganization(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	// Migrating twice must be safe, as it runs on every start
+	for i := 0; i < 2; i++ {
+		if err := Migrate(db); err != nil {
+			t.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
+	var organizations []models.Organization
+	db.Find(&organizations)
+	if len(organizations) != 1 || organizations[0].ID != models.DefaultOrganizationID || organizations[0].Slug != "default" {
+		t.Errorf("Expected only the default organization, got %+v", organizations)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	db.AutoMigrate(&models.Organization{}, &models.Team{})
+	db.Migrator().DropColumn(&models.Team{}, "logo")
+
+	missing, err := CheckSchema(db)
+	if err != nil {
+		t.Fatalf("Failed to check schema: %v", err)
+	}
+	if !containsString(missing, "column teams.logo") || !containsString(missing, "table feedbacks") || containsString(missing, "table organizations") {
+		t.Errorf("Expected the missing column and tables, got %v", missing)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	if missing, err := CheckSchema(db); err != nil || len(missing) != 0 {
+		t.Errorf("Expected the schema to be up to date after migrating, got %v, %v", missing, err)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 func TestDatabaseTransactions(t *testing.T) {
@@ -0,0 +1,220 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"coaching-app-backend/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	testKey1 = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	testKey2 = "HxweHRwbGhkYFxYVFBMSERAPDg0MCwoJCAcGBQQDAgE="
+)
+
+func mustParseKeyring(t *testing.T, spec string) *Keyring {
+	t.Helper()
+	keys, err := ParseKeyring(spec)
+	if err != nil {
+		t.Fatalf("Failed to parse keyring: %v", err)
+	}
+	return keys
+}
+
+func setupTestDB(keys *Keyring) *gorm.DB {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	Register(db, keys)
+	db.AutoMigrate(&models.Feedback{})
+	return db
+}
+
+// storedContent reads the content column as stored, bypassing decryption
+func storedContent(db *gorm.DB, id uint) (string, string) {
+	var row struct {
+		Content      string
+		ContentKeyID string
+	}
+	db.Raw("SELECT content, content_key_id FROM feedbacks WHERE id = ?", id).Scan(&row)
+	return row.Content, row.ContentKeyID
+}
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		activeID string
+		valid    bool
+	}{
+		{"Comma-separated", "k2:" + testKey2 + ",k1:" + testKey1, "k2", true},
+		{"Commented file", "# rotated 2026-10\n\nk1: " + testKey1 + "\n", "k1", true},
+		{"Empty", "", "", false},
+		{"Missing ID", ":" + testKey1, "", false},
+		{"Short key", "k1:" + testKey1[:20], "", false},
+		{"Duplicate ID", "k1:" + testKey1 + ",k1:" + testKey2, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeyring(tt.spec)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Expected valid %v, got %v", tt.valid, err)
+			}
+			if tt.valid && keys.ActiveID() != tt.activeID {
+				t.Errorf("Expected active key %q, got %q", tt.activeID, keys.ActiveID())
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "keyring")
+	os.WriteFile(path, []byte("k1:"+testKey1+"\n"), 0o600)
+	if keys, err := LoadKeyringFile(path); err != nil || keys.ActiveID() != "k1" {
+		t.Errorf("Failed to load keyring file: %v", err)
+	}
+}
+
+func TestSealAndOpen(t *testing.T) {
+	keys := mustParseKeyring(t, "k1:"+testKey1)
+
+	kid, sealed, err := keys.Seal("Needs to speak up in retros")
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	if kid != "k1" || strings.Contains(sealed, "retros") {
+		t.Errorf("Expected an envelope for key k1, got %q %q", kid, sealed)
+	}
+	if _, again, _ := keys.Seal("Needs to speak up in retros"); again == sealed {
+		t.Error("Expected every envelope to use a new data key and nonce")
+	}
+
+	plaintext, err := keys.Open(kid, sealed)
+	if err != nil || plaintext != "Needs to speak up in retros" {
+		t.Errorf("Expected the plaintext back, got %q: %v", plaintext, err)
+	}
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)-5] ^= 1
+	if _, err := keys.Open(kid, string(tampered)); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Expected ErrInvalidEnvelope for a tampered envelope, got %v", err)
+	}
+	if _, err := keys.Open("k2", sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	// The key ID is authenticated along with the data key
+	relabeled := mustParseKeyring(t, "k2:"+testKey1)
+	if _, err := relabeled.Open("k2", sealed); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Errorf("Expected ErrInvalidEnvelope under another key ID, got %v", err)
+	}
+
+	var empty *Keyring
+	if _, _, err := empty.Seal("anything"); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Expected ErrNoKeys, got %v", err)
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	keys := mustParseKeyring(t, "k1:"+testKey1)
+	db := setupTestDB(keys)
+
+	feedback := models.Feedback{Content: "Great presentation", TargetType: "team", TargetID: 1}
+	if err := db.Create(&feedback).Error; err != nil {
+		t.Fatalf("Failed to create feedback: %v", err)
+	}
+	if feedback.Content != "Great presentation" || feedback.ContentKeyID != "k1" {
+		t.Errorf("Expected the created record to hold its plaintext, got %+v", feedback)
+	}
+	if stored, kid := storedContent(db, feedback.ID); kid != "k1" || strings.Contains(stored, "presentation") {
+		t.Errorf("Expected the content to be stored encrypted, got %q with key %q", stored, kid)
+	}
+
+	var loaded []models.Feedback
+	db.Find(&loaded)
+	if len(loaded) != 1 || loaded[0].Content != "Great presentation" {
+		t.Errorf("Expected loaded feedback to be decrypted, got %+v", loaded)
+	}
+
+	db.Model(&feedback).Updates(map[string]interface{}{"content": "Great demo"})
+	feedback.TargetID = 2
+	db.Save(&feedback)
+	if stored, _ := storedContent(db, feedback.ID); strings.Contains(stored, "demo") {
+		t.Errorf("Expected updated content to be stored encrypted, got %q", stored)
+	}
+	var reloaded models.Feedback
+	db.First(&reloaded, feedback.ID)
+	if reloaded.Content != "Great demo" || reloaded.TargetID != 2 {
+		t.Errorf("Expected the updates to be kept, got %+v", reloaded)
+	}
+
+	// Feedback stored before encryption was enabled is read as it is
+	db.Exec("INSERT INTO feedbacks (content, target_type, target_id) VALUES ('Legacy note', 'team', 1)")
+	var legacy models.Feedback
+	if err := db.Where("content_key_id = ''").First(&legacy).Error; err != nil || legacy.Content != "Legacy note" {
+		t.Errorf("Expected legacy feedback to be read as plaintext, got %+v: %v", legacy, err)
+	}
+
+	// Without the key, encrypted feedback cannot be read
+	if err := setupTestDBWith(db, nil).First(&models.Feedback{}, feedback.ID).Error; !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey without the key, got %v", err)
+	}
+}
+
+// setupTestDBWith returns a session of db's connection decrypting with keys
+func setupTestDBWith(db *gorm.DB, keys *Keyring) *gorm.DB {
+	sqlDB, _ := db.DB()
+	other, _ := gorm.Open(sqlite.Dialector{Conn: sqlDB}, &gorm.Config{})
+	Register(other, keys)
+	return other
+}
+
+func TestReencrypt(t *testing.T) {
+	old := mustParseKeyring(t, "k1:"+testKey1)
+	db := setupTestDB(old)
+	for _, content := range []string{"First", "Second", "Third"} {
+		db.Create(&models.Feedback{Content: content, TargetType: "team", TargetID: 1})
+	}
+	db.Exec("INSERT INTO feedbacks (content, target_type, target_id) VALUES ('Legacy', 'team', 1)")
+
+	rotated := mustParseKeyring(t, "k2:"+testKey2+",k1:"+testKey1)
+	rotatedDB := setupTestDBWith(db, rotated)
+	changed, err := Reencrypt(context.Background(), rotatedDB, rotated, &models.Feedback{}, ReencryptOptions{BatchSize: 3})
+	if err != nil || changed != 4 {
+		t.Fatalf("Expected 4 records to be re-encrypted, got %d: %v", changed, err)
+	}
+	if changed, _ := Reencrypt(context.Background(), rotatedDB, rotated, &models.Feedback{}, ReencryptOptions{}); changed != 0 {
+		t.Errorf("Expected nothing left to re-encrypt, got %d", changed)
+	}
+
+	// The old key is no longer needed
+	current := setupTestDBWith(db, mustParseKeyring(t, "k2:"+testKey2))
+	var feedback []models.Feedback
+	if err := current.Order("id").Find(&feedback).Error; err != nil || len(feedback) != 4 || feedback[3].Content != "Legacy" {
+		t.Fatalf("Expected all feedback to be readable with the new key, got %+v: %v", feedback, err)
+	}
+	for _, item := range feedback {
+		if stored, kid := storedContent(db, item.ID); kid != "k2" || stored == item.Content {
+			t.Errorf("Expected feedback %d to be encrypted with k2, got %q with key %q", item.ID, stored, kid)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	feedback := models.Feedback{ID: 1, Content: "Secret", TargetType: "team"}
+
+	redacted, ok := Redact(&feedback).(models.Feedback)
+	if !ok || redacted.Content != Redacted || redacted.TargetType != "team" {
+		t.Errorf("Expected a redacted copy, got %+v", Redact(&feedback))
+	}
+	if feedback.Content != "Secret" {
+		t.Error("Expected the original to be left alone")
+	}
+	team := models.Team{Name: "Dev Team"}
+	if Redact(team).(models.Team).Name != "Dev Team" {
+		t.Error("Expected values without encrypted fields to be returned as they are")
+	}
+}
@@ -0,0 +1,117 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// envelopeVersion prefixes every envelope, so the format can change later
+const envelopeVersion byte = 1
+
+var (
+	ErrUnknownKey      = errors.New("unknown encryption key")
+	ErrInvalidEnvelope = errors.New("invalid encrypted value")
+)
+
+// Seal encrypts plaintext with a new random data key, which is itself
+// encrypted with the active key. It returns the ID of the active key and the
+// envelope holding both, encoded in base64.
+func (k *Keyring) Seal(plaintext string) (string, string, error) {
+	kid := k.ActiveID()
+	kek, ok := k.lookup(kid)
+	if !ok {
+		return "", "", ErrNoKeys
+	}
+
+	dek := make([]byte, KeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", "", err
+	}
+	// The key ID is authenticated, so an envelope cannot be passed off as
+	// encrypted with another key
+	wrapped, err := seal(kek, dek, []byte(kid))
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := seal(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	envelope := make([]byte, 0, 1+len(wrapped)+len(sealed))
+	envelope = append(envelope, envelopeVersion)
+	envelope = append(envelope, wrapped...)
+	envelope = append(envelope, sealed...)
+	return kid, base64.StdEncoding.EncodeToString(envelope), nil
+}
+
+// Open decrypts an envelope made by Seal with the key kid
+func (k *Keyring) Open(kid, encoded string) (string, error) {
+	kek, ok := k.lookup(kid)
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+
+	envelope, err := base64.StdEncoding.DecodeString(encoded)
+	// Version, then the wrapped data key and its nonce and tag
+	wrappedSize := 1 + nonceSize + KeySize + tagSize
+	if err != nil || len(envelope) < wrappedSize || envelope[0] != envelopeVersion {
+		return "", ErrInvalidEnvelope
+	}
+
+	dek, err := open(kek, envelope[1:wrappedSize], []byte(kid))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, envelope[wrappedSize:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+const (
+	nonceSize = 12
+	tagSize   = 16
+)
+
+// seal encrypts plaintext with AES-GCM, returning the nonce followed by the
+// ciphertext
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < nonceSize+tagSize {
+		return nil, ErrInvalidEnvelope
+	}
+	plaintext, err := aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], additionalData)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,252 @@
+// Package encryption encrypts sensitive text fields at the application layer,
+// so that they are only ever stored encrypted.
+//
+// Fields are marked with an encrypted tag naming the field that stores the ID
+// of the key they were encrypted with:
+//
+//	Content      string `encrypted:"ContentKeyID"`
+//	ContentKeyID string
+//
+// Register installs GORM callbacks that encrypt marked fields when records
+// are created or updated and decrypt them when records are loaded, so the
+// rest of the application only sees plaintext. Each value is encrypted with
+// AES-GCM under a random data key, stored alongside it encrypted with a key
+// encryption key from the keyring. Values with an empty key ID were stored
+// before encryption was enabled and are read as they are; Reencrypt moves
+// every value to the active key.
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Redacted replaces encrypted fields in audit snapshots
+const Redacted = "[encrypted]"
+
+// encryptedField is a field marked for encryption and the field storing its
+// key ID
+type encryptedField struct {
+	value *schema.Field
+	keyID *schema.Field
+}
+
+var fieldsCache sync.Map
+
+// encryptedFields returns the fields of s marked for encryption
+func encryptedFields(s *schema.Schema) ([]encryptedField, error) {
+	if cached, ok := fieldsCache.Load(s); ok {
+		return cached.([]encryptedField), nil
+	}
+	var fields []encryptedField
+	for _, f := range s.Fields {
+		name := f.Tag.Get("encrypted")
+		if name == "" {
+			continue
+		}
+		keyID := s.LookUpField(name)
+		if f.FieldType.Kind() != reflect.String || keyID == nil || keyID.FieldType.Kind() != reflect.String {
+			return nil, fmt.Errorf("encrypted field %s.%s must be a string naming a string key ID field", s.Name, f.Name)
+		}
+		fields = append(fields, encryptedField{value: f, keyID: keyID})
+	}
+	fieldsCache.Store(s, fields)
+	return fields, nil
+}
+
+// Register installs the encryption callbacks on db. New values are encrypted
+// with the active key of keys; without keys they are stored in plaintext.
+func Register(db *gorm.DB, keys *Keyring) error {
+	callbacks := db.Callback()
+	encrypt := func(db *gorm.DB) { transform(db, keys, true) }
+	decrypt := func(db *gorm.DB) { transform(db, keys, false) }
+
+	if err := callbacks.Create().Before("gorm:create").Register("encryption:encrypt_create", encrypt); err != nil {
+		return err
+	}
+	// Callers keep using the records they saved, so they get their plaintext
+	// back once the statement has run
+	if err := callbacks.Create().After("gorm:create").Register("encryption:decrypt_create", decrypt); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("encryption:encrypt_update", encrypt); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("encryption:decrypt_update", decrypt); err != nil {
+		return err
+	}
+	return callbacks.Query().After("gorm:query").Register("encryption:decrypt_query", decrypt)
+}
+
+// transform encrypts or decrypts the marked fields of the records of a
+// statement: its destination for queries, and the values being written for
+// creates and updates.
+func transform(db *gorm.DB, keys *Keyring, encrypt bool) {
+	// Runs even after errors, so that records written by failed statements
+	// are restored to plaintext
+	if db.Statement.Schema == nil {
+		return
+	}
+	fields, err := encryptedFields(db.Statement.Schema)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if updates, ok := db.Statement.Dest.(map[string]interface{}); ok {
+		if encrypt {
+			db.AddError(encryptMap(updates, fields, keys))
+			return
+		}
+		// Updates copies the encrypted values into the model
+		record := db.Statement.ReflectValue
+		if record.Kind() != reflect.Struct {
+			return
+		}
+		for _, f := range fields {
+			if mapHasField(updates, f) {
+				db.AddError(decryptField(ctx, record, f, keys))
+			}
+		}
+		return
+	}
+
+	record := db.Statement.ReflectValue
+	// Model(&a).Updates(&b) writes the fields of b
+	if dest := reflect.ValueOf(db.Statement.Dest); dest.Kind() == reflect.Pointer && dest.Elem().Kind() == reflect.Struct {
+		if dest.Elem().Type() != db.Statement.Schema.ModelType {
+			return
+		}
+		record = dest.Elem()
+	}
+
+	each := func(record reflect.Value) {
+		for _, f := range fields {
+			if encrypt {
+				db.AddError(encryptField(ctx, record, f, keys))
+			} else {
+				db.AddError(decryptField(ctx, record, f, keys))
+			}
+		}
+	}
+	switch record.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < record.Len(); i++ {
+			each(reflect.Indirect(record.Index(i)))
+		}
+	case reflect.Struct:
+		each(record)
+	}
+}
+
+func encryptField(ctx context.Context, record reflect.Value, f encryptedField, keys *Keyring) error {
+	if keys.ActiveID() == "" {
+		return nil
+	}
+	value, _ := f.value.ValueOf(ctx, record)
+	// Empty values are left alone, as Updates skips them
+	if value.(string) == "" {
+		return nil
+	}
+	kid, sealed, err := keys.Seal(value.(string))
+	if err != nil {
+		return err
+	}
+	if err := f.value.Set(ctx, record, sealed); err != nil {
+		return err
+	}
+	return f.keyID.Set(ctx, record, kid)
+}
+
+func decryptField(ctx context.Context, record reflect.Value, f encryptedField, keys *Keyring) error {
+	kid, _ := f.keyID.ValueOf(ctx, record)
+	value, _ := f.value.ValueOf(ctx, record)
+	if kid.(string) == "" || value.(string) == "" {
+		return nil
+	}
+	plaintext, err := keys.Open(kid.(string), value.(string))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", f.value.Name, err)
+	}
+	return f.value.Set(ctx, record, plaintext)
+}
+
+// encryptMap encrypts marked fields in the values of Updates(map), adding
+// their key IDs
+func encryptMap(updates map[string]interface{}, fields []encryptedField, keys *Keyring) error {
+	if keys.ActiveID() == "" {
+		return nil
+	}
+	for _, f := range fields {
+		for _, name := range []string{f.value.DBName, f.value.Name} {
+			value, ok := updates[name]
+			if !ok || value == "" {
+				continue
+			}
+			plaintext, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("encrypted field %s must be updated with a string", f.value.Name)
+			}
+			kid, sealed, err := keys.Seal(plaintext)
+			if err != nil {
+				return err
+			}
+			updates[name] = sealed
+			updates[f.keyID.DBName] = kid
+		}
+	}
+	return nil
+}
+
+func mapHasField(updates map[string]interface{}, f encryptedField) bool {
+	_, byColumn := updates[f.value.DBName]
+	_, byName := updates[f.value.Name]
+	return byColumn || byName
+}
+
+// Redact returns a copy of value with its fields marked for encryption
+// replaced by Redacted, for copies that are stored outside the encrypted
+// columns, such as audit snapshots. Values other than structs and pointers to
+// structs are returned as they are.
+func Redact(value interface{}) interface{} {
+	record := reflect.ValueOf(value)
+	for record.Kind() == reflect.Pointer {
+		if record.IsNil() {
+			return value
+		}
+		record = record.Elem()
+	}
+	if record.Kind() != reflect.Struct {
+		return value
+	}
+
+	var redacted reflect.Value
+	for i := 0; i < record.NumField(); i++ {
+		field := record.Type().Field(i)
+		if field.Tag.Get("encrypted") == "" || field.Type.Kind() != reflect.String {
+			continue
+		}
+		if !redacted.IsValid() {
+			redacted = reflect.New(record.Type()).Elem()
+			redacted.Set(record)
+		}
+		redacted.Field(i).SetString(Redacted)
+	}
+	if !redacted.IsValid() {
+		return value
+	}
+	return redacted.Interface()
+}
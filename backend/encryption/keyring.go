@@ -0,0 +1,101 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of key encryption keys, for AES-256
+const KeySize = 32
+
+var ErrNoKeys = errors.New("no encryption keys configured")
+
+// Keyring holds the key encryption keys by ID. The active key encrypts new
+// values; every key decrypts.
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// ParseKeyring reads kid:key pairs separated by commas or newlines, where key
+// is 32 bytes encoded in base64. The first key is the active key. Blank lines
+// and lines starting with # are ignored, so a keyring file may be commented.
+func ParseKeyring(spec string) (*Keyring, error) {
+	ring := &Keyring{keys: make(map[string][]byte)}
+	scanner := bufio.NewScanner(strings.NewReader(strings.ReplaceAll(spec, ",", "\n")))
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		kid, encoded, ok := strings.Cut(entry, ":")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("encryption key %q must be in kid:key form", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("encryption key %q must be %d bytes encoded in base64", kid, KeySize)
+		}
+		if _, exists := ring.keys[kid]; exists {
+			return nil, fmt.Errorf("duplicate encryption key ID %q", kid)
+		}
+
+		ring.keys[kid] = key
+		if ring.activeID == "" {
+			ring.activeID = kid
+		}
+	}
+
+	if ring.activeID == "" {
+		return nil, ErrNoKeys
+	}
+	return ring, nil
+}
+
+// LoadKeyringFile reads a keyring file in the format of ParseKeyring
+func LoadKeyringFile(path string) (*Keyring, error) {
+	spec, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyring(string(spec))
+}
+
+// GenerateKey returns a new random key in the encoding ParseKeyring expects
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ActiveID returns the ID of the key new values are encrypted with, or ""
+// for an empty keyring
+func (k *Keyring) ActiveID() string {
+	if k == nil {
+		return ""
+	}
+	return k.activeID
+}
+
+// Has reports whether the keyring holds the key kid, so values encrypted with
+// it can be decrypted
+func (k *Keyring) Has(kid string) bool {
+	_, ok := k.lookup(kid)
+	return ok
+}
+
+func (k *Keyring) lookup(kid string) ([]byte, bool) {
+	if k == nil {
+		return nil, false
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
@@ -0,0 +1,101 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReencryptOptions configures Reencrypt
+type ReencryptOptions struct {
+	// BatchSize is how many records are re-encrypted per transaction
+	BatchSize int
+	// Pause is waited between batches, so that re-encryption can run next to
+	// the application without crowding out its queries
+	Pause time.Duration
+}
+
+// Reencrypt encrypts every marked field of the records of model that is not
+// encrypted with the active key of keys, including values stored before
+// encryption was enabled, and returns how many records were changed. Once it
+// has run, keys only needed by earlier values can be removed from the
+// keyring. db must have the callbacks of Register with the same keys, and is
+// used without a tenant, so it covers every organization.
+func Reencrypt(ctx context.Context, db *gorm.DB, keys *Keyring, model interface{}, options ReencryptOptions) (int, error) {
+	active := keys.ActiveID()
+	if active == "" {
+		return 0, ErrNoKeys
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 100
+	}
+
+	db = db.WithContext(ctx)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return 0, err
+	}
+	fields, err := encryptedFields(stmt.Schema)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%s has no encrypted fields", stmt.Schema.Name)
+	}
+	primaryKey := stmt.Schema.PrioritizedPrimaryField
+	if primaryKey == nil {
+		return 0, fmt.Errorf("%s has no primary key", stmt.Schema.Name)
+	}
+
+	query := db.Model(model)
+	columns := []string{}
+	for _, f := range fields {
+		query = query.Or(fmt.Sprintf("%s <> ? OR %s IS NULL", f.keyID.DBName, f.keyID.DBName), active)
+		columns = append(columns, f.value.DBName, f.keyID.DBName)
+	}
+
+	changed := 0
+	var last interface{}
+	for {
+		batch := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+		page := db.Model(model).Where(query).Order(primaryKey.DBName).Limit(options.BatchSize)
+		if last != nil {
+			page = page.Where(primaryKey.DBName+" > ?", last)
+		}
+		if err := page.Find(batch.Interface()).Error; err != nil {
+			return changed, err
+		}
+		records := batch.Elem()
+		if records.Len() == 0 {
+			return changed, nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i := 0; i < records.Len(); i++ {
+				// Loading decrypted the record, and saving encrypts it again
+				// with the active key
+				record := records.Index(i).Addr().Interface()
+				if err := tx.Model(record).Select(columns).Updates(record).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return changed, err
+		}
+		changed += records.Len()
+		last, _ = primaryKey.ValueOf(ctx, records.Index(records.Len()-1))
+
+		if options.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return changed, ctx.Err()
+			case <-time.After(options.Pause):
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tenant"
+	"coaching-app-backend/validation"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	tenant.Register(db)
+	db.AutoMigrate(&models.Organization{}, &models.TeamMember{}, &models.Team{}, &models.TeamAssignment{}, &models.Feedback{}, &models.TeamCoach{}, &models.AuditEvent{})
+	return db
+}
+
+// adminContext is the context of a request made by an admin
+func adminContext() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: 1, Role: models.RoleAdmin})
+}
+
+func execute(t *testing.T, server *Server, query string, variables map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	result := server.Execute(adminContext(), Request{Query: query, Variables: variables})
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors: %v", result.Errors)
+	}
+
+	raw, _ := json.Marshal(result.Data)
+	var data map[string]interface{}
+	json.Unmarshal(raw, &data)
+	return data
+}
+
+func TestQueryTeamsWithMembersAndFeedbackIsBatched(t *testing.T) {
+	db := setupTestDB()
+	for i := 1; i <= 3; i++ {
+		team := models.Team{Name: fmt.Sprintf("Team %d", i)}
+		db.Create(&team)
+		for j := 1; j <= 2; j++ {
+			member := models.TeamMember{Name: fmt.Sprintf("Member %d-%d", i, j), Email: fmt.Sprintf("m%d%d@example.com", i, j)}
+			db.Create(&member)
+			db.Model(&team).Association("Members").Append(&member)
+			db.Create(&models.Feedback{Content: "Older", TargetType: "member", TargetID: member.ID})
+			db.Create(&models.Feedback{Content: "Newer", TargetType: "member", TargetID: member.ID})
+		}
+	}
+
+	server, err := NewServer(db, DefaultLimits)
+	if err != nil {
+		t.Fatalf("Failed to build server: %v", err)
+	}
+
+	queries := 0
+	countQuery := func(*gorm.DB) { queries++ }
+	db.Callback().Query().After("gorm:query").Register("test:count_queries", countQuery)
+	db.Callback().Row().After("gorm:row").Register("test:count_rows", countQuery)
+
+	data := execute(t, server, `{
+		teams {
+			name
+			members {
+				name
+				feedbackCount
+				feedback(limit: 1) { content }
+			}
+		}
+	}`, nil)
+
+	teams := data["teams"].([]interface{})
+	if len(teams) != 3 {
+		t.Fatalf("Expected 3 teams, got %d", len(teams))
+	}
+	for _, raw := range teams {
+		members := raw.(map[string]interface{})["members"].([]interface{})
+		if len(members) != 2 {
+			t.Fatalf("Expected 2 members per team, got %d", len(members))
+		}
+		member := members[0].(map[string]interface{})
+		feedback := member["feedback"].([]interface{})
+		if len(feedback) != 1 || feedback[0].(map[string]interface{})["content"] != "Newer" {
+			t.Errorf("Expected only the most recent feedback, got %v", feedback)
+		}
+		if member["feedbackCount"] != float64(2) {
+			t.Errorf("Expected feedback count 2, got %v", member["feedbackCount"])
+		}
+	}
+
+	// teams, assignments, members, feedback and feedback counts
+	if queries != 5 {
+		t.Errorf("Expected 5 queries regardless of result size, got %d", queries)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	db := setupTestDB()
+	server, _ := NewServer(db, DefaultLimits)
+
+	team := execute(t, server, `mutation { createTeam(name: "Dev Team") { id name } }`, nil)["createTeam"].(map[string]interface{})
+	member := execute(t, server, `mutation { createTeamMember(name: "John Doe", email: "john@example.com") { id } }`, nil)["createTeamMember"].(map[string]interface{})
+
+	assignment := execute(t, server, `mutation Assign($team: ID!, $member: ID!) {
+		assignMember(teamId: $team, teamMemberId: $member) { team { name } member { id } }
+	}`, map[string]interface{}{"team": team["id"], "member": member["id"]})["assignMember"].(map[string]interface{})
+
+	if assignment["team"].(map[string]interface{})["name"] != "Dev Team" {
+		t.Errorf("Unexpected assignment: %v", assignment)
+	}
+
+	feedback := execute(t, server, `mutation($id: ID!) {
+		createFeedback(content: "Great work!", targetType: MEMBER, targetId: $id) { targetType content }
+	}`, map[string]interface{}{"id": member["id"]})["createFeedback"].(map[string]interface{})
+
+	if feedback["targetType"] != "MEMBER" {
+		t.Errorf("Expected MEMBER target type, got %v", feedback["targetType"])
+	}
+
+	result := server.Execute(adminContext(), Request{Query: `mutation { createTeam(name: "  ") { id } }`})
+	if !result.HasErrors() {
+		t.Error("Expected an error for a blank team name")
+	}
+
+	result = server.Execute(adminContext(), Request{Query: `mutation { createTeamMember(name: "Jane", email: "jane@", picture: "picture.png") { id } }`})
+	if !result.HasErrors() || result.Errors[0].Extensions["code"] != "VALIDATION_FAILED" {
+		t.Fatalf("Expected a validation error, got %v", result.Errors)
+	}
+	if fields := result.Errors[0].Extensions["fields"].(validation.Errors); len(fields) != 2 {
+		t.Errorf("Expected email and picture errors, got %v", fields)
+	}
+}
+
+func TestQueryLimits(t *testing.T) {
+	db := setupTestDB()
+	server, _ := NewServer(db, Limits{MaxDepth: 4, MaxComplexity: 300})
+
+	tests := []struct {
+		name    string
+		query   string
+		message string
+	}{
+		{
+			name:    "Too deep",
+			query:   `{ teams { members { teams { members { name } } } } }`,
+			message: "depth",
+		},
+		{
+			name:    "Too deep through a fragment",
+			query:   `{ teams { ...nested } } fragment nested on Team { members { teams { members { id } } } }`,
+			message: "depth",
+		},
+		{
+			name:    "Too complex",
+			query:   `{ teams { members { feedback(limit: 100) { id content } } } }`,
+			message: "complexity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := server.Execute(adminContext(), Request{Query: tt.query})
+			if !result.HasErrors() || !strings.Contains(result.Errors[0].Message, tt.message) {
+				t.Errorf("Expected a %s error, got %v", tt.message, result.Errors)
+			}
+		})
+	}
+
+	result := server.Execute(adminContext(), Request{Query: `{ teams { members { feedback(limit: 1) { id } } } }`})
+	if result.HasErrors() {
+		t.Errorf("Expected query within limits to succeed, got %v", result.Errors)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	db := setupTestDB()
+	server, _ := NewServer(db, DefaultLimits)
+	db.Create(&models.Team{Name: "Dev Team"})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: 2, Role: models.RoleMember})
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode string
+	}{
+		{"Create team", `mutation { createTeam(name: "Ops") { id } }`, "FORBIDDEN"},
+		{"Delete team", `mutation { deleteTeam(id: 1) }`, "FORBIDDEN"},
+		{"Nested feedback", `{ teams { feedback { id } } }`, "FORBIDDEN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := server.Execute(ctx, Request{Query: tt.query})
+			if len(result.Errors) == 0 || result.Errors[0].Extensions["code"] != tt.expectedCode {
+				t.Errorf("Expected %s error, got %v", tt.expectedCode, result.Errors)
+			}
+		})
+	}
+
+	keyCtx := auth.WithPrincipal(context.Background(), auth.Principal{APIKeyID: 1, Scopes: []string{"members:read"}})
+	if result := server.Execute(keyCtx, Request{Query: `{ teams { id } }`}); len(result.Errors) == 0 {
+		t.Error("Expected an API key without teams:read to be denied teams")
+	}
+	if result := server.Execute(keyCtx, Request{Query: `{ teamMembers { id } }`}); result.HasErrors() {
+		t.Errorf("Expected an API key with members:read to read members, got %v", result.Errors)
+	}
+
+	data := execute(t, server, `{ teams { name } }`, nil)
+	if len(data["teams"].([]interface{})) != 1 {
+		t.Errorf("Expected admins to read teams, got %v", data)
+	}
+}
@@ -0,0 +1,179 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// defaultListSize is the number of items assumed for a list field without a
+// limit argument when estimating query complexity.
+const defaultListSize = 10
+
+// Limits bound how expensive a single GraphQL document may be. Depth counts
+// nested fields, complexity counts every field that would be resolved, with
+// list fields multiplying the cost of their children by the list size.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+var DefaultLimits = Limits{
+	MaxDepth:      6,
+	MaxComplexity: 1000,
+}
+
+type cost struct {
+	depth      int
+	complexity int
+}
+
+type costWalker struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+}
+
+// checkLimits measures the selected operation (or every operation when no
+// name is given) and returns an error when it exceeds the limits.
+func checkLimits(schema graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}, limits Limits) error {
+	walker := &costWalker{
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+		visiting:  make(map[string]bool),
+	}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			walker.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName != "" && (operation.Name == nil || operation.Name.Value != operationName) {
+			continue
+		}
+
+		root := schema.QueryType()
+		if operation.Operation == ast.OperationTypeMutation {
+			root = schema.MutationType()
+		}
+
+		measured := walker.selectionSet(operation.SelectionSet, root, 0)
+		if limits.MaxDepth > 0 && measured.depth > limits.MaxDepth {
+			return fmt.Errorf("query depth %d exceeds the maximum of %d", measured.depth, limits.MaxDepth)
+		}
+		if limits.MaxComplexity > 0 && measured.complexity > limits.MaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the maximum of %d", measured.complexity, limits.MaxComplexity)
+		}
+	}
+	return nil
+}
+
+func (w *costWalker) selectionSet(set *ast.SelectionSet, parent graphql.Type, depth int) cost {
+	total := cost{depth: depth}
+	if set == nil {
+		return total
+	}
+
+	for _, selection := range set.Selections {
+		var measured cost
+		switch node := selection.(type) {
+		case *ast.Field:
+			measured = w.field(node, parent, depth)
+		case *ast.InlineFragment:
+			measured = w.selectionSet(node.SelectionSet, w.typeCondition(node.TypeCondition, parent), depth)
+		case *ast.FragmentSpread:
+			name := node.Name.Value
+			fragment, ok := w.fragments[name]
+			if !ok || w.visiting[name] {
+				continue
+			}
+			w.visiting[name] = true
+			measured = w.selectionSet(fragment.SelectionSet, w.typeCondition(fragment.TypeCondition, parent), depth)
+			w.visiting[name] = false
+		}
+
+		total.complexity += measured.complexity
+		if measured.depth > total.depth {
+			total.depth = measured.depth
+		}
+	}
+	return total
+}
+
+func (w *costWalker) field(field *ast.Field, parent graphql.Type, depth int) cost {
+	// Introspection is cheap and served from the schema, not the database
+	object, ok := parent.(*graphql.Object)
+	if !ok || len(field.Name.Value) > 1 && field.Name.Value[:2] == "__" {
+		return cost{depth: depth + 1, complexity: 1}
+	}
+
+	definition, ok := object.Fields()[field.Name.Value]
+	if !ok {
+		return cost{depth: depth + 1, complexity: 1}
+	}
+
+	fieldType, _ := graphql.GetNullable(definition.Type).(graphql.Type)
+	multiplier := 1
+	if list, ok := fieldType.(*graphql.List); ok {
+		multiplier = w.listSize(field)
+		fieldType, _ = graphql.GetNullable(list.OfType).(graphql.Type)
+	}
+
+	children := w.selectionSet(field.SelectionSet, fieldType, depth+1)
+	return cost{
+		depth:      maxInt(depth+1, children.depth),
+		complexity: 1 + multiplier*children.complexity,
+	}
+}
+
+func (w *costWalker) listSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "limit" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil && n >= 0 {
+				return n
+			}
+		case *ast.Variable:
+			switch n := w.variables[value.Name.Value].(type) {
+			case float64:
+				if n >= 0 {
+					return int(n)
+				}
+			case int:
+				if n >= 0 {
+					return n
+				}
+			}
+		}
+	}
+	return defaultListSize
+}
+
+func (w *costWalker) typeCondition(condition *ast.Named, parent graphql.Type) graphql.Type {
+	if condition == nil {
+		return parent
+	}
+	if named := w.schema.Type(condition.Name.Value); named != nil {
+		return named
+	}
+	return parent
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
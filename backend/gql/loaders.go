@@ -0,0 +1,201 @@
+package gql
+
+import (
+	"sync"
+
+	"coaching-app-backend/models"
+)
+
+// Resolvers never load a relation for a single parent. Every team or member
+// returned by a resolver belongs to a batch holding all of its siblings, and
+// the first time any sibling asks for a relation it is loaded for the whole
+// batch in one query. A query for N teams with their members and each
+// member's feedback therefore costs three queries instead of 1+N+N*M.
+
+// lazy runs its loader at most once and shares the result with every caller
+type lazy[T any] struct {
+	once  sync.Once
+	value T
+	err   error
+}
+
+func (l *lazy[T]) get(load func() (T, error)) (T, error) {
+	l.once.Do(func() {
+		l.value, l.err = load()
+	})
+	return l.value, l.err
+}
+
+// lazyByKey holds a lazy value per key, for relations loaded with arguments
+// that siblings may ask for with different values
+type lazyByKey[K comparable, T any] struct {
+	mu     sync.Mutex
+	values map[K]*lazy[T]
+}
+
+func (l *lazyByKey[K, T]) get(key K, load func() (T, error)) (T, error) {
+	l.mu.Lock()
+	if l.values == nil {
+		l.values = make(map[K]*lazy[T])
+	}
+	value, ok := l.values[key]
+	if !ok {
+		value = &lazy[T]{}
+		l.values[key] = value
+	}
+	l.mu.Unlock()
+	return value.get(load)
+}
+
+type teamNode struct {
+	team  models.Team
+	batch *teamBatch
+}
+
+type memberNode struct {
+	member models.TeamMember
+	batch  *memberBatch
+}
+
+type assignmentNode struct {
+	team   *teamNode
+	member *memberNode
+}
+
+type teamBatch struct {
+	services *resolverServices
+	ids      []uint
+	members  lazy[map[uint][]*memberNode]
+	// feedback is loaded per limit of feedback per target
+	feedback lazyByKey[int, map[uint][]models.Feedback]
+	counts   lazy[map[uint]int64]
+}
+
+type memberBatch struct {
+	services *resolverServices
+	ids      []uint
+	teams    lazy[map[uint][]*teamNode]
+	// feedback is loaded per limit of feedback per target
+	feedback lazyByKey[int, map[uint][]models.Feedback]
+	counts   lazy[map[uint]int64]
+}
+
+func newTeamNodes(services *resolverServices, teams []models.Team) []*teamNode {
+	batch := &teamBatch{services: services}
+	var ids idSet
+	nodes := make([]*teamNode, len(teams))
+	for i, team := range teams {
+		ids.add(team.ID)
+		nodes[i] = &teamNode{team: team, batch: batch}
+	}
+	batch.ids = ids.ids
+	return nodes
+}
+
+func newMemberNodes(services *resolverServices, members []models.TeamMember) []*memberNode {
+	batch := &memberBatch{services: services}
+	var ids idSet
+	nodes := make([]*memberNode, len(members))
+	for i, member := range members {
+		ids.add(member.ID)
+		nodes[i] = &memberNode{member: member, batch: batch}
+	}
+	batch.ids = ids.ids
+	return nodes
+}
+
+func (b *teamBatch) membersOf(teamID uint) ([]*memberNode, error) {
+	grouped, err := b.members.get(func() (map[uint][]*memberNode, error) {
+		byTeam, err := b.services.assignments.MembersByTeam(b.ids)
+		if err != nil {
+			return nil, err
+		}
+		return groupMemberNodes(b.services, byTeam), nil
+	})
+	return grouped[teamID], err
+}
+
+func (b *teamBatch) feedbackOf(teamID uint, limit int) ([]models.Feedback, error) {
+	grouped, err := b.feedback.get(limit, func() (map[uint][]models.Feedback, error) {
+		return b.services.feedback.FeedbackForTargets("team", b.ids, limit)
+	})
+	return grouped[teamID], err
+}
+
+func (b *teamBatch) feedbackCountOf(teamID uint) (int64, error) {
+	counts, err := b.counts.get(func() (map[uint]int64, error) {
+		return b.services.feedback.CountFeedbackForTargets("team", b.ids)
+	})
+	return counts[teamID], err
+}
+
+func (b *memberBatch) teamsOf(memberID uint) ([]*teamNode, error) {
+	grouped, err := b.teams.get(func() (map[uint][]*teamNode, error) {
+		byMember, err := b.services.assignments.TeamsByMember(b.ids)
+		if err != nil {
+			return nil, err
+		}
+		return groupTeamNodes(b.services, byMember), nil
+	})
+	return grouped[memberID], err
+}
+
+func (b *memberBatch) feedbackOf(memberID uint, limit int) ([]models.Feedback, error) {
+	grouped, err := b.feedback.get(limit, func() (map[uint][]models.Feedback, error) {
+		return b.services.feedback.FeedbackForTargets("member", b.ids, limit)
+	})
+	return grouped[memberID], err
+}
+
+func (b *memberBatch) feedbackCountOf(memberID uint) (int64, error) {
+	counts, err := b.counts.get(func() (map[uint]int64, error) {
+		return b.services.feedback.CountFeedbackForTargets("member", b.ids)
+	})
+	return counts[memberID], err
+}
+
+// groupMemberNodes wraps members loaded for several parents so that all of
+// them, across every parent, share a single batch.
+func groupMemberNodes(services *resolverServices, grouped map[uint][]models.TeamMember) map[uint][]*memberNode {
+	batch := &memberBatch{services: services}
+	var ids idSet
+	nodes := make(map[uint][]*memberNode, len(grouped))
+	for parentID, members := range grouped {
+		for _, member := range members {
+			ids.add(member.ID)
+			nodes[parentID] = append(nodes[parentID], &memberNode{member: member, batch: batch})
+		}
+	}
+	batch.ids = ids.ids
+	return nodes
+}
+
+func groupTeamNodes(services *resolverServices, grouped map[uint][]models.Team) map[uint][]*teamNode {
+	batch := &teamBatch{services: services}
+	var ids idSet
+	nodes := make(map[uint][]*teamNode, len(grouped))
+	for parentID, teams := range grouped {
+		for _, team := range teams {
+			ids.add(team.ID)
+			nodes[parentID] = append(nodes[parentID], &teamNode{team: team, batch: batch})
+		}
+	}
+	batch.ids = ids.ids
+	return nodes
+}
+
+// idSet collects IDs in insertion order, skipping duplicates
+type idSet struct {
+	ids  []uint
+	seen map[uint]bool
+}
+
+func (s *idSet) add(id uint) {
+	if s.seen == nil {
+		s.seen = make(map[uint]bool)
+	}
+	if !s.seen[id] {
+		s.seen[id] = true
+		s.ids = append(s.ids, id)
+	}
+}
@@ -0,0 +1,581 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/policy"
+	"coaching-app-backend/services"
+	"coaching-app-backend/validation"
+
+	"github.com/graphql-go/graphql"
+	"gorm.io/gorm"
+)
+
+type resolverServices struct {
+	teams       *services.TeamService
+	members     *services.TeamMemberService
+	assignments *services.AssignmentService
+	feedback    *services.FeedbackService
+	policy      *policy.Authorizer
+}
+
+// withContext returns the services running their queries with ctx, which
+// carries the organization of the request, for a resolver and the nodes it
+// returns
+func (s *resolverServices) withContext(ctx context.Context) *resolverServices {
+	return &resolverServices{
+		teams:       s.teams.WithContext(ctx),
+		members:     s.members.WithContext(ctx),
+		assignments: s.assignments.WithContext(ctx),
+		feedback:    s.feedback.WithContext(ctx),
+		policy:      s.policy,
+	}
+}
+
+func newResolverServices(db *gorm.DB) *resolverServices {
+	return &resolverServices{
+		teams:       services.NewTeamService(db),
+		members:     services.NewTeamMemberService(db),
+		assignments: services.NewAssignmentService(db),
+		feedback:    services.NewFeedbackService(db),
+		policy:      policy.NewAuthorizer(db),
+	}
+}
+
+// NewSchema builds the GraphQL schema. Queries and mutations delegate to the
+// same services used by the REST handlers.
+func NewSchema(db *gorm.DB) (graphql.Schema, error) {
+	svc := newResolverServices(db)
+
+	feedbackTargetEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "FeedbackTarget",
+		Values: graphql.EnumValueConfigMap{
+			"TEAM":   &graphql.EnumValueConfig{Value: "team"},
+			"MEMBER": &graphql.EnumValueConfig{Value: "member"},
+		},
+	})
+
+	feedbackType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Feedback",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Feedback).ID, nil
+			}},
+			"content": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Feedback).Content, nil
+			}},
+			"targetType": &graphql.Field{Type: graphql.NewNonNull(feedbackTargetEnum), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Feedback).TargetType, nil
+			}},
+			"targetId": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Feedback).TargetID, nil
+			}},
+			"authorId": &graphql.Field{Type: graphql.ID, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if author := p.Source.(models.Feedback).AuthorID; author != nil {
+					return *author, nil
+				}
+				return nil, nil
+			}},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(models.Feedback).CreatedAt, nil
+			}},
+		},
+	})
+
+	limitArgs := graphql.FieldConfigArgument{
+		"limit": &graphql.ArgumentConfig{Type: graphql.Int, Description: "Return at most this many items"},
+	}
+
+	var teamType, memberType *graphql.Object
+
+	teamType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Team",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*teamNode).team.ID, nil
+				}},
+				"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*teamNode).team.Name, nil
+				}},
+				"logo": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*teamNode).team.Logo, nil
+				}},
+				"members": &graphql.Field{Type: listOf(memberType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					node := p.Source.(*teamNode)
+					return node.batch.membersOf(node.team.ID)
+				}},
+				"feedback": &graphql.Field{Type: listOf(feedbackType), Args: limitArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, svc.policy, policy.ReadAllFeedback, policy.Resource{}); err != nil {
+						return nil, err
+					}
+					node := p.Source.(*teamNode)
+					return batchedFeedback(p.Args, func(limit int) ([]models.Feedback, error) {
+						return node.batch.feedbackOf(node.team.ID, limit)
+					})
+				}},
+				"feedbackCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, svc.policy, policy.ReadAllFeedback, policy.Resource{}); err != nil {
+						return nil, err
+					}
+					node := p.Source.(*teamNode)
+					return node.batch.feedbackCountOf(node.team.ID)
+				}},
+			}
+		}),
+	})
+
+	memberType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "TeamMember",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*memberNode).member.ID, nil
+				}},
+				"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*memberNode).member.Name, nil
+				}},
+				"email": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*memberNode).member.Email, nil
+				}},
+				"picture": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*memberNode).member.Picture, nil
+				}},
+				"teams": &graphql.Field{Type: listOf(teamType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					node := p.Source.(*memberNode)
+					return node.batch.teamsOf(node.member.ID)
+				}},
+				"feedback": &graphql.Field{Type: listOf(feedbackType), Args: limitArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, svc.policy, policy.ReadAllFeedback, policy.Resource{}); err != nil {
+						return nil, err
+					}
+					node := p.Source.(*memberNode)
+					return batchedFeedback(p.Args, func(limit int) ([]models.Feedback, error) {
+						return node.batch.feedbackOf(node.member.ID, limit)
+					})
+				}},
+				"feedbackCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if err := authorize(p.Context, svc.policy, policy.ReadAllFeedback, policy.Resource{}); err != nil {
+						return nil, err
+					}
+					node := p.Source.(*memberNode)
+					return node.batch.feedbackCountOf(node.member.ID)
+				}},
+			}
+		}),
+	})
+
+	assignmentType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Assignment",
+		Fields: graphql.Fields{
+			"team": &graphql.Field{Type: graphql.NewNonNull(teamType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*assignmentNode).team, nil
+			}},
+			"member": &graphql.Field{Type: graphql.NewNonNull(memberType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*assignmentNode).member, nil
+			}},
+		},
+	})
+
+	idArgs := graphql.FieldConfigArgument{
+		"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+	}
+	assignmentArgs := graphql.FieldConfigArgument{
+		"teamId":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+		"teamMemberId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"teams": &graphql.Field{Type: listOf(teamType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				svc := svc.withContext(p.Context)
+				if err := authorize(p.Context, svc.policy, policy.ReadTeams, policy.Resource{}); err != nil {
+					return nil, err
+				}
+				teams, err := svc.teams.GetAllTeams()
+				if err != nil {
+					return nil, err
+				}
+				return newTeamNodes(svc, teams), nil
+			}},
+			"team": &graphql.Field{Type: teamType, Args: idArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				svc := svc.withContext(p.Context)
+				if err := authorize(p.Context, svc.policy, policy.ReadTeams, policy.Resource{}); err != nil {
+					return nil, err
+				}
+				id, err := parseID(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				team, err := svc.teams.FindTeam(id, services.QueryOptions{})
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				return newTeamNodes(svc, []models.Team{*team})[0], nil
+			}},
+			"teamMembers": &graphql.Field{Type: listOf(memberType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				svc := svc.withContext(p.Context)
+				if err := authorize(p.Context, svc.policy, policy.ReadMembers, policy.Resource{}); err != nil {
+					return nil, err
+				}
+				members, err := svc.members.GetAllTeamMembers()
+				if err != nil {
+					return nil, err
+				}
+				return newMemberNodes(svc, members), nil
+			}},
+			"teamMember": &graphql.Field{Type: memberType, Args: idArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				svc := svc.withContext(p.Context)
+				if err := authorize(p.Context, svc.policy, policy.ReadMembers, policy.Resource{}); err != nil {
+					return nil, err
+				}
+				id, err := parseID(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				member, err := svc.members.GetTeamMemberByID(id)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				return newMemberNodes(svc, []models.TeamMember{*member})[0], nil
+			}},
+			"feedback": &graphql.Field{
+				Type: listOf(feedbackType),
+				Args: graphql.FieldConfigArgument{
+					"targetType": &graphql.ArgumentConfig{Type: feedbackTargetEnum},
+					"targetId":   &graphql.ArgumentConfig{Type: graphql.ID},
+					"limit":      limitArgs["limit"],
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					svc := svc.withContext(p.Context)
+					var filter services.FeedbackFilter
+					filter.TargetType, _ = p.Args["targetType"].(string)
+					if raw, ok := p.Args["targetId"]; ok {
+						id, err := parseID(raw)
+						if err != nil {
+							return nil, err
+						}
+						filter.TargetID = id
+					}
+					feedback, err := svc.feedback.FindFeedback(filter)
+					if err != nil {
+						return nil, err
+					}
+					if feedback, err = svc.policy.FilterFeedback(p.Context, feedback); err != nil {
+						return nil, authorizationError(err)
+					}
+					return limitFeedback(feedback, p.Args), nil
+				},
+			},
+			"assignments": &graphql.Field{Type: listOf(assignmentType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				svc := svc.withContext(p.Context)
+				if err := authorize(p.Context, svc.policy, policy.ReadAssignments, policy.Resource{}); err != nil {
+					return nil, err
+				}
+				teams, err := svc.assignments.GetAllAssignments()
+				if err != nil {
+					return nil, err
+				}
+				return newAssignmentNodes(svc, teams), nil
+			}},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createTeam": &graphql.Field{
+				Type: graphql.NewNonNull(teamType),
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"logo": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					svc := svc.withContext(p.Context)
+					if err := authorize(p.Context, svc.policy, policy.CreateTeam, policy.Resource{}); err != nil {
+						return nil, err
+					}
+					input := services.TeamInput{Name: stringArg(p.Args, "name"), Logo: stringArg(p.Args, "logo")}
+					if err := validateInput(&input); err != nil {
+						return nil, err
+					}
+					team := input.Team()
+					if err := svc.teams.CreateTeam(&team); err != nil {
+						return nil, errors.New("Failed to create team")
+					}
+					return newTeamNodes(svc, []models.Team{team})[0], nil
+				},
+			},
+			"deleteTeam": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: idArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					svc := svc.withContext(p.Context)
+					id, err := parseID(p.Args["id"])
+					if err != nil {
+						return nil, err
+					}
+					if err := authorize(p.Context, svc.policy, policy.DeleteTeam, policy.Resource{TeamID: id}); err != nil {
+						return nil, err
+					}
+					if err := svc.teams.DeleteTeam(id); err != nil {
+						return nil, errors.New("Failed to delete team")
+					}
+					return true, nil
+				},
+			},
+			"createTeamMember": &graphql.Field{
+				Type: graphql.NewNonNull(memberType),
+				Args: graphql.FieldConfigArgument{
+					"name":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"email":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"picture": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					svc := svc.withContext(p.Context)
+					if err := authorize(p.Context, svc.policy, policy.CreateMember, policy.Resource{}); err != nil {
+						return nil, err
+					}
+					input := services.MemberInput{
+						Name:    stringArg(p.Args, "name"),
+						Email:   stringArg(p.Args, "email"),
+						Picture: stringArg(p.Args, "picture"),
+					}
+					if err := validateInput(&input); err != nil {
+						return nil, err
+					}
+					member := input.TeamMember()
+					if err := svc.members.CreateTeamMember(&member); err != nil {
+						if errors.Is(err, services.ErrEmailTaken) {
+							return nil, errors.New("A team member with this email already exists")
+						}
+						return nil, errors.New("Failed to create team member")
+					}
+					return newMemberNodes(svc, []models.TeamMember{member})[0], nil
+				},
+			},
+			"assignMember": &graphql.Field{
+				Type: graphql.NewNonNull(assignmentType),
+				Args: assignmentArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					svc := svc.withContext(p.Context)
+					teamID, memberID, err := parseAssignmentArgs(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					if err := authorize(p.Context, svc.policy, policy.ManageAssignments, policy.Resource{TeamID: teamID}); err != nil {
+						return nil, err
+					}
+					if err := svc.assignments.AssignMemberToTeam(teamID, memberID); err != nil {
+						return nil, errors.New("Failed to assign member to team")
+					}
+					team, err := svc.teams.FindTeam(teamID, services.QueryOptions{})
+					if err != nil {
+						return nil, err
+					}
+					member, err := svc.members.GetTeamMemberByID(memberID)
+					if err != nil {
+						return nil, err
+					}
+					return &assignmentNode{
+						team:   newTeamNodes(svc, []models.Team{*team})[0],
+						member: newMemberNodes(svc, []models.TeamMember{*member})[0],
+					}, nil
+				},
+			},
+			"removeMember": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: assignmentArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					svc := svc.withContext(p.Context)
+					teamID, memberID, err := parseAssignmentArgs(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					if err := authorize(p.Context, svc.policy, policy.ManageAssignments, policy.Resource{TeamID: teamID}); err != nil {
+						return nil, err
+					}
+					if err := svc.assignments.RemoveMemberFromTeam(teamID, memberID); err != nil {
+						return nil, errors.New("Failed to remove member from team")
+					}
+					return true, nil
+				},
+			},
+			"createFeedback": &graphql.Field{
+				Type: graphql.NewNonNull(feedbackType),
+				Args: graphql.FieldConfigArgument{
+					"content":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"targetType": &graphql.ArgumentConfig{Type: graphql.NewNonNull(feedbackTargetEnum)},
+					"targetId":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					svc := svc.withContext(p.Context)
+					if err := authorize(p.Context, svc.policy, policy.CreateFeedback, policy.Resource{}); err != nil {
+						return nil, err
+					}
+					targetID, err := parseID(p.Args["targetId"])
+					if err != nil {
+						return nil, err
+					}
+					input := services.FeedbackInput{
+						Content:    stringArg(p.Args, "content"),
+						TargetType: stringArg(p.Args, "targetType"),
+						TargetID:   targetID,
+					}
+					if principal, _ := auth.FromContext(p.Context); principal.Role != models.RoleAdmin {
+						input.AuthorID = principal.TeamMemberID
+					}
+					if err := validateInput(&input); err != nil {
+						return nil, err
+					}
+					feedback := input.Feedback()
+					if err := svc.feedback.CreateFeedback(&feedback); err != nil {
+						return nil, errors.New("Invalid target ID or failed to create feedback")
+					}
+					return feedback, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+// newAssignmentNodes flattens teams with their members into assignments,
+// sharing one team batch and one member batch across the whole list.
+func newAssignmentNodes(svc *resolverServices, teams []models.Team) []*assignmentNode {
+	var members []models.TeamMember
+	for _, team := range teams {
+		members = append(members, team.Members...)
+	}
+
+	teamNodes := newTeamNodes(svc, teams)
+	memberNodes := newMemberNodes(svc, members)
+
+	var assignments []*assignmentNode
+	next := 0
+	for i, team := range teams {
+		for range team.Members {
+			assignments = append(assignments, &assignmentNode{team: teamNodes[i], member: memberNodes[next]})
+			next++
+		}
+	}
+	return assignments
+}
+
+func listOf(t graphql.Type) graphql.Output {
+	return graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(t)))
+}
+
+// batchedFeedback resolves the feedback of a team or member, which load
+// passes the limit argument on to, so that the batched query loads no more
+// than is returned for each target. Without a limit, load gets 0.
+func batchedFeedback(args map[string]interface{}, load func(limit int) ([]models.Feedback, error)) ([]models.Feedback, error) {
+	limit, ok := args["limit"].(int)
+	switch {
+	case !ok || limit < 0:
+		return load(0)
+	case limit == 0:
+		return []models.Feedback{}, nil
+	}
+	return load(limit)
+}
+
+func limitFeedback(feedback []models.Feedback, args map[string]interface{}) []models.Feedback {
+	limit, ok := args["limit"].(int)
+	if !ok || limit < 0 || limit >= len(feedback) {
+		return feedback
+	}
+	return feedback[:limit]
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	value, _ := args[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// inputError carries field errors to the client in the error's extensions
+type inputError struct {
+	fields validation.Errors
+}
+
+func (e inputError) Error() string {
+	return "Validation failed: " + e.fields.Error()
+}
+
+func (e inputError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": "VALIDATION_FAILED", "fields": e.fields}
+}
+
+func validateInput(input interface{}) error {
+	err := validation.Struct(input)
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		return inputError{fields: fieldErrs}
+	}
+	return err
+}
+
+// accessError reports a failed authorization check with a machine-readable code
+type accessError struct {
+	code    string
+	message string
+}
+
+func (e accessError) Error() string {
+	return e.message
+}
+
+func (e accessError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+func authorize(ctx context.Context, authz *policy.Authorizer, action policy.Action, resource policy.Resource) error {
+	return authorizationError(authz.Authorize(ctx, action, resource))
+}
+
+func authorizationError(err error) error {
+	switch {
+	case errors.Is(err, policy.ErrUnauthenticated):
+		return accessError{code: "UNAUTHENTICATED", message: "Authentication required"}
+	case errors.Is(err, policy.ErrForbidden):
+		return accessError{code: "FORBIDDEN", message: "You are not allowed to perform this action"}
+	}
+	return err
+}
+
+func parseID(raw interface{}) (uint, error) {
+	id, err := strconv.ParseUint(fmt.Sprint(raw), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q", fmt.Sprint(raw))
+	}
+	return uint(id), nil
+}
+
+func parseAssignmentArgs(args map[string]interface{}) (uint, uint, error) {
+	teamID, err := parseID(args["teamId"])
+	if err != nil {
+		return 0, 0, err
+	}
+	memberID, err := parseID(args["teamMemberId"])
+	if err != nil {
+		return 0, 0, err
+	}
+	return teamID, memberID, nil
+}
@@ -0,0 +1,43 @@
+package grpcapi
+
+import (
+	"context"
+
+	"coaching-app-backend/policy"
+	coachingv1 "coaching-app-backend/proto/coaching/v1"
+	"coaching-app-backend/services"
+)
+
+type assignmentServer struct {
+	coachingv1.UnimplementedAssignmentServiceServer
+	service *services.AssignmentService
+	policy  *policy.Authorizer
+}
+
+func (s *assignmentServer) AssignMember(ctx context.Context, req *coachingv1.AssignMemberRequest) (*coachingv1.AssignMemberResponse, error) {
+	if err := s.policy.Authorize(ctx, policy.ManageAssignments, policy.Resource{TeamID: uint(req.GetTeamId())}); err != nil {
+		return nil, err
+	}
+	if err := s.service.WithContext(ctx).AssignMemberToTeam(uint(req.GetTeamId()), uint(req.GetTeamMemberId())); err != nil {
+		return nil, err
+	}
+	return &coachingv1.AssignMemberResponse{}, nil
+}
+
+func (s *assignmentServer) RemoveMember(ctx context.Context, req *coachingv1.RemoveMemberRequest) (*coachingv1.RemoveMemberResponse, error) {
+	if err := s.policy.Authorize(ctx, policy.ManageAssignments, policy.Resource{TeamID: uint(req.GetTeamId())}); err != nil {
+		return nil, err
+	}
+	if err := s.service.WithContext(ctx).RemoveMemberFromTeam(uint(req.GetTeamId()), uint(req.GetTeamMemberId())); err != nil {
+		return nil, err
+	}
+	return &coachingv1.RemoveMemberResponse{}, nil
+}
+
+func (s *assignmentServer) ListAssignments(ctx context.Context, req *coachingv1.ListAssignmentsRequest) (*coachingv1.ListAssignmentsResponse, error) {
+	teams, err := s.service.WithContext(ctx).GetAllAssignments()
+	if err != nil {
+		return nil, err
+	}
+	return &coachingv1.ListAssignmentsResponse{Teams: toTeams(teams)}, nil
+}
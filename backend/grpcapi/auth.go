@@ -0,0 +1,91 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/auth"
+	"coaching-app-backend/tenant"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// authenticator checks the bearer token in incoming metadata and stores the
+// caller's principal and organization on the context, mirroring the REST auth
+// and tenant middleware.
+type authenticator struct {
+	tokens *auth.TokenIssuer
+}
+
+func (a *authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Authentication required")
+	}
+
+	scheme, token, _ := strings.Cut(values[0], " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, status.Error(codes.Unauthenticated, "Authentication required")
+	}
+
+	principal, err := a.tokens.ParseAccessToken(strings.TrimSpace(token))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Invalid or expired access token")
+	}
+	if principal.OrganizationID == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Access token has no organization, please sign in again")
+	}
+	ctx = tenant.WithOrganization(auth.WithPrincipal(ctx, principal), principal.OrganizationID)
+	setServedContext(ctx)
+	return ctx, nil
+}
+
+// withRequest stores the request ID from the x-request-id metadata, or a new
+// one, and the caller's address for audit events
+func withRequest(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id, ip string
+	if values := md.Get("x-request-id"); len(values) > 0 {
+		id = values[0]
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+	return audit.WithRequest(ctx, audit.Request{ID: audit.EnsureRequestID(id), IP: ip})
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+}
+
+// contextStream serves a stream with a context of its own
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
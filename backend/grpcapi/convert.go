@@ -0,0 +1,74 @@
+package grpcapi
+
+import (
+	"coaching-app-backend/models"
+	coachingv1 "coaching-app-backend/proto/coaching/v1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toTeam(team *models.Team) *coachingv1.Team {
+	return &coachingv1.Team{
+		Id:      uint32(team.ID),
+		Name:    team.Name,
+		Logo:    team.Logo,
+		Members: toTeamMembers(team.Members),
+	}
+}
+
+func toTeams(teams []models.Team) []*coachingv1.Team {
+	converted := make([]*coachingv1.Team, len(teams))
+	for i := range teams {
+		converted[i] = toTeam(&teams[i])
+	}
+	return converted
+}
+
+func toTeamMember(member *models.TeamMember) *coachingv1.TeamMember {
+	return &coachingv1.TeamMember{
+		Id:      uint32(member.ID),
+		Name:    member.Name,
+		Picture: member.Picture,
+		Email:   member.Email,
+	}
+}
+
+func toTeamMembers(members []models.TeamMember) []*coachingv1.TeamMember {
+	converted := make([]*coachingv1.TeamMember, len(members))
+	for i := range members {
+		converted[i] = toTeamMember(&members[i])
+	}
+	return converted
+}
+
+func toFeedback(feedback *models.Feedback) *coachingv1.Feedback {
+	return &coachingv1.Feedback{
+		Id:         uint32(feedback.ID),
+		Content:    feedback.Content,
+		TargetType: toTargetType(feedback.TargetType),
+		TargetId:   uint32(feedback.TargetID),
+		CreatedAt:  timestamppb.New(feedback.CreatedAt),
+	}
+}
+
+func toTargetType(targetType string) coachingv1.FeedbackTargetType {
+	switch targetType {
+	case "team":
+		return coachingv1.FeedbackTargetType_FEEDBACK_TARGET_TYPE_TEAM
+	case "member":
+		return coachingv1.FeedbackTargetType_FEEDBACK_TARGET_TYPE_MEMBER
+	default:
+		return coachingv1.FeedbackTargetType_FEEDBACK_TARGET_TYPE_UNSPECIFIED
+	}
+}
+
+func fromTargetType(targetType coachingv1.FeedbackTargetType) string {
+	switch targetType {
+	case coachingv1.FeedbackTargetType_FEEDBACK_TARGET_TYPE_TEAM:
+		return "team"
+	case coachingv1.FeedbackTargetType_FEEDBACK_TARGET_TYPE_MEMBER:
+		return "member"
+	default:
+		return ""
+	}
+}
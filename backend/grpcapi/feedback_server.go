@@ -0,0 +1,64 @@
+package grpcapi
+
+import (
+	"context"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/policy"
+	coachingv1 "coaching-app-backend/proto/coaching/v1"
+	"coaching-app-backend/services"
+)
+
+type feedbackServer struct {
+	coachingv1.UnimplementedFeedbackServiceServer
+	service *services.FeedbackService
+	policy  *policy.Authorizer
+}
+
+func (s *feedbackServer) CreateFeedback(ctx context.Context, req *coachingv1.CreateFeedbackRequest) (*coachingv1.Feedback, error) {
+	if err := s.policy.Authorize(ctx, policy.CreateFeedback, policy.Resource{}); err != nil {
+		return nil, err
+	}
+
+	input := services.FeedbackInput{
+		Content:    req.GetContent(),
+		TargetType: fromTargetType(req.GetTargetType()),
+		TargetID:   uint(req.GetTargetId()),
+	}
+	if principal, _ := auth.FromContext(ctx); principal.Role != models.RoleAdmin {
+		input.AuthorID = principal.TeamMemberID
+	}
+	if err := validateInput(&input); err != nil {
+		return nil, err
+	}
+
+	feedback := input.Feedback()
+	if err := s.service.WithContext(ctx).CreateFeedback(&feedback); err != nil {
+		return nil, err
+	}
+	return toFeedback(&feedback), nil
+}
+
+func (s *feedbackServer) ListFeedback(req *coachingv1.ListFeedbackRequest, stream coachingv1.FeedbackService_ListFeedbackServer) error {
+	feedback, err := s.service.WithContext(stream.Context()).FindFeedback(services.FeedbackFilter{
+		TargetType: fromTargetType(req.GetTargetType()),
+		TargetID:   uint(req.GetTargetId()),
+	})
+	if err != nil {
+		return err
+	}
+	if feedback, err = s.policy.FilterFeedback(stream.Context(), feedback); err != nil {
+		return err
+	}
+
+	for i := range feedback {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+		if err := stream.Send(toFeedback(&feedback[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
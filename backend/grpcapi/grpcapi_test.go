@@ -0,0 +1,264 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/logging"
+	"coaching-app-backend/models"
+	coachingv1 "coaching-app-backend/proto/coaching/v1"
+	"coaching-app-backend/tenant"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	tenant.Register(db)
+	db.AutoMigrate(&models.Organization{}, &models.TeamMember{}, &models.Team{}, &models.TeamAssignment{}, &models.Feedback{}, &models.TeamCoach{}, &models.AuditEvent{})
+	return db
+}
+
+// bearerToken attaches an access token to every call
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+func setupTestClient(t *testing.T, db *gorm.DB) *grpc.ClientConn {
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+	token, _, _ := tokens.IssueAccessToken(auth.Principal{UserID: 1, Email: "admin@example.com", Role: models.RoleAdmin, OrganizationID: models.DefaultOrganizationID})
+	return dialTestServer(t, NewServer(db, tokens), grpc.WithPerRPCCredentials(bearerToken(token)))
+}
+
+func dialTestServer(t *testing.T, server *grpc.Server, opts ...grpc.DialOption) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatalf("Failed to dial server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return conn
+}
+
+func TestRequiresAuthentication(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&logs, slog.LevelInfo))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+	conn := dialTestServer(t, NewServer(setupTestDB(), tokens))
+
+	_, err := coachingv1.NewTeamServiceClient(conn).ListTeams(context.Background(), &coachingv1.ListTeamsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token, got %v", err)
+	}
+
+	stream, _ := coachingv1.NewFeedbackServiceClient(conn).ListFeedback(context.Background(), &coachingv1.ListFeedbackRequest{})
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated for a stream without a token, got %v", err)
+	}
+
+	// Rejected calls are logged with their request ID
+	var calls int
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record map[string]interface{}
+		json.Unmarshal([]byte(line), &record)
+		if record["msg"] == "gRPC call" && record["code"] == "Unauthenticated" && record["request_id"] != nil {
+			calls++
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected both rejected calls to be logged, got:\n%s", logs.String())
+	}
+}
+
+func TestRequiresPermission(t *testing.T) {
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+	token, _, _ := tokens.IssueAccessToken(auth.Principal{UserID: 2, Email: "member@example.com", Role: models.RoleMember, OrganizationID: models.DefaultOrganizationID})
+	conn := dialTestServer(t, NewServer(setupTestDB(), tokens), grpc.WithPerRPCCredentials(bearerToken(token)))
+	teams := coachingv1.NewTeamServiceClient(conn)
+
+	if _, err := teams.ListTeams(context.Background(), &coachingv1.ListTeamsRequest{}); err != nil {
+		t.Errorf("Expected members to list teams, got %v", err)
+	}
+	if _, err := teams.CreateTeam(context.Background(), &coachingv1.CreateTeamRequest{Name: "Dev Team"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for a member creating a team, got %v", err)
+	}
+}
+
+func TestTeamAndAssignmentServices(t *testing.T) {
+	conn := setupTestClient(t, setupTestDB())
+	ctx := context.Background()
+	teams := coachingv1.NewTeamServiceClient(conn)
+	members := coachingv1.NewTeamMemberServiceClient(conn)
+	assignments := coachingv1.NewAssignmentServiceClient(conn)
+
+	team, err := teams.CreateTeam(ctx, &coachingv1.CreateTeamRequest{Name: "Dev Team"})
+	if err != nil {
+		t.Fatalf("Failed to create team: %v", err)
+	}
+	member, err := members.CreateTeamMember(ctx, &coachingv1.CreateTeamMemberRequest{Name: "John Doe", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create team member: %v", err)
+	}
+
+	assign := &coachingv1.AssignMemberRequest{TeamId: team.Id, TeamMemberId: member.Id}
+	if _, err := assignments.AssignMember(ctx, assign); err != nil {
+		t.Fatalf("Failed to assign member: %v", err)
+	}
+	if _, err := assignments.AssignMember(ctx, assign); err != nil {
+		t.Errorf("Expected assigning the member again to succeed, got %v", err)
+	}
+
+	fetched, err := teams.GetTeam(ctx, &coachingv1.GetTeamRequest{Id: team.Id})
+	if err != nil {
+		t.Fatalf("Failed to get team: %v", err)
+	}
+	if len(fetched.Members) != 1 || fetched.Members[0].Email != "john@example.com" {
+		t.Errorf("Expected team to include its member, got %v", fetched.Members)
+	}
+
+	if _, err := teams.DeleteTeam(ctx, &coachingv1.DeleteTeamRequest{Id: team.Id}); err != nil {
+		t.Fatalf("Failed to delete team: %v", err)
+	}
+	if _, err := teams.GetTeam(ctx, &coachingv1.GetTeamRequest{Id: team.Id}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for a deleted team, got %v", err)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	conn := setupTestClient(t, setupTestDB())
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{
+			name: "Team without name",
+			call: func() error {
+				_, err := coachingv1.NewTeamServiceClient(conn).CreateTeam(ctx, &coachingv1.CreateTeamRequest{Name: "  "})
+				return err
+			},
+		},
+		{
+			name: "Member without email",
+			call: func() error {
+				_, err := coachingv1.NewTeamMemberServiceClient(conn).CreateTeamMember(ctx, &coachingv1.CreateTeamMemberRequest{Name: "John Doe"})
+				return err
+			},
+		},
+		{
+			name: "Feedback without target type",
+			call: func() error {
+				_, err := coachingv1.NewFeedbackServiceClient(conn).CreateFeedback(ctx, &coachingv1.CreateFeedbackRequest{Content: "Great work!", TargetId: 1})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(tt.call()); code != codes.InvalidArgument {
+				t.Errorf("Expected InvalidArgument, got %v", code)
+			}
+		})
+	}
+
+	_, err := coachingv1.NewTeamMemberServiceClient(conn).CreateTeamMember(ctx, &coachingv1.CreateTeamMemberRequest{Email: "john@", Picture: "picture.png"})
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = badRequest.GetFieldViolations()
+		}
+	}
+	if len(violations) != 3 {
+		t.Errorf("Expected violations for name, email and picture, got %v", violations)
+	}
+}
+
+func TestListFeedbackStream(t *testing.T) {
+	db := setupTestDB()
+	conn := setupTestClient(t, db)
+	ctx := context.Background()
+	client := coachingv1.NewFeedbackServiceClient(conn)
+	db.Create(&models.Team{Name: "Dev Team"})
+	db.Create(&models.TeamMember{Name: "John Doe", Email: "john@example.com"})
+
+	for _, content := range []string{"First", "Second", "Third"} {
+		_, err := client.CreateFeedback(ctx, &coachingv1.CreateFeedbackRequest{
+			Content:    content,
+			TargetType: coachingv1.FeedbackTargetType_FEEDBACK_TARGET_TYPE_MEMBER,
+			TargetId:   1,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create feedback: %v", err)
+		}
+	}
+	db.Create(&models.Feedback{Content: "Team feedback", TargetType: "team", TargetID: 1})
+
+	stream, err := client.ListFeedback(ctx, &coachingv1.ListFeedbackRequest{
+		TargetType: coachingv1.FeedbackTargetType_FEEDBACK_TARGET_TYPE_MEMBER,
+		TargetId:   1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	var received []*coachingv1.Feedback
+	for {
+		feedback, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to receive feedback: %v", err)
+		}
+		received = append(received, feedback)
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("Expected 3 feedback items, got %d", len(received))
+	}
+	for _, feedback := range received {
+		if feedback.TargetType != coachingv1.FeedbackTargetType_FEEDBACK_TARGET_TYPE_MEMBER || feedback.CreatedAt == nil {
+			t.Errorf("Unexpected feedback: %v", feedback)
+		}
+	}
+}
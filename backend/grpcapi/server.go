@@ -0,0 +1,170 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/policy"
+	coachingv1 "coaching-app-backend/proto/coaching/v1"
+	"coaching-app-backend/services"
+	"coaching-app-backend/validation"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// NewServer returns a gRPC server exposing the coaching API on top of the
+// same services used by the REST handlers. Every call must carry an access
+// token in the "authorization: Bearer <token>" metadata.
+func NewServer(db *gorm.DB, tokens *auth.TokenIssuer, opts ...grpc.ServerOption) *grpc.Server {
+	authn := &authenticator{tokens: tokens}
+	opts = append(opts,
+		// Logging comes before authentication, so rejected calls are logged
+		// too, and after error mapping, so the underlying errors are
+		grpc.ChainUnaryInterceptor(unaryErrorInterceptor, unaryLogInterceptor, authn.unary),
+		grpc.ChainStreamInterceptor(streamErrorInterceptor, streamLogInterceptor, authn.stream),
+	)
+	server := grpc.NewServer(opts...)
+	Register(server, db)
+	return server
+}
+
+// Register adds every coaching service to s
+func Register(s grpc.ServiceRegistrar, db *gorm.DB) {
+	authz := policy.NewAuthorizer(db)
+	coachingv1.RegisterTeamServiceServer(s, &teamServer{service: services.NewTeamService(db), policy: authz})
+	coachingv1.RegisterTeamMemberServiceServer(s, &teamMemberServer{service: services.NewTeamMemberService(db), policy: authz})
+	coachingv1.RegisterAssignmentServiceServer(s, &assignmentServer{service: services.NewAssignmentService(db), policy: authz})
+	coachingv1.RegisterFeedbackServiceServer(s, &feedbackServer{service: services.NewFeedbackService(db), policy: authz})
+}
+
+func unaryErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, statusFromError(err)
+}
+
+func streamErrorInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return statusFromError(handler(srv, stream))
+}
+
+// unaryLogInterceptor stores the request ID on the context and logs calls
+// once they have been served, with the caller if the authenticator accepted
+// them
+func unaryLogInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx, served := withServedContext(withRequest(ctx))
+	resp, err := handler(ctx, req)
+	logCall(*served, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamLogInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, served := withServedContext(withRequest(stream.Context()))
+	err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	logCall(*served, info.FullMethod, start, err)
+	return err
+}
+
+type servedKey struct{}
+
+// withServedContext returns a copy of ctx holding where the authenticator
+// leaves the context it serves the call with, starting out as ctx itself
+func withServedContext(ctx context.Context) (context.Context, *context.Context) {
+	served := new(context.Context)
+	ctx = context.WithValue(ctx, servedKey{}, served)
+	*served = ctx
+	return ctx, served
+}
+
+// setServedContext records ctx as the context the call is served with
+func setServedContext(ctx context.Context) {
+	if served, ok := ctx.Value(servedKey{}).(*context.Context); ok {
+		*served = ctx
+	}
+}
+
+// logCall logs a call at error level when it fails with an internal error,
+// warning level for other errors and info level otherwise
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(statusFromError(err))
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.Internal, codes.Unknown:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Float64("duration_ms", float64(time.Since(start))/float64(time.Millisecond)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.Default().LogAttrs(ctx, level, "gRPC call", attrs...)
+}
+
+// statusFromError maps errors returned by the services onto gRPC status
+// codes. Errors that already carry a status are passed through, and anything
+// unexpected becomes a generic Internal error so details don't leak.
+func statusFromError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "not found")
+	case errors.Is(err, services.ErrEmailTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, policy.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, "authentication required")
+	case errors.Is(err, policy.ErrForbidden):
+		return status.Error(codes.PermissionDenied, "not allowed to perform this action")
+	case errors.Is(err, services.ErrInvalidQueryOption):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// validateInput normalizes and validates input, reporting every invalid field
+// as a BadRequest field violation on an InvalidArgument status.
+func validateInput(input interface{}) error {
+	err := validation.Struct(input)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validation.Errors
+	if !errors.As(err, &fieldErrs) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		violations[i] = &errdetails.BadRequest_FieldViolation{Field: fieldErr.Field, Description: fieldErr.Message}
+	}
+	st, detailErr := status.New(codes.InvalidArgument, "Validation failed").WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if detailErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
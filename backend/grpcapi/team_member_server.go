@@ -0,0 +1,48 @@
+package grpcapi
+
+import (
+	"context"
+
+	"coaching-app-backend/policy"
+	coachingv1 "coaching-app-backend/proto/coaching/v1"
+	"coaching-app-backend/services"
+)
+
+type teamMemberServer struct {
+	coachingv1.UnimplementedTeamMemberServiceServer
+	service *services.TeamMemberService
+	policy  *policy.Authorizer
+}
+
+func (s *teamMemberServer) CreateTeamMember(ctx context.Context, req *coachingv1.CreateTeamMemberRequest) (*coachingv1.TeamMember, error) {
+	if err := s.policy.Authorize(ctx, policy.CreateMember, policy.Resource{}); err != nil {
+		return nil, err
+	}
+
+	input := services.MemberInput{Name: req.GetName(), Email: req.GetEmail(), Picture: req.GetPicture()}
+	if err := validateInput(&input); err != nil {
+		return nil, err
+	}
+
+	member := input.TeamMember()
+	if err := s.service.WithContext(ctx).CreateTeamMember(&member); err != nil {
+		return nil, err
+	}
+	return toTeamMember(&member), nil
+}
+
+func (s *teamMemberServer) GetTeamMember(ctx context.Context, req *coachingv1.GetTeamMemberRequest) (*coachingv1.TeamMember, error) {
+	member, err := s.service.WithContext(ctx).GetTeamMemberByID(uint(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return toTeamMember(member), nil
+}
+
+func (s *teamMemberServer) ListTeamMembers(ctx context.Context, req *coachingv1.ListTeamMembersRequest) (*coachingv1.ListTeamMembersResponse, error) {
+	members, err := s.service.WithContext(ctx).GetAllTeamMembers()
+	if err != nil {
+		return nil, err
+	}
+	return &coachingv1.ListTeamMembersResponse{Members: toTeamMembers(members)}, nil
+}
@@ -0,0 +1,66 @@
+package grpcapi
+
+import (
+	"context"
+
+	"coaching-app-backend/policy"
+	coachingv1 "coaching-app-backend/proto/coaching/v1"
+	"coaching-app-backend/services"
+)
+
+type teamServer struct {
+	coachingv1.UnimplementedTeamServiceServer
+	service *services.TeamService
+	policy  *policy.Authorizer
+}
+
+func (s *teamServer) CreateTeam(ctx context.Context, req *coachingv1.CreateTeamRequest) (*coachingv1.Team, error) {
+	if err := s.policy.Authorize(ctx, policy.CreateTeam, policy.Resource{}); err != nil {
+		return nil, err
+	}
+
+	input := services.TeamInput{Name: req.GetName(), Logo: req.GetLogo()}
+	if err := validateInput(&input); err != nil {
+		return nil, err
+	}
+
+	team := input.Team()
+	if err := s.service.WithContext(ctx).CreateTeam(&team); err != nil {
+		return nil, err
+	}
+	return toTeam(&team), nil
+}
+
+func (s *teamServer) GetTeam(ctx context.Context, req *coachingv1.GetTeamRequest) (*coachingv1.Team, error) {
+	team, err := s.service.WithContext(ctx).GetTeamByID(uint(req.GetId()))
+	if err != nil {
+		return nil, err
+	}
+	return toTeam(team), nil
+}
+
+func (s *teamServer) ListTeams(ctx context.Context, req *coachingv1.ListTeamsRequest) (*coachingv1.ListTeamsResponse, error) {
+	teams, err := s.service.WithContext(ctx).GetAllTeams()
+	if err != nil {
+		return nil, err
+	}
+	return &coachingv1.ListTeamsResponse{Teams: toTeams(teams)}, nil
+}
+
+func (s *teamServer) GetTeamMembers(ctx context.Context, req *coachingv1.GetTeamMembersRequest) (*coachingv1.GetTeamMembersResponse, error) {
+	members, err := s.service.WithContext(ctx).GetTeamMembers(uint(req.GetTeamId()))
+	if err != nil {
+		return nil, err
+	}
+	return &coachingv1.GetTeamMembersResponse{Members: toTeamMembers(members)}, nil
+}
+
+func (s *teamServer) DeleteTeam(ctx context.Context, req *coachingv1.DeleteTeamRequest) (*coachingv1.DeleteTeamResponse, error) {
+	if err := s.policy.Authorize(ctx, policy.DeleteTeam, policy.Resource{TeamID: uint(req.GetId())}); err != nil {
+		return nil, err
+	}
+	if err := s.service.WithContext(ctx).DeleteTeam(uint(req.GetId())); err != nil {
+		return nil, err
+	}
+	return &coachingv1.DeleteTeamResponse{}, nil
+}
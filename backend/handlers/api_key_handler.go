@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/policy"
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type APIKeyHandler struct {
+	service *services.APIKeyService
+	policy  *policy.Authorizer
+}
+
+func NewAPIKeyHandler(db *gorm.DB) *APIKeyHandler {
+	return &APIKeyHandler{
+		service: services.NewAPIKeyService(db),
+		policy:  policy.NewAuthorizer(db),
+	}
+}
+
+// CreateAPIKey responds with the new key. It is not stored and cannot be
+// shown again.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ManageAPIKeys, policy.Resource{}) {
+		return
+	}
+
+	var input services.APIKeyInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	principal, _ := auth.FromContext(c.Request.Context())
+	created, err := h.service.WithContext(c.Request.Context()).CreateAPIKey(input, principal.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyExpiry) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry must be in the future"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+func (h *APIKeyHandler) GetAllAPIKeys(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ManageAPIKeys, policy.Resource{}) {
+		return
+	}
+
+	keys, err := h.service.WithContext(c.Request.Context()).GetAllAPIKeys()
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.ManageAPIKeys, policy.Resource{}) {
+		return
+	}
+
+	if err := h.service.WithContext(c.Request.Context()).RevokeAPIKey(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func SetupAPIKeyRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	handler := NewAPIKeyHandler(db)
+
+	api.POST("/api-keys", handler.CreateAPIKey)
+	api.GET("/api-keys", handler.GetAllAPIKeys)
+	api.DELETE("/api-keys/:id", handler.RevokeAPIKey)
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"net/http"
 
+	"coaching-app-backend/middleware"
+	"coaching-app-backend/policy"
 	"coaching-app-backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +13,7 @@ import (
 
 type AssignmentHandler struct {
 	service *services.AssignmentService
+	policy  *policy.Authorizer
 }
 
 type AssignmentRequest struct {
@@ -21,17 +24,25 @@ type AssignmentRequest struct {
 func NewAssignmentHandler(db *gorm.DB) *AssignmentHandler {
 	return &AssignmentHandler{
 		service: services.NewAssignmentService(db),
+		policy:  policy.NewAuthorizer(db),
 	}
 }
 
 func (h *AssignmentHandler) AssignMemberToTeam(c *gin.Context) {
 	var req AssignmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.service.AssignMemberToTeam(req.TeamID, req.TeamMemberID); err != nil {
+	if !authorize(c, h.policy, policy.ManageAssignments, policy.Resource{TeamID: req.TeamID}) {
+		return
+	}
+
+	if err := h.service.WithContext(c.Request.Context()).AssignMemberToTeam(req.TeamID, req.TeamMemberID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to assign member to team"})
 		return
 	}
@@ -40,8 +51,13 @@ func (h *AssignmentHandler) AssignMemberToTeam(c *gin.Context) {
 }
 
 func (h *AssignmentHandler) GetAllAssignments(c *gin.Context) {
-	assignments, err := h.service.GetAllAssignments()
+	if !authorize(c, h.policy, policy.ReadAssignments, policy.Resource{}) {
+		return
+	}
+
+	assignments, err := h.service.WithContext(c.Request.Context()).GetAllAssignments()
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve assignments"})
 		return
 	}
@@ -52,11 +68,18 @@ func (h *AssignmentHandler) GetAllAssignments(c *gin.Context) {
 func (h *AssignmentHandler) RemoveMemberFromTeam(c *gin.Context) {
 	var req AssignmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.service.RemoveMemberFromTeam(req.TeamID, req.TeamMemberID); err != nil {
+	if !authorize(c, h.policy, policy.ManageAssignments, policy.Resource{TeamID: req.TeamID}) {
+		return
+	}
+
+	if err := h.service.WithContext(c.Request.Context()).RemoveMemberFromTeam(req.TeamID, req.TeamMemberID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to remove member from team"})
 		return
 	}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+
+	"coaching-app-backend/policy"
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AuditHandler struct {
+	service *services.AuditService
+	policy  *policy.Authorizer
+}
+
+func NewAuditHandler(db *gorm.DB) *AuditHandler {
+	return &AuditHandler{
+		service: services.NewAuditService(db),
+		policy:  policy.NewAuthorizer(db),
+	}
+}
+
+// GetEvents lists audit events, newest first, a page at a time
+func (h *AuditHandler) GetEvents(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ReadAuditLog, policy.Resource{}) {
+		return
+	}
+
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := queryInt(c, "page", 1)
+	if !ok {
+		return
+	}
+	perPage, ok := queryInt(c, "per_page", services.DefaultAuditPageSize)
+	if !ok {
+		return
+	}
+
+	events, err := h.service.WithContext(c.Request.Context()).FindEvents(filter, page, perPage)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// ExportEvents downloads every matching audit event as CSV
+func (h *AuditHandler) ExportEvents(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ReadAuditLog, policy.Resource{}) {
+		return
+	}
+
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	sendCSV(c, "audit", func(buf *bytes.Buffer) error {
+		return h.service.WithContext(c.Request.Context()).ExportEvents(buf, filter, splitList(c.Query("fields")))
+	})
+}
+
+// parseAuditFilter reads the audit filter query parameters, writing a 400
+// response and returning false when they are invalid.
+func parseAuditFilter(c *gin.Context) (services.AuditFilter, bool) {
+	filter := services.AuditFilter{
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		RequestID:  c.Query("request_id"),
+	}
+
+	ids := []struct {
+		param string
+		value *uint
+	}{
+		{"entity_id", &filter.EntityID},
+		{"actor_user_id", &filter.ActorUserID},
+		{"actor_api_key_id", &filter.ActorAPIKeyID},
+	}
+	for _, id := range ids {
+		idStr := c.Query(id.param)
+		if idStr == "" {
+			continue
+		}
+		parsed, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + id.param})
+			return filter, false
+		}
+		*id.value = uint(parsed)
+	}
+
+	times := []struct {
+		param string
+		value *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	}
+	for _, t := range times {
+		value := c.Query(t.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "'" + t.param + "' must be an RFC 3339 timestamp"})
+			return filter, false
+		}
+		*t.value = parsed
+	}
+
+	return filter, true
+}
+
+// queryInt reads a positive integer query parameter, writing a 400 response
+// and returning false when it is invalid.
+func queryInt(c *gin.Context, name string, fallback int) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, true
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'" + name + "' must be a positive integer"})
+		return 0, false
+	}
+	return parsed, true
+}
+
+func SetupAuditRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	handler := NewAuditHandler(db)
+
+	api.GET("/audit", handler.GetEvents)
+	api.GET("/audit/export", handler.ExportEvents)
+}
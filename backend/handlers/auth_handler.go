@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/policy"
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AuthHandler struct {
+	service *services.AuthService
+	policy  *policy.Authorizer
+}
+
+type LinkTeamMemberRequest struct {
+	TeamMemberID *uint `json:"team_member_id"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role" normalize:"trim,lower" validate:"required,oneof=admin coach team_lead member"`
+}
+
+func NewAuthHandler(db *gorm.DB, tokens *auth.TokenIssuer) *AuthHandler {
+	return &AuthHandler{
+		service: services.NewAuthService(db, tokens),
+		policy:  policy.NewAuthorizer(db),
+	}
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
+	var input services.LoginInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	pair, err := h.service.WithContext(c.Request.Context()).Login(input)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var input services.RefreshInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	pair, err := h.service.WithContext(c.Request.Context()).Refresh(input)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var input services.RefreshInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	if err := h.service.WithContext(c.Request.Context()).Logout(input); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
+	principal, ok := auth.FromContext(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	user, err := h.service.WithContext(c.Request.Context()).GetUser(principal.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AuthHandler) CreateUser(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ManageUsers, policy.Resource{}) {
+		return
+	}
+
+	var input services.UserInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	user, err := h.service.WithContext(c.Request.Context()).CreateUser(input)
+	if err != nil {
+		h.userError(c, err, "Failed to create user")
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// LinkTeamMember links a user to a team member, or unlinks it when
+// team_member_id is null.
+func (h *AuthHandler) LinkTeamMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.ManageUsers, policy.Resource{}) {
+		return
+	}
+
+	var req LinkTeamMemberRequest
+	if !bindInput(c, &req) {
+		return
+	}
+
+	user, err := h.service.WithContext(c.Request.Context()).LinkTeamMember(uint(id), req.TeamMemberID)
+	if err != nil {
+		h.userError(c, err, "Failed to link team member")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AuthHandler) SetRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.ManageUsers, policy.Resource{}) {
+		return
+	}
+
+	var req SetRoleRequest
+	if !bindInput(c, &req) {
+		return
+	}
+
+	user, err := h.service.WithContext(c.Request.Context()).SetRole(uint(id), req.Role)
+	if err != nil {
+		h.userError(c, err, "Failed to change role")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AuthHandler) userError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A user with this email already exists"})
+	case errors.Is(err, services.ErrTeamMemberLinked):
+		c.JSON(http.StatusConflict, gin.H{"error": "Team member is already linked to another user"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User or team member not found"})
+	default:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// SetupAuthRoutes registers the sign-in endpoints, which must be reachable
+// without an access token.
+func SetupAuthRoutes(group *gin.RouterGroup, db *gorm.DB, tokens *auth.TokenIssuer) {
+	handler := NewAuthHandler(db, tokens)
+
+	group.POST("/login", handler.Login)
+	group.POST("/refresh", handler.Refresh)
+	group.POST("/logout", handler.Logout)
+}
+
+func SetupUserRoutes(api *gin.RouterGroup, db *gorm.DB, tokens *auth.TokenIssuer) {
+	handler := NewAuthHandler(db, tokens)
+
+	api.GET("/users/me", handler.GetCurrentUser)
+	api.POST("/users", handler.CreateUser)
+	api.PUT("/users/:id/team-member", handler.LinkTeamMember)
+	api.PUT("/users/:id/role", handler.SetRole)
+}
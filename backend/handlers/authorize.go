@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"coaching-app-backend/policy"
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorize checks the caller may perform action on resource. It writes a
+// 401, 403 or 500 response and returns false when they may not.
+func authorize(c *gin.Context, authz *policy.Authorizer, action policy.Action, resource policy.Resource) bool {
+	return authorizationResult(c, authz.Authorize(c.Request.Context(), action, resource))
+}
+
+func authorizationResult(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, policy.ErrUnauthenticated):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+	case errors.Is(err, policy.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to perform this action"})
+	default:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+	}
+	return false
+}
+
+// authorizeIncludes checks a read action and, when the query includes
+// feedback or feedback counts, that the caller may read all feedback. Other
+// callers read feedback through the feedback endpoints, which filter it.
+func authorizeIncludes(c *gin.Context, authz *policy.Authorizer, action policy.Action, opts services.QueryOptions) bool {
+	if !authorize(c, authz, action, policy.Resource{}) {
+		return false
+	}
+	for _, include := range opts.Include {
+		if include == "feedback" || include == "feedback_count" || strings.HasSuffix(include, ".feedback") || strings.HasSuffix(include, ".feedback_count") {
+			return authorize(c, authz, policy.ReadAllFeedback, policy.Resource{})
+		}
+	}
+	return true
+}
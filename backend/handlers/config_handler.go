@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"coaching-app-backend/config"
+	"coaching-app-backend/policy"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ConfigHandler struct {
+	settings []config.Setting
+	policy   *policy.Authorizer
+}
+
+func NewConfigHandler(db *gorm.DB, cfg *config.Config) *ConfigHandler {
+	return &ConfigHandler{
+		settings: cfg.Settings(),
+		policy:   policy.NewAuthorizer(db),
+	}
+}
+
+// GetConfig lists the settings in use and where each came from, with
+// secrets redacted
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ReadConfig, policy.Resource{}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": h.settings})
+}
+
+func SetupConfigRoutes(group *gin.RouterGroup, db *gorm.DB, cfg *config.Config) {
+	handler := NewConfigHandler(db, cfg)
+
+	group.GET("/config", handler.GetConfig)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"coaching-app-backend/middleware"
+	"coaching-app-backend/policy"
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CSVHandler struct {
+	service *services.CSVService
+	policy  *policy.Authorizer
+}
+
+func NewCSVHandler(db *gorm.DB) *CSVHandler {
+	return &CSVHandler{
+		service: services.NewCSVService(db),
+		policy:  policy.NewAuthorizer(db),
+	}
+}
+
+func (h *CSVHandler) ExportMembers(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ReadMembers, policy.Resource{}) {
+		return
+	}
+
+	var teamID uint
+	if idStr := c.Query("team_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+			return
+		}
+		teamID = uint(id)
+	}
+
+	sendCSV(c, "members", func(buf *bytes.Buffer) error {
+		return h.service.WithContext(c.Request.Context()).ExportMembers(buf, teamID, splitList(c.Query("fields")))
+	})
+}
+
+func (h *CSVHandler) ExportTeams(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ReadTeams, policy.Resource{}) {
+		return
+	}
+
+	sendCSV(c, "teams", func(buf *bytes.Buffer) error {
+		return h.service.WithContext(c.Request.Context()).ExportTeams(buf, splitList(c.Query("fields")))
+	})
+}
+
+func (h *CSVHandler) ExportFeedback(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ReadAllFeedback, policy.Resource{}) {
+		return
+	}
+
+	filter, ok := parseFeedbackFilter(c)
+	if !ok {
+		return
+	}
+
+	sendCSV(c, "feedback", func(buf *bytes.Buffer) error {
+		return h.service.WithContext(c.Request.Context()).ExportFeedback(buf, filter, splitList(c.Query("fields")))
+	})
+}
+
+// PreviewMemberImport validates an uploaded roster and reports what importing
+// it would do, without saving anything.
+func (h *CSVHandler) PreviewMemberImport(c *gin.Context) {
+	h.importMembers(c, true)
+}
+
+// CommitMemberImport imports an uploaded roster
+func (h *CSVHandler) CommitMemberImport(c *gin.Context) {
+	h.importMembers(c, false)
+}
+
+func (h *CSVHandler) importMembers(c *gin.Context, dryRun bool) {
+	if !authorize(c, h.policy, policy.ImportMembers, policy.Resource{}) {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV file is required in the 'file' form field"})
+		return
+	}
+
+	mapping := map[string]string{}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mapping must be a JSON object of column names"})
+			return
+		}
+	}
+
+	mode := services.BatchMode(c.DefaultPostForm("mode", string(services.BatchTransactional)))
+	if mode != services.BatchTransactional && mode != services.BatchBestEffort {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be 'transactional' or 'best_effort'"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	result, err := h.service.WithContext(c.Request.Context()).ImportMembers(file, mapping, mode, dryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCSV):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrBatchAborted):
+			c.JSON(http.StatusUnprocessableEntity, result)
+		default:
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import members"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// sendCSV renders the export into memory first so that failures can still
+// be reported as a JSON error instead of a truncated file.
+func sendCSV(c *gin.Context, name string, export func(buf *bytes.Buffer) error) {
+	var buf bytes.Buffer
+	if err := export(&buf); err != nil {
+		if errors.Is(err, services.ErrInvalidQueryOption) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Only exports of a team's members look up a record
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export " + name})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.csv", name, time.Now().UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+func SetupCSVRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	handler := NewCSVHandler(db)
+
+	api.GET("/export/members", handler.ExportMembers)
+	api.GET("/export/teams", handler.ExportTeams)
+	api.GET("/export/feedback", handler.ExportFeedback)
+	api.POST("/import/members/preview", handler.PreviewMemberImport)
+	api.POST("/import/members/commit", handler.CommitMemberImport)
+}
@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"coaching-app-backend/auth"
 	"coaching-app-backend/models"
+	"coaching-app-backend/policy"
 	"coaching-app-backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -13,32 +15,33 @@ import (
 
 type FeedbackHandler struct {
 	service *services.FeedbackService
+	policy  *policy.Authorizer
 }
 
 func NewFeedbackHandler(db *gorm.DB) *FeedbackHandler {
 	return &FeedbackHandler{
 		service: services.NewFeedbackService(db),
+		policy:  policy.NewAuthorizer(db),
 	}
 }
 
 func (h *FeedbackHandler) CreateFeedback(c *gin.Context) {
-	var feedback models.Feedback
-	if err := c.ShouldBindJSON(&feedback); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !authorize(c, h.policy, policy.CreateFeedback, policy.Resource{}) {
 		return
 	}
 
-	if feedback.Content == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Feedback content is required"})
+	var input services.FeedbackInput
+	if !bindInput(c, &input) {
 		return
 	}
 
-	if feedback.TargetType != "team" && feedback.TargetType != "member" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Target type must be 'team' or 'member'"})
-		return
+	// Only admins may record feedback on someone else's behalf
+	if principal, _ := auth.FromContext(c.Request.Context()); principal.Role != models.RoleAdmin {
+		input.AuthorID = principal.TeamMemberID
 	}
 
-	if err := h.service.CreateFeedback(&feedback); err != nil {
+	feedback := input.Feedback()
+	if err := h.service.WithContext(c.Request.Context()).CreateFeedback(&feedback); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID or failed to create feedback"})
 		return
 	}
@@ -47,13 +50,19 @@ func (h *FeedbackHandler) CreateFeedback(c *gin.Context) {
 }
 
 func (h *FeedbackHandler) GetAllFeedback(c *gin.Context) {
-	feedback, err := h.service.GetAllFeedback()
+	filter, ok := parseFeedbackFilter(c)
+	if !ok {
+		return
+	}
+
+	feedback, err := h.service.WithContext(c.Request.Context()).FindFeedback(filter)
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feedback"})
 		return
 	}
 
-	c.JSON(http.StatusOK, feedback)
+	h.sendVisible(c, feedback)
 }
 
 func (h *FeedbackHandler) GetFeedbackForTeam(c *gin.Context) {
@@ -64,13 +73,14 @@ func (h *FeedbackHandler) GetFeedbackForTeam(c *gin.Context) {
 		return
 	}
 
-	feedback, err := h.service.GetFeedbackByTarget("team", uint(id))
+	feedback, err := h.service.WithContext(c.Request.Context()).GetFeedbackByTarget("team", uint(id))
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feedback"})
 		return
 	}
 
-	c.JSON(http.StatusOK, feedback)
+	h.sendVisible(c, feedback)
 }
 
 func (h *FeedbackHandler) GetFeedbackForMember(c *gin.Context) {
@@ -81,13 +91,47 @@ func (h *FeedbackHandler) GetFeedbackForMember(c *gin.Context) {
 		return
 	}
 
-	feedback, err := h.service.GetFeedbackByTarget("member", uint(id))
+	feedback, err := h.service.WithContext(c.Request.Context()).GetFeedbackByTarget("member", uint(id))
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feedback"})
 		return
 	}
 
-	c.JSON(http.StatusOK, feedback)
+	h.sendVisible(c, feedback)
+}
+
+// sendVisible responds with the feedback the caller is allowed to read
+func (h *FeedbackHandler) sendVisible(c *gin.Context, feedback []models.Feedback) {
+	visible, err := h.policy.FilterFeedback(c.Request.Context(), feedback)
+	if !authorizationResult(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// parseFeedbackFilter reads the target_type and target_id query parameters,
+// writing a 400 response and returning false when they are invalid.
+func parseFeedbackFilter(c *gin.Context) (services.FeedbackFilter, bool) {
+	var filter services.FeedbackFilter
+
+	filter.TargetType = c.Query("target_type")
+	if filter.TargetType != "" && filter.TargetType != "team" && filter.TargetType != "member" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Target type must be 'team' or 'member'"})
+		return filter, false
+	}
+
+	if idStr := c.Query("target_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+			return filter, false
+		}
+		filter.TargetID = uint(id)
+	}
+
+	return filter, true
 }
 
 func SetupFeedbackRoutes(api *gin.RouterGroup, db *gorm.DB) {
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"coaching-app-backend/gql"
+	"coaching-app-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type GraphQLHandler struct {
+	server *gql.Server
+}
+
+func NewGraphQLHandler(db *gorm.DB) (*GraphQLHandler, error) {
+	server, err := gql.NewServer(db, gql.DefaultLimits)
+	if err != nil {
+		return nil, err
+	}
+	return &GraphQLHandler{server: server}, nil
+}
+
+// Query executes a GraphQL request. As with other GraphQL servers, errors in
+// a well-formed request are reported in the "errors" field of a 200 response.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req gql.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query is required"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.server.Execute(c.Request.Context(), req))
+}
+
+func SetupGraphQLRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	handler, err := NewGraphQLHandler(db)
+	if err != nil {
+		log.Fatal("Failed to build GraphQL schema:", err)
+	}
+
+	api.POST("/graphql", handler.Query)
+}
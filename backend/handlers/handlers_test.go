@@ -2,13 +2,25 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	"coaching-app-backend/auth"
+	"coaching-app-backend/config"
+	"coaching-app-backend/middleware"
 	"coaching-app-backend/models"
+	"coaching-app-backend/oidc"
+	"coaching-app-backend/oidc/oidctest"
+	"coaching-app-backend/services"
+	"coaching-app-backend/tenant"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/sqlite"
@@ -17,17 +29,33 @@ import (
 
 func setupTestDB() *gorm.DB {
 	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	db.AutoMigrate(&models.TeamMember{}, &models.Team{}, &models.TeamAssignment{}, &models.Feedback{})
+	tenant.Register(db)
+	db.AutoMigrate(&models.Organization{}, &models.TeamMember{}, &models.Team{}, &models.TeamAssignment{}, &models.Feedback{}, &models.AuditEvent{}, &models.User{}, &models.RefreshToken{}, &models.TeamCoach{}, &models.APIKey{}, &models.OIDCLoginState{}, &models.ErasureRecord{}, &models.IdempotencyKey{})
 	return db
 }
 
+// newTestRouter returns a router whose requests are made by an admin
+func newTestRouter() *gin.Engine {
+	return newTestRouterAs(auth.Principal{UserID: 1, Email: "admin@example.com", Role: models.RoleAdmin})
+}
+
+func newTestRouterAs(principal auth.Principal) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(middleware.PrincipalKey, principal)
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	})
+	return router
+}
+
 // TeamMember Handler Tests
 func TestCreateTeamMember(t *testing.T) {
 	db := setupTestDB()
 	handler := NewTeamMemberHandler(db)
 
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	router := newTestRouter()
 	router.POST("/team-members", handler.CreateTeamMember)
 
 	tests := []struct {
@@ -40,10 +68,18 @@ func TestCreateTeamMember(t *testing.T) {
 			member: models.TeamMember{
 				Name:    "John Doe",
 				Email:   "john@example.com",
-				Picture: "profile.jpg",
+				Picture: "https://example.com/profile.jpg",
 			},
 			expectedStatus: http.StatusCreated,
 		},
+		{
+			name: "Duplicate email with different case",
+			member: models.TeamMember{
+				Name:  "John Doe",
+				Email: "John@Example.com",
+			},
+			expectedStatus: http.StatusConflict,
+		},
 		{
 			name: "Missing name",
 			member: models.TeamMember{
@@ -76,6 +112,49 @@ func TestCreateTeamMember(t *testing.T) {
 	}
 }
 
+func TestCreateTeamMemberValidation(t *testing.T) {
+	db := setupTestDB()
+	handler := NewTeamMemberHandler(db)
+
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter()
+	router.POST("/team-members", handler.CreateTeamMember)
+
+	body := fmt.Sprintf(`{"name": "%s", "email": "not-an-email", "picture": "ftp://example.com/a.png"}`, strings.Repeat("a", 256))
+	req, _ := http.NewRequest("POST", "/team-members", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	var response ValidationErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	rules := map[string]string{}
+	for _, field := range response.Fields {
+		rules[field.Field] = field.Rule
+	}
+	expected := map[string]string{"name": "max", "email": "email", "picture": "http_url"}
+	for field, rule := range expected {
+		if rules[field] != rule {
+			t.Errorf("Expected %s to fail %s, got %v", field, rule, response.Fields)
+		}
+	}
+
+	req, _ = http.NewRequest("POST", "/team-members", strings.NewReader(`{"name": "  Jane Doe ", "email": " Jane@Example.COM "}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var member models.TeamMember
+	json.Unmarshal(w.Body.Bytes(), &member)
+	if w.Code != http.StatusCreated || member.Name != "Jane Doe" || member.Email != "jane@example.com" {
+		t.Errorf("Expected a normalized member, got %d %+v", w.Code, member)
+	}
+}
+
 func TestGetAllTeamMembers(t *testing.T) {
 	db := setupTestDB()
 	handler := NewTeamMemberHandler(db)
@@ -87,7 +166,7 @@ func TestGetAllTeamMembers(t *testing.T) {
 	db.Create(&member2)
 
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	router := newTestRouter()
 	router.GET("/team-members", handler.GetAllTeamMembers)
 
 	req, _ := http.NewRequest("GET", "/team-members", nil)
@@ -115,7 +194,7 @@ func TestGetTeamMemberByID(t *testing.T) {
 	db.Create(&member)
 
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	router := newTestRouter()
 	router.GET("/team-members/:id", handler.GetTeamMemberByID)
 
 	tests := []struct {
@@ -153,13 +232,112 @@ func TestGetTeamMemberByID(t *testing.T) {
 	}
 }
 
+func TestBatchUpsertTeamMembers(t *testing.T) {
+	db := setupTestDB()
+	handler := NewTeamMemberHandler(db)
+
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter()
+	router.POST("/team-members/batch", handler.BatchUpsertTeamMembers)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Best effort with one invalid item",
+			body:           `{"mode":"best_effort","members":[{"name":"John Doe","email":"john@example.com"},{"name":"No Email"}]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Transactional with one invalid item",
+			body:           `{"members":[{"name":"Jane Doe","email":"jane@example.com"},{"email":"nobody@example.com"}]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Unknown mode",
+			body:           `{"mode":"sometimes","members":[]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/team-members/batch", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
 // Team Handler Tests
+func TestGetAllTeamsIncludesAndFields(t *testing.T) {
+	db := setupTestDB()
+	handler := NewTeamHandler(db)
+
+	team := models.Team{Name: "Dev Team", Logo: "logo.png"}
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	db.Create(&team)
+	db.Create(&member)
+	db.Model(&team).Association("Members").Append(&member)
+
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter()
+	router.GET("/teams", handler.GetAllTeams)
+
+	req, _ := http.NewRequest("GET", "/teams?include=members,feedback_count&fields=id,name", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var teams []map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &teams)
+
+	if len(teams) != 1 {
+		t.Fatalf("Expected 1 team, got %d", len(teams))
+	}
+	for _, key := range []string{"id", "name", "members", "feedback_count"} {
+		if _, ok := teams[0][key]; !ok {
+			t.Errorf("Expected key %q in response", key)
+		}
+	}
+	if _, ok := teams[0]["logo"]; ok {
+		t.Error("Expected logo to be omitted from sparse response")
+	}
+
+	req, _ = http.NewRequest("GET", "/teams?include=members.teams.members", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for over-deep include, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/teams?include=members.feedback", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `requires include \"members\"`) {
+		t.Errorf("Expected status %d for a nested include without its parent, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
 func TestCreateTeam(t *testing.T) {
 	db := setupTestDB()
 	handler := NewTeamHandler(db)
 
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	router := newTestRouter()
 	router.POST("/teams", handler.CreateTeam)
 
 	tests := []struct {
@@ -171,13 +349,21 @@ func TestCreateTeam(t *testing.T) {
 			name: "Valid team",
 			team: models.Team{
 				Name: "Development Team",
-				Logo: "team-logo.png",
+				Logo: "https://example.com/team-logo.png",
 			},
 			expectedStatus: http.StatusCreated,
 		},
 		{
 			name: "Missing name",
 			team: models.Team{
+				Logo: "https://example.com/team-logo.png",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Logo is not a URL",
+			team: models.Team{
+				Name: "Development Team",
 				Logo: "team-logo.png",
 			},
 			expectedStatus: http.StatusBadRequest,
@@ -212,7 +398,7 @@ func TestCreateFeedback(t *testing.T) {
 	db.Create(&member)
 
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	router := newTestRouter()
 	router.POST("/feedback", handler.CreateFeedback)
 
 	tests := []struct {
@@ -276,7 +462,7 @@ func TestAssignMemberToTeam(t *testing.T) {
 	db.Create(&member)
 
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	router := newTestRouter()
 	router.POST("/assignments", handler.AssignMemberToTeam)
 
 	assignment := map[string]uint{
@@ -301,7 +487,7 @@ func TestInvalidJSONRequest(t *testing.T) {
 	handler := NewTeamMemberHandler(db)
 
 	gin.SetMode(gin.TestMode)
-	router := gin.New()
+	router := newTestRouter()
 	router.POST("/team-members", handler.CreateTeamMember)
 
 	req, _ := http.NewRequest("POST", "/team-members", bytes.NewBuffer([]byte("invalid json")))
@@ -314,3 +500,872 @@ func TestInvalidJSONRequest(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 }
+
+// CSV Handler Tests
+func TestCSVImportPreview(t *testing.T) {
+	db := setupTestDB()
+	handler := NewCSVHandler(db)
+
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter()
+	router.POST("/import/members/preview", handler.PreviewMemberImport)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "roster.csv")
+	part.Write([]byte("name,email\nJohn Doe,john@example.com\n"))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/import/members/preview", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var count int64
+	db.Model(&models.TeamMember{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected preview not to create members, got %d", count)
+	}
+}
+
+func TestCSVExportMembers(t *testing.T) {
+	db := setupTestDB()
+	handler := NewCSVHandler(db)
+	team := models.Team{Name: "Dev Team"}
+	db.Create(&team)
+
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter()
+	router.GET("/export/members", handler.ExportMembers)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{"All members", "", http.StatusOK},
+		{"Members of a team", fmt.Sprintf("?team_id=%d", team.ID), http.StatusOK},
+		{"Unknown team", "?team_id=999", http.StatusNotFound},
+		{"Invalid team ID", "?team_id=abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/export/members"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCSVExportFeedback(t *testing.T) {
+	db := setupTestDB()
+	handler := NewCSVHandler(db)
+
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter()
+	router.GET("/export/feedback", handler.ExportFeedback)
+
+	req, _ := http.NewRequest("GET", "/export/feedback?target_type=member", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Errorf("Expected CSV content type, got %q", contentType)
+	}
+
+	req, _ = http.NewRequest("GET", "/export/feedback?target_type=project", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid target type, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestMergeTeamMembers(t *testing.T) {
+	db := setupTestDB()
+	target := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	source := models.TeamMember{Name: "Jon Doe", Email: "jon@example.com"}
+	db.Create(&target)
+	db.Create(&source)
+
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter()
+	SetupTeamMemberRoutes(router.Group("/api"), db)
+
+	req, _ := http.NewRequest("GET", "/api/team-members/duplicates", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "similar_name") {
+		t.Errorf("Expected a duplicate candidate, got %d %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"Missing source", fmt.Sprintf("/api/team-members/%d/merge", target.ID), `{}`, http.StatusBadRequest},
+		{"Merge into itself", fmt.Sprintf("/api/team-members/%d/merge", target.ID), fmt.Sprintf(`{"source_id": %d}`, target.ID), http.StatusBadRequest},
+		{"Unknown target", "/api/team-members/999/merge", fmt.Sprintf(`{"source_id": %d}`, source.ID), http.StatusNotFound},
+		{"Valid merge", fmt.Sprintf("/api/team-members/%d/merge", target.ID), fmt.Sprintf(`{"source_id": %d}`, source.ID), http.StatusOK},
+		{"Source already merged", fmt.Sprintf("/api/team-members/%d/merge", target.ID), fmt.Sprintf(`{"source_id": %d}`, source.ID), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthRoutes(t *testing.T) {
+	db := setupTestDB()
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+	services.NewAuthService(db, tokens).CreateUser(services.UserInput{Email: "coach@example.com", Password: "coach-password", Role: models.RoleAdmin})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupAuthRoutes(router.Group("/api/auth"), db, tokens)
+	api := router.Group("/api")
+	api.Use(middleware.Authenticate(tokens, nil))
+	SetupUserRoutes(api, db, tokens)
+	SetupTeamRoutes(api, db)
+
+	send := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("DELETE", "/api/teams/1", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", w.Code)
+	}
+	if w := send("POST", "/api/auth/login", "", `{"email": "coach@example.com", "password": "wrong"}`); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong password, got %d", w.Code)
+	}
+
+	w := send("POST", "/api/auth/login", "", `{"email": " Coach@Example.com ", "password": "coach-password"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected login to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var pair services.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &pair)
+
+	w = send("GET", "/api/users/me", pair.AccessToken, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "coach@example.com") {
+		t.Errorf("Expected the current user, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "password") {
+		t.Error("Expected the password hash not to be exposed")
+	}
+
+	w = send("POST", "/api/auth/refresh", "", fmt.Sprintf(`{"refresh_token": %q}`, pair.RefreshToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected refresh to succeed, got %d", w.Code)
+	}
+	if w := send("POST", "/api/auth/refresh", "", fmt.Sprintf(`{"refresh_token": %q}`, pair.RefreshToken)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a reused refresh token to be rejected, got %d", w.Code)
+	}
+
+	if w := send("POST", "/api/users", pair.AccessToken, `{"email": "lead@example.com", "password": "short"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a short password to be rejected, got %d", w.Code)
+	}
+	if w := send("POST", "/api/users", pair.AccessToken, `{"email": "lead@example.com", "password": "lead-password"}`); w.Code != http.StatusCreated {
+		t.Errorf("Expected user to be created, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRefreshInOtherOrganization(t *testing.T) {
+	db := setupTestDB()
+	db.Create(&models.Organization{ID: models.DefaultOrganizationID, Name: "Default", Slug: "default"})
+	db.Create(&models.Organization{ID: 2, Name: "Other", Slug: "other"})
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+	users := services.NewAuthService(db, tokens).WithContext(tenant.WithOrganization(context.Background(), 2))
+	if _, err := users.CreateUser(services.UserInput{Email: "coach@example.com", Password: "coach-password", Role: models.RoleCoach}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/api/auth")
+	group.Use(middleware.Tenant(services.NewOrganizationService(db)))
+	SetupAuthRoutes(group, db, tokens)
+
+	send := func(path, organization, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if organization != "" {
+			req.Header.Set(middleware.OrganizationHeader, organization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("/api/auth/login", "other", `{"email": "coach@example.com", "password": "coach-password"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected login to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var pair services.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &pair)
+
+	// Refreshing needs no organization header
+	w = send("/api/auth/refresh", "", fmt.Sprintf(`{"refresh_token": %q}`, pair.RefreshToken))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected refresh to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &pair)
+	principal, err := tokens.ParseAccessToken(pair.AccessToken)
+	if err != nil || principal.OrganizationID != 2 {
+		t.Errorf("Expected a token for the user's organization, got %+v: %v", principal, err)
+	}
+
+	var refreshTokens int64
+	db.Model(&models.RefreshToken{}).Where("revoked_at IS NULL").Count(&refreshTokens)
+	if refreshTokens != 1 {
+		t.Errorf("Expected the old refresh token to be revoked, got %d active", refreshTokens)
+	}
+}
+
+func TestTeamPermissions(t *testing.T) {
+	db := setupTestDB()
+	coached := models.Team{Name: "Coached"}
+	other := models.Team{Name: "Other"}
+	db.Create(&coached)
+	db.Create(&other)
+	coach := models.User{Email: "coach@example.com", PasswordHash: "x", Role: models.RoleCoach}
+	member := models.User{Email: "member@example.com", PasswordHash: "x", Role: models.RoleMember}
+	db.Create(&coach)
+	db.Create(&member)
+
+	admin := newTestRouter()
+	SetupTeamRoutes(admin.Group("/api"), db)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/teams/%d/coaches", coached.ID), strings.NewReader(fmt.Sprintf(`{"user_id": %d}`, coach.ID)))
+	req.Header.Set("Content-Type", "application/json")
+	admin.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected coach to be added, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/teams/%d/coaches", coached.ID), strings.NewReader(fmt.Sprintf(`{"user_id": %d}`, member.ID)))
+	req.Header.Set("Content-Type", "application/json")
+	admin.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a non-coach to be rejected, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name           string
+		principal      auth.Principal
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"Member lists teams", auth.Principal{UserID: member.ID, Role: models.RoleMember}, "GET", "/api/teams", http.StatusOK},
+		{"Member creates team", auth.Principal{UserID: member.ID, Role: models.RoleMember}, "POST", "/api/teams", http.StatusForbidden},
+		{"Member deletes team", auth.Principal{UserID: member.ID, Role: models.RoleMember}, "DELETE", fmt.Sprintf("/api/teams/%d", coached.ID), http.StatusForbidden},
+		{"Member includes feedback", auth.Principal{UserID: member.ID, Role: models.RoleMember}, "GET", "/api/teams?include=feedback", http.StatusForbidden},
+		{"Coach deletes other team", auth.Principal{UserID: coach.ID, Role: models.RoleCoach}, "DELETE", fmt.Sprintf("/api/teams/%d", other.ID), http.StatusForbidden},
+		{"Coach manages coaches", auth.Principal{UserID: coach.ID, Role: models.RoleCoach}, "DELETE", fmt.Sprintf("/api/teams/%d/coaches/%d", coached.ID, coach.ID), http.StatusForbidden},
+		{"Coach deletes coached team", auth.Principal{UserID: coach.ID, Role: models.RoleCoach}, "DELETE", fmt.Sprintf("/api/teams/%d", coached.ID), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouterAs(tt.principal)
+			SetupTeamRoutes(router.Group("/api"), db)
+
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(`{"name": "New team"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFeedbackVisibility(t *testing.T) {
+	db := setupTestDB()
+	alice := models.TeamMember{Name: "Alice", Email: "alice@example.com"}
+	bob := models.TeamMember{Name: "Bob", Email: "bob@example.com"}
+	db.Create(&alice)
+	db.Create(&bob)
+	db.Create(&models.Feedback{Content: "About Alice", TargetType: "member", TargetID: alice.ID})
+	db.Create(&models.Feedback{Content: "About Bob", TargetType: "member", TargetID: bob.ID})
+	db.Create(&models.Feedback{Content: "By Alice", TargetType: "member", TargetID: bob.ID, AuthorID: &alice.ID})
+
+	router := newTestRouterAs(auth.Principal{UserID: 2, Role: models.RoleMember, TeamMemberID: &alice.ID})
+	SetupFeedbackRoutes(router.Group("/api"), db)
+
+	req, _ := http.NewRequest("GET", "/api/feedback", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var feedback []models.Feedback
+	json.Unmarshal(w.Body.Bytes(), &feedback)
+	if len(feedback) != 2 {
+		t.Fatalf("Expected 2 visible feedback entries, got %d", len(feedback))
+	}
+	for _, item := range feedback {
+		if item.Content == "About Bob" {
+			t.Error("Expected feedback about another member to be hidden")
+		}
+	}
+
+	body := fmt.Sprintf(`{"content": "Great work", "target_type": "member", "target_id": %d, "author_id": %d}`, alice.ID, bob.ID)
+	req, _ = http.NewRequest("POST", "/api/feedback", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var created models.Feedback
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if w.Code != http.StatusCreated || created.AuthorID == nil || *created.AuthorID != alice.ID {
+		t.Errorf("Expected feedback to be attributed to the caller, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	db := setupTestDB()
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+	adminToken, _, _ := tokens.IssueAccessToken(auth.Principal{UserID: 1, Email: "admin@example.com", Role: models.RoleAdmin})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api")
+	api.Use(middleware.Authenticate(tokens, services.NewAPIKeyService(db)))
+	SetupAPIKeyRoutes(api, db)
+	SetupTeamMemberRoutes(api, db)
+	SetupTeamRoutes(api, db)
+
+	send := func(method, path, authorization, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("POST", "/api/api-keys", "Bearer "+adminToken, `{"name": "HR sync", "scopes": ["members:everything"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown scope to be rejected, got %d", w.Code)
+	}
+
+	w := send("POST", "/api/api-keys", "Bearer "+adminToken, `{"name": "HR sync", "scopes": ["members:read", " Members:Write "]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected API key to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	var created services.CreatedAPIKey
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Key == "" || created.CreatedByID != 1 {
+		t.Fatalf("Expected the key and its creator in the response, got %s", w.Body.String())
+	}
+
+	w = send("GET", "/api/api-keys", "Bearer "+adminToken, "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Key) || strings.Contains(w.Body.String(), "hash") {
+		t.Errorf("Expected listings not to expose the key, got %d: %s", w.Code, w.Body.String())
+	}
+
+	apiKey := "ApiKey " + created.Key
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"Read members", "GET", "/api/team-members", "", http.StatusOK},
+		{"Create member", "POST", "/api/team-members", `{"name": "Jane Doe", "email": "jane@example.com"}`, http.StatusCreated},
+		{"Read teams without scope", "GET", "/api/teams", "", http.StatusForbidden},
+		{"Manage API keys", "GET", "/api/api-keys", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := send(tt.method, tt.path, apiKey, tt.body); w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if w := send("DELETE", fmt.Sprintf("/api/api-keys/%d", created.ID), "Bearer "+adminToken, ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected API key to be revoked, got %d", w.Code)
+	}
+	if w := send("GET", "/api/team-members", apiKey, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a revoked key to be rejected, got %d", w.Code)
+	}
+	if w := send("DELETE", "/api/api-keys/999", "Bearer "+adminToken, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown key, got %d", w.Code)
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	db := setupTestDB()
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+	idp := oidctest.NewServer("coaching-app")
+	defer idp.Close()
+	provider := oidc.NewProvider(oidc.Config{
+		IssuerURL:   idp.URL,
+		ClientID:    "coaching-app",
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+		Scopes:      []string{"email"},
+	}, idp.Client())
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupOIDCRoutes(router.Group("/api/auth"), db, tokens, provider, services.OIDCRoleMapping{})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	client := idp.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	w := get("/api/auth/oidc/login")
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected 302 to the provider, got %d", w.Code)
+	}
+	resp, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to sign in at provider: %v", err)
+	}
+	resp.Body.Close()
+	callback, _ := url.Parse(resp.Header.Get("Location"))
+	if callback.Path != "/api/auth/oidc/callback" {
+		t.Fatalf("Expected provider to redirect to the callback, got %s", callback)
+	}
+
+	w = get(callback.RequestURI())
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from callback, got %d: %s", w.Code, w.Body.String())
+	}
+	var pair services.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &pair)
+	principal, err := tokens.ParseAccessToken(pair.AccessToken)
+	if err != nil || principal.Email != "user@example.com" || principal.Role != models.RoleMember {
+		t.Errorf("Unexpected principal %+v, err %v", principal, err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"Replayed callback", callback.RawQuery, http.StatusBadRequest},
+		{"Provider error", "error=access_denied&state=x", http.StatusUnauthorized},
+		{"Missing code", "state=x", http.StatusBadRequest},
+		{"Unknown state", "code=x&state=x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := get("/api/auth/oidc/callback?" + tt.query); w.Code != tt.code {
+				t.Errorf("Expected %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	db := setupTestDB()
+	team := models.Team{Name: "Doomed"}
+	db.Create(&team)
+
+	router := newTestRouter()
+	router.Use(middleware.RequestID())
+	SetupTeamRoutes(router.Group("/api"), db)
+	SetupAuditRoutes(router.Group("/api"), db)
+
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/teams/%d", team.ID), nil)
+	req.Header.Set(middleware.RequestIDHeader, "delete-doomed")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected team to be deleted, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/api/audit?request_id=delete-doomed", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var page services.AuditPage
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if w.Code != http.StatusOK || page.Total != 1 {
+		t.Fatalf("Expected one event for the request, got %d: %s", w.Code, w.Body.String())
+	}
+	event := page.Events[0]
+	if event.Action != "team.deleted" || event.EntityID != team.ID || event.ActorUserID == nil || *event.ActorUserID != 1 {
+		t.Errorf("Expected the admin to be recorded deleting the team, got %+v", event)
+	}
+
+	tests := []struct {
+		name           string
+		principal      auth.Principal
+		path           string
+		expectedStatus int
+	}{
+		{"Admin filters by entity", auth.Principal{UserID: 1, Role: models.RoleAdmin}, fmt.Sprintf("/api/audit?entity_type=team&entity_id=%d&page=1&per_page=10", team.ID), http.StatusOK},
+		{"Admin exports", auth.Principal{UserID: 1, Role: models.RoleAdmin}, "/api/audit/export?action=team.deleted", http.StatusOK},
+		{"Invalid entity ID", auth.Principal{UserID: 1, Role: models.RoleAdmin}, "/api/audit?entity_id=abc", http.StatusBadRequest},
+		{"Invalid from", auth.Principal{UserID: 1, Role: models.RoleAdmin}, "/api/audit?from=yesterday", http.StatusBadRequest},
+		{"Invalid page", auth.Principal{UserID: 1, Role: models.RoleAdmin}, "/api/audit?page=0", http.StatusBadRequest},
+		{"Invalid export column", auth.Principal{UserID: 1, Role: models.RoleAdmin}, "/api/audit/export?fields=password", http.StatusBadRequest},
+		{"Coach reads audit log", auth.Principal{UserID: 2, Role: models.RoleCoach}, "/api/audit", http.StatusForbidden},
+		{"Key without scope exports", auth.Principal{APIKeyID: 1, Scopes: []string{"teams:read"}}, "/api/audit/export", http.StatusForbidden},
+		{"Key with scope exports", auth.Principal{APIKeyID: 1, Scopes: []string{"audit:read"}}, "/api/audit/export", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouterAs(tt.principal)
+			SetupAuditRoutes(router.Group("/api"), db)
+
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	db := setupTestDB()
+	db.Create(&models.Organization{ID: models.DefaultOrganizationID, Name: "Default", Slug: "default"})
+	db.Create(&models.Organization{ID: 2, Name: "Other", Slug: "other"})
+	team := models.Team{Name: "Dev Team"}
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	user := models.User{Email: "coach@example.com", Role: models.RoleCoach}
+	db.Create(&team)
+	db.Create(&member)
+	db.Create(&user)
+	db.Model(&team).Association("Members").Append(&member)
+	db.Create(&models.TeamCoach{TeamID: team.ID, UserID: user.ID})
+	db.Create(&models.Feedback{Content: "Great work", TargetType: "team", TargetID: team.ID})
+
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+	newRouter := func(organizationID uint) *gin.Engine {
+		router := newTestRouterAs(auth.Principal{UserID: 1, Role: models.RoleAdmin, OrganizationID: organizationID})
+		router.Use(middleware.Tenant(services.NewOrganizationService(db)))
+		api := router.Group("/api")
+		SetupUserRoutes(api, db, tokens)
+		SetupTeamMemberRoutes(api, db)
+		SetupTeamRoutes(api, db)
+		SetupAssignmentRoutes(api, db)
+		SetupFeedbackRoutes(api, db)
+		SetupCSVRoutes(api, db)
+		SetupGraphQLRoutes(api, db)
+		SetupAuditRoutes(api, db)
+		return router
+	}
+	theirs := newRouter(2)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		unexpected     string
+	}{
+		{"List teams", "GET", "/api/teams", "", http.StatusOK, "Dev Team"},
+		{"Read team", "GET", fmt.Sprintf("/api/teams/%d", team.ID), "", http.StatusNotFound, ""},
+		{"Read team members", "GET", fmt.Sprintf("/api/teams/%d/members", team.ID), "", http.StatusNotFound, ""},
+		{"List members", "GET", "/api/team-members", "", http.StatusOK, "john@example.com"},
+		{"Read member", "GET", fmt.Sprintf("/api/team-members/%d", member.ID), "", http.StatusNotFound, ""},
+		{"List assignments", "GET", "/api/assignments", "", http.StatusOK, "Dev Team"},
+		{"List feedback", "GET", "/api/feedback", "", http.StatusOK, "Great work"},
+		{"Read team feedback", "GET", fmt.Sprintf("/api/feedback/team/%d", team.ID), "", http.StatusOK, "Great work"},
+		{"Export feedback", "GET", "/api/export/feedback", "", http.StatusOK, "Great work"},
+		{"Query GraphQL", "POST", "/api/graphql", `{"query": "{ teams { name } teamMembers { email } }"}`, http.StatusOK, "Dev Team"},
+		{"Read audit log", "GET", "/api/audit", "", http.StatusOK, "Dev Team"},
+		{"Give feedback", "POST", "/api/feedback", fmt.Sprintf(`{"content": "Sneaky", "target_type": "team", "target_id": %d}`, team.ID), http.StatusBadRequest, ""},
+		{"Assign member", "POST", "/api/assignments", fmt.Sprintf(`{"team_id": %d, "team_member_id": %d}`, team.ID, member.ID), http.StatusBadRequest, ""},
+		{"Unassign member", "DELETE", fmt.Sprintf("/api/teams/%d/members/%d", team.ID, member.ID), "", http.StatusBadRequest, ""},
+		{"Add coach", "POST", fmt.Sprintf("/api/teams/%d/coaches", team.ID), fmt.Sprintf(`{"user_id": %d}`, user.ID), http.StatusNotFound, ""},
+		{"Remove coach", "DELETE", fmt.Sprintf("/api/teams/%d/coaches/%d", team.ID, user.ID), "", http.StatusNotFound, ""},
+		{"Change role", "PUT", fmt.Sprintf("/api/users/%d/role", user.ID), `{"role": "admin"}`, http.StatusNotFound, ""},
+		{"Delete team", "DELETE", fmt.Sprintf("/api/teams/%d", team.ID), "", http.StatusOK, ""},
+		{"Create member with a taken email", "POST", "/api/team-members", `{"name": "John Doe", "email": "john@example.com"}`, http.StatusCreated, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			theirs.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.unexpected != "" && strings.Contains(w.Body.String(), tt.unexpected) {
+				t.Errorf("Expected no data of another organization, got %s", w.Body.String())
+			}
+		})
+	}
+
+	// The first organization's data is unchanged
+	var reloaded models.Team
+	var coachCount int64
+	db.Model(&models.TeamCoach{}).Count(&coachCount)
+	if err := db.Preload("Members").First(&reloaded, team.ID).Error; err != nil || len(reloaded.Members) != 1 || coachCount != 1 {
+		t.Errorf("Expected the team to be untouched, got %+v: %v", reloaded, err)
+	}
+	var feedbackCount, memberCount int64
+	db.Model(&models.Feedback{}).Count(&feedbackCount)
+	db.Model(&models.TeamMember{}).Where("organization_id = ?", models.DefaultOrganizationID).Count(&memberCount)
+	if feedbackCount != 1 || memberCount != 1 {
+		t.Errorf("Expected one feedback and one member in the first organization, got %d and %d", feedbackCount, memberCount)
+	}
+	db.First(&user, user.ID)
+	if user.Role != models.RoleCoach {
+		t.Errorf("Expected the role to be unchanged, got %s", user.Role)
+	}
+
+	// Credentials cannot be pointed at another organization
+	req, _ := http.NewRequest("GET", "/api/teams", nil)
+	req.Header.Set(middleware.OrganizationHeader, "default")
+	w := httptest.NewRecorder()
+	theirs.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 naming another organization, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/teams", nil)
+	w = httptest.NewRecorder()
+	newRouter(models.DefaultOrganizationID).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Dev Team") {
+		t.Errorf("Expected the owning organization to see its team, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestOrganizationRoutes(t *testing.T) {
+	db := setupTestDB()
+	db.Create(&models.Organization{ID: models.DefaultOrganizationID, Name: "Default", Slug: "default"})
+
+	tests := []struct {
+		name           string
+		principal      auth.Principal
+		method         string
+		body           string
+		expectedStatus int
+	}{
+		{"Admin creates organization", auth.Principal{UserID: 1, Role: models.RoleAdmin, OrganizationID: 1}, "POST", `{"name": "Other", "slug": " Other ", "admin": {"email": "admin@other.example", "password": "admin-password"}}`, http.StatusCreated},
+		{"Duplicate slug", auth.Principal{UserID: 1, Role: models.RoleAdmin, OrganizationID: 1}, "POST", `{"name": "Again", "slug": "other"}`, http.StatusConflict},
+		{"Invalid slug", auth.Principal{UserID: 1, Role: models.RoleAdmin, OrganizationID: 1}, "POST", `{"name": "Bad", "slug": "bad slug"}`, http.StatusBadRequest},
+		{"Admin lists organizations", auth.Principal{UserID: 1, Role: models.RoleAdmin, OrganizationID: 1}, "GET", "", http.StatusOK},
+		{"Admin of another organization", auth.Principal{UserID: 2, Role: models.RoleAdmin, OrganizationID: 2}, "GET", "", http.StatusForbidden},
+		{"Coach", auth.Principal{UserID: 3, Role: models.RoleCoach, OrganizationID: 1}, "GET", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouterAs(tt.principal)
+			SetupOrganizationRoutes(router.Group("/api"), db)
+
+			req, _ := http.NewRequest(tt.method, "/api/organizations", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBodyLimits(t *testing.T) {
+	db := setupTestDB()
+	router := newTestRouter()
+	router.Use(middleware.BodyLimit(middleware.BodyLimits{Default: 256}))
+	SetupFeedbackRoutes(router.Group("/api"), db)
+	SetupCSVRoutes(router.Group("/api"), db)
+	SetupGraphQLRoutes(router.Group("/api"), db)
+
+	var roster bytes.Buffer
+	writer := multipart.NewWriter(&roster)
+	part, _ := writer.CreateFormFile("file", "roster.csv")
+	part.Write([]byte("name,email\n" + strings.Repeat("John Doe,john@example.com\n", 20)))
+	writer.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"Feedback", "/api/feedback", "application/json", `{"content": "` + strings.Repeat("a", 300) + `", "target_type": "team", "target_id": 1}`},
+		{"GraphQL", "/api/graphql", "application/json", `{"query": "{ teams { name } }` + strings.Repeat(" ", 300) + `"}`},
+		{"Roster import", "/api/import/members/preview", writer.FormDataContentType(), roster.String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Hide the length so that the limit is only hit while reading
+			req, _ := http.NewRequest("POST", tt.path, io.MultiReader(strings.NewReader(tt.body)))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPrivacyRoutes(t *testing.T) {
+	db := setupTestDB()
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	db.Create(&member)
+	db.Create(&models.Feedback{Content: "Great retro", TargetType: "team", TargetID: 1, AuthorID: &member.ID})
+
+	gin.SetMode(gin.TestMode)
+	router := newTestRouter()
+	SetupPrivacyRoutes(router.Group("/api"), db, services.DefaultErasurePolicy())
+	coachRouter := newTestRouterAs(auth.Principal{UserID: 2, Role: models.RoleCoach})
+	SetupPrivacyRoutes(coachRouter.Group("/api"), db, services.DefaultErasurePolicy())
+	keyRouter := newTestRouterAs(auth.Principal{APIKeyID: 1, Scopes: []string{"members:read", "members:write"}})
+	SetupPrivacyRoutes(keyRouter.Group("/api"), db, services.DefaultErasurePolicy())
+
+	exportPath := fmt.Sprintf("/api/team-members/%d/export", member.ID)
+	erasePath := fmt.Sprintf("/api/team-members/%d/erase", member.ID)
+	tests := []struct {
+		name           string
+		router         *gin.Engine
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Coach exports member", coachRouter, "GET", exportPath, "", http.StatusForbidden, ""},
+		{"API key exports member", keyRouter, "GET", exportPath, "", http.StatusForbidden, ""},
+		{"API key erases member", keyRouter, "POST", erasePath, `{"reason": "Request"}`, http.StatusForbidden, ""},
+		{"Unknown member", router, "GET", "/api/team-members/999/export", "", http.StatusNotFound, ""},
+		{"Invalid ID", router, "GET", "/api/team-members/abc/export", "", http.StatusBadRequest, ""},
+		{"Export", router, "GET", exportPath, "", http.StatusOK, `"feedback_given":[{`},
+		{"Erase without reason", router, "POST", erasePath, `{}`, http.StatusBadRequest, ""},
+		{"Erase", router, "POST", erasePath, `{"reason": "Request by email"}`, http.StatusOK, `"hash":"`},
+		{"Erase twice", router, "POST", erasePath, `{"reason": "Request by email"}`, http.StatusConflict, ""},
+		{"Export after erasure", router, "GET", exportPath, "", http.StatusOK, `"content":"[redacted]"`},
+		{"List erasures", router, "GET", "/api/erasures", "", http.StatusOK, `"valid":true`},
+		{"Coach lists erasures", coachRouter, "GET", "/api/erasures", "", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			tt.router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain %s, got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestConfigRoutes(t *testing.T) {
+	db := setupTestDB()
+	cfg, _, err := config.Load([]string{"--database.password=hunter2", "--database.max_open_conns=50"})
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		principal      auth.Principal
+		expectedStatus int
+	}{
+		{"Admin reads configuration", auth.Principal{UserID: 1, Role: models.RoleAdmin, OrganizationID: 1}, http.StatusOK},
+		{"Admin of another organization", auth.Principal{UserID: 2, Role: models.RoleAdmin, OrganizationID: 2}, http.StatusForbidden},
+		{"Coach", auth.Principal{UserID: 3, Role: models.RoleCoach, OrganizationID: 1}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouterAs(tt.principal)
+			SetupConfigRoutes(router.Group("/debug"), db, cfg)
+
+			req, _ := http.NewRequest("GET", "/debug/config", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if strings.Contains(w.Body.String(), "hunter2") {
+				t.Errorf("Expected the database password to be redacted, got %s", w.Body.String())
+			}
+
+			var response struct {
+				Settings []config.Setting `json:"settings"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			settings := make(map[string]config.Setting)
+			for _, s := range response.Settings {
+				settings[s.Key] = s
+			}
+			if s := settings["database.password"]; s.Value != config.Redacted || s.Source != config.SourceFlag {
+				t.Errorf("Expected a redacted password set by flag, got %+v", s)
+			}
+			if s := settings["database.max_open_conns"]; s.Value != "50" || s.Source != config.SourceFlag {
+				t.Errorf("Expected max_open_conns 50 set by flag, got %+v", s)
+			}
+			if s := settings["server.port"]; s.Value != "8080" || s.Source != config.SourceDefault {
+				t.Errorf("Expected the default port, got %+v", s)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/oidc"
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OIDCHandler struct {
+	service *services.OIDCService
+}
+
+func NewOIDCHandler(db *gorm.DB, tokens *auth.TokenIssuer, provider *oidc.Provider, roles services.OIDCRoleMapping) *OIDCHandler {
+	return &OIDCHandler{service: services.NewOIDCService(db, tokens, provider, roles)}
+}
+
+// Login redirects to the identity provider's sign-in page
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, err := h.service.Begin(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, oidc.ErrDiscovery) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start sign-in"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the sign-in when the identity provider redirects back
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in was rejected by the identity provider: " + providerError})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	pair, err := h.service.Complete(c.Request.Context(), code, state)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidOIDCState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-in expired or was already completed, please try again"})
+		case errors.Is(err, services.ErrOIDCEmailUnverified):
+			c.JSON(http.StatusForbidden, gin.H{"error": "A verified email is required to sign in"})
+		case errors.Is(err, services.ErrUserExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "This email belongs to an account linked to another identity"})
+		case errors.Is(err, oidc.ErrDiscovery):
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		case errors.Is(err, oidc.ErrExchange), errors.Is(err, oidc.ErrInvalidIDToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in could not be verified"})
+		default:
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+func SetupOIDCRoutes(group *gin.RouterGroup, db *gorm.DB, tokens *auth.TokenIssuer, provider *oidc.Provider, roles services.OIDCRoleMapping) {
+	handler := NewOIDCHandler(db, tokens, provider, roles)
+
+	group.GET("/oidc/login", handler.Login)
+	group.GET("/oidc/callback", handler.Callback)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"coaching-app-backend/policy"
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OrganizationHandler struct {
+	service *services.OrganizationService
+	policy  *policy.Authorizer
+}
+
+func NewOrganizationHandler(db *gorm.DB) *OrganizationHandler {
+	return &OrganizationHandler{
+		service: services.NewOrganizationService(db),
+		policy:  policy.NewAuthorizer(db),
+	}
+}
+
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ManageOrganizations, policy.Resource{}) {
+		return
+	}
+
+	var input services.OrganizationInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	organization, err := h.service.WithContext(c.Request.Context()).CreateOrganization(input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOrganizationExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "An organization with this slug already exists"})
+		default:
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+func (h *OrganizationHandler) GetAllOrganizations(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ManageOrganizations, policy.Resource{}) {
+		return
+	}
+
+	organizations, err := h.service.WithContext(c.Request.Context()).GetAllOrganizations()
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+func SetupOrganizationRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	handler := NewOrganizationHandler(db)
+
+	api.POST("/organizations", handler.CreateOrganization)
+	api.GET("/organizations", handler.GetAllOrganizations)
+}
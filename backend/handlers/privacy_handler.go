@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"coaching-app-backend/policy"
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PrivacyHandler struct {
+	service *services.PrivacyService
+	policy  *policy.Authorizer
+	erasure services.ErasurePolicy
+}
+
+type EraseTeamMemberRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+func NewPrivacyHandler(db *gorm.DB, erasure services.ErasurePolicy) *PrivacyHandler {
+	return &PrivacyHandler{
+		service: services.NewPrivacyService(db),
+		policy:  policy.NewAuthorizer(db),
+		erasure: erasure,
+	}
+}
+
+// ExportTeamMember downloads everything stored about a member as JSON
+func (h *PrivacyHandler) ExportTeamMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.ManagePersonalData, policy.Resource{}) {
+		return
+	}
+
+	export, err := h.service.WithContext(c.Request.Context()).ExportTeamMember(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export team member"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=team-member-%d.json", id))
+	c.JSON(http.StatusOK, export)
+}
+
+// EraseTeamMember anonymizes a member and redacts their content according to
+// the configured erasure policy
+func (h *PrivacyHandler) EraseTeamMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.ManagePersonalData, policy.Resource{}) {
+		return
+	}
+
+	var req EraseTeamMemberRequest
+	if !bindInput(c, &req) {
+		return
+	}
+
+	record, err := h.service.WithContext(c.Request.Context()).EraseTeamMember(uint(id), req.Reason, h.erasure)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAlreadyErased):
+			c.JSON(http.StatusConflict, gin.H{"error": "Team member has already been erased"})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+		default:
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase team member"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// GetErasures lists the erasure records and whether their hash chain is intact
+func (h *PrivacyHandler) GetErasures(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ManagePersonalData, policy.Resource{}) {
+		return
+	}
+
+	log, err := h.service.WithContext(c.Request.Context()).ErasureLog()
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve erasure records"})
+		return
+	}
+
+	c.JSON(http.StatusOK, log)
+}
+
+func SetupPrivacyRoutes(api *gin.RouterGroup, db *gorm.DB, erasure services.ErasurePolicy) {
+	handler := NewPrivacyHandler(db, erasure)
+
+	api.GET("/team-members/:id/export", handler.ExportTeamMember)
+	api.POST("/team-members/:id/erase", handler.EraseTeamMember)
+	api.GET("/erasures", handler.GetErasures)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"coaching-app-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseQueryOptions reads the include and fields query parameters. The default
+// includes are only used when the request has no include parameter at all, so
+// "?include=" explicitly asks for no relations.
+func parseQueryOptions(c *gin.Context, defaultIncludes ...string) services.QueryOptions {
+	opts := services.QueryOptions{Include: defaultIncludes}
+	if include, ok := c.GetQuery("include"); ok {
+		opts.Include = splitList(include)
+	}
+	opts.Fields = splitList(c.Query("fields"))
+	return opts
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// renderSparse writes data as JSON, trimming every object down to the
+// requested fields plus the included relations when a field list is given.
+func renderSparse(c *gin.Context, status int, data interface{}, opts services.QueryOptions) {
+	if len(opts.Fields) == 0 {
+		c.JSON(status, data)
+		return
+	}
+
+	keep := make(map[string]bool)
+	for _, field := range opts.Fields {
+		keep[field] = true
+	}
+	for _, include := range opts.Include {
+		keep[strings.SplitN(include, ".", 2)[0]] = true
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render response"})
+		return
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render response"})
+		return
+	}
+
+	switch value := decoded.(type) {
+	case []interface{}:
+		for _, item := range value {
+			trimObject(item, keep)
+		}
+	default:
+		trimObject(value, keep)
+	}
+
+	c.JSON(status, decoded)
+}
+
+func trimObject(value interface{}, keep map[string]bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key := range object {
+		if !keep[key] {
+			delete(object, key)
+		}
+	}
+}
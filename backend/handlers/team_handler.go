@@ -1,10 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
-	"coaching-app-backend/models"
+	"coaching-app-backend/policy"
 	"coaching-app-backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -13,27 +14,33 @@ import (
 
 type TeamHandler struct {
 	service *services.TeamService
+	policy  *policy.Authorizer
+}
+
+type TeamCoachRequest struct {
+	UserID uint `json:"user_id" validate:"required"`
 }
 
 func NewTeamHandler(db *gorm.DB) *TeamHandler {
 	return &TeamHandler{
 		service: services.NewTeamService(db),
+		policy:  policy.NewAuthorizer(db),
 	}
 }
 
 func (h *TeamHandler) CreateTeam(c *gin.Context) {
-	var team models.Team
-	if err := c.ShouldBindJSON(&team); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !authorize(c, h.policy, policy.CreateTeam, policy.Resource{}) {
 		return
 	}
 
-	if team.Name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Team name is required"})
+	var input services.TeamInput
+	if !bindInput(c, &input) {
 		return
 	}
 
-	if err := h.service.CreateTeam(&team); err != nil {
+	team := input.Team()
+	if err := h.service.WithContext(c.Request.Context()).CreateTeam(&team); err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create team"})
 		return
 	}
@@ -42,13 +49,22 @@ func (h *TeamHandler) CreateTeam(c *gin.Context) {
 }
 
 func (h *TeamHandler) GetAllTeams(c *gin.Context) {
-	teams, err := h.service.GetAllTeams()
+	opts := parseQueryOptions(c)
+	if !authorizeIncludes(c, h.policy, policy.ReadTeams, opts) {
+		return
+	}
+	teams, err := h.service.WithContext(c.Request.Context()).FindTeams(opts)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidQueryOption) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve teams"})
 		return
 	}
 
-	c.JSON(http.StatusOK, teams)
+	renderSparse(c, http.StatusOK, teams, opts)
 }
 
 func (h *TeamHandler) GetTeamByID(c *gin.Context) {
@@ -59,13 +75,21 @@ func (h *TeamHandler) GetTeamByID(c *gin.Context) {
 		return
 	}
 
-	team, err := h.service.GetTeamByID(uint(id))
+	opts := parseQueryOptions(c, "members")
+	if !authorizeIncludes(c, h.policy, policy.ReadTeams, opts) {
+		return
+	}
+	team, err := h.service.WithContext(c.Request.Context()).FindTeam(uint(id), opts)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidQueryOption) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, team)
+	renderSparse(c, http.StatusOK, team, opts)
 }
 
 func (h *TeamHandler) GetTeamMembers(c *gin.Context) {
@@ -76,7 +100,11 @@ func (h *TeamHandler) GetTeamMembers(c *gin.Context) {
 		return
 	}
 
-	members, err := h.service.GetTeamMembers(uint(id))
+	if !authorize(c, h.policy, policy.ReadMembers, policy.Resource{TeamID: uint(id)}) {
+		return
+	}
+
+	members, err := h.service.WithContext(c.Request.Context()).GetTeamMembers(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found or failed to retrieve members"})
 		return
@@ -101,7 +129,11 @@ func (h *TeamHandler) RemoveMemberFromTeam(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.RemoveMemberFromTeam(uint(teamId), uint(memberId)); err != nil {
+	if !authorize(c, h.policy, policy.ManageAssignments, policy.Resource{TeamID: uint(teamId)}) {
+		return
+	}
+
+	if err := h.service.WithContext(c.Request.Context()).RemoveMemberFromTeam(uint(teamId), uint(memberId)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to remove member from team"})
 		return
 	}
@@ -117,7 +149,11 @@ func (h *TeamHandler) DeleteTeam(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteTeam(uint(id)); err != nil {
+	if !authorize(c, h.policy, policy.DeleteTeam, policy.Resource{TeamID: uint(id)}) {
+		return
+	}
+
+	if err := h.service.WithContext(c.Request.Context()).DeleteTeam(uint(id)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to delete team"})
 		return
 	}
@@ -125,6 +161,89 @@ func (h *TeamHandler) DeleteTeam(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
 }
 
+func (h *TeamHandler) GetTeamCoaches(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.ReadTeams, policy.Resource{TeamID: uint(id)}) {
+		return
+	}
+
+	coaches, err := h.service.WithContext(c.Request.Context()).GetCoaches(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, coaches)
+}
+
+func (h *TeamHandler) AddTeamCoach(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.ManageCoaches, policy.Resource{TeamID: uint(id)}) {
+		return
+	}
+
+	var req TeamCoachRequest
+	if !bindInput(c, &req) {
+		return
+	}
+
+	if err := h.service.WithContext(c.Request.Context()).AddCoach(uint(id), req.UserID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotCoach):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only users with the coach role can coach a team"})
+		case errors.Is(err, services.ErrAlreadyCoaching):
+			c.JSON(http.StatusConflict, gin.H{"error": "User already coaches this team"})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team or user not found"})
+		default:
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add coach"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Coach added to team successfully"})
+}
+
+func (h *TeamHandler) RemoveTeamCoach(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.ManageCoaches, policy.Resource{TeamID: uint(id)}) {
+		return
+	}
+
+	if err := h.service.WithContext(c.Request.Context()).RemoveCoach(uint(id), uint(userID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove coach"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Coach removed from team successfully"})
+}
+
 func SetupTeamRoutes(api *gin.RouterGroup, db *gorm.DB) {
 	handler := NewTeamHandler(db)
 
@@ -134,4 +253,7 @@ func SetupTeamRoutes(api *gin.RouterGroup, db *gorm.DB) {
 	api.GET("/teams/:id/members", handler.GetTeamMembers)
 	api.DELETE("/teams/:id/members/:memberId", handler.RemoveMemberFromTeam)
 	api.DELETE("/teams/:id", handler.DeleteTeam)
+	api.GET("/teams/:id/coaches", handler.GetTeamCoaches)
+	api.POST("/teams/:id/coaches", handler.AddTeamCoach)
+	api.DELETE("/teams/:id/coaches/:userId", handler.RemoveTeamCoach)
 }
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
-	"coaching-app-backend/models"
+	"coaching-app-backend/middleware"
+	"coaching-app-backend/policy"
 	"coaching-app-backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -13,27 +16,42 @@ import (
 
 type TeamMemberHandler struct {
 	service *services.TeamMemberService
+	policy  *policy.Authorizer
+}
+
+type BatchTeamMembersRequest struct {
+	Mode    services.BatchMode         `json:"mode"`
+	Members []services.TeamMemberInput `json:"members" binding:"required"`
+}
+
+type MergeTeamMembersRequest struct {
+	SourceID uint `json:"source_id" validate:"required"`
 }
 
 func NewTeamMemberHandler(db *gorm.DB) *TeamMemberHandler {
 	return &TeamMemberHandler{
 		service: services.NewTeamMemberService(db),
+		policy:  policy.NewAuthorizer(db),
 	}
 }
 
 func (h *TeamMemberHandler) CreateTeamMember(c *gin.Context) {
-	var member models.TeamMember
-	if err := c.ShouldBindJSON(&member); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !authorize(c, h.policy, policy.CreateMember, policy.Resource{}) {
 		return
 	}
 
-	if member.Name == "" || member.Email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and email are required"})
+	var input services.MemberInput
+	if !bindInput(c, &input) {
 		return
 	}
 
-	if err := h.service.CreateTeamMember(&member); err != nil {
+	member := input.TeamMember()
+	if err := h.service.WithContext(c.Request.Context()).CreateTeamMember(&member); err != nil {
+		if errors.Is(err, services.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A team member with this email already exists"})
+			return
+		}
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create team member"})
 		return
 	}
@@ -42,13 +60,22 @@ func (h *TeamMemberHandler) CreateTeamMember(c *gin.Context) {
 }
 
 func (h *TeamMemberHandler) GetAllTeamMembers(c *gin.Context) {
-	members, err := h.service.GetAllTeamMembers()
+	opts := parseQueryOptions(c)
+	if !authorizeIncludes(c, h.policy, policy.ReadMembers, opts) {
+		return
+	}
+	members, err := h.service.WithContext(c.Request.Context()).FindTeamMembers(opts)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidQueryOption) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve team members"})
 		return
 	}
 
-	c.JSON(http.StatusOK, members)
+	renderSparse(c, http.StatusOK, members, opts)
 }
 
 func (h *TeamMemberHandler) GetTeamMemberByID(c *gin.Context) {
@@ -59,19 +86,122 @@ func (h *TeamMemberHandler) GetTeamMemberByID(c *gin.Context) {
 		return
 	}
 
-	member, err := h.service.GetTeamMemberByID(uint(id))
+	opts := parseQueryOptions(c)
+	if !authorizeIncludes(c, h.policy, policy.ReadMembers, opts) {
+		return
+	}
+	member, err := h.service.WithContext(c.Request.Context()).FindTeamMember(uint(id), opts)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidQueryOption) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, member)
+	renderSparse(c, http.StatusOK, member, opts)
+}
+
+func (h *TeamMemberHandler) BatchUpsertTeamMembers(c *gin.Context) {
+	if !authorize(c, h.policy, policy.ImportMembers, policy.Resource{}) {
+		return
+	}
+
+	var req BatchTeamMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = services.BatchTransactional
+	}
+	if req.Mode != services.BatchTransactional && req.Mode != services.BatchBestEffort {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be 'transactional' or 'best_effort'"})
+		return
+	}
+	if len(req.Members) > services.MaxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A batch may contain at most %d members", services.MaxBatchSize)})
+		return
+	}
+
+	results, err := h.service.WithContext(c.Request.Context()).BatchUpsertTeamMembers(req.Members, req.Mode)
+	if err != nil {
+		if errors.Is(err, services.ErrBatchAborted) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"mode": req.Mode, "committed": false, "results": results})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process batch"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mode": req.Mode, "committed": true, "results": results})
+}
+
+func (h *TeamMemberHandler) FindDuplicateTeamMembers(c *gin.Context) {
+	if !authorize(c, h.policy, policy.MergeMembers, policy.Resource{}) {
+		return
+	}
+
+	candidates, err := h.service.WithContext(c.Request.Context()).FindDuplicateCandidates()
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicate team members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, candidates)
+}
+
+// MergeTeamMembers folds the member given as source_id into the member in the
+// URL, which is kept.
+func (h *TeamMemberHandler) MergeTeamMembers(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if !authorize(c, h.policy, policy.MergeMembers, policy.Resource{}) {
+		return
+	}
+
+	var req MergeTeamMembersRequest
+	if !bindInput(c, &req) {
+		return
+	}
+
+	result, err := h.service.WithContext(c.Request.Context()).MergeTeamMembers(uint(id), req.SourceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMergeSelf):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A team member cannot be merged into itself"})
+		case errors.Is(err, services.ErrMergeLinkedAccounts):
+			c.JSON(http.StatusConflict, gin.H{"error": "Both team members are linked to an account"})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team member not found"})
+		default:
+			c.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge team members"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
 func SetupTeamMemberRoutes(api *gin.RouterGroup, db *gorm.DB) {
 	handler := NewTeamMemberHandler(db)
 
 	api.POST("/team-members", handler.CreateTeamMember)
+	api.POST("/team-members/batch", handler.BatchUpsertTeamMembers)
 	api.GET("/team-members", handler.GetAllTeamMembers)
+	api.GET("/team-members/duplicates", handler.FindDuplicateTeamMembers)
 	api.GET("/team-members/:id", handler.GetTeamMemberByID)
+	api.POST("/team-members/:id/merge", handler.MergeTeamMembers)
 }
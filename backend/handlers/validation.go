@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"coaching-app-backend/middleware"
+	"coaching-app-backend/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidationErrorResponse lists every invalid field of a request at once
+type ValidationErrorResponse struct {
+	Error  string                  `json:"error"`
+	Code   string                  `json:"code"`
+	Fields []validation.FieldError `json:"fields"`
+}
+
+// bindInput decodes the JSON body into input, normalizes and validates it.
+// It writes a 400 response and returns false when the body is unusable.
+func bindInput(c *gin.Context, input interface{}) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		if middleware.BodyTooLarge(c, err) {
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if err := validation.Struct(input); err != nil {
+		var fieldErrs validation.Errors
+		if errors.As(err, &fieldErrs) {
+			c.JSON(http.StatusBadRequest, ValidationErrorResponse{
+				Error:  "Validation failed",
+				Code:   "VALIDATION_FAILED",
+				Fields: fieldErrs,
+			})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
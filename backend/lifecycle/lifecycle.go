@@ -0,0 +1,221 @@
+// Package lifecycle runs the servers until the process is asked to stop, then
+// shuts down in order:
+//
+//  1. /ready starts failing, so load balancers stop sending new requests
+//  2. after the drain delay, the servers stop accepting connections and wait
+//     for the requests in flight, up to the grace period
+//  3. the shutdown hooks run in the order they were added, such as closing
+//     the database
+//
+// Requests still running when the grace period ends are cut off.
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	// DefaultGracePeriod is how long requests in flight may take to finish
+	DefaultGracePeriod = 30 * time.Second
+	// DefaultDrainDelay is how long /ready fails before the servers stop
+	// accepting connections, giving load balancers time to notice
+	DefaultDrainDelay = 5 * time.Second
+	// readinessTimeout bounds the readiness check
+	readinessTimeout = 2 * time.Second
+)
+
+// Config sets how long shutting down may take
+type Config struct {
+	GracePeriod time.Duration
+	DrainDelay  time.Duration
+}
+
+// Server is a server run until shutdown. Serve blocks until the server stops,
+// returning nil once it is shut down; Shutdown stops it, waiting for its
+// requests in flight until ctx ends.
+type Server struct {
+	Name     string
+	Addr     string
+	Serve    func() error
+	Shutdown func(ctx context.Context) error
+}
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Runner runs servers and shuts them down when asked to stop
+type Runner struct {
+	config  Config
+	servers []Server
+	hooks   []hook
+	ready   atomic.Bool
+}
+
+func New(config Config) *Runner {
+	return &Runner{config: config}
+}
+
+// AddServer adds a server run by Run
+func (r *Runner) AddServer(server Server) {
+	r.servers = append(r.servers, server)
+}
+
+// OnShutdown adds a function run once the servers have stopped. Hooks run in
+// the order they were added, and all of them run even if one fails.
+func (r *Runner) OnShutdown(name string, fn func(ctx context.Context) error) {
+	r.hooks = append(r.hooks, hook{name: name, fn: fn})
+}
+
+// Ready reports whether the servers are running and not shutting down
+func (r *Runner) Ready() bool {
+	return r.ready.Load()
+}
+
+// Run serves until ctx is done or a server fails, then shuts down. It returns
+// the error of the failed server, or of shutting down.
+func (r *Runner) Run(ctx context.Context) error {
+	stopped := make(chan error, len(r.servers))
+	for _, server := range r.servers {
+		server := server
+		go func() {
+			slog.Info("Server starting", "server", server.Name, "address", server.Addr)
+			// Servers only stop by themselves when they fail
+			err := server.Serve()
+			if err == nil {
+				err = errors.New("stopped unexpectedly")
+			}
+			stopped <- fmt.Errorf("%s server: %w", server.Name, err)
+		}()
+	}
+	r.ready.Store(true)
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutting down")
+	case err = <-stopped:
+		slog.Error("Server failed, shutting down", "error", err)
+	}
+	return errors.Join(err, r.shutdown())
+}
+
+func (r *Runner) shutdown() error {
+	r.ready.Store(false)
+	if r.config.DrainDelay > 0 {
+		slog.Info("Draining before stopping the servers", "delay", r.config.DrainDelay.String())
+		time.Sleep(r.config.DrainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.GracePeriod)
+	defer cancel()
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, server := range r.servers {
+		server := server
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("shutting down %s server: %w", server.Name, err))
+				mu.Unlock()
+				return
+			}
+			slog.Info("Server stopped", "server", server.Name)
+		}()
+	}
+	wg.Wait()
+
+	for _, hook := range r.hooks {
+		if err := hook.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReadinessHandler reports whether the instance should receive requests: it
+// fails while the servers are not running, or once they are shutting down,
+// and when check fails
+func (r *Runner) ReadinessHandler(check func(ctx context.Context) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		status, body := http.StatusOK, "ready"
+		if !r.Ready() {
+			status, body = http.StatusServiceUnavailable, "draining"
+		} else if check != nil {
+			ctx, cancel := context.WithTimeout(req.Context(), readinessTimeout)
+			defer cancel()
+			if err := check(ctx); err != nil {
+				slog.WarnContext(req.Context(), "Readiness check failed", "error", err)
+				status, body = http.StatusServiceUnavailable, "unavailable"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"status": body})
+	})
+}
+
+// HTTPServer serves server on listener. Shutting it down stops accepting
+// connections and waits for requests in flight, then closes the connections
+// left when ctx ends.
+func HTTPServer(name string, server *http.Server, listener net.Listener) Server {
+	return Server{
+		Name: name,
+		Addr: listener.Addr().String(),
+		Serve: func() error {
+			if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		Shutdown: func(ctx context.Context) error {
+			if err := server.Shutdown(ctx); err != nil {
+				server.Close()
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+// GRPCServer serves server on listener. Shutting it down stops accepting
+// connections and waits for calls in flight, then cancels the calls left when
+// ctx ends.
+func GRPCServer(name string, server *grpc.Server, listener net.Listener) Server {
+	return Server{
+		Name:  name,
+		Addr:  listener.Addr().String(),
+		Serve: func() error { return server.Serve(listener) },
+		Shutdown: func(ctx context.Context) error {
+			done := make(chan struct{})
+			go func() {
+				server.GracefulStop()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				server.Stop()
+				<-done
+				return ctx.Err()
+			}
+		},
+	}
+}
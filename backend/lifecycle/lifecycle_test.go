@@ -0,0 +1,256 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// events records what happened, in order
+type events struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (e *events) add(event string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.list = append(e.list, event)
+}
+
+func (e *events) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.list...)
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	return listener
+}
+
+// eventually waits up to a second for condition to hold
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestShutdownSequence(t *testing.T) {
+	var log events
+	entered, release := make(chan struct{}), make(chan struct{})
+	runner := New(Config{GracePeriod: 5 * time.Second, DrainDelay: 50 * time.Millisecond})
+
+	mux := http.NewServeMux()
+	mux.Handle("/ready", runner.ReadinessHandler(nil))
+	mux.HandleFunc("/feedback", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		log.add("request finished")
+		w.WriteHeader(http.StatusCreated)
+	})
+	listener := listen(t)
+	address := "http://" + listener.Addr().String()
+	runner.AddServer(HTTPServer("HTTP", &http.Server{Handler: mux}, listener))
+	runner.OnShutdown("closing the database", func(context.Context) error {
+		log.add("database closed")
+		return nil
+	})
+	runner.OnShutdown("flushing traces", func(context.Context) error {
+		log.add("traces flushed")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runner.Run(ctx) }()
+
+	// Every request gets a connection of its own, so no spare connection is
+	// left open for the server to wait for
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	eventually(t, "the server to become ready", func() bool {
+		resp, err := client.Get(address + "/ready")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+
+	inFlight := make(chan int, 1)
+	go func() {
+		resp, err := client.Post(address+"/feedback", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			inFlight <- 0
+			return
+		}
+		resp.Body.Close()
+		inFlight <- resp.StatusCode
+	}()
+	<-entered
+	cancel()
+
+	eventually(t, "readiness to fail once shutting down", func() bool { return !runner.Ready() })
+	rec := httptest.NewRecorder()
+	runner.ReadinessHandler(nil).ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "draining") {
+		t.Errorf("Expected /ready to report draining, got %d %s", rec.Code, rec.Body.String())
+	}
+	eventually(t, "new connections to be refused after the drain delay", func() bool {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	})
+	if got := log.get(); len(got) != 0 {
+		t.Errorf("Expected nothing to be closed while a request is in flight, got %v", got)
+	}
+
+	close(release)
+	if status := <-inFlight; status != http.StatusCreated {
+		t.Errorf("Expected the request in flight to finish, got status %d", status)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	expected := []string{"request finished", "database closed", "traces flushed"}
+	if got := log.get(); strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestGracePeriodExpires(t *testing.T) {
+	var log events
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	runner := New(Config{GracePeriod: 50 * time.Millisecond})
+
+	listener := listen(t)
+	runner.AddServer(HTTPServer("HTTP", &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})}, listener))
+	runner.OnShutdown("closing the database", func(context.Context) error {
+		log.add("database closed")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runner.Run(ctx) }()
+
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+	<-entered
+	cancel()
+
+	err := <-done
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the grace period to expire, got %v", err)
+	}
+	if <-requestErr == nil {
+		t.Errorf("Expected the request still running to be cut off")
+	}
+	if got := log.get(); len(got) != 1 {
+		t.Errorf("Expected the hooks to run after the grace period, got %v", got)
+	}
+}
+
+func TestServerFailure(t *testing.T) {
+	var log events
+	runner := New(Config{GracePeriod: time.Second})
+	runner.AddServer(Server{
+		Name:     "HTTP",
+		Serve:    func() error { return errors.New("address already in use") },
+		Shutdown: func(context.Context) error { return nil },
+	})
+	runner.OnShutdown("closing the database", func(context.Context) error {
+		log.add("database closed")
+		return errors.New("already closed")
+	})
+
+	err := runner.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "HTTP server: address already in use") || !strings.Contains(err.Error(), "closing the database: already closed") {
+		t.Errorf("Expected the server and hook errors, got %v", err)
+	}
+	if got := log.get(); len(got) != 1 {
+		t.Errorf("Expected the hooks to run after a server failed, got %v", got)
+	}
+}
+
+func TestGRPCServer(t *testing.T) {
+	runner := New(Config{GracePeriod: time.Second})
+	runner.AddServer(GRPCServer("gRPC", grpc.NewServer(), listen(t)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runner.Run(ctx) }()
+	eventually(t, "the server to become ready", runner.Ready)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the gRPC server to stop")
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		running  bool
+		check    func(context.Context) error
+		expected int
+		status   string
+	}{
+		{"Not running", false, nil, http.StatusServiceUnavailable, "draining"},
+		{"Running", true, nil, http.StatusOK, "ready"},
+		{"Database reachable", true, func(context.Context) error { return nil }, http.StatusOK, "ready"},
+		{"Database unreachable", true, func(context.Context) error { return errors.New("connection refused") }, http.StatusServiceUnavailable, "unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := New(Config{})
+			runner.ready.Store(tt.running)
+
+			rec := httptest.NewRecorder()
+			runner.ReadinessHandler(tt.check).ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), `"status":"`+tt.status+`"`) {
+				t.Errorf("Expected status %q, got %s", tt.status, rec.Body.String())
+			}
+		})
+	}
+}
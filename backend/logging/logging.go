@@ -0,0 +1,76 @@
+// Package logging writes structured JSON logs with log/slog.
+//
+// Records logged with a context carry the request ID, organization and actor
+// stored on it by the middleware, and the trace and span IDs of its span, so
+// every line written while serving a request, including those of the services
+// and database queries it runs, can be correlated:
+//
+//	slog.InfoContext(ctx, "Team member erased", "team_member_id", id)
+//
+// New builds the logger, which main installs as the default for slog and the
+// standard log package; NewGormLogger routes GORM's logs through it.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/auth"
+	"coaching-app-backend/tenant"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New returns a logger writing JSON records of level and above to w
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(&contextHandler{Handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(spec string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(spec))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("log level %q must be debug, info, warn or error", spec)
+	}
+	return level, nil
+}
+
+// contextHandler adds the request, organization, actor and span of the
+// record's context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if request, ok := audit.RequestFromContext(ctx); ok {
+			record.AddAttrs(slog.String("request_id", request.ID))
+		}
+		if organizationID, ok := tenant.FromContext(ctx); ok {
+			record.AddAttrs(slog.Uint64("organization_id", uint64(organizationID)))
+		}
+		if principal, ok := auth.FromContext(ctx); ok {
+			if principal.APIKeyID != 0 {
+				record.AddAttrs(slog.Uint64("api_key_id", uint64(principal.APIKeyID)))
+			} else {
+				record.AddAttrs(slog.Uint64("user_id", uint64(principal.UserID)))
+			}
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
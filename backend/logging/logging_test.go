@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tenant"
+
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// records decodes the JSON lines written to buf
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var result []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected JSON log lines, got %q: %v", line, err)
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected slog.Level
+		wantErr  bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{" warn ", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			level, err := ParseLevel(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && level != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, level)
+			}
+		})
+	}
+}
+
+func TestContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	ctx := audit.WithRequest(context.Background(), audit.Request{ID: "req-1", IP: "127.0.0.1"})
+	ctx = tenant.WithOrganization(ctx, 2)
+	ctx = auth.WithPrincipal(ctx, auth.Principal{UserID: 7, Role: models.RoleAdmin})
+	logger.InfoContext(ctx, "Handled", "team_id", 3)
+	logger.Info("Without context")
+	logger.DebugContext(ctx, "Below the level")
+
+	logged := records(t, &buf)
+	if len(logged) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %s", len(logged), buf.String())
+	}
+	first := logged[0]
+	if first["request_id"] != "req-1" || first["organization_id"] != float64(2) || first["user_id"] != float64(7) || first["team_id"] != float64(3) {
+		t.Errorf("Expected the request, organization and user of the context, got %v", first)
+	}
+	if _, ok := logged[1]["request_id"]; ok {
+		t.Errorf("Expected no request ID without a context, got %v", logged[1])
+	}
+
+	buf.Reset()
+	keyCtx := auth.WithPrincipal(context.Background(), auth.Principal{APIKeyID: 4})
+	logger.With("component", "test").InfoContext(keyCtx, "Key call")
+	logged = records(t, &buf)
+	if logged[0]["api_key_id"] != float64(4) || logged[0]["component"] != "test" {
+		t.Errorf("Expected the API key and logger attributes, got %v", logged[0])
+	}
+	if _, ok := logged[0]["user_id"]; ok {
+		t.Errorf("Expected no user ID for an API key, got %v", logged[0])
+	}
+
+	buf.Reset()
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	logger.InfoContext(trace.ContextWithSpanContext(context.Background(), span), "Traced")
+	logged = records(t, &buf)
+	if logged[0]["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || logged[0]["span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("Expected the trace and span of the context, got %v", logged[0])
+	}
+}
+
+func TestGormLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+	gormLog := NewGormLogger(logger, 50*time.Millisecond)
+	ctx := audit.WithRequest(context.Background(), audit.Request{ID: "req-2"})
+	query := func() (string, int64) { return "SELECT 1", 1 }
+
+	gormLog.Trace(ctx, time.Now(), query, nil)
+	if buf.Len() != 0 {
+		t.Errorf("Expected fast queries to be logged at debug level only, got %s", buf.String())
+	}
+
+	gormLog.Trace(ctx, time.Now().Add(-100*time.Millisecond), query, nil)
+	gormLog.Trace(ctx, time.Now(), query, errors.New("no such table"))
+	gormLog.Trace(ctx, time.Now(), query, gorm.ErrRecordNotFound)
+	logged := records(t, &buf)
+	if len(logged) != 2 {
+		t.Fatalf("Expected a slow query and a failed query, got %s", buf.String())
+	}
+	if logged[0]["msg"] != "Slow query" || logged[0]["level"] != "WARN" || logged[0]["sql"] != "SELECT 1" || logged[0]["request_id"] != "req-2" {
+		t.Errorf("Expected a slow query warning with the request ID, got %v", logged[0])
+	}
+	if logged[1]["msg"] != "Query failed" || logged[1]["level"] != "ERROR" || logged[1]["error"] != "no such table" {
+		t.Errorf("Expected a failed query error, got %v", logged[1])
+	}
+
+	buf.Reset()
+	gormLog.LogMode(gormlogger.Silent).Trace(ctx, time.Now(), query, errors.New("silent"))
+	gormLog.LogMode(gormlogger.Error).Trace(ctx, time.Now().Add(-100*time.Millisecond), query, nil)
+	if buf.Len() != 0 {
+		t.Errorf("Expected log modes to silence queries below their level, got %s", buf.String())
+	}
+
+	buf.Reset()
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: NewGormLogger(New(&buf, slog.LevelDebug), 0)})
+	db.WithContext(ctx).Exec("SELECT 1")
+	logged = records(t, &buf)
+	if len(logged) != 1 || logged[0]["msg"] != "Query" || logged[0]["request_id"] != "req-2" {
+		t.Errorf("Expected queries run with a context to be logged with its request ID, got %s", buf.String())
+	}
+}
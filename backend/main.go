@@ -1,30 +1,177 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"coaching-app-backend/auth"
+	"coaching-app-backend/cli"
+	"coaching-app-backend/config"
 	"coaching-app-backend/database"
+	"coaching-app-backend/encryption"
+	"coaching-app-backend/grpcapi"
 	"coaching-app-backend/handlers"
+	"coaching-app-backend/lifecycle"
+	"coaching-app-backend/logging"
+	"coaching-app-backend/metrics"
 	"coaching-app-backend/middleware"
+	"coaching-app-backend/oidc"
+	"coaching-app-backend/ratelimit"
+	"coaching-app-backend/services"
+	"coaching-app-backend/tracing"
+	"coaching-app-backend/validation"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
-	db, err := database.Connect()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		// Written as is rather than logged, so each invalid setting is on a
+		// line of its own
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	err = database.Migrate(db)
+	command, _, err := cli.Lookup(args)
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		fmt.Fprintf(os.Stderr, "%v\n\n", err)
+		cli.Usage(os.Stderr)
+		os.Exit(2)
+	}
+	if command.Name == "help" {
+		cli.Usage(os.Stdout)
+		return
+	}
+
+	// Commands other than serve print their results to stdout, so they log
+	// to stderr
+	logOutput := os.Stderr
+	if command.Name == "serve" {
+		logOutput = os.Stdout
 	}
+	logger := logging.New(logOutput, cfg.Log.Level)
+	slog.SetDefault(logger)
 
-	r := gin.Default()
+	shutdownTracing, err := setupTracing(cfg.Tracing)
+	if err != nil {
+		fatal("Invalid tracing configuration", err)
+	}
 
-	r.Use(middleware.CORS())
+	db, err := database.Connect(cfg.Database, logging.NewGormLogger(logger, cfg.Log.SlowQueryThreshold))
+	if err != nil {
+		fatal("Failed to connect to database", err)
+	}
+	if err := tracing.RegisterGorm(db); err != nil {
+		fatal("Failed to register query tracing", err)
+	}
+
+	encryptionKeys, err := setupEncryptionKeys(cfg.Encryption)
+	if err != nil {
+		fatal("Invalid encryption keys", err)
+	}
+	if err := encryption.Register(db, encryptionKeys); err != nil {
+		fatal("Failed to register field encryption", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal stops the process without waiting for shutdown
+		<-ctx.Done()
+		stop()
+	}()
+
+	app := &cli.App{
+		DB:   db,
+		Keys: encryptionKeys,
+		Out:  os.Stdout,
+		Err:  os.Stderr,
+		Serve: func(ctx context.Context) error {
+			return serve(ctx, cfg, db, logger)
+		},
+	}
+	err = app.Run(ctx, args)
+	// Done here rather than deferred, as failed commands exit
+	closeResources(db, shutdownTracing, cfg.Server.ShutdownGracePeriod)
+	switch {
+	case command.Name == "serve" && err != nil:
+		fatal("Server failed", err)
+	case command.Name == "serve":
+		slog.Info("Shutdown complete")
+	case errors.Is(err, cli.ErrUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// closeResources closes the database and flushes traces once the command is
+// done, whether or not it failed
+func closeResources(db *gorm.DB, shutdownTracing func(context.Context) error, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			slog.Error("Failed to close the database", "error", err)
+		}
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+}
+
+// serve migrates the database and serves the HTTP and gRPC APIs until ctx is
+// done, then shuts down gracefully. The caller closes the database.
+func serve(ctx context.Context, cfg *config.Config, db *gorm.DB, logger *slog.Logger) error {
+	if err := database.Migrate(db); err != nil {
+		return fmt.Errorf("migrating the database: %w", err)
+	}
+
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.New()
+	// Client IPs are taken from X-Forwarded-For only when set by a trusted proxy
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	server := setupHTTPServer(r, cfg.Server)
+	securityHeaders := middleware.DefaultSecurityHeadersConfig()
+	securityHeaders.HSTSMaxAge = cfg.Server.HSTSMaxAge
+	cors := middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Tracing())
+	r.Use(middleware.Metrics())
+	r.Use(middleware.AccessLog(logger))
+	r.Use(middleware.Recovery(logger))
+	r.Use(middleware.SecurityHeaders(securityHeaders))
+	r.Use(middleware.CORS(cors))
+	r.Use(middleware.HeaderLimit(server.MaxHeaderBytes))
+	r.Use(middleware.BodyLimit(setupBodyLimits(cfg.Server)))
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -34,19 +181,247 @@ func main() {
 		})
 	})
 
+	// Readiness fails once shutting down starts, and while the database is
+	// unreachable
+	runner := lifecycle.New(lifecycle.Config{
+		GracePeriod: cfg.Server.ShutdownGracePeriod,
+		DrainDelay:  cfg.Server.ShutdownDrainDelay,
+	})
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("accessing the database connection pool: %w", err)
+	}
+	r.GET("/ready", gin.WrapH(runner.ReadinessHandler(sqlDB.PingContext)))
+
+	if err := setupMetrics(r, db, cfg.Metrics.Token); err != nil {
+		return fmt.Errorf("setting up metrics: %w", err)
+	}
+
+	tokens, err := setupTokenIssuer(cfg.Auth)
+	if err != nil {
+		return fmt.Errorf("configuring authentication: %w", err)
+	}
+
+	if email := cfg.Auth.BootstrapAdminEmail; email != "" {
+		input := services.UserInput{Email: email, Password: cfg.Auth.BootstrapAdminPassword}
+		if err := validation.Struct(&input); err != nil {
+			return fmt.Errorf("invalid bootstrap admin account: %w", err)
+		}
+		created, err := services.NewAuthService(db, tokens).BootstrapUser(input)
+		if err != nil {
+			return fmt.Errorf("creating the bootstrap admin account: %w", err)
+		}
+		if created {
+			slog.Info("Created bootstrap admin account", "email", input.Email)
+		}
+	}
+
+	rateLimit := setupRateLimit(db, cfg.RateLimit)
+
+	erasurePolicy, err := services.ParseErasurePolicy(cfg.Privacy.ErasureAuthoredFeedback, cfg.Privacy.ErasureReceivedFeedback)
+	if err != nil {
+		return fmt.Errorf("invalid erasure policy: %w", err)
+	}
+
+	organizations := services.NewOrganizationService(db)
+	apiKeys := services.NewAPIKeyService(db)
+
+	authRoutes := r.Group("/api/auth")
+	authRoutes.Use(middleware.Tenant(organizations))
+	authRoutes.Use(rateLimit)
+	handlers.SetupAuthRoutes(authRoutes, db, tokens)
+	if cfg.OIDC.IssuerURL != "" {
+		provider, roles, err := setupOIDC(cfg.OIDC)
+		if err != nil {
+			return fmt.Errorf("configuring single sign-on: %w", err)
+		}
+		handlers.SetupOIDCRoutes(authRoutes, db, tokens, provider, roles)
+	}
+
 	api := r.Group("/api")
+	api.Use(middleware.Authenticate(tokens, apiKeys))
+	api.Use(middleware.Tenant(organizations))
+	api.Use(rateLimit)
+	api.Use(middleware.Idempotency(db, cfg.Server.IdempotencyKeyTTL))
 	{
+		handlers.SetupUserRoutes(api, db, tokens)
+		handlers.SetupAPIKeyRoutes(api, db)
 		handlers.SetupTeamMemberRoutes(api, db)
 		handlers.SetupTeamRoutes(api, db)
 		handlers.SetupAssignmentRoutes(api, db)
 		handlers.SetupFeedbackRoutes(api, db)
+		handlers.SetupCSVRoutes(api, db)
+		handlers.SetupGraphQLRoutes(api, db)
+		handlers.SetupAuditRoutes(api, db)
+		handlers.SetupOrganizationRoutes(api, db)
+		handlers.SetupPrivacyRoutes(api, db, erasurePolicy)
+	}
+
+	// Debug routes are for operating the deployment, so only admins of the
+	// default organization may use them
+	debug := r.Group("/debug")
+	debug.Use(middleware.Authenticate(tokens, apiKeys))
+	debug.Use(middleware.Tenant(organizations))
+	handlers.SetupConfigRoutes(debug, db, cfg)
+
+	grpcListener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.GRPCPort))
+	if err != nil {
+		return fmt.Errorf("listening for gRPC: %w", err)
+	}
+	httpListener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		grpcListener.Close()
+		return fmt.Errorf("listening for HTTP: %w", err)
+	}
+	runner.AddServer(lifecycle.HTTPServer("HTTP", server, httpListener))
+	runner.AddServer(lifecycle.GRPCServer("gRPC", grpcapi.NewServer(db, tokens), grpcListener))
+
+	return runner.Run(ctx)
+}
+
+// setupTracing starts exporting spans as configured. Tracing is off unless the
+// exporter is otlp, stdout or file; the OTLP endpoint and sampling are read
+// from the standard OTEL_* variables by the SDK.
+func setupTracing(cfg config.Tracing) (func(context.Context) error, error) {
+	return tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Exporter,
+		Protocol:    cfg.Protocol,
+		File:        cfg.File,
+		ServiceName: "coaching-app-backend",
+	})
+}
+
+// fatal logs err and exits. Exiting skips closing the database and flushing
+// traces, so it is only used before a command runs or after closeResources.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// setupMetrics serves the Prometheus metrics at /metrics, including database
+// pool statistics and query durations. When token is set, scrapers must send
+// it as a bearer token.
+func setupMetrics(r *gin.Engine, db *gorm.DB, token string) error {
+	if err := metrics.RegisterGorm(db); err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	metrics.RegisterDBStats(metrics.Default, sqlDB)
+
+	handler := metrics.Default.Handler()
+	r.GET("/metrics", func(c *gin.Context) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		handler.ServeHTTP(c.Writer, c.Request)
+	})
+	return nil
+}
+
+// setupEncryptionKeys reads the keys encrypting sensitive fields from the
+// keyring file, or from the configured keys. Without either, new values are
+// stored in plaintext.
+func setupEncryptionKeys(cfg config.Encryption) (*encryption.Keyring, error) {
+	if cfg.KeyringFile != "" {
+		return encryption.LoadKeyringFile(cfg.KeyringFile)
+	}
+	if cfg.Keys != "" {
+		return encryption.ParseKeyring(cfg.Keys)
+	}
+	slog.Warn("ENCRYPTION_KEYS is not set, storing feedback unencrypted")
+	return nil, nil
+}
+
+// setupHTTPServer creates the HTTP server with the configured port, timeouts
+// and header size limit. The timeouts keep slow or idle clients from holding
+// connections open indefinitely.
+func setupHTTPServer(handler http.Handler, cfg config.Server) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    int(cfg.MaxHeaderBytes),
+	}
+}
+
+// setupBodyLimits returns the request body size limits. Feedback and roster
+// imports have limits of their own, which configured routes override.
+func setupBodyLimits(cfg config.Server) middleware.BodyLimits {
+	limits := middleware.BodyLimits{
+		Default: int64(cfg.BodyLimit),
+		Routes: map[string]int64{
+			"POST /api/feedback":               64 << 10,
+			"POST /api/import/members/preview": 10 << 20,
+			"POST /api/import/members/commit":  10 << 20,
+		},
+	}
+	for route, limit := range cfg.BodyLimitRoutes {
+		limits.Routes[route] = limit
+	}
+	return limits
+}
+
+// setupTokenIssuer creates the issuer of access and refresh tokens. Without
+// signing keys a random key is used, which signs everyone out whenever the
+// server restarts.
+func setupTokenIssuer(cfg config.Auth) (*auth.TokenIssuer, error) {
+	var keys *auth.Keyring
+	var err error
+	if cfg.JWTSigningKeys != "" {
+		keys, err = auth.ParseKeyring(cfg.JWTSigningKeys)
+	} else {
+		slog.Warn("JWT_SIGNING_KEYS is not set, using a random signing key")
+		keys, err = auth.RandomKeyring()
+	}
+	if err != nil {
+		return nil, err
 	}
+	return auth.NewTokenIssuer(keys, cfg.AccessTokenTTL, cfg.RefreshTokenTTL), nil
+}
 
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+// setupRateLimit returns the rate limiting middleware. Sign-in and feedback
+// have tighter limits of their own, which configured routes override. Buckets
+// are kept in memory unless the store is database, which shares them between
+// instances.
+func setupRateLimit(db *gorm.DB, cfg config.RateLimit) gin.HandlerFunc {
+	limits := middleware.RateLimits{
+		Read:  cfg.Read,
+		Write: cfg.Write,
+		Routes: map[string]ratelimit.Limit{
+			"POST /api/auth/login": {Requests: 10, Period: time.Minute},
+			"POST /api/feedback":   {Requests: 30, Period: time.Minute},
+		},
+	}
+	for route, limit := range cfg.Routes {
+		limits.Routes[route] = limit
 	}
 
-	log.Printf("Server starting on port %s", port)
-	r.Run(":" + port)
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.Store == "database" {
+		store = ratelimit.NewDatabaseStore(db)
+	}
+	return middleware.RateLimit(store, limits)
+}
+
+// setupOIDC creates the OpenID Connect provider client and role mapping
+func setupOIDC(cfg config.OIDC) (*oidc.Provider, services.OIDCRoleMapping, error) {
+	roles, err := services.ParseOIDCRoleMapping(cfg.RoleClaim, cfg.RoleMapping)
+	if err != nil {
+		return nil, services.OIDCRoleMapping{}, err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	return oidc.NewProvider(oidc.Config{
+		IssuerURL:    cfg.IssuerURL,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+	}, client), roles, nil
 }
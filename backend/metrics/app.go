@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// namespace prefixes the names of the application's metrics
+const namespace = "coaching_"
+
+// Metrics of the API. Labels only take values from fixed sets, such as route
+// templates rather than paths, so their cardinality stays small.
+var (
+	HTTPRequests = Default.NewCounterVec(namespace+"http_requests_total",
+		"HTTP requests served, by method, route template and status code", "method", "route", "status")
+	HTTPRequestDuration = Default.NewHistogramVec(namespace+"http_request_duration_seconds",
+		"Time taken to serve HTTP requests, by method, route template and status code", DefaultBuckets, "method", "route", "status")
+	HTTPRequestsInFlight = Default.NewGauge(namespace+"http_requests_in_flight",
+		"HTTP requests being served")
+
+	DBQueryDuration = Default.NewHistogramVec(namespace+"db_query_duration_seconds",
+		"Time taken by database statements, by operation", DefaultBuckets, "operation")
+	DBQueryErrors = Default.NewCounterVec(namespace+"db_query_errors_total",
+		"Database statements that failed, by operation", "operation")
+
+	FeedbackCreated = Default.NewCounterVec(namespace+"feedback_created_total",
+		"Feedback entries created, by target type", "target_type")
+	AssignmentChanges = Default.NewCounterVec(namespace+"assignment_changes_total",
+		"Team assignments added or removed, by change", "change")
+)
+
+// Assignment changes
+const (
+	AssignmentAdded   = "added"
+	AssignmentRemoved = "removed"
+)
+
+// RegisterDBStats registers the connection pool statistics of db on r, read
+// on every scrape
+func RegisterDBStats(r *Registry, db *sql.DB) {
+	gauges := []struct {
+		name string
+		help string
+		fn   func(sql.DBStats) float64
+	}{
+		{"db_max_open_connections", "Maximum number of open database connections", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_open_connections", "Open database connections, in use or idle", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_in_use_connections", "Database connections in use", func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_idle_connections", "Idle database connections", func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	}
+	for _, g := range gauges {
+		fn := g.fn
+		r.NewGaugeFunc(namespace+g.name, g.help, func() float64 { return fn(db.Stats()) })
+	}
+
+	counters := []struct {
+		name string
+		help string
+		fn   func(sql.DBStats) float64
+	}{
+		{"db_wait_count_total", "Times a statement waited for a free database connection", func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_wait_duration_seconds_total", "Time spent waiting for free database connections", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_max_idle_closed_total", "Connections closed because the idle pool was full", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_max_idle_time_closed_total", "Connections closed because they were idle too long", func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, c := range counters {
+		fn := c.fn
+		r.NewCounterFunc(namespace+c.name, c.help, func() float64 { return fn(db.Stats()) })
+	}
+}
+
+const startSetting = "metrics:start"
+
+// RegisterGorm installs GORM callbacks timing every statement of db into
+// DBQueryDuration and counting failures into DBQueryErrors. Lookups finding
+// no record are not failures.
+func RegisterGorm(db *gorm.DB) error {
+	callbacks := db.Callback()
+	processors := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("*").Register, callbacks.Create().After("*").Register},
+		{"query", callbacks.Query().Before("*").Register, callbacks.Query().After("*").Register},
+		{"update", callbacks.Update().Before("*").Register, callbacks.Update().After("*").Register},
+		{"delete", callbacks.Delete().Before("*").Register, callbacks.Delete().After("*").Register},
+		{"row", callbacks.Row().Before("*").Register, callbacks.Row().After("*").Register},
+		{"raw", callbacks.Raw().Before("*").Register, callbacks.Raw().After("*").Register},
+	}
+	for _, p := range processors {
+		operation := p.operation
+		if err := p.before("metrics:before_"+operation, startTimer); err != nil {
+			return err
+		}
+		if err := p.after("metrics:after_"+operation, func(db *gorm.DB) { observe(db, operation) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(startSetting, time.Now())
+}
+
+func observe(db *gorm.DB, operation string) {
+	if start, ok := db.InstanceGet(startSetting); ok {
+		DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start.(time.Time)).Seconds())
+	}
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		DBQueryErrors.WithLabelValues(operation).Inc()
+	}
+}
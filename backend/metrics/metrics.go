@@ -0,0 +1,250 @@
+// Package metrics collects counters, gauges and histograms and exposes them
+// in the Prometheus text format.
+//
+// Metrics are created on a Registry, usually Default, and updated through the
+// series of their label values:
+//
+//	requests := metrics.Default.NewCounterVec("http_requests_total", "Requests served", "route")
+//	requests.WithLabelValues("/api/teams").Inc()
+//
+// Every metric holds at most MaxSeries label combinations. Once it is full,
+// new combinations are counted in a single series whose labels are all
+// OverflowLabel, so a misbehaving label cannot grow memory or the scrape
+// without bound.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// MaxSeries is the number of label combinations a metric holds
+	MaxSeries = 500
+	// OverflowLabel replaces the labels of combinations over MaxSeries
+	OverflowLabel = "other"
+	// ContentType is the content type of the Prometheus text format
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// DefaultBuckets are histogram buckets in seconds, suited to request and
+// query durations
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the registry the application's metrics are created on
+var Default = NewRegistry()
+
+// Registry holds metrics and writes them in the Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.metrics[name]; exists {
+		panic(fmt.Sprintf("metric %s is already registered", name))
+	}
+	r.metrics[name] = m
+}
+
+// WriteTo writes every metric, sorted by name
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics := make([]metric, len(names))
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	counter := &countingWriter{w: w}
+	buf := bufio.NewWriter(counter)
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	err := buf.Flush()
+	return counter.n, err
+}
+
+// Handler serves the registry's metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// value is a float64 updated atomically
+type value struct {
+	bits uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&v.bits, old, updated) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64) {
+	atomic.StoreUint64(&v.bits, math.Float64bits(f))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+// family holds the series of a metric by label values
+type family struct {
+	name      string
+	help      string
+	kind      string
+	labels    []string
+	newSeries func() interface{}
+
+	mu     sync.Mutex
+	series map[string]*labeledSeries
+}
+
+type labeledSeries struct {
+	values []string
+	series interface{}
+}
+
+func newFamily(name, help, kind string, labels []string, newSeries func() interface{}) *family {
+	return &family{name: name, help: help, kind: kind, labels: labels, newSeries: newSeries, series: make(map[string]*labeledSeries)}
+}
+
+// get returns the series of values, creating it if there is room
+func (f *family) get(values []string) interface{} {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.series[key]; ok {
+		return s.series
+	}
+	if len(f.series) >= MaxSeries {
+		values = make([]string, len(f.labels))
+		for i := range values {
+			values[i] = OverflowLabel
+		}
+		key = strings.Join(values, "\xff")
+		if s, ok := f.series[key]; ok {
+			return s.series
+		}
+	}
+	s := &labeledSeries{values: append([]string(nil), values...), series: f.newSeries()}
+	f.series[key] = s
+	return s.series
+}
+
+// sorted returns the series sorted by label values
+func (f *family) sorted() []*labeledSeries {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]*labeledSeries, len(keys))
+	for i, key := range keys {
+		series[i] = f.series[key]
+	}
+	f.mu.Unlock()
+	return series
+}
+
+func (f *family) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+}
+
+// writeSample writes one sample line, with extra label pairs after the
+// family's labels
+func writeSample(w *bufio.Writer, name string, labels, values []string, extra []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || len(extra) > 0 {
+		w.WriteByte('{')
+		first := true
+		pair := func(label, value string) {
+			if !first {
+				w.WriteByte(',')
+			}
+			first = false
+			w.WriteString(label)
+			w.WriteString(`="`)
+			w.WriteString(escapeLabel(value))
+			w.WriteByte('"')
+		}
+		for i, label := range labels {
+			pair(label, values[i])
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			pair(extra[i], extra[i+1])
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf strings.Builder
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	return buf.String()
+}
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests served", "route", "status")
+	requests.WithLabelValues("/teams", "200").Inc()
+	requests.WithLabelValues("/teams", "200").Add(2)
+	requests.WithLabelValues(`/say "hi"`+"\n", "500").Inc()
+	inFlight := r.NewGauge("in_flight", "Requests in flight")
+	inFlight.Inc()
+	inFlight.Inc()
+	inFlight.Dec()
+	durations := r.NewHistogramVec("duration_seconds", "Time taken", []float64{0.1, 1}, "route")
+	durations.WithLabelValues("/teams").Observe(0.05)
+	durations.WithLabelValues("/teams").Observe(0.1)
+	durations.WithLabelValues("/teams").Observe(3)
+	r.NewGaugeFunc("connections", "Open connections", func() float64 { return 4 })
+
+	expected := `# HELP connections Open connections
+# TYPE connections gauge
+connections 4
+# HELP duration_seconds Time taken
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/teams",le="0.1"} 2
+duration_seconds_bucket{route="/teams",le="1"} 2
+duration_seconds_bucket{route="/teams",le="+Inf"} 3
+duration_seconds_sum{route="/teams"} 3.15
+duration_seconds_count{route="/teams"} 3
+# HELP in_flight Requests in flight
+# TYPE in_flight gauge
+in_flight 1
+# HELP requests_total Requests served
+# TYPE requests_total counter
+requests_total{route="/say \"hi\"\n",status="500"} 1
+requests_total{route="/teams",status="200"} 3
+`
+	if got := scrape(t, r); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Header().Get("Content-Type") != ContentType || w.Body.String() != expected {
+		t.Errorf("Expected the handler to serve the metrics as %s, got %q", ContentType, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestSeriesLimit(t *testing.T) {
+	r := NewRegistry()
+	paths := r.NewCounterVec("paths_total", "Paths requested", "path", "method")
+	for i := 0; i < MaxSeries+10; i++ {
+		paths.WithLabelValues("/"+strconv.Itoa(i), "GET").Inc()
+	}
+	paths.WithLabelValues("/0", "GET").Inc()
+
+	output := scrape(t, r)
+	if lines := strings.Count(output, "paths_total{"); lines != MaxSeries+1 {
+		t.Errorf("Expected %d series including the overflow series, got %d", MaxSeries+1, lines)
+	}
+	if !strings.Contains(output, `paths_total{path="other",method="other"} 10`) {
+		t.Errorf("Expected combinations over the limit in the overflow series, got %s", output[len(output)-200:])
+	}
+	if paths.WithLabelValues("/0", "GET").Value() != 2 {
+		t.Errorf("Expected existing series to keep counting")
+	}
+}
+
+func TestRegistrationErrors(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("total", "Total")
+
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Duplicate name", func() { r.NewGauge("total", "Total") }},
+		{"Unsorted buckets", func() { r.NewHistogramVec("unsorted", "Unsorted", []float64{1, 0.5}) }},
+		{"Wrong label count", func() { r.NewCounterVec("labeled", "Labeled", "route").WithLabelValues() }},
+		{"Negative counter increment", func() { r.NewCounterVec("negative", "Negative").WithLabelValues().Add(-1) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
+
+func TestRegisterGorm(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err := RegisterGorm(db); err != nil {
+		t.Fatalf("Failed to register callbacks: %v", err)
+	}
+	type widget struct {
+		ID   uint
+		Name string
+	}
+	db.AutoMigrate(&widget{})
+
+	creates := DBQueryDuration.WithLabelValues("create")
+	queries := DBQueryDuration.WithLabelValues("query")
+	createsBefore, queriesBefore := atomic.LoadUint64(&creates.count), atomic.LoadUint64(&queries.count)
+	errorsBefore := DBQueryErrors.WithLabelValues("query").Value()
+
+	db.Create(&widget{Name: "a"})
+	var found widget
+	db.First(&found)
+	db.First(&found, 999)
+	db.Table("missing").Find(&found)
+
+	if got := atomic.LoadUint64(&creates.count) - createsBefore; got != 1 {
+		t.Errorf("Expected 1 timed create, got %d", got)
+	}
+	if got := atomic.LoadUint64(&queries.count) - queriesBefore; got != 3 {
+		t.Errorf("Expected 3 timed queries, got %d", got)
+	}
+	if got := DBQueryErrors.WithLabelValues("query").Value() - errorsBefore; got != 1 {
+		t.Errorf("Expected 1 failed query, not counting lookups finding nothing, got %v", got)
+	}
+}
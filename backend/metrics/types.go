@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// Counter is a value that only goes up
+type Counter struct {
+	v value
+}
+
+func (c *Counter) Inc() { c.v.add(1) }
+
+// Add increases the counter by delta, which must not be negative
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("counters cannot decrease")
+	}
+	c.v.add(delta)
+}
+
+// Value returns the current count
+func (c *Counter) Value() float64 { return c.v.get() }
+
+// CounterVec is a counter with labels
+type CounterVec struct {
+	f *family
+}
+
+// NewCounterVec registers a counter with the given labels
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	vec := &CounterVec{f: newFamily(name, help, "counter", labels, func() interface{} { return &Counter{} })}
+	r.register(name, vec)
+	return vec
+}
+
+// WithLabelValues returns the counter of the label values, in label order
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.f.get(values).(*Counter)
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.f.writeHeader(w)
+	for _, s := range v.f.sorted() {
+		writeSample(w, v.f.name, v.f.labels, s.values, nil, s.series.(*Counter).v.get())
+	}
+}
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	v value
+}
+
+func (g *Gauge) Set(v float64)     { g.v.set(v) }
+func (g *Gauge) Add(delta float64) { g.v.add(delta) }
+func (g *Gauge) Inc()              { g.v.add(1) }
+func (g *Gauge) Dec()              { g.v.add(-1) }
+
+// Value returns the current value
+func (g *Gauge) Value() float64 { return g.v.get() }
+
+// GaugeVec is a gauge with labels
+type GaugeVec struct {
+	f *family
+}
+
+// NewGaugeVec registers a gauge with the given labels
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	vec := &GaugeVec{f: newFamily(name, help, "gauge", labels, func() interface{} { return &Gauge{} })}
+	r.register(name, vec)
+	return vec
+}
+
+// NewGauge registers a gauge without labels
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).WithLabelValues()
+}
+
+// WithLabelValues returns the gauge of the label values, in label order
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return v.f.get(values).(*Gauge)
+}
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.f.writeHeader(w)
+	for _, s := range v.f.sorted() {
+		writeSample(w, v.f.name, v.f.labels, s.values, nil, s.series.(*Gauge).v.get())
+	}
+}
+
+// Histogram counts observations in buckets of increasing upper bounds
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     value
+}
+
+func (h *Histogram) Observe(v float64) {
+	// Observations are counted in the first bucket holding them and
+	// accumulated when written
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.counts) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	atomic.AddUint64(&h.count, 1)
+	h.sum.add(v)
+}
+
+// HistogramVec is a histogram with labels
+type HistogramVec struct {
+	f *family
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds, which
+// must be sorted, and labels
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of %s must be sorted", name))
+	}
+	buckets = append([]float64(nil), buckets...)
+	vec := &HistogramVec{}
+	vec.f = newFamily(name, help, "histogram", labels, func() interface{} {
+		return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	})
+	r.register(name, vec)
+	return vec
+}
+
+// WithLabelValues returns the histogram of the label values, in label order
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.f.get(values).(*Histogram)
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.f.writeHeader(w)
+	for _, s := range v.f.sorted() {
+		h := s.series.(*Histogram)
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += atomic.LoadUint64(&h.counts[i])
+			writeSample(w, v.f.name+"_bucket", v.f.labels, s.values, []string{"le", formatFloat(bound)}, float64(cumulative))
+		}
+		count := atomic.LoadUint64(&h.count)
+		writeSample(w, v.f.name+"_bucket", v.f.labels, s.values, []string{"le", "+Inf"}, float64(count))
+		writeSample(w, v.f.name+"_sum", v.f.labels, s.values, nil, h.sum.get())
+		writeSample(w, v.f.name+"_count", v.f.labels, s.values, nil, float64(count))
+	}
+}
+
+// funcMetric reads its value when the metrics are written
+type funcMetric struct {
+	name string
+	help string
+	kind string
+	fn   func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, kind: "gauge", fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is read from fn on every
+// scrape, for counts kept elsewhere
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, kind: "counter", fn: fn})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, escapeHelp(m.help), m.name, m.kind)
+	writeSample(w, m.name, nil, nil, nil, m.fn())
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"coaching-app-backend/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo)
+	router := gin.New()
+	router.Use(RequestID())
+	router.Use(AccessLog(logger))
+	router.Use(Recovery(logger))
+	router.GET("/teams/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	})
+	router.GET("/failing", func(c *gin.Context) {
+		c.Error(errors.New("database is down"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed"})
+	})
+	router.GET("/panicking", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	tests := []struct {
+		name          string
+		path          string
+		expectedCode  int
+		expectedLevel string
+		expectedRoute string
+		expectedError string
+	}{
+		{"Success", "/teams/1?secret=x", http.StatusOK, "INFO", "/teams/:id", ""},
+		{"Client error", "/missing", http.StatusNotFound, "WARN", "/missing", ""},
+		{"Server error", "/failing", http.StatusInternalServerError, "ERROR", "/failing", "database is down"},
+		{"Panic", "/panicking", http.StatusInternalServerError, "ERROR", "/panicking", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set(RequestIDHeader, "req-"+strings.ToLower(strings.ReplaceAll(tt.name, " ", "-")))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &record); err != nil {
+				t.Fatalf("Expected a JSON access log line, got %q", buf.String())
+			}
+			if record["level"] != tt.expectedLevel || record["route"] != tt.expectedRoute || record["status"] != float64(tt.expectedCode) {
+				t.Errorf("Expected a %s record for %s, got %v", tt.expectedLevel, tt.expectedRoute, record)
+			}
+			if record["request_id"] != w.Header().Get(RequestIDHeader) {
+				t.Errorf("Expected request ID %q, got %v", w.Header().Get(RequestIDHeader), record["request_id"])
+			}
+			if tt.expectedError != "" && record["error"] != tt.expectedError {
+				t.Errorf("Expected error %q, got %v", tt.expectedError, record["error"])
+			}
+			if strings.Contains(buf.String(), "secret") {
+				t.Errorf("Expected the query string to be left out, got %s", buf.String())
+			}
+		})
+	}
+
+	if !strings.Contains(buf.String(), `"panic":"boom"`) {
+		t.Errorf("Expected the panic to be logged, got %s", buf.String())
+	}
+}
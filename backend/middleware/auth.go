@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"coaching-app-backend/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalKey is the gin context key holding the authenticated auth.Principal
+const PrincipalKey = "principal"
+
+// APIKeyAuthenticator resolves an API key to the principal it acts as. It
+// returns auth.ErrInvalidAPIKey for keys that must be rejected.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (auth.Principal, error)
+}
+
+// Authenticate requires either a valid "Authorization: Bearer <access token>"
+// header or, when apiKeys is not nil, an "Authorization: ApiKey <key>" header.
+// The caller's principal is stored on the gin context and on the request
+// context, where services and auth.FromContext can find it.
+func Authenticate(issuer *auth.TokenIssuer, apiKeys APIKeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, credentials, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		credentials = strings.TrimSpace(credentials)
+		if credentials == "" {
+			unauthorized(c, "Authentication required")
+			return
+		}
+
+		var principal auth.Principal
+		var err error
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			principal, err = issuer.ParseAccessToken(credentials)
+			if err != nil {
+				unauthorized(c, "Invalid or expired access token")
+				return
+			}
+		case strings.EqualFold(scheme, "ApiKey") && apiKeys != nil:
+			principal, err = apiKeys.AuthenticateAPIKey(c.Request.Context(), credentials)
+			if errors.Is(err, auth.ErrInvalidAPIKey) {
+				unauthorized(c, "Invalid, expired or revoked API key")
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check API key"})
+				return
+			}
+		default:
+			unauthorized(c, "Authentication required")
+			return
+		}
+
+		c.Set(PrincipalKey, principal)
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="api"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message, "code": "UNAUTHENTICATED"})
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"coaching-app-backend/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeAPIKeys accepts "valid-key" and fails on "broken-key"
+type fakeAPIKeys struct{}
+
+func (fakeAPIKeys) AuthenticateAPIKey(ctx context.Context, key string) (auth.Principal, error) {
+	switch key {
+	case "valid-key":
+		return auth.Principal{APIKeyID: 7, Scopes: []string{"members:read"}}, nil
+	case "broken-key":
+		return auth.Principal{}, errors.New("database unavailable")
+	}
+	return auth.Principal{}, auth.ErrInvalidAPIKey
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys, _ := auth.RandomKeyring()
+	tokens := auth.NewTokenIssuer(keys, time.Minute, time.Hour)
+	token, _, _ := tokens.IssueAccessToken(auth.Principal{UserID: 42, Email: "coach@example.com"})
+
+	otherKeys, _ := auth.RandomKeyring()
+	foreign, _, _ := auth.NewTokenIssuer(otherKeys, time.Minute, time.Hour).IssueAccessToken(auth.Principal{UserID: 42})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Authenticate(tokens, fakeAPIKeys{}))
+	router.GET("/me", func(c *gin.Context) {
+		principal, _ := auth.FromContext(c.Request.Context())
+		c.JSON(http.StatusOK, principal)
+	})
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+	}{
+		{"Missing header", "", http.StatusUnauthorized},
+		{"Wrong scheme", "Basic " + token, http.StatusUnauthorized},
+		{"Malformed token", "Bearer not-a-token", http.StatusUnauthorized},
+		{"Token signed with another key", "Bearer " + foreign, http.StatusUnauthorized},
+		{"Valid token", "Bearer " + token, http.StatusOK},
+		{"Lowercase scheme", "bearer " + token, http.StatusOK},
+		{"Valid API key", "ApiKey valid-key", http.StatusOK},
+		{"Unknown API key", "ApiKey other-key", http.StatusUnauthorized},
+		{"API key lookup failure", "ApiKey broken-key", http.StatusInternalServerError},
+		{"API key as bearer token", "Bearer valid-key", http.StatusUnauthorized},
+	}
+
+	disabled := gin.New()
+	disabled.Use(Authenticate(tokens, nil))
+	disabled.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+	req, _ := http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "ApiKey valid-key")
+	w := httptest.NewRecorder()
+	disabled.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected API keys to be rejected when disabled, got %d", w.Code)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
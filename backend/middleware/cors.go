@@ -1,21 +1,188 @@
 package middleware
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
-func CORS() gin.HandlerFunc {
+// CORSConfig describes which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins lists origins such as "https://app.example.com".
+	// "https://*.example.com" allows every subdomain of example.com, and "*"
+	// allows any origin but never with credentials.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts may read
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and HTTP authentication
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// DefaultCORSConfig allows the given origins to use the API with the methods
+// and headers the frontend needs.
+func DefaultCORSConfig(origins ...string) CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Accept", "Authorization", "Cache-Control", "Content-Type", IdempotencyKeyHeader, OrganizationHeader, RequestIDHeader, "X-Requested-With"},
+		ExposedHeaders: []string{
+			IdempotentReplayedHeader, RequestIDHeader,
+			RateLimitLimitHeader, RateLimitRemainingHeader, RateLimitResetHeader, RateLimitPolicyHeader, RetryAfterHeader,
+		},
+		MaxAge: 10 * time.Minute,
+	}
+}
+
+// CORS answers preflight requests and adds CORS headers to responses for
+// allowed origins. Requests from other origins get no CORS headers, so
+// browsers keep their responses from scripts, and their preflights are
+// rejected with 403.
+func CORS(config CORSConfig) gin.HandlerFunc {
+	policy := newCORSPolicy(config)
+
 	return gin.HandlerFunc(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		// Responses differ by origin, so caches must not share them
+		c.Writer.Header().Add("Vary", "Origin")
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		if origin == "" {
+			c.Next()
 			return
 		}
+		allowOrigin, ok := policy.allowOrigin(origin)
+		if !ok {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", allowOrigin)
+		if policy.credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
 
-		c.Next()
+		if !preflight {
+			if policy.exposedHeaders != "" {
+				c.Header("Access-Control-Expose-Headers", policy.exposedHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		if !policy.methods[strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))] ||
+			!policy.allowHeaders(c.GetHeader("Access-Control-Request-Headers")) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Header("Access-Control-Allow-Methods", policy.allowedMethods)
+		if policy.allowedHeaders != "" {
+			c.Header("Access-Control-Allow-Headers", policy.allowedHeaders)
+		}
+		if policy.maxAge > 0 {
+			c.Header("Access-Control-Max-Age", strconv.Itoa(policy.maxAge))
+		}
+		c.AbortWithStatus(http.StatusNoContent)
 	})
 }
+
+// corsPolicy is a CORSConfig prepared for matching
+type corsPolicy struct {
+	anyOrigin bool
+	origins   map[string]bool
+	// wildcards holds the scheme and domain suffix of "scheme://*.domain"
+	wildcards      [][2]string
+	methods        map[string]bool
+	headers        map[string]bool
+	allowedMethods string
+	allowedHeaders string
+	exposedHeaders string
+	credentials    bool
+	maxAge         int
+}
+
+func newCORSPolicy(config CORSConfig) *corsPolicy {
+	policy := &corsPolicy{
+		origins:        make(map[string]bool),
+		methods:        make(map[string]bool),
+		headers:        make(map[string]bool),
+		allowedMethods: strings.Join(config.AllowedMethods, ", "),
+		allowedHeaders: strings.Join(config.AllowedHeaders, ", "),
+		exposedHeaders: strings.Join(config.ExposedHeaders, ", "),
+		credentials:    config.AllowCredentials,
+		maxAge:         int(config.MaxAge / time.Second),
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "*":
+			policy.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, domain, _ := strings.Cut(origin, "://*")
+			policy.wildcards = append(policy.wildcards, [2]string{scheme + "://", domain})
+		case origin != "":
+			policy.origins[origin] = true
+		}
+	}
+	for _, method := range config.AllowedMethods {
+		policy.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range config.AllowedHeaders {
+		policy.headers[strings.ToLower(header)] = true
+	}
+	// Preflights for simple requests still name these, and they are always
+	// allowed
+	policy.methods[http.MethodGet] = true
+	policy.methods[http.MethodHead] = true
+	policy.methods[http.MethodPost] = true
+	return policy
+}
+
+// allowOrigin returns the value of Access-Control-Allow-Origin for origin, or
+// false if it is not allowed. Origins are echoed rather than answered with
+// "*", except when any origin is allowed and credentials are not.
+func (p *corsPolicy) allowOrigin(origin string) (string, bool) {
+	normalized := strings.ToLower(origin)
+	if p.origins[normalized] {
+		return origin, true
+	}
+	for _, wildcard := range p.wildcards {
+		scheme, domain := wildcard[0], wildcard[1]
+		if strings.HasPrefix(normalized, scheme) && strings.HasSuffix(normalized, domain) &&
+			len(normalized) > len(scheme)+len(domain) && !strings.ContainsAny(normalized[len(scheme):], "/?#@") {
+			return origin, true
+		}
+	}
+	if p.anyOrigin && !p.credentials {
+		return "*", true
+	}
+	return "", false
+}
+
+// allowHeaders reports whether every header named in a preflight's
+// Access-Control-Request-Headers is allowed
+func (p *corsPolicy) allowHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header != "" && !p.headers[header] {
+			return false
+		}
+	}
+	return true
+}
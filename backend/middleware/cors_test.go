@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupCORSRouter(config CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CORS(config))
+	router.GET("/api/teams", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	router.PATCH("/api/teams", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	return router
+}
+
+func corsRequest(router *gin.Engine, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/api/teams", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	config := DefaultCORSConfig("https://app.example.com", "https://*.coaching.example.com")
+	config.AllowCredentials = true
+	router := setupCORSRouter(config)
+
+	tests := []struct {
+		name       string
+		origin     string
+		method     string
+		headers    string
+		wantStatus int
+		wantOrigin string
+	}{
+		{"Allowed origin", "https://app.example.com", "PATCH", "Authorization, Content-Type", http.StatusNoContent, "https://app.example.com"},
+		{"Wildcard subdomain", "https://team-a.coaching.example.com", "DELETE", "", http.StatusNoContent, "https://team-a.coaching.example.com"},
+		{"Nested subdomain", "https://eu.team-a.coaching.example.com", "GET", "", http.StatusNoContent, "https://eu.team-a.coaching.example.com"},
+		{"Wildcard does not match the domain itself", "https://coaching.example.com", "GET", "", http.StatusForbidden, ""},
+		{"Wildcard checks the scheme", "http://team-a.coaching.example.com", "GET", "", http.StatusForbidden, ""},
+		{"Lookalike domain", "https://evilcoaching.example.com", "GET", "", http.StatusForbidden, ""},
+		{"Unknown origin", "https://evil.example.com", "GET", "", http.StatusForbidden, ""},
+		{"Method not allowed", "https://app.example.com", "TRACE", "", http.StatusForbidden, "https://app.example.com"},
+		{"Header not allowed", "https://app.example.com", "POST", "X-Custom", http.StatusForbidden, "https://app.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := corsRequest(router, "OPTIONS", tt.origin, map[string]string{
+				"Access-Control-Request-Method":  tt.method,
+				"Access-Control-Request-Headers": tt.headers,
+			})
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if vary := w.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Origin" {
+				t.Errorf("Expected Vary: Origin, got %v", vary)
+			}
+		})
+	}
+
+	w := corsRequest(router, "OPTIONS", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "PATCH"})
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), "PATCH") {
+		t.Errorf("Expected PATCH to be allowed, got %q", w.Header().Get("Access-Control-Allow-Methods"))
+	}
+	if w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Expected max age 600, got %q", w.Header().Get("Access-Control-Max-Age"))
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("Expected credentials to be allowed")
+	}
+}
+
+func TestCORSActualRequests(t *testing.T) {
+	router := setupCORSRouter(DefaultCORSConfig("https://app.example.com"))
+
+	w := corsRequest(router, "GET", "https://app.example.com", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected allowed origin to be echoed, got %d %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), IdempotentReplayedHeader) {
+		t.Errorf("Expected exposed headers, got %q", w.Header().Get("Access-Control-Expose-Headers"))
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("Expected credentials not to be allowed by default")
+	}
+
+	// Other origins are still served, but browsers won't expose the response
+	w = corsRequest(router, "GET", "https://evil.example.com", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for unknown origin, got %d %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// Requests without an origin, and OPTIONS requests that are not
+	// preflights, reach the router untouched
+	if w := corsRequest(router, "GET", "", nil); w.Code != http.StatusOK || w.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected same-origin request to pass with Vary: Origin, got %d", w.Code)
+	}
+	if w := corsRequest(router, "OPTIONS", "https://app.example.com", nil); w.Code == http.StatusNoContent {
+		t.Error("Expected a plain OPTIONS request not to be answered as a preflight")
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	config := DefaultCORSConfig("*")
+	config.MaxAge = time.Hour
+	router := setupCORSRouter(config)
+
+	w := corsRequest(router, "GET", "https://anywhere.example.com", nil)
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected *, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// A wildcard is never combined with credentials
+	config.AllowCredentials = true
+	router = setupCORSRouter(config)
+	w = corsRequest(router, "GET", "https://anywhere.example.com", nil)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("Expected no CORS headers with credentials and any origin, got %v", w.Header())
+	}
+}
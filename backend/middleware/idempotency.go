@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"coaching-app-backend/models"
+	"coaching-app-backend/tenant"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+	// idempotencySweepInterval is how often expired keys are deleted
+	idempotencySweepInterval = time.Minute
+)
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first request with a key is processed normally and its response
+// is stored for ttl; a retry with the same key and body gets the stored
+// response replayed, while reusing the key with a different body is rejected
+// with 422. Keys are scoped to the user, API key or, for anonymous requests,
+// IP address sending them, so clients cannot collide on a key. Server errors
+// are not stored, so the client may retry them.
+func Idempotency(db *gorm.DB, ttl time.Duration) gin.HandlerFunc {
+	sweeper := &idempotencySweeper{}
+	return gin.HandlerFunc(func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			if BodyTooLarge(c, err) {
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		if err := sweeper.sweep(db, now); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to process idempotency key"})
+			return
+		}
+
+		// Stamped explicitly, as keys are looked up across organizations
+		organizationID, _ := tenant.FromContext(c.Request.Context())
+		record := models.IdempotencyKey{
+			OrganizationID: organizationID,
+			Client:         requestClient(c),
+			Key:            key,
+			Method:         c.Request.Method,
+			Path:           c.Request.URL.Path,
+			RequestHash:    hashRequest(c.Request, body),
+			ExpiresAt:      now.Add(ttl),
+		}
+
+		claimed, err := claimIdempotencyKey(db, &record, now)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to process idempotency key"})
+			return
+		}
+		if !claimed {
+			replayIdempotentResponse(c, db, record)
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				db.Delete(&record)
+			}
+		}()
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		err = db.Model(&record).Updates(map[string]interface{}{
+			"completed":     true,
+			"status_code":   c.Writer.Status(),
+			"content_type":  c.Writer.Header().Get("Content-Type"),
+			"response_body": recorder.body.String(),
+		}).Error
+		completed = err == nil
+	})
+}
+
+// claimIdempotencyKey inserts record unless its key is taken. Claiming the
+// key with an insert makes concurrent retries race on the unique index instead
+// of both running the handler. A taken key that has expired but not been
+// swept yet is released and claimed again.
+func claimIdempotencyKey(db *gorm.DB, record *models.IdempotencyKey, now time.Time) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.RowsAffected > 0, result.Error
+	}
+
+	scope := idempotencyScope(*record)
+	released := db.Where(scope).Where("expires_at < ?", now).Delete(&models.IdempotencyKey{})
+	if released.Error != nil || released.RowsAffected == 0 {
+		return false, released.Error
+	}
+	result = db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	return result.RowsAffected > 0, result.Error
+}
+
+// idempotencyScope returns the conditions matching the record of the same
+// client that claimed the key of record first
+func idempotencyScope(record models.IdempotencyKey) *models.IdempotencyKey {
+	return &models.IdempotencyKey{
+		OrganizationID: record.OrganizationID,
+		Client:         record.Client,
+		Key:            record.Key,
+		Method:         record.Method,
+		Path:           record.Path,
+	}
+}
+
+// idempotencySweeper deletes expired keys, at most once per
+// idempotencySweepInterval, so requests do not each write to the whole table
+type idempotencySweeper struct {
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func (s *idempotencySweeper) sweep(db *gorm.DB, now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < idempotencySweepInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	return db.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{}).Error
+}
+
+func replayIdempotentResponse(c *gin.Context, db *gorm.DB, claim models.IdempotencyKey) {
+	var stored models.IdempotencyKey
+	err := db.Where(idempotencyScope(claim)).First(&stored).Error
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to process idempotency key"})
+		return
+	}
+
+	switch {
+	case stored.RequestHash != claim.RequestHash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "Idempotency key was already used with a different request",
+			"code":  "IDEMPOTENCY_KEY_REUSED",
+		})
+	case !stored.Completed:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "A request with this idempotency key is still being processed",
+			"code":  "IDEMPOTENCY_KEY_IN_PROGRESS",
+		})
+	default:
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(stored.StatusCode, stored.ContentType, []byte(stored.ResponseBody))
+		c.Abort()
+	}
+}
+
+// hashRequest fingerprints a request. Keys are scoped to the client that sent
+// them, so the fingerprint only needs to cover the request itself.
+func hashRequest(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// bodyRecorder copies everything written to the response so it can be stored
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
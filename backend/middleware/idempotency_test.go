@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/encryption"
+	"coaching-app-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupIdempotencyRouter(ttl time.Duration) (*gin.Engine, *gorm.DB, *int) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&models.IdempotencyKey{})
+
+	calls := 0
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Idempotency(db, ttl))
+	router.POST("/feedback", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"id": calls})
+	})
+	router.POST("/fail", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+	})
+
+	return router, db, &calls
+}
+
+func postWithKey(router *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	router, _, calls := setupIdempotencyRouter(time.Hour)
+
+	first := postWithKey(router, "/feedback", "abc-123", `{"content":"Great work!"}`)
+	retry := postWithKey(router, "/feedback", "abc-123", `{"content":"Great work!"}`)
+
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated {
+		t.Fatalf("Expected both responses to be %d, got %d and %d", http.StatusCreated, first.Code, retry.Code)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", *calls)
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed body %q, got %q", first.Body.String(), retry.Body.String())
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("Expected replayed response to be marked")
+	}
+}
+
+func TestIdempotencyEncryptsStoredResponse(t *testing.T) {
+	router, db, _ := setupIdempotencyRouter(time.Hour)
+	key, _ := encryption.GenerateKey()
+	keys, err := encryption.ParseKeyring("k1:" + key)
+	if err != nil {
+		t.Fatalf("Failed to parse keyring: %v", err)
+	}
+	encryption.Register(db, keys)
+
+	first := postWithKey(router, "/feedback", "abc-123", `{"content":"Great work!"}`)
+	retry := postWithKey(router, "/feedback", "abc-123", `{"content":"Great work!"}`)
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed body %q, got %q", first.Body.String(), retry.Body.String())
+	}
+
+	var stored struct {
+		ResponseBody  string
+		ResponseKeyID string
+	}
+	db.Raw("SELECT response_body, response_key_id FROM idempotency_keys").Scan(&stored)
+	if stored.ResponseKeyID != "k1" {
+		t.Errorf("Expected response to be encrypted with key k1, got %q", stored.ResponseKeyID)
+	}
+	if strings.Contains(stored.ResponseBody, `"id"`) {
+		t.Errorf("Expected stored response to be encrypted, got %q", stored.ResponseBody)
+	}
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	router, _, calls := setupIdempotencyRouter(time.Hour)
+
+	postWithKey(router, "/feedback", "abc-123", `{"content":"Great work!"}`)
+	w := postWithKey(router, "/feedback", "abc-123", `{"content":"Something else"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected handler to run once, ran %d times", *calls)
+	}
+}
+
+func TestIdempotencyKeysArePerClient(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&models.IdempotencyKey{})
+
+	calls := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if userID, err := strconv.ParseUint(c.GetHeader("X-User"), 10, 32); err == nil {
+			principal := auth.Principal{UserID: uint(userID), Role: models.RoleMember}
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		}
+	})
+	router.Use(Idempotency(db, time.Hour))
+	router.POST("/feedback", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"id": calls})
+	})
+
+	post := func(user, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/feedback", bytes.NewBufferString(body))
+		req.Header.Set(IdempotencyKeyHeader, "abc-123")
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("1", `{"content":"Great work!"}`)
+	other := post("2", `{"content":"Something else"}`)
+	if first.Code != http.StatusCreated || other.Code != http.StatusCreated {
+		t.Fatalf("Expected both clients to be served, got %d and %d", first.Code, other.Code)
+	}
+	if other.Header().Get(IdempotentReplayedHeader) != "" || calls != 2 {
+		t.Errorf("Expected the second client's request to be processed, ran %d times", calls)
+	}
+
+	if retry := post("1", `{"content":"Great work!"}`); retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first client's response %q to be replayed, got %q", first.Body.String(), retry.Body.String())
+	}
+}
+
+func TestIdempotencyWithoutKeyOrAfterExpiry(t *testing.T) {
+	router, db, calls := setupIdempotencyRouter(time.Hour)
+
+	postWithKey(router, "/feedback", "", `{}`)
+	postWithKey(router, "/feedback", "", `{}`)
+	if *calls != 2 {
+		t.Errorf("Expected requests without a key to always run, ran %d times", *calls)
+	}
+
+	postWithKey(router, "/feedback", "abc-123", `{}`)
+	db.Model(&models.IdempotencyKey{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute))
+	postWithKey(router, "/feedback", "abc-123", `{}`)
+	if *calls != 4 {
+		t.Errorf("Expected an expired key to be processed again, ran %d times", *calls)
+	}
+}
+
+func TestIdempotencySweepsExpiredKeysPeriodically(t *testing.T) {
+	router, db, _ := setupIdempotencyRouter(time.Hour)
+
+	postWithKey(router, "/feedback", "abc-123", `{}`)
+	db.Model(&models.IdempotencyKey{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute))
+
+	// The first request swept the table, so the next one leaves it alone
+	postWithKey(router, "/feedback", "def-456", `{}`)
+	var expired int64
+	db.Model(&models.IdempotencyKey{}).Where(&models.IdempotencyKey{Key: "abc-123"}).Count(&expired)
+	if expired != 1 {
+		t.Errorf("Expected the expired key to wait for the next sweep, got %d", expired)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	router, db, calls := setupIdempotencyRouter(time.Hour)
+
+	postWithKey(router, "/fail", "abc-123", `{}`)
+	postWithKey(router, "/fail", "abc-123", `{}`)
+
+	if *calls != 2 {
+		t.Errorf("Expected server errors to be retryable, ran %d times", *calls)
+	}
+
+	var count int64
+	db.Model(&models.IdempotencyKey{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no stored keys, got %d", count)
+	}
+}
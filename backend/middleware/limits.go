@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultMaxBodyBytes   = 1 << 20
+	DefaultMaxHeaderBytes = 32 << 10
+)
+
+// BodyLimits configures the BodyLimit middleware
+type BodyLimits struct {
+	// Default is the largest body accepted by routes without a limit of
+	// their own
+	Default int64
+	// Routes replaces the default of single routes. Keys are a method and
+	// route pattern, such as "POST /api/feedback".
+	Routes map[string]int64
+}
+
+// BodyLimit rejects request bodies larger than the limit of their route with
+// 413. Bodies announcing a larger Content-Length are rejected up front; for
+// the rest the limit is enforced while reading, and handlers pass read errors
+// to BodyTooLarge to answer them the same way.
+func BodyLimit(limits BodyLimits) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		limit := limits.Default
+		if route, ok := limits.Routes[c.Request.Method+" "+c.FullPath()]; ok && c.FullPath() != "" {
+			limit = route
+		}
+		if limit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			abortBodyTooLarge(c)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	})
+}
+
+// BodyTooLarge writes a 413 response and returns true if err comes from
+// reading past the body limit
+func BodyTooLarge(c *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	abortBodyTooLarge(c)
+	return true
+}
+
+func abortBodyTooLarge(c *gin.Context) {
+	// The rest of the body is not read, so the connection cannot be reused
+	c.Header("Connection", "close")
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large", "code": "REQUEST_TOO_LARGE"})
+}
+
+// HeaderLimit rejects requests whose request line and headers together are
+// larger than max bytes with 431. The server's MaxHeaderBytes should be set
+// to the same value: Go allows some slack over it, which this middleware
+// answers in the API's error format, and rejects anything larger itself.
+func HeaderLimit(max int) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if max > 0 && headerSize(c.Request) > max {
+			c.Header("Connection", "close")
+			c.AbortWithStatusJSON(http.StatusRequestHeaderFieldsTooLarge, gin.H{"error": "Request headers are too large", "code": "HEADERS_TOO_LARGE"})
+			return
+		}
+		c.Next()
+	})
+}
+
+// headerSize approximates the size of the request line and headers as sent
+func headerSize(r *http.Request) int {
+	size := len(r.Method) + len(r.RequestURI) + len(r.Proto) + 4
+	for name, values := range r.Header {
+		for _, value := range values {
+			size += len(name) + len(value) + 4
+		}
+	}
+	if r.Host != "" {
+		size += len("Host") + len(r.Host) + 4
+	}
+	return size
+}
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"B", 1},
+}
+
+// ParseByteSize parses sizes such as "512", "64KB" or "10MB". Units are
+// binary, so 1KB is 1024 bytes.
+func ParseByteSize(spec string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(spec))
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("size %q must be a non-negative number of bytes, optionally followed by KB, MB or GB", spec)
+	}
+	return size * multiplier, nil
+}
+
+// ParseRouteBodyLimits parses route body limits written as comma-separated
+// "METHOD /path=size" entries, such as "POST /api/feedback=64KB"
+func ParseRouteBodyLimits(spec string) (map[string]int64, error) {
+	routes := make(map[string]int64)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return nil, fmt.Errorf("route body limit %q must have the form \"METHOD /path=size\"", entry)
+		}
+		size, err := ParseByteSize(value)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = size
+	}
+	return routes, nil
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupLimitsRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(HeaderLimit(1024))
+	router.Use(BodyLimit(BodyLimits{
+		Default: 16,
+		Routes:  map[string]int64{"POST /api/feedback": 64},
+	}))
+	handler := func(c *gin.Context) {
+		var input map[string]string
+		if err := c.ShouldBindJSON(&input); err != nil {
+			if BodyTooLarge(c, err) {
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, input)
+	}
+	router.POST("/api/teams", handler)
+	router.POST("/api/feedback", handler)
+	return router
+}
+
+func TestBodyLimit(t *testing.T) {
+	router := setupLimitsRouter()
+	long := `{"content": "` + strings.Repeat("a", 40) + `"}`
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		chunked        bool
+		expectedStatus int
+	}{
+		{"Small body", "/api/teams", `{"name": "A"}`, false, http.StatusOK},
+		{"Declared length over the default", "/api/teams", long, false, http.StatusRequestEntityTooLarge},
+		{"Chunked body over the default", "/api/teams", long, true, http.StatusRequestEntityTooLarge},
+		{"Route with a larger limit", "/api/feedback", long, false, http.StatusOK},
+		{"Chunked body within the route limit", "/api/feedback", long, true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				// Hide the length so that the limit is only hit while reading
+				body = io.MultiReader(body)
+			}
+			req, _ := http.NewRequest("POST", tt.path, body)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusRequestEntityTooLarge && !strings.Contains(w.Body.String(), `"error":"Request body is too large"`) {
+				t.Errorf("Expected the standard error format, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHeaderLimit(t *testing.T) {
+	router := setupLimitsRouter()
+
+	req, _ := http.NewRequest("POST", "/api/teams", strings.NewReader(`{}`))
+	req.Header.Set("X-Padding", strings.Repeat("a", 2048))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestHeaderFieldsTooLarge || !strings.Contains(w.Body.String(), `"error":"Request headers are too large"`) {
+		t.Errorf("Expected 431 in the standard error format, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req, _ = http.NewRequest("POST", "/api/teams", strings.NewReader(`{}`))
+	req.Header.Set("X-Padding", strings.Repeat("a", 512))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected headers under the limit to be accepted, got %d", w.Code)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected int64
+	}{
+		{"512", 512},
+		{"512B", 512},
+		{"64KB", 64 << 10},
+		{" 10mb ", 10 << 20},
+		{"1GB", 1 << 30},
+	}
+	for _, tt := range tests {
+		if size, err := ParseByteSize(tt.spec); err != nil || size != tt.expected {
+			t.Errorf("Expected %q to be %d bytes, got %d: %v", tt.spec, tt.expected, size, err)
+		}
+	}
+
+	for _, spec := range []string{"", "MB", "-1KB", "1.5MB", "10TB"} {
+		if _, err := ParseByteSize(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestParseRouteBodyLimits(t *testing.T) {
+	routes, err := ParseRouteBodyLimits("post /api/feedback=32KB, POST /api/graphql=256KB")
+	if err != nil {
+		t.Fatalf("Failed to parse route body limits: %v", err)
+	}
+	if routes["POST /api/feedback"] != 32<<10 || len(routes) != 2 {
+		t.Errorf("Unexpected route body limits %v", routes)
+	}
+
+	for _, spec := range []string{"/api/feedback=1KB", "POST /api/feedback", "POST api=1KB", "POST /api/feedback=big"} {
+		if _, err := ParseRouteBodyLimits(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
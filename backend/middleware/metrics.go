@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"coaching-app-backend/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnmatchedRoute labels requests for paths no route matches, which would
+// otherwise add a series for every path scanned
+const UnmatchedRoute = "unmatched"
+
+// standardMethods are labeled as they are; other methods are labeled
+// metrics.OverflowLabel
+var standardMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Metrics counts requests, times them by route template and status, and
+// tracks the requests in flight
+func Metrics() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		method := c.Request.Method
+		if !standardMethods[method] {
+			method = metrics.OverflowLabel
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = UnmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequests.WithLabelValues(method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
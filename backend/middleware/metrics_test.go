@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"coaching-app-backend/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	var inFlight float64
+	router.GET("/teams/:id", func(c *gin.Context) {
+		inFlight = metrics.HTTPRequestsInFlight.Value()
+		c.Status(http.StatusNoContent)
+	})
+	router.Handle("PROPFIND", "/teams/:id", func(c *gin.Context) {
+		c.Status(http.StatusMethodNotAllowed)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		labels []string
+	}{
+		{"Route template", "GET", "/teams/1", []string{"GET", "/teams/:id", "204"}},
+		{"Other team", "GET", "/teams/2", []string{"GET", "/teams/:id", "204"}},
+		{"Unmatched path", "GET", "/wp-login.php", []string{"GET", UnmatchedRoute, "404"}},
+		{"Unusual method", "PROPFIND", "/teams/1", []string{metrics.OverflowLabel, "/teams/:id", "405"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := metrics.HTTPRequests.WithLabelValues(tt.labels...)
+			before := counter.Value()
+
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got := counter.Value() - before; got != 1 {
+				t.Errorf("Expected the request to be counted as %v, got %v", tt.labels, got)
+			}
+		})
+	}
+
+	if inFlight < 1 {
+		t.Errorf("Expected the request to be in flight while served, got %v", inFlight)
+	}
+	if got := metrics.HTTPRequestsInFlight.Value(); got != 0 {
+		t.Errorf("Expected no requests in flight, got %v", got)
+	}
+}
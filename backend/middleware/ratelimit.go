@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+	RetryAfterHeader         = "Retry-After"
+)
+
+// RateLimits configures the RateLimit middleware
+type RateLimits struct {
+	// Read applies to GET, HEAD and OPTIONS requests
+	Read ratelimit.Limit
+	// Write applies to every other method
+	Write ratelimit.Limit
+	// Routes replaces the read or write limit of single routes. Keys are a
+	// method and route pattern, such as "POST /api/feedback", and each route
+	// has buckets of its own.
+	Routes map[string]ratelimit.Limit
+}
+
+// RateLimit limits how often each client may call the API, with a token
+// bucket per client and limit. Clients are told their allowance in RateLimit
+// headers and get 429 with Retry-After once it is spent. Authenticated
+// clients are identified by their user or API key, everyone else by IP
+// address, so the middleware belongs after Authenticate. If the store fails,
+// requests are let through rather than taking the API down with it.
+func RateLimit(store ratelimit.Store, limits RateLimits) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		scope, limit := limits.forRequest(c)
+		if !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		result, err := store.Take(c.Request.Context(), scope+" "+requestClient(c), limit)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Rate limiting failed, allowing request", "error", err)
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set(RateLimitLimitHeader, strconv.Itoa(limit.Requests))
+		header.Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+		header.Set(RateLimitResetHeader, ceilSeconds(result.Reset))
+		header.Set(RateLimitPolicyHeader, fmt.Sprintf("%d;w=%s", limit.Requests, ceilSeconds(limit.Period)))
+
+		if !result.Allowed {
+			header.Set(RetryAfterHeader, ceilSeconds(result.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please retry later"})
+			return
+		}
+		c.Next()
+	})
+}
+
+// forRequest returns the bucket scope and limit that apply to a request
+func (l RateLimits) forRequest(c *gin.Context) (string, ratelimit.Limit) {
+	route := c.Request.Method + " " + c.FullPath()
+	if limit, ok := l.Routes[route]; ok && c.FullPath() != "" {
+		return route, limit
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "read", l.Read
+	default:
+		return "write", l.Write
+	}
+}
+
+// ParseRouteLimits parses route limits written as comma-separated
+// "METHOD /path=limit" entries, such as "POST /api/feedback=20/1m"
+func ParseRouteLimits(spec string) (map[string]ratelimit.Limit, error) {
+	routes := make(map[string]ratelimit.Limit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return nil, fmt.Errorf("route limit %q must have the form \"METHOD /path=requests/period\"", entry)
+		}
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = limit
+	}
+	return routes, nil
+}
+
+// requestClient identifies who sent a request: the API key or user it was
+// authenticated as, or its IP address when it was not
+func requestClient(c *gin.Context) string {
+	if principal, ok := auth.FromContext(c.Request.Context()); ok {
+		if principal.APIKeyID != 0 {
+			return fmt.Sprintf("key:%d", principal.APIKeyID)
+		}
+		return fmt.Sprintf("user:%d", principal.UserID)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
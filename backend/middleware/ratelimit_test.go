@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupRateLimitRouter(principal *auth.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if principal != nil {
+		router.Use(func(c *gin.Context) {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), *principal))
+		})
+	}
+	router.Use(RateLimit(ratelimit.NewMemoryStore(), RateLimits{
+		Read:  ratelimit.Limit{Requests: 3, Period: time.Minute},
+		Write: ratelimit.Limit{Requests: 2, Period: time.Minute},
+		Routes: map[string]ratelimit.Limit{
+			"POST /api/feedback": {Requests: 1, Period: time.Minute},
+		},
+	}))
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+	router.GET("/api/teams", ok)
+	router.POST("/api/teams", ok)
+	router.POST("/api/feedback", ok)
+	return router
+}
+
+func TestRateLimit(t *testing.T) {
+	router := setupRateLimitRouter(nil)
+	send := func(method, path, ip string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := send("GET", "/api/teams", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("Expected read %d to be allowed, got %d", i+1, w.Code)
+		}
+	}
+	w := send("GET", "/api/teams", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 once reads are spent, got %d", w.Code)
+	}
+	if w.Header().Get(RetryAfterHeader) != "20" || w.Header().Get(RateLimitRemainingHeader) != "0" {
+		t.Errorf("Unexpected rate limit headers %v", w.Header())
+	}
+
+	// Writes, single routes and other clients are counted separately
+	w = send("POST", "/api/teams", "10.0.0.1")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected write to be allowed, got %d", w.Code)
+	}
+	if w.Header().Get(RateLimitLimitHeader) != "2" || w.Header().Get(RateLimitRemainingHeader) != "1" ||
+		w.Header().Get(RateLimitResetHeader) != "30" || w.Header().Get(RateLimitPolicyHeader) != "2;w=60" {
+		t.Errorf("Unexpected rate limit headers %v", w.Header())
+	}
+	if w := send("POST", "/api/feedback", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("Expected feedback to be allowed, got %d", w.Code)
+	}
+	if w := send("POST", "/api/feedback", "10.0.0.1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the feedback route limit to apply, got %d", w.Code)
+	}
+	if w := send("GET", "/api/teams", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("Expected another IP to be allowed, got %d", w.Code)
+	}
+}
+
+func TestRateLimitByPrincipal(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal auth.Principal
+	}{
+		{"User", auth.Principal{UserID: 1}},
+		{"API key", auth.Principal{APIKeyID: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRateLimitRouter(&tt.principal)
+			// Changing addresses doesn't escape the limit
+			for i := 0; i < 4; i++ {
+				req, _ := http.NewRequest("POST", "/api/teams", nil)
+				req.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", i+1)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if want := i < 2; (w.Code == http.StatusOK) != want {
+					t.Errorf("Request %d: expected allowed %v, got %d", i+1, want, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	routes, err := ParseRouteLimits("post /api/feedback=5/1m, GET /api/teams/:id=100/1m")
+	if err != nil {
+		t.Fatalf("Failed to parse route limits: %v", err)
+	}
+	if routes["POST /api/feedback"] != (ratelimit.Limit{Requests: 5, Period: time.Minute}) || len(routes) != 2 {
+		t.Errorf("Unexpected route limits %v", routes)
+	}
+
+	for _, spec := range []string{"/api/feedback=5/1m", "POST /api/feedback", "POST api=5/1m", "POST /api/feedback=fast"} {
+		if _, err := ParseRouteLimits(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
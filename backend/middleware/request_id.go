@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"coaching-app-backend/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID gives every request an ID, reusing the X-Request-ID header sent
+// by the client or a proxy when it is valid, and returns it in the response.
+// The ID and the client's IP are stored on the request context, where audit
+// events pick them up.
+func RequestID() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		id := audit.EnsureRequestID(c.GetHeader(RequestIDHeader))
+		c.Header(RequestIDHeader, id)
+		ctx := audit.WithRequest(c.Request.Context(), audit.Request{ID: id, IP: c.ClientIP()})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	})
+}
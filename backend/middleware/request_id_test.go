@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"coaching-app-backend/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		request, _ := audit.RequestFromContext(c.Request.Context())
+		c.String(http.StatusOK, request.ID)
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"Reuses a valid ID", "trace-123.abc:1", "trace-123.abc:1"},
+		{"Replaces an invalid ID", "bad id\n", ""},
+		{"Replaces a long ID", strings.Repeat("a", 65), ""},
+		{"Generates a missing ID", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id != w.Body.String() {
+				t.Errorf("Expected the context to carry the response ID %q, got %q", id, w.Body.String())
+			}
+			if tt.expected != "" && id != tt.expected {
+				t.Errorf("Expected ID %q, got %q", tt.expected, id)
+			}
+			if tt.expected == "" && len(id) != 32 {
+				t.Errorf("Expected a generated ID, got %q", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHeader names the organization of a request by slug or ID
+const OrganizationHeader = "X-Organization"
+
+// OrganizationResolver finds the ID of an organization by slug or ID. It
+// returns tenant.ErrUnknownOrganization for organizations that do not exist.
+type OrganizationResolver interface {
+	ResolveOrganization(ctx context.Context, slugOrID string) (uint, error)
+}
+
+// Tenant stores the organization a request acts for on the request context,
+// where the tenant callbacks scope every query to it. Authenticated callers
+// act for the organization of their account or API key; an
+// X-Organization header naming another one is rejected with 403. Other
+// callers, such as those signing in, act for the organization named by the
+// header, or the default organization without one.
+func Tenant(organizations OrganizationResolver) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		ctx := c.Request.Context()
+		header := c.GetHeader(OrganizationHeader)
+
+		var requested uint
+		if header != "" {
+			id, err := organizations.ResolveOrganization(ctx, header)
+			if errors.Is(err, tenant.ErrUnknownOrganization) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Unknown organization"})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve organization"})
+				return
+			}
+			requested = id
+		}
+
+		organizationID := requested
+		if principal, ok := auth.FromContext(ctx); ok {
+			if principal.OrganizationID == 0 {
+				// Issued before organizations existed
+				unauthorized(c, "Access token has no organization, please sign in again")
+				return
+			}
+			if requested != 0 && requested != principal.OrganizationID {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Your credentials belong to another organization"})
+				return
+			}
+			organizationID = principal.OrganizationID
+		}
+		if organizationID == 0 {
+			organizationID = models.DefaultOrganizationID
+		}
+
+		c.Request = c.Request.WithContext(tenant.WithOrganization(ctx, organizationID))
+		c.Next()
+	})
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeOrganizations resolves slugs from a fixed set
+type fakeOrganizations map[string]uint
+
+func (f fakeOrganizations) ResolveOrganization(ctx context.Context, slugOrID string) (uint, error) {
+	if slugOrID == "broken" {
+		return 0, errors.New("database is down")
+	}
+	if id, ok := f[slugOrID]; ok {
+		return id, nil
+	}
+	return 0, tenant.ErrUnknownOrganization
+}
+
+func TestTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	organizations := fakeOrganizations{"default": 1, "other": 2}
+
+	tests := []struct {
+		name           string
+		principal      *auth.Principal
+		header         string
+		expectedStatus int
+		expected       uint
+	}{
+		{"Defaults to the default organization", nil, "", http.StatusOK, 1},
+		{"Uses the header without credentials", nil, "other", http.StatusOK, 2},
+		{"Rejects unknown organizations", nil, "missing", http.StatusBadRequest, 0},
+		{"Reports resolver failures", nil, "broken", http.StatusInternalServerError, 0},
+		{"Uses the principal's organization", &auth.Principal{UserID: 1, OrganizationID: 2}, "", http.StatusOK, 2},
+		{"Accepts a matching header", &auth.Principal{UserID: 1, OrganizationID: 2}, "other", http.StatusOK, 2},
+		{"Rejects another organization", &auth.Principal{UserID: 1, OrganizationID: 2}, "default", http.StatusForbidden, 0},
+		{"Rejects tokens without an organization", &auth.Principal{UserID: 1}, "", http.StatusUnauthorized, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if tt.principal != nil {
+				router.Use(func(c *gin.Context) {
+					c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), *tt.principal))
+				})
+			}
+			router.Use(Tenant(organizations))
+			router.GET("/", func(c *gin.Context) {
+				organizationID, _ := tenant.FromContext(c.Request.Context())
+				c.String(http.StatusOK, strconv.FormatUint(uint64(organizationID), 10))
+			})
+
+			req, _ := http.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(OrganizationHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expected != 0 && w.Body.String() != strconv.FormatUint(uint64(tt.expected), 10) {
+				t.Errorf("Expected organization %d, got %s", tt.expected, w.Body.String())
+			}
+		})
+	}
+}
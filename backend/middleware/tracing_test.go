@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"coaching-app-backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Tracing())
+	var handlerSpan trace.SpanContext
+	router.GET("/teams/:id", func(c *gin.Context) {
+		_, span := tracing.Start(c.Request.Context(), "TeamService.FindTeam")
+		handlerSpan = span.SpanContext()
+		span.End()
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/failing", func(c *gin.Context) {
+		c.Error(errors.New("database is down"))
+		c.Status(http.StatusInternalServerError)
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		traceparent string
+		spanName    string
+		failed      bool
+	}{
+		{"Route template", "/teams/1", "", "GET /teams/:id", false},
+		{"Incoming trace", "/teams/2", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "GET /teams/:id", false},
+		{"Unmatched path", "/wp-login.php", "", "GET", false},
+		{"Server error", "/failing", "", "GET /failing", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			handlerSpan = trace.SpanContext{}
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			var server tracetest.SpanStub
+			for _, span := range exporter.GetSpans() {
+				if span.SpanKind == trace.SpanKindServer {
+					server = span
+				}
+			}
+			if server.Name != tt.spanName {
+				t.Fatalf("Expected a server span named %q, got %v", tt.spanName, exporter.GetSpans())
+			}
+			if tt.traceparent != "" {
+				if server.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+					t.Errorf("Expected the span to continue the incoming trace, got trace %s and parent %s", server.SpanContext.TraceID(), server.Parent.SpanID())
+				}
+			} else if server.Parent.IsValid() {
+				t.Errorf("Expected a new trace, got parent %s", server.Parent.SpanID())
+			}
+			if handlerSpan.IsValid() && handlerSpan.TraceID() != server.SpanContext.TraceID() {
+				t.Errorf("Expected spans started by the handler to join the request's trace")
+			}
+			if failed := server.Status.Code == codes.Error; failed != tt.failed {
+				t.Errorf("Expected failed %v, got status %v", tt.failed, server.Status)
+			}
+			if tt.failed && len(server.Events) == 0 {
+				t.Errorf("Expected the request's errors to be recorded")
+			}
+		})
+	}
+}
@@ -1,21 +1,50 @@
 package models
 
 import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
 	"time"
 )
 
+// DefaultOrganizationID is the organization created by the first migration.
+// Data from before organizations existed belongs to it.
+const DefaultOrganizationID = 1
+
+// Organization is a tenant: a business unit whose teams, members, feedback
+// and accounts are invisible to every other organization.
+type Organization struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null;size:255"`
+	Slug      string    `json:"slug" gorm:"not null;uniqueIndex;size:63"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
 type TeamMember struct {
-	ID      uint   `json:"id" gorm:"primaryKey"`
-	Name    string `json:"name" gorm:"not null;size:255"`
-	Picture string `json:"picture" gorm:"size:500"`
-	Email   string `json:"email" gorm:"not null;unique;size:255"`
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	OrganizationID uint   `json:"-" gorm:"not null;default:1;uniqueIndex:idx_team_members_organization_email,priority:1"`
+	Name           string `json:"name" gorm:"not null;size:255"`
+	Picture        string `json:"picture" gorm:"size:500"`
+	Email          string `json:"email" gorm:"not null;size:255;uniqueIndex:idx_team_members_organization_email,priority:2"`
+	// ErasedAt is set once the member's personal data has been erased
+	ErasedAt *time.Time `json:"erased_at,omitempty"`
+
+	// Optional relations, only populated when requested through includes
+	Teams         []Team     `json:"teams,omitempty" gorm:"many2many:team_assignments;"`
+	Feedback      []Feedback `json:"feedback,omitempty" gorm:"-"`
+	FeedbackCount *int64     `json:"feedback_count,omitempty" gorm:"-"`
 }
 
 type Team struct {
-	ID      uint         `json:"id" gorm:"primaryKey"`
-	Name    string       `json:"name" gorm:"not null;size:255"`
-	Logo    string       `json:"logo" gorm:"size:500"`
-	Members []TeamMember `json:"members" gorm:"many2many:team_assignments;"`
+	ID             uint         `json:"id" gorm:"primaryKey"`
+	OrganizationID uint         `json:"-" gorm:"not null;default:1;index"`
+	Name           string       `json:"name" gorm:"not null;size:255"`
+	Logo           string       `json:"logo" gorm:"size:500"`
+	Members        []TeamMember `json:"members" gorm:"many2many:team_assignments;"`
+
+	// Optional relations, only populated when requested through includes
+	Feedback      []Feedback `json:"feedback,omitempty" gorm:"-"`
+	FeedbackCount *int64     `json:"feedback_count,omitempty" gorm:"-"`
 }
 
 type TeamAssignment struct {
@@ -24,9 +53,229 @@ type TeamAssignment struct {
 }
 
 type Feedback struct {
-	ID         uint      `json:"id" gorm:"primaryKey"`
-	Content    string    `json:"content" gorm:"not null;type:text"`
-	TargetType string    `json:"target_type" gorm:"not null;size:50"`
-	TargetID   uint      `json:"target_id" gorm:"not null"`
-	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	OrganizationID uint   `json:"-" gorm:"not null;default:1;index"`
+	Content        string `json:"content" gorm:"not null;type:text" encrypted:"ContentKeyID"`
+	// ContentKeyID is the key Content is encrypted with, empty for feedback
+	// stored before encryption was enabled
+	ContentKeyID string    `json:"-" gorm:"not null;default:'';size:64;index"`
+	TargetType   string    `json:"target_type" gorm:"not null;size:50"`
+	TargetID     uint      `json:"target_id" gorm:"not null"`
+	AuthorID     *uint     `json:"author_id,omitempty" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// User roles, from most to least privileged
+const (
+	RoleAdmin    = "admin"
+	RoleCoach    = "coach"
+	RoleTeamLead = "team_lead"
+	RoleMember   = "member"
+)
+
+// User is an account that can sign in to the API. It may be linked to the
+// TeamMember record of the same person.
+type User struct {
+	ID             uint        `json:"id" gorm:"primaryKey"`
+	OrganizationID uint        `json:"-" gorm:"not null;default:1;uniqueIndex:idx_users_organization_email,priority:1;uniqueIndex:idx_users_organization_oidc_subject,priority:1"`
+	Email          string      `json:"email" gorm:"not null;size:255;uniqueIndex:idx_users_organization_email,priority:2"`
+	PasswordHash   string      `json:"-" gorm:"not null;size:255"`
+	Role           string      `json:"role" gorm:"not null;size:20;default:member"`
+	TeamMemberID   *uint       `json:"team_member_id,omitempty" gorm:"uniqueIndex"`
+	TeamMember     *TeamMember `json:"team_member,omitempty"`
+	// OIDCSubject links the account to its identity provider subject. Accounts
+	// created through single sign-on have no password.
+	OIDCSubject *string   `json:"-" gorm:"column:oidc_subject;size:255;uniqueIndex:idx_users_organization_oidc_subject,priority:2"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TeamCoach assigns a user with the coach role to a team they coach
+type TeamCoach struct {
+	TeamID uint `json:"team_id" gorm:"primaryKey"`
+	UserID uint `json:"user_id" gorm:"primaryKey"`
+}
+
+// RefreshToken is a single-use token exchanged for a new access token. Each
+// use rotates it within its family; presenting a token that was already
+// rotated revokes the whole family.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex;size:64"`
+	FamilyID  string     `json:"family_id" gorm:"not null;index;size:64"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RateLimitBucket is the token bucket of one client and route group, for
+// rate limits shared by several server instances.
+type RateLimitBucket struct {
+	Key        string    `json:"key" gorm:"column:bucket_key;primaryKey;size:255"`
+	Tokens     float64   `json:"tokens" gorm:"not null"`
+	RefilledAt time.Time `json:"refilled_at" gorm:"not null"`
+	// ExpiresAt is when the bucket is full again and can be forgotten
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// OIDCLoginState holds what a single sign-on attempt needs to be completed
+// when the identity provider redirects back: the PKCE verifier and the nonce
+// the ID token must carry. It is looked up by the hash of the state parameter.
+type OIDCLoginState struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// SignInOrganizationID is the organization the user signs in to. It is
+	// not named OrganizationID, so that the state is found however the
+	// provider's redirect resolves its organization.
+	SignInOrganizationID uint      `json:"-" gorm:"not null;default:1"`
+	StateHash            string    `json:"-" gorm:"not null;uniqueIndex;size:64"`
+	Nonce                string    `json:"-" gorm:"not null;size:64"`
+	CodeVerifier         string    `json:"-" gorm:"not null;size:128"`
+	ExpiresAt            time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt            time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// APIKey grants an integration access to the API with a fixed set of
+// scopes. Only a hash of the key is stored; the key itself is shown once.
+type APIKey struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrganizationID uint       `json:"-" gorm:"not null;default:1;index"`
+	Name           string     `json:"name" gorm:"not null;size:100"`
+	Prefix         string     `json:"prefix" gorm:"not null;size:20"`
+	KeyHash        string     `json:"-" gorm:"not null;uniqueIndex;size:64"`
+	Scopes         ScopeList  `json:"scopes" gorm:"not null;size:500"`
+	CreatedByID    uint       `json:"created_by_id" gorm:"not null"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// ScopeList is stored as a space-separated string
+type ScopeList []string
+
+func (s ScopeList) Value() (driver.Value, error) {
+	return strings.Join(s, " "), nil
+}
+
+func (s *ScopeList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*s = strings.Fields(v)
+	case []byte:
+		*s = strings.Fields(string(v))
+	case nil:
+		*s = nil
+	default:
+		return fmt.Errorf("cannot scan %T into ScopeList", value)
+	}
+	return nil
+}
+
+func (ScopeList) GormDataType() string {
+	return "string"
+}
+
+// AuditEvent records a change made to a resource: who made it, from which
+// request, and the resource before and after the change. Details holds
+// further JSON context for changes that touch several resources.
+type AuditEvent struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"-" gorm:"not null;default:1;index"`
+	Action         string    `json:"action" gorm:"not null;size:100;index"`
+	EntityType     string    `json:"entity_type" gorm:"not null;size:50;index:idx_audit_entity"`
+	EntityID       uint      `json:"entity_id" gorm:"not null;index:idx_audit_entity"`
+	ActorUserID    *uint     `json:"actor_user_id,omitempty" gorm:"index"`
+	ActorAPIKeyID  *uint     `json:"actor_api_key_id,omitempty" gorm:"column:actor_api_key_id;index"`
+	Before         JSON      `json:"before" gorm:"type:text"`
+	After          JSON      `json:"after" gorm:"type:text"`
+	Details        JSON      `json:"details,omitempty" gorm:"type:text"`
+	RequestID      string    `json:"request_id,omitempty" gorm:"size:64;index"`
+	IP             string    `json:"ip,omitempty" gorm:"size:45"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// ErasureRecord documents the erasure of a team member's personal data: who
+// asked for it, why, under which policy and what was changed. The records of
+// an organization form a hash chain, each Hash covering the record and the
+// Hash of the record before it, so changing or removing a record breaks the
+// chain from there on. Each record may follow only one other, so concurrent
+// erasures cannot fork the chain.
+type ErasureRecord struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID    uint      `json:"-" gorm:"not null;default:1;uniqueIndex:idx_erasure_records_chain,priority:1"`
+	TeamMemberID      uint      `json:"team_member_id" gorm:"not null;index"`
+	RequestedByUserID uint      `json:"requested_by_user_id" gorm:"not null"`
+	Reason            string    `json:"reason" gorm:"not null;size:500"`
+	Policy            JSON      `json:"policy" gorm:"type:text"`
+	Summary           JSON      `json:"summary" gorm:"type:text"`
+	RequestID         string    `json:"request_id,omitempty" gorm:"size:64"`
+	CreatedAt         time.Time `json:"created_at" gorm:"not null"`
+	PreviousHash      string    `json:"previous_hash" gorm:"not null;size:64;uniqueIndex:idx_erasure_records_chain,priority:2"`
+	Hash              string    `json:"hash" gorm:"not null;size:64;uniqueIndex"`
+}
+
+// JSON is a JSON document stored as text and rendered as-is
+type JSON []byte
+
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*j = JSON(v)
+	case []byte:
+		*j = append(JSON(nil), v...)
+	case nil:
+		*j = nil
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", value)
+	}
+	return nil
+}
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*j = nil
+		return nil
+	}
+	*j = append(JSON(nil), data...)
+	return nil
+}
+
+// IdempotencyKey stores the outcome of a POST request made with an
+// Idempotency-Key header so that retries can be answered with the same response.
+type IdempotencyKey struct {
+	ID             uint `json:"id" gorm:"primaryKey"`
+	OrganizationID uint `json:"-" gorm:"not null;default:1;uniqueIndex:idx_idempotency_client_scope"`
+	// Client is who sent the request, as "user:ID", "key:ID" or "ip:address",
+	// so that keys only need to be unique per client
+	Client      string `json:"client" gorm:"not null;size:64;uniqueIndex:idx_idempotency_client_scope"`
+	Key         string `json:"key" gorm:"not null;size:255;uniqueIndex:idx_idempotency_client_scope"`
+	Method      string `json:"method" gorm:"not null;size:10;uniqueIndex:idx_idempotency_client_scope"`
+	Path        string `json:"path" gorm:"not null;size:255;uniqueIndex:idx_idempotency_client_scope"`
+	RequestHash string `json:"request_hash" gorm:"not null;size:64"`
+	Completed   bool   `json:"completed" gorm:"not null;default:false"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type" gorm:"size:255"`
+	// ResponseBody is encrypted like feedback, as responses can carry the
+	// content of feedback and the details of team members
+	ResponseBody string `json:"response_body" encrypted:"ResponseKeyID"`
+	// ResponseKeyID is the key ResponseBody is encrypted with, empty when
+	// encryption is not enabled
+	ResponseKeyID string    `json:"-" gorm:"not null;default:'';size:64"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+	ExpiresAt     time.Time `json:"expires_at" gorm:"not null;index"`
 }
@@ -0,0 +1,182 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"coaching-app-backend/oidc"
+	"coaching-app-backend/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURL = "http://localhost:8080/api/auth/oidc/callback"
+
+func setupProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	idp := oidctest.NewServer("coaching-app")
+	t.Cleanup(idp.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		IssuerURL:   idp.URL,
+		ClientID:    "coaching-app",
+		RedirectURL: redirectURL,
+		Scopes:      []string{"email", "profile"},
+	}, idp.Client())
+	return idp, provider
+}
+
+// authorize follows the authorization URL and returns the code and state the
+// provider redirects back with
+func authorize(t *testing.T, idp *oidctest.Server, authURL string) (string, string) {
+	t.Helper()
+	client := idp.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("Failed to authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected a redirect from the provider, got %d", resp.StatusCode)
+	}
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp, provider := setupProvider(t)
+	ctx := context.Background()
+
+	verifier, _ := oidc.NewCodeVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("Failed to build authorization URL: %v", err)
+	}
+	params, _ := url.Parse(authURL)
+	if params.Query().Get("code_challenge") != oidc.CodeChallenge(verifier) || params.Query().Get("scope") != "openid email profile" {
+		t.Errorf("Unexpected authorization URL %s", authURL)
+	}
+
+	code, state := authorize(t, idp, authURL)
+	if state != "state-1" {
+		t.Errorf("Expected state to be returned, got %q", state)
+	}
+
+	identity, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+	if identity.Subject != "user-1" || identity.Email != "user@example.com" || !identity.EmailVerified {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+
+	if _, err := provider.Exchange(ctx, code, verifier, "nonce-1"); !errors.Is(err, oidc.ErrExchange) {
+		t.Errorf("Expected a redeemed code to be rejected, got %v", err)
+	}
+
+	code, _ = authorize(t, idp, authURL)
+	other, _ := oidc.NewCodeVerifier()
+	if _, err := provider.Exchange(ctx, code, other, "nonce-1"); !errors.Is(err, oidc.ErrExchange) {
+		t.Errorf("Expected a wrong code verifier to be rejected, got %v", err)
+	}
+
+	code, _ = authorize(t, idp, authURL)
+	if _, err := provider.Exchange(ctx, code, verifier, "nonce-2"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Expected a nonce mismatch to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp, provider := setupProvider(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		base := jwt.MapClaims{
+			"iss":   idp.URL,
+			"aud":   "coaching-app",
+			"sub":   "user-1",
+			"nonce": "n",
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Minute).Unix(),
+		}
+		for name, value := range overrides {
+			base[name] = value
+		}
+		return base
+	}
+
+	signed := func(overrides jwt.MapClaims) func() string {
+		return func() string {
+			token, _ := idp.SignIDToken(claims(overrides))
+			return token
+		}
+	}
+
+	foreign := oidctest.NewServer("coaching-app")
+	defer foreign.Close()
+	forged, _ := foreign.SignIDToken(claims(nil))
+
+	tests := []struct {
+		name  string
+		token func() string
+		valid bool
+	}{
+		{"Valid", signed(nil), true},
+		{"Expired", signed(jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()}), false},
+		{"Other audience", signed(jwt.MapClaims{"aud": "other-app"}), false},
+		{"Other issuer", signed(jwt.MapClaims{"iss": "https://evil.example.com"}), false},
+		{"Missing subject", signed(jwt.MapClaims{"sub": ""}), false},
+		{"Several audiences without azp", signed(jwt.MapClaims{"aud": []string{"coaching-app", "other-app"}}), false},
+		{"Several audiences with azp", signed(jwt.MapClaims{"aud": []string{"coaching-app", "other-app"}, "azp": "coaching-app"}), true},
+		{"Signed by another provider", func() string { return forged }, false},
+		{"Unsigned", func() string {
+			token, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return token
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(ctx, tt.token(), "n")
+			if tt.valid && err != nil {
+				t.Errorf("Expected token to be valid, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("Expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	idp, provider := setupProvider(t)
+	ctx := context.Background()
+	claims := jwt.MapClaims{"iss": idp.URL, "aud": "coaching-app", "sub": "user-1", "exp": time.Now().Add(time.Minute).Unix()}
+
+	token, _ := idp.SignIDToken(claims)
+	if _, err := provider.VerifyIDToken(ctx, token, ""); err != nil {
+		t.Fatalf("Failed to verify token: %v", err)
+	}
+
+	// The key set was fetched just now, so a new key is not picked up yet
+	idp.RotateKey()
+	token, _ = idp.SignIDToken(claims)
+	if _, err := provider.VerifyIDToken(ctx, token, ""); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Expected an unknown key to be rejected within the refresh interval, got %v", err)
+	}
+}
+
+func TestDiscoveryFailure(t *testing.T) {
+	idp := oidctest.NewServer("coaching-app")
+	defer idp.Close()
+	provider := oidc.NewProvider(oidc.Config{IssuerURL: idp.URL + "/tenant", ClientID: "coaching-app"}, idp.Client())
+
+	if _, err := provider.AuthCodeURL(context.Background(), "s", "n", "v"); !errors.Is(err, oidc.ErrDiscovery) {
+		t.Errorf("Expected ErrDiscovery, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package policy
+
+import (
+	"context"
+	"errors"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("not allowed")
+)
+
+// Authorizer checks actions for the principal of a request context, loading
+// the team relations the policy needs from the database.
+type Authorizer struct {
+	db *gorm.DB
+}
+
+func NewAuthorizer(db *gorm.DB) *Authorizer {
+	return &Authorizer{db: db}
+}
+
+// Subject loads the caller of ctx. Team relations are only loaded for roles
+// whose rules depend on them.
+func (a *Authorizer) Subject(ctx context.Context) (Subject, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return Subject{}, ErrUnauthenticated
+	}
+
+	if principal.APIKeyID != 0 {
+		scopes := make(map[string]bool, len(principal.Scopes))
+		for _, scope := range principal.Scopes {
+			scopes[scope] = true
+		}
+		return Subject{OrganizationID: principal.OrganizationID, APIKeyID: principal.APIKeyID, Scopes: scopes}, nil
+	}
+
+	subject := Subject{
+		OrganizationID: principal.OrganizationID,
+		UserID:         principal.UserID,
+		Role:           principal.Role,
+		TeamMemberID:   principal.TeamMemberID,
+		CoachedTeams:   map[uint]bool{},
+		MemberTeams:    map[uint]bool{},
+	}
+	if subject.Role == models.RoleAdmin {
+		return subject, nil
+	}
+
+	db := a.db.WithContext(ctx)
+	if subject.Role == models.RoleCoach {
+		var teamIDs []uint
+		if err := db.Model(&models.TeamCoach{}).Where("user_id = ?", subject.UserID).Pluck("team_id", &teamIDs).Error; err != nil {
+			return Subject{}, err
+		}
+		for _, id := range teamIDs {
+			subject.CoachedTeams[id] = true
+		}
+	}
+	if subject.TeamMemberID != nil {
+		var teamIDs []uint
+		if err := db.Model(&models.TeamAssignment{}).Where("team_member_id = ?", *subject.TeamMemberID).Pluck("team_id", &teamIDs).Error; err != nil {
+			return Subject{}, err
+		}
+		for _, id := range teamIDs {
+			subject.MemberTeams[id] = true
+		}
+	}
+	return subject, nil
+}
+
+// Authorize returns nil if the caller of ctx may perform action on resource,
+// ErrUnauthenticated without a caller and ErrForbidden otherwise.
+func (a *Authorizer) Authorize(ctx context.Context, action Action, resource Resource) error {
+	subject, err := a.Subject(ctx)
+	if err != nil {
+		return err
+	}
+
+	if action == ReadFeedback && resource.MemberID != 0 && resource.MemberTeams == nil && subject.Role == models.RoleCoach {
+		teams, err := a.memberTeams(ctx, []uint{resource.MemberID})
+		if err != nil {
+			return err
+		}
+		resource.MemberTeams = teams[resource.MemberID]
+	}
+
+	if !Allowed(subject, action, resource) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// FilterFeedback returns the feedback the caller of ctx may read
+func (a *Authorizer) FilterFeedback(ctx context.Context, feedback []models.Feedback) ([]models.Feedback, error) {
+	subject, err := a.Subject(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if Allowed(subject, ReadAllFeedback, Resource{}) {
+		return feedback, nil
+	}
+
+	var teamsByMember map[uint][]uint
+	if subject.Role == models.RoleCoach {
+		var memberIDs []uint
+		for _, item := range feedback {
+			if item.TargetType == "member" {
+				memberIDs = append(memberIDs, item.TargetID)
+			}
+		}
+		if teamsByMember, err = a.memberTeams(ctx, memberIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	visible := []models.Feedback{}
+	for _, item := range feedback {
+		if Allowed(subject, ReadFeedback, FeedbackResource(item, teamsByMember[item.TargetID])) {
+			visible = append(visible, item)
+		}
+	}
+	return visible, nil
+}
+
+// FeedbackResource describes a feedback entry for a ReadFeedback check.
+// memberTeams are the teams of the member the feedback is about, if any.
+func FeedbackResource(feedback models.Feedback, memberTeams []uint) Resource {
+	resource := Resource{AuthorID: feedback.AuthorID}
+	switch feedback.TargetType {
+	case "team":
+		resource.TeamID = feedback.TargetID
+	case "member":
+		resource.MemberID = feedback.TargetID
+		resource.MemberTeams = memberTeams
+	}
+	return resource
+}
+
+func (a *Authorizer) memberTeams(ctx context.Context, memberIDs []uint) (map[uint][]uint, error) {
+	teams := make(map[uint][]uint)
+	if len(memberIDs) == 0 {
+		return teams, nil
+	}
+
+	var assignments []models.TeamAssignment
+	if err := a.db.WithContext(ctx).Where("team_member_id IN ?", memberIDs).Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	for _, assignment := range assignments {
+		teams[assignment.TeamMemberID] = append(teams[assignment.TeamMemberID], assignment.TeamID)
+	}
+	return teams, nil
+}
@@ -0,0 +1,172 @@
+// Package policy decides what each role may do.
+//
+//   - Admins may do everything.
+//   - Coaches manage the teams they are assigned to: they delete those teams,
+//     manage their assignments and read feedback about them and their
+//     members. They may also add new team members.
+//   - Team leads manage assignments of the teams their own team member record
+//     belongs to, and otherwise have the same rights as members.
+//   - Members read feedback about themselves or written by them, and give
+//     feedback.
+//
+// Everyone signed in may read teams, members and assignments. Admins of the
+// default organization operate the deployment: they manage organizations and
+// read the configuration in use.
+//
+// API keys have no role. They may perform the actions their scopes grant,
+// regardless of team, and never manage users or API keys or export or erase
+// personal data.
+package policy
+
+import "coaching-app-backend/models"
+
+type Action string
+
+const (
+	ReadTeams         Action = "teams:read"
+	CreateTeam        Action = "teams:create"
+	DeleteTeam        Action = "teams:delete"
+	ManageCoaches     Action = "teams:manage_coaches"
+	ReadAssignments   Action = "assignments:read"
+	ManageAssignments Action = "assignments:manage"
+	ReadMembers       Action = "members:read"
+	CreateMember      Action = "members:create"
+	ImportMembers     Action = "members:import"
+	MergeMembers      Action = "members:merge"
+	CreateFeedback    Action = "feedback:create"
+	ReadFeedback      Action = "feedback:read"
+	ReadAllFeedback   Action = "feedback:read_all"
+	ManageUsers       Action = "users:manage"
+	ManageAPIKeys     Action = "api_keys:manage"
+	ReadAuditLog      Action = "audit:read"
+	// ManagePersonalData covers exporting and erasing a member's personal
+	// data, which no API key scope grants
+	ManagePersonalData Action = "members:personal_data"
+	// ManageOrganizations is not an action within an organization, so it
+	// is not granted by the admin role alone
+	ManageOrganizations Action = "organizations:manage"
+	// ReadConfig covers reading the settings the deployment runs with, so,
+	// like ManageOrganizations, it is not granted by the admin role alone
+	ReadConfig Action = "config:read"
+)
+
+// Scopes an API key may be granted
+const (
+	ScopeTeamsRead        = "teams:read"
+	ScopeTeamsWrite       = "teams:write"
+	ScopeMembersRead      = "members:read"
+	ScopeMembersWrite     = "members:write"
+	ScopeAssignmentsRead  = "assignments:read"
+	ScopeAssignmentsWrite = "assignments:write"
+	ScopeFeedbackRead     = "feedback:read"
+	ScopeFeedbackWrite    = "feedback:write"
+	ScopeAuditRead        = "audit:read"
+)
+
+// actionScopes maps each action an API key may perform to the scope it needs
+var actionScopes = map[Action]string{
+	ReadTeams:         ScopeTeamsRead,
+	CreateTeam:        ScopeTeamsWrite,
+	DeleteTeam:        ScopeTeamsWrite,
+	ManageCoaches:     ScopeTeamsWrite,
+	ReadAssignments:   ScopeAssignmentsRead,
+	ManageAssignments: ScopeAssignmentsWrite,
+	ReadMembers:       ScopeMembersRead,
+	CreateMember:      ScopeMembersWrite,
+	ImportMembers:     ScopeMembersWrite,
+	MergeMembers:      ScopeMembersWrite,
+	CreateFeedback:    ScopeFeedbackWrite,
+	ReadFeedback:      ScopeFeedbackRead,
+	ReadAllFeedback:   ScopeFeedbackRead,
+	ReadAuditLog:      ScopeAuditRead,
+}
+
+// Subject is the caller an action is checked for, with the team relations
+// the rules depend on.
+type Subject struct {
+	OrganizationID uint
+	UserID         uint
+	Role           string
+	TeamMemberID   *uint
+	// APIKeyID is set when the caller uses an API key with Scopes
+	APIKeyID uint
+	Scopes   map[string]bool
+	// CoachedTeams are the teams a coach is assigned to
+	CoachedTeams map[uint]bool
+	// MemberTeams are the teams the caller's own team member belongs to
+	MemberTeams map[uint]bool
+}
+
+// Resource is what an action applies to. Only the fields relevant to the
+// action need to be set.
+type Resource struct {
+	TeamID   uint
+	MemberID uint
+	// MemberTeams are the teams of MemberID
+	MemberTeams []uint
+	// AuthorID is the author of the feedback being read, if any
+	AuthorID *uint
+}
+
+// Allowed reports whether subject may perform action on resource
+func Allowed(subject Subject, action Action, resource Resource) bool {
+	if subject.APIKeyID != 0 {
+		scope, ok := actionScopes[action]
+		return ok && subject.Scopes[scope]
+	}
+	if action == ManageOrganizations || action == ReadConfig {
+		return subject.Role == models.RoleAdmin && subject.OrganizationID == models.DefaultOrganizationID
+	}
+	if subject.Role == models.RoleAdmin {
+		return true
+	}
+
+	switch action {
+	case ReadTeams, ReadMembers, ReadAssignments, CreateFeedback:
+		return subject.Role != ""
+	case DeleteTeam:
+		return subject.Role == models.RoleCoach && subject.CoachedTeams[resource.TeamID]
+	case ManageAssignments:
+		switch subject.Role {
+		case models.RoleCoach:
+			return subject.CoachedTeams[resource.TeamID]
+		case models.RoleTeamLead:
+			return subject.MemberTeams[resource.TeamID]
+		}
+		return false
+	case CreateMember:
+		return subject.Role == models.RoleCoach
+	case ReadFeedback:
+		return canReadFeedback(subject, resource)
+	default:
+		// CreateTeam, ManageCoaches, ImportMembers, MergeMembers,
+		// ReadAllFeedback, ManageUsers, ManageAPIKeys, ReadAuditLog and
+		// ManagePersonalData are admin only
+		return false
+	}
+}
+
+func canReadFeedback(subject Subject, resource Resource) bool {
+	own := subject.TeamMemberID
+	if own != nil {
+		if resource.MemberID != 0 && resource.MemberID == *own {
+			return true
+		}
+		if resource.AuthorID != nil && *resource.AuthorID == *own {
+			return true
+		}
+	}
+
+	if subject.Role != models.RoleCoach {
+		return false
+	}
+	if resource.TeamID != 0 && subject.CoachedTeams[resource.TeamID] {
+		return true
+	}
+	for _, teamID := range resource.MemberTeams {
+		if subject.CoachedTeams[teamID] {
+			return true
+		}
+	}
+	return false
+}
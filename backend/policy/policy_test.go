@@ -0,0 +1,177 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&models.TeamMember{}, &models.Team{}, &models.TeamAssignment{}, &models.Feedback{}, &models.User{}, &models.TeamCoach{})
+	return db
+}
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+func TestAllowed(t *testing.T) {
+	admin := Subject{OrganizationID: models.DefaultOrganizationID, UserID: 1, Role: models.RoleAdmin}
+	unitAdmin := Subject{OrganizationID: 2, UserID: 5, Role: models.RoleAdmin}
+	coach := Subject{UserID: 2, Role: models.RoleCoach, TeamMemberID: uintPtr(20), CoachedTeams: map[uint]bool{1: true}, MemberTeams: map[uint]bool{}}
+	lead := Subject{UserID: 3, Role: models.RoleTeamLead, TeamMemberID: uintPtr(30), CoachedTeams: map[uint]bool{}, MemberTeams: map[uint]bool{2: true}}
+	member := Subject{UserID: 4, Role: models.RoleMember, TeamMemberID: uintPtr(40), CoachedTeams: map[uint]bool{}, MemberTeams: map[uint]bool{1: true}}
+	anonymous := Subject{}
+	apiKey := Subject{APIKeyID: 1, Scopes: map[string]bool{ScopeMembersRead: true, ScopeFeedbackWrite: true}}
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		action   Action
+		resource Resource
+		expected bool
+	}{
+		{"Admin creates team", admin, CreateTeam, Resource{}, true},
+		{"Admin manages users", admin, ManageUsers, Resource{}, true},
+		{"Admin reads all feedback", admin, ReadAllFeedback, Resource{}, true},
+		{"Anonymous reads teams", anonymous, ReadTeams, Resource{}, false},
+		{"Admin manages organizations", admin, ManageOrganizations, Resource{}, true},
+		{"Admin of another organization manages users", unitAdmin, ManageUsers, Resource{}, true},
+		{"Admin of another organization manages organizations", unitAdmin, ManageOrganizations, Resource{}, false},
+		{"Admin reads the configuration", admin, ReadConfig, Resource{}, true},
+		{"Admin of another organization reads the configuration", unitAdmin, ReadConfig, Resource{}, false},
+
+		{"Coach reads teams", coach, ReadTeams, Resource{}, true},
+		{"Coach creates team", coach, CreateTeam, Resource{}, false},
+		{"Coach deletes coached team", coach, DeleteTeam, Resource{TeamID: 1}, true},
+		{"Coach deletes other team", coach, DeleteTeam, Resource{TeamID: 2}, false},
+		{"Coach manages coached team assignments", coach, ManageAssignments, Resource{TeamID: 1}, true},
+		{"Coach manages other team assignments", coach, ManageAssignments, Resource{TeamID: 2}, false},
+		{"Coach manages coaches", coach, ManageCoaches, Resource{TeamID: 1}, false},
+		{"Coach creates member", coach, CreateMember, Resource{}, true},
+		{"Coach imports members", coach, ImportMembers, Resource{}, false},
+		{"Coach reads coached team feedback", coach, ReadFeedback, Resource{TeamID: 1}, true},
+		{"Coach reads other team feedback", coach, ReadFeedback, Resource{TeamID: 2}, false},
+		{"Coach reads coached member feedback", coach, ReadFeedback, Resource{MemberID: 50, MemberTeams: []uint{3, 1}}, true},
+		{"Coach reads other member feedback", coach, ReadFeedback, Resource{MemberID: 50, MemberTeams: []uint{2}}, false},
+		{"Coach reads all feedback", coach, ReadAllFeedback, Resource{}, false},
+
+		{"Lead manages own team assignments", lead, ManageAssignments, Resource{TeamID: 2}, true},
+		{"Lead manages other team assignments", lead, ManageAssignments, Resource{TeamID: 1}, false},
+		{"Lead deletes own team", lead, DeleteTeam, Resource{TeamID: 2}, false},
+		{"Lead creates member", lead, CreateMember, Resource{}, false},
+		{"Lead reads own team feedback", lead, ReadFeedback, Resource{TeamID: 2}, false},
+
+		{"Member gives feedback", member, CreateFeedback, Resource{}, true},
+		{"Member reads members", member, ReadMembers, Resource{}, true},
+		{"Member reads own feedback", member, ReadFeedback, Resource{MemberID: 40}, true},
+		{"Member reads authored feedback", member, ReadFeedback, Resource{MemberID: 50, AuthorID: uintPtr(40)}, true},
+		{"Member reads other member feedback", member, ReadFeedback, Resource{MemberID: 50}, false},
+		{"Member reads own team feedback", member, ReadFeedback, Resource{TeamID: 1}, false},
+		{"Member manages own team assignments", member, ManageAssignments, Resource{TeamID: 1}, false},
+		{"Member merges members", member, MergeMembers, Resource{}, false},
+		{"Member manages users", member, ManageUsers, Resource{}, false},
+		{"Coach reads audit log", coach, ReadAuditLog, Resource{}, false},
+		{"Admin reads audit log", admin, ReadAuditLog, Resource{}, true},
+		{"Admin manages personal data", admin, ManagePersonalData, Resource{}, true},
+		{"Coach manages personal data", coach, ManagePersonalData, Resource{}, false},
+
+		{"Key reads members", apiKey, ReadMembers, Resource{}, true},
+		{"Key gives feedback", apiKey, CreateFeedback, Resource{}, true},
+		{"Key reads teams without scope", apiKey, ReadTeams, Resource{}, false},
+		{"Key creates member without scope", apiKey, CreateMember, Resource{}, false},
+		{"Key reads feedback without scope", apiKey, ReadFeedback, Resource{TeamID: 1}, false},
+		{"Key manages users", Subject{APIKeyID: 1, Scopes: map[string]bool{ScopeTeamsWrite: true, ScopeMembersWrite: true}}, ManageUsers, Resource{}, false},
+		{"Key manages API keys", apiKey, ManageAPIKeys, Resource{}, false},
+		{"Key reads audit log without scope", apiKey, ReadAuditLog, Resource{}, false},
+		{"Key manages personal data", Subject{APIKeyID: 1, Scopes: map[string]bool{ScopeMembersRead: true, ScopeMembersWrite: true}}, ManagePersonalData, Resource{}, false},
+		{"Key reads audit log", Subject{APIKeyID: 1, Scopes: map[string]bool{ScopeAuditRead: true}}, ReadAuditLog, Resource{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Allowed(tt.subject, tt.action, tt.resource); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	db := setupTestDB()
+	authz := NewAuthorizer(db)
+
+	coached := models.Team{Name: "Coached"}
+	other := models.Team{Name: "Other"}
+	db.Create(&coached)
+	db.Create(&other)
+	alice := models.TeamMember{Name: "Alice", Email: "alice@example.com"}
+	bob := models.TeamMember{Name: "Bob", Email: "bob@example.com"}
+	db.Create(&alice)
+	db.Create(&bob)
+	db.Create(&models.TeamAssignment{TeamID: coached.ID, TeamMemberID: alice.ID})
+	db.Create(&models.TeamAssignment{TeamID: other.ID, TeamMemberID: bob.ID})
+	coach := models.User{Email: "coach@example.com", PasswordHash: "x", Role: models.RoleCoach}
+	db.Create(&coach)
+	db.Create(&models.TeamCoach{TeamID: coached.ID, UserID: coach.ID})
+
+	if err := authz.Authorize(context.Background(), ReadTeams, Resource{}); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated without a principal, got %v", err)
+	}
+
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: coach.ID, Role: models.RoleCoach})
+	if err := authz.Authorize(ctx, DeleteTeam, Resource{TeamID: coached.ID}); err != nil {
+		t.Errorf("Expected coach to delete a coached team, got %v", err)
+	}
+	if err := authz.Authorize(ctx, DeleteTeam, Resource{TeamID: other.ID}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for another team, got %v", err)
+	}
+	if err := authz.Authorize(ctx, ReadFeedback, Resource{MemberID: alice.ID}); err != nil {
+		t.Errorf("Expected coach to read feedback about a coached member, got %v", err)
+	}
+
+	feedback := []models.Feedback{
+		{ID: 1, TargetType: "team", TargetID: coached.ID},
+		{ID: 2, TargetType: "team", TargetID: other.ID},
+		{ID: 3, TargetType: "member", TargetID: alice.ID},
+		{ID: 4, TargetType: "member", TargetID: bob.ID},
+		{ID: 5, TargetType: "member", TargetID: bob.ID, AuthorID: &alice.ID},
+	}
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		expected  []uint
+	}{
+		{"Admin", auth.Principal{UserID: 9, Role: models.RoleAdmin}, []uint{1, 2, 3, 4, 5}},
+		{"Coach", auth.Principal{UserID: coach.ID, Role: models.RoleCoach}, []uint{1, 3}},
+		{"Member", auth.Principal{UserID: 10, Role: models.RoleMember, TeamMemberID: &alice.ID}, []uint{3, 5}},
+		{"Member without team member", auth.Principal{UserID: 11, Role: models.RoleMember}, nil},
+		{"Key with feedback scope", auth.Principal{APIKeyID: 1, Scopes: []string{ScopeFeedbackRead}}, []uint{1, 2, 3, 4, 5}},
+		{"Key without feedback scope", auth.Principal{APIKeyID: 1, Scopes: []string{ScopeFeedbackWrite}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visible, err := authz.FilterFeedback(auth.WithPrincipal(context.Background(), tt.principal), feedback)
+			if err != nil {
+				t.Fatalf("Failed to filter feedback: %v", err)
+			}
+			if len(visible) != len(tt.expected) {
+				t.Fatalf("Expected %d feedback entries, got %d", len(tt.expected), len(visible))
+			}
+			for i, item := range visible {
+				if item.ID != tt.expected[i] {
+					t.Errorf("Expected feedback %d at position %d, got %d", tt.expected[i], i, item.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: coaching/v1/team_member_service.proto
+
+package coachingv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	TeamMemberService_CreateTeamMember_FullMethodName = "/coaching.v1.TeamMemberService/CreateTeamMember"
+	TeamMemberService_GetTeamMember_FullMethodName    = "/coaching.v1.TeamMemberService/GetTeamMember"
+	TeamMemberService_ListTeamMembers_FullMethodName  = "/coaching.v1.TeamMemberService/ListTeamMembers"
+)
+
+// TeamMemberServiceClient is the client API for TeamMemberService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TeamMemberServiceClient interface {
+	CreateTeamMember(ctx context.Context, in *CreateTeamMemberRequest, opts ...grpc.CallOption) (*TeamMember, error)
+	GetTeamMember(ctx context.Context, in *GetTeamMemberRequest, opts ...grpc.CallOption) (*TeamMember, error)
+	ListTeamMembers(ctx context.Context, in *ListTeamMembersRequest, opts ...grpc.CallOption) (*ListTeamMembersResponse, error)
+}
+
+type teamMemberServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTeamMemberServiceClient(cc grpc.ClientConnInterface) TeamMemberServiceClient {
+	return &teamMemberServiceClient{cc}
+}
+
+func (c *teamMemberServiceClient) CreateTeamMember(ctx context.Context, in *CreateTeamMemberRequest, opts ...grpc.CallOption) (*TeamMember, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TeamMember)
+	err := c.cc.Invoke(ctx, TeamMemberService_CreateTeamMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teamMemberServiceClient) GetTeamMember(ctx context.Context, in *GetTeamMemberRequest, opts ...grpc.CallOption) (*TeamMember, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TeamMember)
+	err := c.cc.Invoke(ctx, TeamMemberService_GetTeamMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teamMemberServiceClient) ListTeamMembers(ctx context.Context, in *ListTeamMembersRequest, opts ...grpc.CallOption) (*ListTeamMembersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTeamMembersResponse)
+	err := c.cc.Invoke(ctx, TeamMemberService_ListTeamMembers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TeamMemberServiceServer is the server API for TeamMemberService service.
+// All implementations must embed UnimplementedTeamMemberServiceServer
+// for forward compatibility
+type TeamMemberServiceServer interface {
+	CreateTeamMember(context.Context, *CreateTeamMemberRequest) (*TeamMember, error)
+	GetTeamMember(context.Context, *GetTeamMemberRequest) (*TeamMember, error)
+	ListTeamMembers(context.Context, *ListTeamMembersRequest) (*ListTeamMembersResponse, error)
+	mustEmbedUnimplementedTeamMemberServiceServer()
+}
+
+// UnimplementedTeamMemberServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedTeamMemberServiceServer struct {
+}
+
+func (UnimplementedTeamMemberServiceServer) CreateTeamMember(context.Context, *CreateTeamMemberRequest) (*TeamMember, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTeamMember not implemented")
+}
+func (UnimplementedTeamMemberServiceServer) GetTeamMember(context.Context, *GetTeamMemberRequest) (*TeamMember, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTeamMember not implemented")
+}
+func (UnimplementedTeamMemberServiceServer) ListTeamMembers(context.Context, *ListTeamMembersRequest) (*ListTeamMembersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTeamMembers not implemented")
+}
+func (UnimplementedTeamMemberServiceServer) mustEmbedUnimplementedTeamMemberServiceServer() {}
+
+// UnsafeTeamMemberServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TeamMemberServiceServer will
+// result in compilation errors.
+type UnsafeTeamMemberServiceServer interface {
+	mustEmbedUnimplementedTeamMemberServiceServer()
+}
+
+func RegisterTeamMemberServiceServer(s grpc.ServiceRegistrar, srv TeamMemberServiceServer) {
+	s.RegisterService(&TeamMemberService_ServiceDesc, srv)
+}
+
+func _TeamMemberService_CreateTeamMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTeamMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeamMemberServiceServer).CreateTeamMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TeamMemberService_CreateTeamMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeamMemberServiceServer).CreateTeamMember(ctx, req.(*CreateTeamMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TeamMemberService_GetTeamMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTeamMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeamMemberServiceServer).GetTeamMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TeamMemberService_GetTeamMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeamMemberServiceServer).GetTeamMember(ctx, req.(*GetTeamMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TeamMemberService_ListTeamMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTeamMembersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeamMemberServiceServer).ListTeamMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TeamMemberService_ListTeamMembers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeamMemberServiceServer).ListTeamMembers(ctx, req.(*ListTeamMembersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TeamMemberService_ServiceDesc is the grpc.ServiceDesc for TeamMemberService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TeamMemberService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "coaching.v1.TeamMemberService",
+	HandlerType: (*TeamMemberServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateTeamMember",
+			Handler:    _TeamMemberService_CreateTeamMember_Handler,
+		},
+		{
+			MethodName: "GetTeamMember",
+			Handler:    _TeamMemberService_GetTeamMember_Handler,
+		},
+		{
+			MethodName: "ListTeamMembers",
+			Handler:    _TeamMemberService_ListTeamMembers_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "coaching/v1/team_member_service.proto",
+}
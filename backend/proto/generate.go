@@ -0,0 +1,5 @@
+// Package proto holds the protobuf definitions of the gRPC API. The Go code
+// under coaching/v1 is generated; regenerate it after editing a .proto file.
+package proto
+
+//go:generate buf generate
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"coaching-app-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DatabaseStore keeps buckets in the database, so every server instance
+// counts against the same limits.
+type DatabaseStore struct {
+	db  *gorm.DB
+	now func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewDatabaseStore(db *gorm.DB) *DatabaseStore {
+	return &DatabaseStore{db: db, now: time.Now}
+}
+
+func (s *DatabaseStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+	db := s.db.WithContext(ctx)
+	if err := s.sweep(db, now); err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	err := db.Transaction(func(tx *gorm.DB) error {
+		initial := newBucket(limit, now)
+		record := models.RateLimitBucket{Key: key, Tokens: initial.tokens, RefilledAt: now, ExpiresAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+			return err
+		}
+
+		// The row lock serializes concurrent requests for the same bucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("bucket_key = ?", key).First(&record).Error; err != nil {
+			return err
+		}
+		b := bucket{tokens: record.Tokens, refilledAt: record.RefilledAt}
+		result = b.take(limit, now)
+		return tx.Model(&models.RateLimitBucket{}).Where("bucket_key = ?", key).Updates(map[string]interface{}{
+			"tokens":      b.tokens,
+			"refilled_at": b.refilledAt,
+			"expires_at":  now.Add(result.Reset),
+		}).Error
+	})
+	return result, err
+}
+
+// sweep deletes buckets that have refilled, at most once per sweepInterval
+func (s *DatabaseStore) sweep(db *gorm.DB, now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	return db.Where("expires_at <= ?", now).Delete(&models.RateLimitBucket{}).Error
+}
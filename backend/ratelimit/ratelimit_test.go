@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"coaching-app-backend/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// testClock is a settable time source shared by a test's stores
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func setupStores(clock *testClock) map[string]Store {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&models.RateLimitBucket{})
+
+	memory := NewMemoryStore()
+	memory.now = clock.Now
+	database := NewDatabaseStore(db)
+	database.now = clock.Now
+	return map[string]Store{"Memory": memory, "Database": database}
+}
+
+func TestStores(t *testing.T) {
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+
+	for _, name := range []string{"Memory", "Database"} {
+		t.Run(name, func(t *testing.T) {
+			clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+			store := setupStores(clock)[name]
+			ctx := context.Background()
+
+			// The whole allowance can be spent in a burst
+			for i := 2; i >= 0; i-- {
+				result, err := store.Take(ctx, "client", limit)
+				if err != nil {
+					t.Fatalf("Failed to take token: %v", err)
+				}
+				if !result.Allowed || result.Remaining != i {
+					t.Errorf("Expected request to be allowed with %d remaining, got %+v", i, result)
+				}
+			}
+
+			result, _ := store.Take(ctx, "client", limit)
+			if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+				t.Errorf("Expected request to be denied for a second, got %+v", result)
+			}
+
+			// Other clients have buckets of their own
+			if result, _ := store.Take(ctx, "other", limit); !result.Allowed {
+				t.Error("Expected another client to be allowed")
+			}
+
+			// One token refills per second
+			clock.Advance(time.Second)
+			if result, _ := store.Take(ctx, "client", limit); !result.Allowed || result.Remaining != 0 {
+				t.Errorf("Expected one request to be allowed after a second, got %+v", result)
+			}
+			if result, _ := store.Take(ctx, "client", limit); result.Allowed {
+				t.Error("Expected the refilled token to be spent")
+			}
+
+			// Buckets never hold more than the limit
+			clock.Advance(time.Hour)
+			if result, _ := store.Take(ctx, "client", limit); result.Remaining != 2 {
+				t.Errorf("Expected a full bucket after an hour, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestDatabaseStoreConcurrency(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	db.AutoMigrate(&models.RateLimitBucket{})
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	store := NewDatabaseStore(db)
+	limit := Limit{Requests: 5, Period: time.Hour}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Take(context.Background(), "client", limit)
+			if err != nil {
+				t.Errorf("Failed to take token: %v", err)
+				return
+			}
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 5 {
+		t.Errorf("Expected 5 of 20 concurrent requests to be allowed, got %d", allowed)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{"60/1m", Limit{Requests: 60, Period: time.Minute}, false},
+		{" 5/30s ", Limit{Requests: 5, Period: 30 * time.Second}, false},
+		{"0", Limit{}, false},
+		{"60", Limit{}, true},
+		{"x/1m", Limit{}, true},
+		{"60/0s", Limit{}, true},
+		{"-1/1m", Limit{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q): expected error %v, got %v", tt.value, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q): expected %v, got %v", tt.value, tt.want, got)
+		}
+	}
+}
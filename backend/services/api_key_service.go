@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
+
+	"gorm.io/gorm"
+)
+
+var ErrAPIKeyExpiry = errors.New("expiry must be in the future")
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognize
+const APIKeyPrefix = "cak_"
+
+// apiKeyDisplayLength is how much of a key is kept in clear text to tell
+// keys apart in listings
+const apiKeyDisplayLength = 12
+
+// lastUsedResolution limits how often using a key records its last use
+const lastUsedResolution = time.Minute
+
+// APIKeyInput holds the fields used to create an API key
+type APIKeyInput struct {
+	Name      string     `json:"name" normalize:"trim" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" normalize:"trim,lower" validate:"required,min=1,dive,oneof=teams:read teams:write members:read members:write assignments:read assignments:write feedback:read feedback:write audit:read"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is returned once, when a key is created. It is the only time
+// the key itself is available.
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events
+func (s *APIKeyService) WithContext(ctx context.Context) *APIKeyService {
+	return &APIKeyService{db: s.db.WithContext(ctx)}
+}
+
+// CreateAPIKey generates a key with the given scopes and stores its hash
+func (s *APIKeyService) CreateAPIKey(input APIKeyInput, createdByID uint) (*CreatedAPIKey, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "APIKeyService.CreateAPIKey")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, ErrAPIKeyExpiry
+	}
+
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	key := APIKeyPrefix + token
+
+	scopes := models.ScopeList{}
+	seen := make(map[string]bool, len(input.Scopes))
+	for _, scope := range input.Scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	apiKey := models.APIKey{
+		Name:        input.Name,
+		Prefix:      key[:apiKeyDisplayLength],
+		KeyHash:     auth.HashToken(key),
+		Scopes:      scopes,
+		CreatedByID: createdByID,
+		ExpiresAt:   input.ExpiresAt,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{Action: audit.ActionCreated, EntityType: audit.EntityAPIKey, EntityID: apiKey.ID, After: apiKey})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+func (s *APIKeyService) GetAllAPIKeys() ([]models.APIKey, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "APIKeyService.GetAllAPIKeys")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var keys []models.APIKey
+	err := s.db.Order("id").Find(&keys).Error
+	return keys, err
+}
+
+// RevokeAPIKey stops a key from being accepted. Revoking a revoked key is a
+// no-op.
+func (s *APIKeyService) RevokeAPIKey(id uint) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "APIKeyService.RevokeAPIKey")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var apiKey models.APIKey
+		if err := tx.First(&apiKey, id).Error; err != nil {
+			return err
+		}
+		before := apiKey
+		now := time.Now()
+		revoked := tx.Model(&models.APIKey{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now)
+		if revoked.Error != nil || revoked.RowsAffected == 0 {
+			return revoked.Error
+		}
+		apiKey.RevokedAt = &now
+		return audit.Record(tx, audit.Change{Action: audit.ActionRevoked, EntityType: audit.EntityAPIKey, EntityID: id, Before: before, After: apiKey})
+	})
+}
+
+// AuthenticateAPIKey returns the principal an API key acts as, or
+// auth.ErrInvalidAPIKey if the key is unknown, expired or revoked. The key's
+// last use is recorded at most once per lastUsedResolution.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (auth.Principal, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.AuthenticateAPIKey")
+	defer span.End()
+
+	db := s.db.WithContext(ctx)
+
+	var apiKey models.APIKey
+	err := db.Where("key_hash = ?", auth.HashToken(key)).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return auth.Principal{}, auth.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt)) {
+		return auth.Principal{}, auth.ErrInvalidAPIKey
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastUsedResolution {
+		err := db.Model(&models.APIKey{}).Where("id = ?", apiKey.ID).Update("last_used_at", now).Error
+		if err != nil {
+			return auth.Principal{}, err
+		}
+	}
+
+	return auth.Principal{OrganizationID: apiKey.OrganizationID, APIKeyID: apiKey.ID, Scopes: apiKey.Scopes}, nil
+}
@@ -1,7 +1,12 @@
 package services
 
 import (
+	"context"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/metrics"
 	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
 
 	"gorm.io/gorm"
 )
@@ -14,36 +19,207 @@ func NewAssignmentService(db *gorm.DB) *AssignmentService {
 	return &AssignmentService{db: db}
 }
 
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events
+func (s *AssignmentService) WithContext(ctx context.Context) *AssignmentService {
+	return &AssignmentService{db: s.db.WithContext(ctx)}
+}
+
+// AssignMemberToTeam assigns the member to the team. Assigning a member who
+// already is assigned changes nothing and succeeds, so retries are safe.
 func (s *AssignmentService) AssignMemberToTeam(teamID, memberID uint) error {
-	var team models.Team
-	if err := s.db.First(&team, teamID).Error; err != nil {
-		return err
-	}
+	ctx, span := tracing.Start(s.db.Statement.Context, "AssignmentService.AssignMemberToTeam")
+	defer span.End()
+	s = s.WithContext(ctx)
 
-	var member models.TeamMember
-	if err := s.db.First(&member, memberID).Error; err != nil {
+	var added bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.First(&team, teamID).Error; err != nil {
+			return err
+		}
+
+		var member models.TeamMember
+		if err := tx.First(&member, memberID).Error; err != nil {
+			return err
+		}
+
+		assigned, err := isAssigned(tx, teamID, memberID)
+		if err != nil || assigned {
+			return err
+		}
+
+		added = true
+		return assignMember(tx, &team, &member)
+	})
+	if err != nil {
 		return err
 	}
-
-	return s.db.Model(&team).Association("Members").Append(&member)
+	if added {
+		metrics.AssignmentChanges.WithLabelValues(metrics.AssignmentAdded).Inc()
+	}
+	return nil
 }
 
 func (s *AssignmentService) GetAllAssignments() ([]models.Team, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AssignmentService.GetAllAssignments")
+	defer span.End()
+	s = s.WithContext(ctx)
+
 	var teams []models.Team
 	err := s.db.Preload("Members").Find(&teams).Error
 	return teams, err
 }
 
 func (s *AssignmentService) RemoveMemberFromTeam(teamID, memberID uint) error {
-	var team models.Team
-	if err := s.db.First(&team, teamID).Error; err != nil {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AssignmentService.RemoveMemberFromTeam")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return removeMemberFromTeam(s.db, teamID, memberID)
+}
+
+// removeMemberFromTeam unassigns a member in a transaction of its own and
+// counts the change once it is saved
+func removeMemberFromTeam(db *gorm.DB, teamID, memberID uint) error {
+	var removed bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		removed, err = unassignMember(tx, teamID, memberID)
 		return err
+	})
+	if err != nil {
+		return err
+	}
+	if removed {
+		metrics.AssignmentChanges.WithLabelValues(metrics.AssignmentRemoved).Inc()
 	}
+	return nil
+}
 
-	var member models.TeamMember
-	if err := s.db.First(&member, memberID).Error; err != nil {
+// assignMember adds a member to a team and records it
+func assignMember(tx *gorm.DB, team *models.Team, member *models.TeamMember) error {
+	if err := tx.Model(team).Association("Members").Append(member); err != nil {
 		return err
 	}
+	return audit.Record(tx, audit.Change{
+		Action:     audit.ActionMemberAssigned,
+		EntityType: audit.EntityTeam,
+		EntityID:   team.ID,
+		After:      models.TeamAssignment{TeamID: team.ID, TeamMemberID: member.ID},
+	})
+}
+
+// unassignMember removes a member from a team, recording it if the member
+// was assigned, and reports whether they were
+func unassignMember(tx *gorm.DB, teamID, memberID uint) (bool, error) {
+	var team models.Team
+	if err := tx.First(&team, teamID).Error; err != nil {
+		return false, err
+	}
+
+	var member models.TeamMember
+	if err := tx.First(&member, memberID).Error; err != nil {
+		return false, err
+	}
+
+	assigned, err := isAssigned(tx, teamID, memberID)
+	if err != nil || !assigned {
+		return false, err
+	}
+	if err := tx.Model(&team).Association("Members").Delete(&member); err != nil {
+		return false, err
+	}
+	return true, audit.Record(tx, audit.Change{
+		Action:     audit.ActionMemberUnassigned,
+		EntityType: audit.EntityTeam,
+		EntityID:   teamID,
+		Before:     models.TeamAssignment{TeamID: teamID, TeamMemberID: memberID},
+	})
+}
+
+// MembersByTeam loads the members of many teams at once, keyed by team ID
+func (s *AssignmentService) MembersByTeam(teamIDs []uint) (map[uint][]models.TeamMember, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AssignmentService.MembersByTeam")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	grouped := make(map[uint][]models.TeamMember)
+	assignments, err := s.assignmentsWhere("team_id IN ?", teamIDs)
+	if err != nil || len(assignments) == 0 {
+		return grouped, err
+	}
+
+	memberIDs := make([]uint, len(assignments))
+	for i, assignment := range assignments {
+		memberIDs[i] = assignment.TeamMemberID
+	}
+
+	var members []models.TeamMember
+	if err := s.db.Find(&members, memberIDs).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.TeamMember, len(members))
+	for _, member := range members {
+		byID[member.ID] = member
+	}
+
+	for _, assignment := range assignments {
+		if member, ok := byID[assignment.TeamMemberID]; ok {
+			grouped[assignment.TeamID] = append(grouped[assignment.TeamID], member)
+		}
+	}
+	return grouped, nil
+}
+
+// TeamsByMember loads the teams of many members at once, keyed by member ID
+func (s *AssignmentService) TeamsByMember(memberIDs []uint) (map[uint][]models.Team, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AssignmentService.TeamsByMember")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	grouped := make(map[uint][]models.Team)
+	assignments, err := s.assignmentsWhere("team_member_id IN ?", memberIDs)
+	if err != nil || len(assignments) == 0 {
+		return grouped, err
+	}
+
+	teamIDs := make([]uint, len(assignments))
+	for i, assignment := range assignments {
+		teamIDs[i] = assignment.TeamID
+	}
+
+	var teams []models.Team
+	if err := s.db.Find(&teams, teamIDs).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Team, len(teams))
+	for _, team := range teams {
+		byID[team.ID] = team
+	}
+
+	for _, assignment := range assignments {
+		if team, ok := byID[assignment.TeamID]; ok {
+			grouped[assignment.TeamMemberID] = append(grouped[assignment.TeamMemberID], team)
+		}
+	}
+	return grouped, nil
+}
+
+func (s *AssignmentService) assignmentsWhere(query string, ids []uint) ([]models.TeamAssignment, error) {
+	var assignments []models.TeamAssignment
+	if len(ids) == 0 {
+		return assignments, nil
+	}
+	err := s.db.Where(query, ids).Order("team_id, team_member_id").Find(&assignments).Error
+	return assignments, err
+}
 
-	return s.db.Model(&team).Association("Members").Delete(&member)
+func isAssigned(db *gorm.DB, teamID, memberID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.TeamAssignment{}).
+		Where("team_id = ? AND team_member_id = ?", teamID, memberID).
+		Count(&count).Error
+	return count > 0, err
 }
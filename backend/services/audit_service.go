@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 200
+)
+
+var auditExportColumns = []string{
+	"id", "created_at", "action", "entity_type", "entity_id", "actor_user_id", "actor_api_key_id",
+	"request_id", "ip", "before", "after", "details",
+}
+
+// AuditFilter narrows down audit events. Zero fields match everything; From
+// is inclusive and To exclusive.
+type AuditFilter struct {
+	Action        string
+	EntityType    string
+	EntityID      uint
+	ActorUserID   uint
+	ActorAPIKeyID uint
+	RequestID     string
+	From          time.Time
+	To            time.Time
+}
+
+// AuditPage is one page of audit events, newest first
+type AuditPage struct {
+	Events  []models.AuditEvent `json:"events"`
+	Page    int                 `json:"page"`
+	PerPage int                 `json:"per_page"`
+	Total   int64               `json:"total"`
+}
+
+// AuditService reads the audit log. Events are written by the services making
+// the changes, see package audit.
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization whose events are read
+func (s *AuditService) WithContext(ctx context.Context) *AuditService {
+	return &AuditService{db: s.db.WithContext(ctx)}
+}
+
+// FindEvents returns a page of events matching filter. Pages start at 1, and
+// page sizes outside 1 to MaxAuditPageSize are clamped.
+func (s *AuditService) FindEvents(filter AuditFilter, page, perPage int) (*AuditPage, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuditService.FindEvents")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	if page < 1 {
+		page = 1
+	}
+	switch {
+	case perPage < 1:
+		perPage = DefaultAuditPageSize
+	case perPage > MaxAuditPageSize:
+		perPage = MaxAuditPageSize
+	}
+
+	result := &AuditPage{Events: []models.AuditEvent{}, Page: page, PerPage: perPage}
+	query := filter.scope(s.db.Model(&models.AuditEvent{}))
+	if err := query.Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&result.Events).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ExportEvents writes every event matching filter as CSV, oldest first, with
+// the given columns (all columns when empty).
+func (s *AuditService) ExportEvents(w io.Writer, filter AuditFilter, columns []string) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuditService.ExportEvents")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	columns, err := exportColumns(columns, auditExportColumns)
+	if err != nil {
+		return err
+	}
+
+	var events []models.AuditEvent
+	if err := filter.scope(s.db).Order("created_at, id").Find(&events).Error; err != nil {
+		return err
+	}
+
+	return writeCSV(w, columns, len(events), func(i int) map[string]string {
+		event := events[i]
+		return map[string]string{
+			"id":               strconv.FormatUint(uint64(event.ID), 10),
+			"created_at":       event.CreatedAt.UTC().Format(time.RFC3339),
+			"action":           event.Action,
+			"entity_type":      event.EntityType,
+			"entity_id":        strconv.FormatUint(uint64(event.EntityID), 10),
+			"actor_user_id":    formatOptionalID(event.ActorUserID),
+			"actor_api_key_id": formatOptionalID(event.ActorAPIKeyID),
+			"request_id":       event.RequestID,
+			"ip":               event.IP,
+			"before":           string(event.Before),
+			"after":            string(event.After),
+			"details":          string(event.Details),
+		}
+	})
+}
+
+func (f AuditFilter) scope(db *gorm.DB) *gorm.DB {
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if f.EntityType != "" {
+		db = db.Where("entity_type = ?", f.EntityType)
+	}
+	if f.EntityID != 0 {
+		db = db.Where("entity_id = ?", f.EntityID)
+	}
+	if f.ActorUserID != 0 {
+		db = db.Where("actor_user_id = ?", f.ActorUserID)
+	}
+	if f.ActorAPIKeyID != 0 {
+		db = db.Where("actor_api_key_id = ?", f.ActorAPIKeyID)
+	}
+	if f.RequestID != "" {
+		db = db.Where("request_id = ?", f.RequestID)
+	}
+	if !f.From.IsZero() {
+		db = db.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		db = db.Where("created_at < ?", f.To)
+	}
+	return db
+}
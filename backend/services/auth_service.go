@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tenant"
+	"coaching-app-backend/tracing"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrUserExists          = errors.New("a user with this email already exists")
+	ErrTeamMemberLinked    = errors.New("team member is already linked to another user")
+)
+
+// TokenPair is returned on login and refresh
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// UserInput holds the fields used to create an account. Passwords are limited
+// to 72 bytes, the most bcrypt will hash.
+type UserInput struct {
+	Email        string `json:"email" normalize:"trim,lower" validate:"required,email,max=255"`
+	Password     string `json:"password" validate:"required,min=8,max=72"`
+	Role         string `json:"role" normalize:"trim,lower" validate:"omitempty,oneof=admin coach team_lead member"`
+	TeamMemberID *uint  `json:"team_member_id"`
+}
+
+type LoginInput struct {
+	Email    string `json:"email" normalize:"trim,lower" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" normalize:"trim" validate:"required"`
+}
+
+type AuthService struct {
+	db     *gorm.DB
+	tokens *auth.TokenIssuer
+}
+
+func NewAuthService(db *gorm.DB, tokens *auth.TokenIssuer) *AuthService {
+	return &AuthService{db: db, tokens: tokens}
+}
+
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events
+func (s *AuthService) WithContext(ctx context.Context) *AuthService {
+	return &AuthService{db: s.db.WithContext(ctx), tokens: s.tokens}
+}
+
+// CreateUser creates an account with a bcrypt-hashed password, optionally
+// linked to a team member.
+func (s *AuthService) CreateUser(input UserInput) (*models.User, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuthService.CreateUser")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	hash, err := auth.HashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	role := input.Role
+	if role == "" {
+		role = models.RoleMember
+	}
+	user := models.User{Email: input.Email, PasswordHash: hash, Role: role}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", input.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserExists
+		}
+
+		if input.TeamMemberID != nil {
+			if err := checkTeamMemberLink(tx, *input.TeamMemberID, 0); err != nil {
+				return err
+			}
+			user.TeamMemberID = input.TeamMemberID
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{Action: audit.ActionCreated, EntityType: audit.EntityUser, EntityID: user.ID, After: user})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// BootstrapUser creates the given account as an admin if no accounts exist
+// yet, so that a fresh installation can be signed in to. It reports whether a
+// user was created.
+func (s *AuthService) BootstrapUser(input UserInput) (bool, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuthService.BootstrapUser")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	input.Role = models.RoleAdmin
+	var count int64
+	if err := s.db.Model(&models.User{}).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if _, err := s.CreateUser(input); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// LinkTeamMember links a user to a team member, or unlinks it when
+// teamMemberID is nil. A team member can be linked to at most one user.
+func (s *AuthService) LinkTeamMember(userID uint, teamMemberID *uint) (*models.User, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuthService.LinkTeamMember")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if teamMemberID != nil {
+			if err := checkTeamMemberLink(tx, *teamMemberID, userID); err != nil {
+				return err
+			}
+		}
+		before := user
+		user.TeamMemberID = teamMemberID
+		if err := tx.Model(&user).Update("team_member_id", teamMemberID).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{Action: audit.ActionUpdated, EntityType: audit.EntityUser, EntityID: user.ID, Before: before, After: user})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetRole changes a user's role. It applies to access tokens issued from the
+// next login or refresh on.
+func (s *AuthService) SetRole(userID uint, role string) (*models.User, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuthService.SetRole")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		before := user
+		if err := tx.Model(&user).Update("role", role).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{Action: audit.ActionUpdated, EntityType: audit.EntityUser, EntityID: user.ID, Before: before, After: user})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *AuthService) GetUser(id uint) (*models.User, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuthService.GetUser")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var user models.User
+	if err := s.db.Preload("TeamMember").First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login checks a user's password and starts a new refresh token family
+func (s *AuthService) Login(input LoginInput) (*TokenPair, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuthService.Login")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var user models.User
+	err := s.db.Where("email = ?", input.Email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Hash anyway so unknown emails take as long as wrong passwords
+		auth.CheckPassword(dummyPasswordHash(), input.Password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !auth.CheckPassword(user.PasswordHash, input.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	family, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	var pair *TokenPair
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		pair, err = s.issue(tx, &user, family)
+		return err
+	})
+	return pair, err
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token
+// is revoked; if it had already been revoked, it has leaked or been replayed,
+// so every token in its family is revoked as well.
+func (s *AuthService) Refresh(input RefreshInput) (*TokenPair, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuthService.Refresh")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var pair *TokenPair
+	var reused bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		err := tx.Where("token_hash = ?", auth.HashToken(input.RefreshToken)).First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if token.RevokedAt != nil {
+			reused = true
+			slog.WarnContext(tx.Statement.Context, "Revoked refresh token reused, revoking its family", "refresh_user_id", token.UserID, "family_id", token.FamilyID)
+			return nil
+		}
+		if time.Now().After(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		// Only one concurrent refresh can revoke the token
+		now := time.Now()
+		revoked := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", token.ID).
+			Update("revoked_at", now)
+		if revoked.Error != nil {
+			return revoked.Error
+		}
+		if revoked.RowsAffected == 0 {
+			return ErrInvalidRefreshToken
+		}
+
+		// Refresh requests name no organization, so the user is looked up
+		// in all of them and the new tokens are issued for the user's own
+		var user models.User
+		if err := tx.WithContext(tenant.WithoutOrganization(tx.Statement.Context)).First(&user, token.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		tx = tx.WithContext(tenant.WithOrganization(tx.Statement.Context, user.OrganizationID))
+		pair, err = s.issue(tx, &user, token.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		if err := s.revokeFamilyOf(input.RefreshToken); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	return pair, nil
+}
+
+// Logout revokes every token in the refresh token's family
+func (s *AuthService) Logout(input RefreshInput) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "AuthService.Logout")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.revokeFamilyOf(input.RefreshToken)
+}
+
+func (s *AuthService) revokeFamilyOf(refreshToken string) error {
+	var token models.RefreshToken
+	err := s.db.Where("token_hash = ?", auth.HashToken(refreshToken)).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", token.FamilyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (s *AuthService) issue(tx *gorm.DB, user *models.User, family string) (*TokenPair, error) {
+	accessToken, expiresAt, err := s.tokens.IssueAccessToken(auth.Principal{
+		OrganizationID: user.OrganizationID,
+		UserID:         user.ID,
+		Email:          user.Email,
+		Role:           user.Role,
+		TeamMemberID:   user.TeamMemberID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(refreshToken),
+		FamilyID:  family,
+		ExpiresAt: time.Now().Add(s.tokens.RefreshTokenTTL),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: record.ExpiresAt,
+	}, nil
+}
+
+func checkTeamMemberLink(tx *gorm.DB, teamMemberID, userID uint) error {
+	var member models.TeamMember
+	if err := tx.First(&member, teamMemberID).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	err := tx.Model(&models.User{}).Where("team_member_id = ? AND id <> ?", teamMemberID, userID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTeamMemberLinked
+	}
+	return nil
+}
+
+// dummyPasswordHash is a bcrypt hash compared against when no user matches
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("not a real password")
+	return hash
+})
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCSV = errors.New("invalid CSV")
+
+// utf8BOM lets Excel detect that an exported file is UTF-8
+const utf8BOM = "\ufeff"
+
+var memberExportColumns = []string{"id", "name", "email", "picture", "teams"}
+var teamExportColumns = []string{"id", "name", "logo", "member_count", "members"}
+var feedbackExportColumns = []string{"id", "target_type", "target_id", "content", "created_at", "author_id"}
+
+// memberImportColumns are the canonical import columns; name and email are required
+var memberImportColumns = []string{"name", "email", "picture", "teams"}
+
+// CSVService exports rosters and feedback as spreadsheet-friendly CSV and
+// imports member rosters through the team member batch upsert.
+type CSVService struct {
+	db       *gorm.DB
+	members  *TeamMemberService
+	teams    *TeamService
+	feedback *FeedbackService
+}
+
+// ImportRow is the outcome of a single CSV row. Row is the 1-based record
+// number in the uploaded file, counting the header row.
+type ImportRow struct {
+	Row    int         `json:"row"`
+	Email  string      `json:"email"`
+	Status BatchStatus `json:"status"`
+	ID     uint        `json:"id,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	DryRun    bool                `json:"dry_run"`
+	Committed bool                `json:"committed"`
+	Summary   map[BatchStatus]int `json:"summary"`
+	Rows      []ImportRow         `json:"rows"`
+}
+
+func NewCSVService(db *gorm.DB) *CSVService {
+	return &CSVService{
+		db:       db,
+		members:  NewTeamMemberService(db),
+		teams:    NewTeamService(db),
+		feedback: NewFeedbackService(db),
+	}
+}
+
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events
+func (s *CSVService) WithContext(ctx context.Context) *CSVService {
+	return NewCSVService(s.db.WithContext(ctx))
+}
+
+// ExportMembers writes members, optionally limited to one team, with the
+// given columns (all columns when empty).
+func (s *CSVService) ExportMembers(w io.Writer, teamID uint, columns []string) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "CSVService.ExportMembers")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	columns, err := exportColumns(columns, memberExportColumns)
+	if err != nil {
+		return err
+	}
+
+	var members []models.TeamMember
+	if teamID != 0 {
+		var team models.Team
+		err = s.db.Preload("Members.Teams").First(&team, teamID).Error
+		members = team.Members
+	} else {
+		members, err = s.members.FindTeamMembers(QueryOptions{Include: []string{"teams"}})
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeCSV(w, columns, len(members), func(i int) map[string]string {
+		member := members[i]
+		teamNames := make([]string, len(member.Teams))
+		for j, team := range member.Teams {
+			teamNames[j] = team.Name
+		}
+		return map[string]string{
+			"id":      strconv.FormatUint(uint64(member.ID), 10),
+			"name":    member.Name,
+			"email":   member.Email,
+			"picture": member.Picture,
+			"teams":   strings.Join(teamNames, "; "),
+		}
+	})
+}
+
+// ExportTeams writes one row per team with its members flattened into a
+// single "Name <email>; ..." cell.
+func (s *CSVService) ExportTeams(w io.Writer, columns []string) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "CSVService.ExportTeams")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	columns, err := exportColumns(columns, teamExportColumns)
+	if err != nil {
+		return err
+	}
+
+	teams, err := s.teams.FindTeams(QueryOptions{Include: []string{"members"}})
+	if err != nil {
+		return err
+	}
+
+	return writeCSV(w, columns, len(teams), func(i int) map[string]string {
+		team := teams[i]
+		members := make([]string, len(team.Members))
+		for j, member := range team.Members {
+			members[j] = fmt.Sprintf("%s <%s>", member.Name, member.Email)
+		}
+		return map[string]string{
+			"id":           strconv.FormatUint(uint64(team.ID), 10),
+			"name":         team.Name,
+			"logo":         team.Logo,
+			"member_count": strconv.Itoa(len(team.Members)),
+			"members":      strings.Join(members, "; "),
+		}
+	})
+}
+
+// ExportFeedback writes feedback matching the same filter as the list endpoint
+func (s *CSVService) ExportFeedback(w io.Writer, filter FeedbackFilter, columns []string) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "CSVService.ExportFeedback")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	columns, err := exportColumns(columns, feedbackExportColumns)
+	if err != nil {
+		return err
+	}
+
+	feedback, err := s.feedback.FindFeedback(filter)
+	if err != nil {
+		return err
+	}
+
+	return writeCSV(w, columns, len(feedback), func(i int) map[string]string {
+		item := feedback[i]
+		return map[string]string{
+			"id":          strconv.FormatUint(uint64(item.ID), 10),
+			"target_type": item.TargetType,
+			"target_id":   strconv.FormatUint(uint64(item.TargetID), 10),
+			"content":     item.Content,
+			"created_at":  item.CreatedAt.UTC().Format(time.RFC3339),
+			"author_id":   formatOptionalID(item.AuthorID),
+		}
+	})
+}
+
+// ImportMembers reads a member roster and upserts it by email. mapping maps
+// canonical columns (name, email, picture, teams) to the headers used in the
+// file; unmapped columns are matched by name, ignoring case. The teams column
+// holds team IDs or names separated by semicolons.
+//
+// A dry run previews every row without saving anything. Otherwise rows are
+// saved according to mode; in transactional mode any invalid row aborts the
+// import and ErrBatchAborted is returned alongside the result.
+func (s *CSVService) ImportMembers(r io.Reader, mapping map[string]string, mode BatchMode, dryRun bool) (*ImportResult, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "CSVService.ImportMembers")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	inputs, rows, rowErrors, err := s.parseMemberImport(r, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{DryRun: dryRun}
+
+	var batch []BatchItemResult
+	var batchErr error
+	switch {
+	case dryRun:
+		// Previews run best-effort so every row's outcome is visible
+		batch, batchErr = s.members.PreviewBatchUpsertTeamMembers(inputs, BatchBestEffort)
+	case mode == BatchTransactional && len(rowErrors) > 0:
+		batchErr = ErrBatchAborted
+	default:
+		batch, batchErr = s.members.BatchUpsertTeamMembers(inputs, mode)
+		result.Committed = batchErr == nil
+	}
+	if batchErr != nil && !errors.Is(batchErr, ErrBatchAborted) {
+		return nil, batchErr
+	}
+
+	result.Rows = rowErrors
+	for _, item := range batch {
+		result.Rows = append(result.Rows, ImportRow{
+			Row:    rows[item.Index],
+			Email:  item.Email,
+			Status: item.Status,
+			ID:     item.ID,
+			Error:  item.Error,
+		})
+	}
+	sort.Slice(result.Rows, func(i, j int) bool {
+		return result.Rows[i].Row < result.Rows[j].Row
+	})
+
+	result.Summary = make(map[BatchStatus]int)
+	for _, row := range result.Rows {
+		result.Summary[row.Status]++
+	}
+
+	return result, batchErr
+}
+
+func (s *CSVService) parseMemberImport(r io.Reader, mapping map[string]string) ([]TeamMemberInput, []int, []ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: failed to read header row", ErrInvalidCSV)
+	}
+
+	positions, err := importColumnPositions(header, mapping)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var teams []models.Team
+	if err := s.db.Find(&teams).Error; err != nil {
+		return nil, nil, nil, err
+	}
+
+	var inputs []TeamMemberInput
+	var rows []int
+	var rowErrors []ImportRow
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+		}
+		if len(inputs)+len(rowErrors) >= MaxBatchSize {
+			return nil, nil, nil, fmt.Errorf("%w: a file may contain at most %d rows", ErrInvalidCSV, MaxBatchSize)
+		}
+
+		cell := func(column string) string {
+			if i, ok := positions[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		input := TeamMemberInput{
+			Name:    cell("name"),
+			Email:   cell("email"),
+			Picture: cell("picture"),
+		}
+
+		teamIDs, err := resolveTeams(cell("teams"), teams)
+		if err != nil {
+			rowErrors = append(rowErrors, ImportRow{Row: row, Email: input.Email, Status: BatchError, Error: err.Error()})
+			continue
+		}
+		input.TeamIDs = teamIDs
+
+		inputs = append(inputs, input)
+		rows = append(rows, row)
+	}
+
+	return inputs, rows, rowErrors, nil
+}
+
+func importColumnPositions(header []string, mapping map[string]string) (map[string]int, error) {
+	byName := make(map[string]int, len(header))
+	for i, name := range header {
+		byName[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, utf8BOM)))] = i
+	}
+
+	positions := make(map[string]int)
+	for _, column := range memberImportColumns {
+		source := column
+		if mapped, ok := mapping[column]; ok {
+			source = mapped
+		}
+		if i, ok := byName[strings.ToLower(strings.TrimSpace(source))]; ok {
+			positions[column] = i
+		} else if column == "name" || column == "email" {
+			return nil, fmt.Errorf("%w: missing required column %q", ErrInvalidCSV, source)
+		}
+	}
+
+	for column := range mapping {
+		if !contains(memberImportColumns, column) {
+			return nil, fmt.Errorf("%w: unknown mapping target %q", ErrInvalidCSV, column)
+		}
+	}
+
+	return positions, nil
+}
+
+// resolveTeams turns a "3; Design Team" cell into team IDs, accepting either
+// IDs or case-insensitive team names.
+func resolveTeams(cell string, teams []models.Team) ([]uint, error) {
+	var ids []uint
+	for _, ref := range strings.Split(cell, ";") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+
+		if id, err := strconv.ParseUint(ref, 10, 32); err == nil {
+			ids = append(ids, uint(id))
+			continue
+		}
+
+		var matches []uint
+		for _, team := range teams {
+			if strings.EqualFold(team.Name, ref) {
+				matches = append(matches, team.ID)
+			}
+		}
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("Team %q not found", ref)
+		case 1:
+			ids = append(ids, matches[0])
+		default:
+			return nil, fmt.Errorf("Team name %q is ambiguous, use the team ID", ref)
+		}
+	}
+	return ids, nil
+}
+
+func exportColumns(requested, allowed []string) ([]string, error) {
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+	for _, column := range requested {
+		if !contains(allowed, column) {
+			return nil, fmt.Errorf("%w: unsupported column %q", ErrInvalidQueryOption, column)
+		}
+	}
+	return requested, nil
+}
+
+// writeCSV writes a BOM-prefixed, CRLF-terminated CSV so the file opens
+// cleanly in Excel as well as other spreadsheet tools.
+func writeCSV(w io.Writer, columns []string, count int, row func(i int) map[string]string) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for i := 0; i < count; i++ {
+		values := row(i)
+		for j, column := range columns {
+			record[j] = escapeFormula(values[column])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeFormula stops spreadsheet applications from evaluating user-supplied
+// text such as "=HYPERLINK(...)" as a formula.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
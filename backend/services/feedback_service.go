@@ -1,7 +1,12 @@
 package services
 
 import (
+	"context"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/metrics"
 	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
 
 	"gorm.io/gorm"
 )
@@ -14,7 +19,18 @@ func NewFeedbackService(db *gorm.DB) *FeedbackService {
 	return &FeedbackService{db: db}
 }
 
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events
+func (s *FeedbackService) WithContext(ctx context.Context) *FeedbackService {
+	return &FeedbackService{db: s.db.WithContext(ctx)}
+}
+
 func (s *FeedbackService) CreateFeedback(feedback *models.Feedback) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "FeedbackService.CreateFeedback")
+	defer span.End()
+	s = s.WithContext(ctx)
+
 	if feedback.TargetType == "team" {
 		var team models.Team
 		if err := s.db.First(&team, feedback.TargetID).Error; err != nil {
@@ -27,17 +43,132 @@ func (s *FeedbackService) CreateFeedback(feedback *models.Feedback) error {
 		}
 	}
 
-	return s.db.Create(feedback).Error
+	if feedback.AuthorID != nil {
+		var author models.TeamMember
+		if err := s.db.First(&author, *feedback.AuthorID).Error; err != nil {
+			return err
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(feedback).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{Action: audit.ActionCreated, EntityType: audit.EntityFeedback, EntityID: feedback.ID, After: feedback})
+	})
+	if err != nil {
+		return err
+	}
+	metrics.FeedbackCreated.WithLabelValues(feedback.TargetType).Inc()
+	return nil
+}
+
+// FeedbackFilter narrows feedback listings; zero values match everything
+type FeedbackFilter struct {
+	TargetType string
+	TargetID   uint
 }
 
 func (s *FeedbackService) GetAllFeedback() ([]models.Feedback, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "FeedbackService.GetAllFeedback")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.FindFeedback(FeedbackFilter{})
+}
+
+func (s *FeedbackService) FindFeedback(filter FeedbackFilter) ([]models.Feedback, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "FeedbackService.FindFeedback")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	query := s.db
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+
 	var feedback []models.Feedback
-	err := s.db.Find(&feedback).Error
+	err := query.Find(&feedback).Error
 	return feedback, err
 }
 
 func (s *FeedbackService) GetFeedbackByTarget(targetType string, targetID uint) ([]models.Feedback, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "FeedbackService.GetFeedbackByTarget")
+	defer span.End()
+	s = s.WithContext(ctx)
+
 	var feedback []models.Feedback
 	err := s.db.Where("target_type = ? AND target_id = ?", targetType, targetID).Find(&feedback).Error
 	return feedback, err
 }
+
+// FeedbackForTargets loads feedback for many targets with a single query,
+// grouped by target ID with the most recent feedback first. A positive
+// perTarget loads at most that much feedback for each target; otherwise all
+// of it is loaded.
+func (s *FeedbackService) FeedbackForTargets(targetType string, ids []uint, perTarget int) (map[uint][]models.Feedback, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "FeedbackService.FeedbackForTargets")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	grouped := make(map[uint][]models.Feedback)
+	if len(ids) == 0 {
+		return grouped, nil
+	}
+
+	query := s.db.Where("target_type = ? AND target_id IN ?", targetType, ids)
+	if perTarget > 0 {
+		// Numbering the feedback of each target, most recent first, lets the
+		// database stop at the limit for every target within the same query.
+		// The outer query is scoped to the organization, so the ranking does
+		// not need to be.
+		query = query.Where(`id IN (SELECT id FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY target_id ORDER BY created_at DESC, id DESC) AS position
+			FROM feedbacks WHERE target_type = ? AND target_id IN ?
+		) AS ranked WHERE position <= ?)`, targetType, ids, perTarget)
+	}
+
+	var feedback []models.Feedback
+	if err := query.Order("created_at DESC, id DESC").Find(&feedback).Error; err != nil {
+		return nil, err
+	}
+
+	for _, item := range feedback {
+		grouped[item.TargetID] = append(grouped[item.TargetID], item)
+	}
+	return grouped, nil
+}
+
+// CountFeedbackForTargets counts feedback for many targets with a single query.
+// Targets without feedback are absent from the result.
+func (s *FeedbackService) CountFeedbackForTargets(targetType string, ids []uint) (map[uint]int64, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "FeedbackService.CountFeedbackForTargets")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	counts := make(map[uint]int64)
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		TargetID uint
+		Count    int64
+	}
+	err := s.db.Model(&models.Feedback{}).
+		Select("target_id, COUNT(*) AS count").
+		Where("target_type = ? AND target_id IN ?", targetType, ids).
+		Group("target_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.TargetID] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,40 @@
+package services
+
+import "coaching-app-backend/models"
+
+// TeamInput holds the client-supplied fields of a team. Validate it with
+// validation.Struct before use.
+type TeamInput struct {
+	Name string `json:"name" normalize:"trim" validate:"required,max=255"`
+	Logo string `json:"logo" normalize:"trim" validate:"omitempty,http_url,max=500"`
+}
+
+func (in TeamInput) Team() models.Team {
+	return models.Team{Name: in.Name, Logo: in.Logo}
+}
+
+// MemberInput holds the client-supplied fields of a team member. Emails are
+// lowercased so that lookups by email are case-insensitive.
+type MemberInput struct {
+	Name    string `json:"name" normalize:"trim" validate:"required,max=255"`
+	Email   string `json:"email" normalize:"trim,lower" validate:"required,email,max=255"`
+	Picture string `json:"picture" normalize:"trim" validate:"omitempty,http_url,max=500"`
+}
+
+func (in MemberInput) TeamMember() models.TeamMember {
+	return models.TeamMember{Name: in.Name, Email: in.Email, Picture: in.Picture}
+}
+
+// FeedbackInput holds the client-supplied fields of a feedback entry
+type FeedbackInput struct {
+	// Content is capped well within the text column size
+	Content    string `json:"content" normalize:"trim" validate:"required,max=10000"`
+	TargetType string `json:"target_type" normalize:"trim,lower" validate:"required,oneof=team member"`
+	TargetID   uint   `json:"target_id" validate:"required"`
+	// AuthorID optionally names the team member who wrote the feedback
+	AuthorID *uint `json:"author_id"`
+}
+
+func (in FeedbackInput) Feedback() models.Feedback {
+	return models.Feedback{Content: in.Content, TargetType: in.TargetType, TargetID: in.TargetID, AuthorID: in.AuthorID}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/oidc"
+	"coaching-app-backend/tenant"
+	"coaching-app-backend/tracing"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidOIDCState    = errors.New("invalid or expired sign-in state")
+	ErrOIDCEmailUnverified = errors.New("the identity provider did not return a verified email")
+)
+
+// oidcStateTTL is how long a user has to sign in at the identity provider
+const oidcStateTTL = 10 * time.Minute
+
+// rolePriority orders roles from most to least privileged, so a user in
+// several mapped groups gets the highest role among them.
+var rolePriority = []string{models.RoleAdmin, models.RoleCoach, models.RoleTeamLead, models.RoleMember}
+
+// OIDCRoleMapping maps values of an ID token claim, typically the user's
+// groups, to roles. Without a mapping roles are managed locally.
+type OIDCRoleMapping struct {
+	Claim string
+	Roles map[string]string
+}
+
+// ParseOIDCRoleMapping parses "value:role" pairs separated by commas
+func ParseOIDCRoleMapping(claim, mapping string) (OIDCRoleMapping, error) {
+	result := OIDCRoleMapping{Claim: claim, Roles: make(map[string]string)}
+	for _, entry := range strings.Split(mapping, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		separator := strings.LastIndex(entry, ":")
+		if separator <= 0 {
+			return OIDCRoleMapping{}, fmt.Errorf("role mapping %q must have the form value:role", entry)
+		}
+		value, role := entry[:separator], strings.ToLower(entry[separator+1:])
+		if !isRole(role) {
+			return OIDCRoleMapping{}, fmt.Errorf("role mapping %q names unknown role %q", entry, role)
+		}
+		result.Roles[value] = role
+	}
+	return result, nil
+}
+
+// role returns the role the claims map to, and false when nothing is mapped
+func (m OIDCRoleMapping) role(claims map[string]interface{}) (string, bool) {
+	if len(m.Roles) == 0 {
+		return "", false
+	}
+
+	var values []string
+	switch claim := claims[m.Claim].(type) {
+	case string:
+		values = strings.Fields(claim)
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	granted := make(map[string]bool)
+	for _, value := range values {
+		if role, ok := m.Roles[value]; ok {
+			granted[role] = true
+		}
+	}
+	for _, role := range rolePriority {
+		if granted[role] {
+			return role, true
+		}
+	}
+	return models.RoleMember, true
+}
+
+func isRole(role string) bool {
+	for _, known := range rolePriority {
+		if role == known {
+			return true
+		}
+	}
+	return false
+}
+
+// OIDCService signs users in through an OpenID Connect provider. Accounts are
+// matched by the provider's subject, then by verified email, and otherwise
+// created on first sign-in.
+type OIDCService struct {
+	db       *gorm.DB
+	auth     *AuthService
+	provider *oidc.Provider
+	roles    OIDCRoleMapping
+}
+
+func NewOIDCService(db *gorm.DB, tokens *auth.TokenIssuer, provider *oidc.Provider, roles OIDCRoleMapping) *OIDCService {
+	return &OIDCService{db: db, auth: NewAuthService(db, tokens), provider: provider, roles: roles}
+}
+
+// Begin starts a sign-in to the organization of ctx and returns the provider
+// URL to send the user to. The state, nonce and PKCE verifier are kept until
+// the user comes back.
+func (s *OIDCService) Begin(ctx context.Context) (string, error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.Begin")
+	defer span.End()
+
+	state, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	organizationID, ok := tenant.FromContext(ctx)
+	if !ok {
+		organizationID = models.DefaultOrganizationID
+	}
+
+	now := time.Now()
+	if err := s.db.Where("expires_at < ?", now).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		return "", err
+	}
+	record := models.OIDCLoginState{
+		SignInOrganizationID: organizationID,
+		StateHash:            auth.HashToken(state),
+		Nonce:                nonce,
+		CodeVerifier:         verifier,
+		ExpiresAt:            now.Add(oidcStateTTL),
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+// Complete finishes a sign-in with the code and state the provider redirected
+// back with, and starts a new refresh token family for the user. The account
+// belongs to the organization the sign-in was started for.
+func (s *OIDCService) Complete(ctx context.Context, code, state string) (*TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.Complete")
+	defer span.End()
+
+	login, err := s.consumeState(state)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	family, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	var pair *TokenPair
+	ctx = tenant.WithOrganization(ctx, login.SignInOrganizationID)
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.provision(tx, identity)
+		if err != nil {
+			return err
+		}
+		pair, err = s.auth.issue(tx, user, family)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// consumeState deletes the sign-in state so it cannot be used twice
+func (s *OIDCService) consumeState(state string) (*models.OIDCLoginState, error) {
+	var login models.OIDCLoginState
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("state_hash = ?", auth.HashToken(state)).First(&login).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidOIDCState
+		}
+		if err != nil {
+			return err
+		}
+
+		// Only one concurrent callback can delete the state
+		deleted := tx.Delete(&models.OIDCLoginState{}, login.ID)
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		if deleted.RowsAffected == 0 || time.Now().After(login.ExpiresAt) {
+			return ErrInvalidOIDCState
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// provision finds or creates the account for an identity, keeps its role in
+// line with the role mapping and links it to the team member with the same
+// email.
+func (s *OIDCService) provision(tx *gorm.DB, identity *oidc.Identity) (*models.User, error) {
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+
+	var user, before models.User
+	var created, changed bool
+	err := tx.Where("oidc_subject = ?", identity.Subject).First(&user).Error
+	switch {
+	case err == nil:
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	case email == "" || !identity.EmailVerified:
+		// Matching or creating accounts by an unverified email would let
+		// anyone claim an address they don't own
+		return nil, ErrOIDCEmailUnverified
+	default:
+		err = tx.Where("email = ? AND oidc_subject IS NULL", email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			var taken int64
+			if err := tx.Model(&models.User{}).Where("email = ?", email).Count(&taken).Error; err != nil {
+				return nil, err
+			}
+			if taken > 0 {
+				return nil, ErrUserExists
+			}
+			user = models.User{Email: email, Role: models.RoleMember}
+			if err := tx.Create(&user).Error; err != nil {
+				return nil, err
+			}
+			created = true
+		} else if err != nil {
+			return nil, err
+		}
+		before = user
+		if err := tx.Model(&user).Update("oidc_subject", identity.Subject).Error; err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	if !changed {
+		before = user
+	}
+
+	if role, ok := s.roles.role(identity.Claims); ok && role != user.Role {
+		if err := tx.Model(&user).Update("role", role).Error; err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+
+	if user.TeamMemberID == nil && email != "" && identity.EmailVerified {
+		linked, err := linkTeamMemberByEmail(tx, &user, email)
+		if err != nil {
+			return nil, err
+		}
+		changed = changed || linked
+	}
+
+	change := audit.Change{EntityType: audit.EntityUser, EntityID: user.ID, After: user, Details: map[string]string{"source": "oidc"}}
+	switch {
+	case created:
+		change.Action = audit.ActionCreated
+	case changed:
+		change.Action, change.Before = audit.ActionUpdated, before
+	default:
+		return &user, nil
+	}
+	if err := audit.Record(tx, change); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// linkTeamMemberByEmail links the user to the team member with their email,
+// unless that member is already linked to someone else. It reports whether
+// the user was linked.
+func linkTeamMemberByEmail(tx *gorm.DB, user *models.User, email string) (bool, error) {
+	var member models.TeamMember
+	err := tx.Where("email = ?", email).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var linked int64
+	if err := tx.Model(&models.User{}).Where("team_member_id = ?", member.ID).Count(&linked).Error; err != nil {
+		return false, err
+	}
+	if linked > 0 {
+		return false, nil
+	}
+	if err := tx.Model(user).Update("team_member_id", member.ID).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
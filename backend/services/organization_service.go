@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tenant"
+	"coaching-app-backend/tracing"
+
+	"gorm.io/gorm"
+)
+
+var ErrOrganizationExists = errors.New("an organization with this slug already exists")
+
+// OrganizationInput holds the fields used to create an organization. Admin
+// optionally creates the organization's first admin account.
+type OrganizationInput struct {
+	Name  string     `json:"name" normalize:"trim" validate:"required,max=255"`
+	Slug  string     `json:"slug" normalize:"trim,lower" validate:"required,max=63,slug"`
+	Admin *UserInput `json:"admin"`
+}
+
+// OrganizationService manages the organizations sharing the deployment.
+// Organizations are not tenant data themselves, so its queries are never
+// scoped to one.
+type OrganizationService struct {
+	db *gorm.DB
+}
+
+func NewOrganizationService(db *gorm.DB) *OrganizationService {
+	return &OrganizationService{db: db}
+}
+
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events
+func (s *OrganizationService) WithContext(ctx context.Context) *OrganizationService {
+	return &OrganizationService{db: s.db.WithContext(ctx)}
+}
+
+// CreateOrganization creates an organization and, if requested, its first
+// admin account
+func (s *OrganizationService) CreateOrganization(input OrganizationInput) (*models.Organization, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "OrganizationService.CreateOrganization")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	organization := models.Organization{Name: input.Name, Slug: input.Slug}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Organization{}).Where("slug = ?", input.Slug).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrOrganizationExists
+		}
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+		if err := audit.Record(tx, audit.Change{Action: audit.ActionCreated, EntityType: audit.EntityOrganization, EntityID: organization.ID, After: organization}); err != nil {
+			return err
+		}
+
+		if input.Admin == nil {
+			return nil
+		}
+		admin := *input.Admin
+		admin.Role = models.RoleAdmin
+		admin.TeamMemberID = nil
+		// The account and its audit event belong to the new organization
+		ctx := tenant.WithOrganization(tx.Statement.Context, organization.ID)
+		_, err := NewAuthService(tx.WithContext(ctx), nil).CreateUser(admin)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (s *OrganizationService) GetAllOrganizations() ([]models.Organization, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "OrganizationService.GetAllOrganizations")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var organizations []models.Organization
+	err := s.db.Order("id").Find(&organizations).Error
+	return organizations, err
+}
+
+// ResolveOrganization finds an organization by slug or ID, returning
+// tenant.ErrUnknownOrganization if there is none
+func (s *OrganizationService) ResolveOrganization(ctx context.Context, slugOrID string) (uint, error) {
+	ctx, span := tracing.Start(ctx, "OrganizationService.ResolveOrganization")
+	defer span.End()
+
+	query := s.db.WithContext(ctx).Model(&models.Organization{})
+	if id, err := strconv.ParseUint(slugOrID, 10, 32); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("slug = ?", slugOrID)
+	}
+
+	var organization models.Organization
+	err := query.First(&organization).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, tenant.ErrUnknownOrganization
+	}
+	if err != nil {
+		return 0, err
+	}
+	return organization.ID, nil
+}
@@ -0,0 +1,629 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/auth"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tenant"
+	"coaching-app-backend/tracing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAlreadyErased        = errors.New("team member has already been erased")
+	ErrInvalidErasureAction = errors.New("erasure action must be redact or keep")
+)
+
+// ErasureAction is what an erasure does with one kind of content
+type ErasureAction string
+
+const (
+	// ErasureRedact replaces the content with RedactedContent
+	ErasureRedact ErasureAction = "redact"
+	// ErasureKeep leaves the content as it is
+	ErasureKeep ErasureAction = "keep"
+)
+
+const (
+	// RedactedContent replaces the content of redacted feedback
+	RedactedContent = "[redacted]"
+	// ErasedMemberName replaces the name of erased members
+	ErasedMemberName = "Erased member"
+	// erasedEmailDomain is reserved, so anonymized addresses never reach anyone
+	erasedEmailDomain = "erased.invalid"
+)
+
+// ErasurePolicy decides what happens to the feedback of an erased member.
+// Feedback rows are never deleted, so feedback counts stay the same.
+type ErasurePolicy struct {
+	// AuthoredFeedback applies to feedback the member wrote
+	AuthoredFeedback ErasureAction `json:"authored_feedback"`
+	// ReceivedFeedback applies to feedback about the member
+	ReceivedFeedback ErasureAction `json:"received_feedback"`
+}
+
+// DefaultErasurePolicy redacts what the member wrote and keeps what others
+// wrote about them, which is theirs to keep
+func DefaultErasurePolicy() ErasurePolicy {
+	return ErasurePolicy{AuthoredFeedback: ErasureRedact, ReceivedFeedback: ErasureKeep}
+}
+
+// ParseErasurePolicy builds a policy from the actions for authored and
+// received feedback. Empty actions take their default.
+func ParseErasurePolicy(authored, received string) (ErasurePolicy, error) {
+	policy := DefaultErasurePolicy()
+	actions := []struct {
+		spec   string
+		action *ErasureAction
+	}{
+		{authored, &policy.AuthoredFeedback},
+		{received, &policy.ReceivedFeedback},
+	}
+	for _, a := range actions {
+		spec := strings.ToLower(strings.TrimSpace(a.spec))
+		switch ErasureAction(spec) {
+		case "":
+		case ErasureRedact, ErasureKeep:
+			*a.action = ErasureAction(spec)
+		default:
+			return policy, fmt.Errorf("%w, got %q", ErrInvalidErasureAction, a.spec)
+		}
+	}
+	return policy, nil
+}
+
+// MemberExport is everything stored about a team member, in a form they can
+// take elsewhere
+type MemberExport struct {
+	ExportedAt       time.Time         `json:"exported_at"`
+	Member           models.TeamMember `json:"member"`
+	Account          *models.User      `json:"account"`
+	Teams            []ExportedTeam    `json:"teams"`
+	FeedbackReceived []models.Feedback `json:"feedback_received"`
+	FeedbackGiven    []models.Feedback `json:"feedback_given"`
+}
+
+// ExportedTeam is a team the exported member is assigned to
+type ExportedTeam struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+}
+
+// ErasureSummary counts what an erasure changed
+type ErasureSummary struct {
+	AccountAnonymized   bool  `json:"account_anonymized"`
+	RevokedSessions     int64 `json:"revoked_sessions"`
+	RedactedAuthored    int64 `json:"redacted_authored_feedback"`
+	RedactedReceived    int64 `json:"redacted_received_feedback"`
+	ScrubbedAuditEvents int64 `json:"scrubbed_audit_events"`
+}
+
+// ErasureLog lists the erasure records of the organization, oldest first,
+// with the result of verifying their hash chain
+type ErasureLog struct {
+	Records []models.ErasureRecord `json:"records"`
+	Valid   bool                   `json:"valid"`
+	// BrokenAt is the first record that does not match the chain
+	BrokenAt *uint `json:"broken_at,omitempty"`
+}
+
+// PrivacyService exports and erases the personal data of team members
+type PrivacyService struct {
+	db *gorm.DB
+}
+
+func NewPrivacyService(db *gorm.DB) *PrivacyService {
+	return &PrivacyService{db: db}
+}
+
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events and erasure records
+func (s *PrivacyService) WithContext(ctx context.Context) *PrivacyService {
+	return &PrivacyService{db: s.db.WithContext(ctx)}
+}
+
+// ExportTeamMember collects the member's profile, account, team assignments
+// and the feedback they gave and received. Exports are recorded in the audit
+// log, as they hand out personal data.
+func (s *PrivacyService) ExportTeamMember(id uint) (*MemberExport, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "PrivacyService.ExportTeamMember")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	export := &MemberExport{
+		ExportedAt:       time.Now().UTC(),
+		Teams:            []ExportedTeam{},
+		FeedbackReceived: []models.Feedback{},
+		FeedbackGiven:    []models.Feedback{},
+	}
+	if err := s.db.First(&export.Member, id).Error; err != nil {
+		return nil, err
+	}
+
+	var account models.User
+	err := s.db.Where("team_member_id = ?", id).First(&account).Error
+	switch {
+	case err == nil:
+		export.Account = &account
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	err = s.db.Model(&models.Team{}).
+		Select("teams.id AS team_id, teams.name AS team_name").
+		Joins("JOIN team_assignments ON team_assignments.team_id = teams.id").
+		Where("team_assignments.team_member_id = ?", id).
+		Order("teams.id").
+		Scan(&export.Teams).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("target_type = ? AND target_id = ?", "member", id).Order("id").Find(&export.FeedbackReceived).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("author_id = ?", id).Order("id").Find(&export.FeedbackGiven).Error; err != nil {
+		return nil, err
+	}
+
+	exported := audit.Change{Action: audit.ActionExported, EntityType: audit.EntityTeamMember, EntityID: id}
+	if err := audit.Record(s.db, exported); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// EraseTeamMember anonymizes the member and their user account, signs the
+// account out, applies policy to their feedback and scrubs the snapshots of
+// them from the audit log, in a single transaction. The member, its team
+// assignments and all feedback rows are kept, so counts do not change. The
+// erasure is documented by an ErasureRecord, chained to the organization's
+// previous record, and an audit event holding no personal data.
+func (s *PrivacyService) EraseTeamMember(id uint, reason string, policy ErasurePolicy) (*models.ErasureRecord, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "PrivacyService.EraseTeamMember")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var record *models.ErasureRecord
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var member models.TeamMember
+		if err := tx.First(&member, id).Error; err != nil {
+			return err
+		}
+		if member.ErasedAt != nil {
+			return ErrAlreadyErased
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		var summary ErasureSummary
+		erased := erasedIdentities{
+			entities: map[string][]uint{audit.EntityTeamMember: {id}},
+			emails:   map[string]string{member.Email: erasedMemberEmail(id)},
+		}
+		anonymized := map[string]interface{}{
+			"name":      ErasedMemberName,
+			"email":     erasedMemberEmail(id),
+			"picture":   "",
+			"erased_at": now,
+		}
+		if err := tx.Model(&member).Updates(anonymized).Error; err != nil {
+			return err
+		}
+
+		var account models.User
+		err := tx.Where("team_member_id = ?", id).First(&account).Error
+		switch {
+		case err == nil:
+			erased.entities[audit.EntityUser] = []uint{account.ID}
+			erased.emails[account.Email] = erasedAccountEmail(account.ID)
+			if err := eraseAccount(tx, &account, &summary); err != nil {
+				return err
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		if policy.AuthoredFeedback == ErasureRedact {
+			redacted, err := redactFeedback(tx.Where("author_id = ?", id))
+			if err != nil {
+				return err
+			}
+			summary.RedactedAuthored = redacted
+		}
+		if policy.ReceivedFeedback == ErasureRedact {
+			redacted, err := redactFeedback(tx.Where("target_type = ? AND target_id = ?", "member", id))
+			if err != nil {
+				return err
+			}
+			summary.RedactedReceived = redacted
+		}
+
+		scrubbed, err := scrubAuditLog(tx, erased)
+		if err != nil {
+			return err
+		}
+		summary.ScrubbedAuditEvents = scrubbed
+		if err := scrubIdempotentResponses(tx, erased, policy); err != nil {
+			return err
+		}
+
+		record, err = appendErasureRecord(tx, id, reason, policy, summary, now)
+		if err != nil {
+			return err
+		}
+		event := audit.Change{
+			Action:     audit.ActionErased,
+			EntityType: audit.EntityTeamMember,
+			EntityID:   id,
+			Details: map[string]interface{}{
+				"erasure_record_id": record.ID,
+				"policy":            policy,
+				"summary":           summary,
+			},
+		}
+		return audit.Record(tx, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(s.db.Statement.Context, "Team member erased", "team_member_id", id, "erasure_record_id", record.ID)
+	return record, nil
+}
+
+// eraseAccount anonymizes the account linked to an erased member. Without a
+// password or identity provider subject it can no longer sign in, and its
+// refresh tokens are deleted so existing sessions end.
+func eraseAccount(tx *gorm.DB, account *models.User, summary *ErasureSummary) error {
+	anonymized := map[string]interface{}{
+		"email":         erasedAccountEmail(account.ID),
+		"password_hash": "",
+		"oidc_subject":  nil,
+	}
+	if err := tx.Model(account).Updates(anonymized).Error; err != nil {
+		return err
+	}
+	revoked := tx.Where("user_id = ?", account.ID).Delete(&models.RefreshToken{})
+	if revoked.Error != nil {
+		return revoked.Error
+	}
+	summary.AccountAnonymized = true
+	summary.RevokedSessions = revoked.RowsAffected
+	return nil
+}
+
+// redactFeedback replaces the content of the feedback matched by query. The
+// key ID is cleared for deployments without encryption keys; with keys, the
+// redacted content is encrypted like any other.
+func redactFeedback(query *gorm.DB) (int64, error) {
+	result := query.Model(&models.Feedback{}).Updates(map[string]interface{}{
+		"content":        RedactedContent,
+		"content_key_id": "",
+	})
+	return result.RowsAffected, result.Error
+}
+
+func erasedMemberEmail(id uint) string {
+	return fmt.Sprintf("erased-%d@%s", id, erasedEmailDomain)
+}
+
+func erasedAccountEmail(id uint) string {
+	return fmt.Sprintf("erased-user-%d@%s", id, erasedEmailDomain)
+}
+
+// erasedIdentities are the records of an erased person: the IDs of their
+// member, the members merged into it and their account, by entity type, and
+// their email addresses with the anonymized address replacing each
+type erasedIdentities struct {
+	entities map[string][]uint
+	emails   map[string]string
+}
+
+// scrubAuditLog removes the erased person's data from the audit log. The
+// snapshots of their own records are dropped. Elsewhere, such as among the
+// members of a deleted team or in the details of a merge, entries holding one
+// of their email addresses are anonymized. The events themselves stay, so
+// the log still shows what happened and when.
+func scrubAuditLog(tx *gorm.DB, erased erasedIdentities) (int64, error) {
+	if err := findMergedMembers(tx, erased); err != nil {
+		return 0, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	for entityType, ids := range erased.entities {
+		conditions = append(conditions, "(entity_type = ? AND entity_id IN ?)")
+		args = append(args, entityType, ids)
+	}
+	// before is reserved in MySQL, so the columns are quoted by gorm
+	for email := range erased.emails {
+		for _, column := range []string{"before", "after", "details"} {
+			conditions = append(conditions, "? LIKE ?")
+			args = append(args, clause.Column{Name: column}, "%"+email+"%")
+		}
+	}
+	var events []models.AuditEvent
+	if err := tx.Where(strings.Join(conditions, " OR "), args...).Find(&events).Error; err != nil {
+		return 0, err
+	}
+
+	var scrubbed int64
+	for _, event := range events {
+		before, after := event.Before, event.After
+		if erased.owns(event) {
+			before, after = nil, nil
+		}
+		before, changedBefore := anonymizeJSON(before, erased.emails)
+		after, changedAfter := anonymizeJSON(after, erased.emails)
+		details, changedDetails := anonymizeJSON(event.Details, erased.emails)
+		if !erased.owns(event) && !changedBefore && !changedAfter && !changedDetails {
+			continue
+		}
+		updates := map[string]interface{}{"before": before, "after": after, "details": details}
+		if err := tx.Model(&models.AuditEvent{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+			return 0, err
+		}
+		scrubbed++
+	}
+	return scrubbed, nil
+}
+
+// findMergedMembers adds the members merged into the erased member, which
+// were the same person, and the members merged into those
+func findMergedMembers(tx *gorm.DB, erased erasedIdentities) error {
+	for i := 0; i < len(erased.entities[audit.EntityTeamMember]); i++ {
+		var merges []models.AuditEvent
+		err := tx.Where("action = ? AND entity_id = ?", audit.EntityTeamMember+"."+audit.ActionMerged, erased.entities[audit.EntityTeamMember][i]).
+			Find(&merges).Error
+		if err != nil {
+			return err
+		}
+		for _, merge := range merges {
+			var details struct {
+				SourceID    uint   `json:"source_id"`
+				SourceEmail string `json:"source_email"`
+			}
+			if json.Unmarshal(merge.Details, &details) != nil || details.SourceID == 0 {
+				continue
+			}
+			erased.entities[audit.EntityTeamMember] = append(erased.entities[audit.EntityTeamMember], details.SourceID)
+			if details.SourceEmail != "" {
+				erased.emails[details.SourceEmail] = erasedMemberEmail(details.SourceID)
+			}
+		}
+	}
+	return nil
+}
+
+// owns reports whether event is about one of the erased records
+func (e erasedIdentities) owns(event models.AuditEvent) bool {
+	for _, id := range e.entities[event.EntityType] {
+		if id == event.EntityID {
+			return true
+		}
+	}
+	return false
+}
+
+// scrubIdempotentResponses removes the erased person's data from the
+// responses kept for Idempotency-Key replays, which can hold their member,
+// account and feedback. Their details are anonymized as in the audit log, and
+// feedback the policy redacts is redacted in the responses too. The responses
+// are kept, so that retries are still answered rather than processed again.
+func scrubIdempotentResponses(tx *gorm.DB, erased erasedIdentities, policy ErasurePolicy) error {
+	var stored []models.IdempotencyKey
+	err := tx.Select("id", "response_body", "response_key_id").Where("completed = ?", true).Find(&stored).Error
+	if err != nil {
+		return err
+	}
+	for i := range stored {
+		body, changed := rewriteJSON(models.JSON(stored[i].ResponseBody), func(value interface{}) bool {
+			anonymized := anonymizeValue(value, erased.emails)
+			return redactFeedbackValue(value, erased.entities[audit.EntityTeamMember], policy) || anonymized
+		})
+		if !changed {
+			continue
+		}
+		if err := tx.Model(&stored[i]).Update("response_body", string(body)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactFeedbackValue replaces the content of every feedback object in value
+// written by or about one of members, as policy redacts it, reporting whether
+// anything changed
+func redactFeedbackValue(value interface{}, members []uint, policy ErasurePolicy) bool {
+	isMember := func(id interface{}) bool {
+		for _, member := range members {
+			if id == json.Number(strconv.FormatUint(uint64(member), 10)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	changed := false
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if content, ok := value["content"].(string); ok && content != RedactedContent {
+			authored := policy.AuthoredFeedback == ErasureRedact && isMember(value["author_id"])
+			received := policy.ReceivedFeedback == ErasureRedact && value["target_type"] == "member" && isMember(value["target_id"])
+			if authored || received {
+				value["content"] = RedactedContent
+				changed = true
+			}
+		}
+		for _, child := range value {
+			changed = redactFeedbackValue(child, members, policy) || changed
+		}
+	case []interface{}:
+		for _, child := range value {
+			changed = redactFeedbackValue(child, members, policy) || changed
+		}
+	}
+	return changed
+}
+
+// anonymizeJSON replaces the name, email and picture of every object in doc
+// whose email, or source_email for merges, is one of emails, reporting
+// whether anything changed
+func anonymizeJSON(doc models.JSON, emails map[string]string) (models.JSON, bool) {
+	return rewriteJSON(doc, func(value interface{}) bool {
+		return anonymizeValue(value, emails)
+	})
+}
+
+// rewriteJSON decodes doc, lets rewrite change it in place and encodes it
+// again if rewrite reports a change. Documents that are not JSON are left
+// alone.
+func rewriteJSON(doc models.JSON, rewrite func(value interface{}) bool) (models.JSON, bool) {
+	if len(doc) == 0 {
+		return doc, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	var value interface{}
+	if decoder.Decode(&value) != nil || !rewrite(value) {
+		return doc, false
+	}
+	rewritten, err := json.Marshal(value)
+	if err != nil {
+		return doc, false
+	}
+	return rewritten, true
+}
+
+func anonymizeValue(value interface{}, emails map[string]string) bool {
+	changed := false
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, prefix := range []string{"", "source_"} {
+			email, _ := value[prefix+"email"].(string)
+			replacement, ok := emails[email]
+			if !ok {
+				continue
+			}
+			value[prefix+"email"] = replacement
+			if _, ok := value[prefix+"name"]; ok {
+				value[prefix+"name"] = ErasedMemberName
+			}
+			if _, ok := value[prefix+"picture"]; ok {
+				value[prefix+"picture"] = ""
+			}
+			changed = true
+		}
+		for _, child := range value {
+			changed = anonymizeValue(child, emails) || changed
+		}
+	case []interface{}:
+		for _, child := range value {
+			changed = anonymizeValue(child, emails) || changed
+		}
+	}
+	return changed
+}
+
+// appendErasureRecord saves the record of an erasure, chained to the last
+// record of the organization
+func appendErasureRecord(tx *gorm.DB, memberID uint, reason string, policy ErasurePolicy, summary ErasureSummary, at time.Time) (*models.ErasureRecord, error) {
+	record := &models.ErasureRecord{
+		OrganizationID: models.DefaultOrganizationID,
+		TeamMemberID:   memberID,
+		Reason:         reason,
+		CreatedAt:      at,
+	}
+	// The organization is part of the hash, so it is set here rather than
+	// by the tenant callbacks on create
+	if ctx := tx.Statement.Context; ctx != nil {
+		if organizationID, ok := tenant.FromContext(ctx); ok {
+			record.OrganizationID = organizationID
+		}
+		if principal, ok := auth.FromContext(ctx); ok {
+			record.RequestedByUserID = principal.UserID
+		}
+		if request, ok := audit.RequestFromContext(ctx); ok {
+			record.RequestID = request.ID
+		}
+	}
+
+	var err error
+	if record.Policy, err = json.Marshal(policy); err != nil {
+		return nil, err
+	}
+	if record.Summary, err = json.Marshal(summary); err != nil {
+		return nil, err
+	}
+
+	var previous models.ErasureRecord
+	err = tx.Order("id DESC").First(&previous).Error
+	switch {
+	case err == nil:
+		record.PreviousHash = previous.Hash
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	record.Hash = erasureRecordHash(record)
+	if err := tx.Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// erasureRecordHash is the SHA-256 of the record's fields and the hash of the
+// record before it, hex-encoded
+func erasureRecordHash(record *models.ErasureRecord) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n%d\n%d\n%q\n%s\n%s\n%q\n%s\n",
+		record.PreviousHash,
+		record.OrganizationID,
+		record.TeamMemberID,
+		record.RequestedByUserID,
+		record.Reason,
+		record.Policy,
+		record.Summary,
+		record.RequestID,
+		record.CreatedAt.UTC().Format(time.RFC3339),
+	)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ErasureLog returns the organization's erasure records and verifies their
+// chain: each record must follow the one before it and match its own hash.
+func (s *PrivacyService) ErasureLog() (*ErasureLog, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "PrivacyService.ErasureLog")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	log := &ErasureLog{Records: []models.ErasureRecord{}, Valid: true}
+	if err := s.db.Order("id").Find(&log.Records).Error; err != nil {
+		return nil, err
+	}
+
+	previous := ""
+	for i := range log.Records {
+		record := &log.Records[i]
+		if record.PreviousHash != previous || erasureRecordHash(record) != record.Hash {
+			log.Valid = false
+			log.BrokenAt = &record.ID
+			break
+		}
+		previous = record.Hash
+	}
+	return log, nil
+}
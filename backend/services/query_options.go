@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"coaching-app-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxIncludeDepth is the deepest relation path accepted in an include list,
+// e.g. "members.feedback" has a depth of 2.
+const MaxIncludeDepth = 2
+
+var ErrInvalidQueryOption = errors.New("invalid query option")
+
+// QueryOptions selects which relations are loaded and which columns are read
+// when fetching teams and team members.
+type QueryOptions struct {
+	Include []string
+	Fields  []string
+}
+
+var teamIncludes = map[string]bool{
+	"members":                true,
+	"feedback":               true,
+	"feedback_count":         true,
+	"members.teams":          true,
+	"members.feedback":       true,
+	"members.feedback_count": true,
+}
+
+var teamFields = map[string]bool{
+	"id":   true,
+	"name": true,
+	"logo": true,
+}
+
+var memberIncludes = map[string]bool{
+	"teams":                true,
+	"feedback":             true,
+	"feedback_count":       true,
+	"teams.members":        true,
+	"teams.feedback":       true,
+	"teams.feedback_count": true,
+}
+
+var memberFields = map[string]bool{
+	"id":      true,
+	"name":    true,
+	"picture": true,
+	"email":   true,
+}
+
+func (o QueryOptions) validate(includes, fields map[string]bool) error {
+	for _, include := range o.Include {
+		if strings.Count(include, ".")+1 > MaxIncludeDepth {
+			return fmt.Errorf("%w: include %q exceeds maximum depth of %d", ErrInvalidQueryOption, include, MaxIncludeDepth)
+		}
+		if !includes[include] {
+			return fmt.Errorf("%w: unsupported include %q", ErrInvalidQueryOption, include)
+		}
+		// Nested relations are loaded onto their parent, so without it they
+		// would be silently dropped
+		if parent, _, nested := strings.Cut(include, "."); nested && !o.has(parent) {
+			return fmt.Errorf("%w: include %q requires include %q", ErrInvalidQueryOption, include, parent)
+		}
+	}
+	for _, field := range o.Fields {
+		if !fields[field] {
+			return fmt.Errorf("%w: unsupported field %q", ErrInvalidQueryOption, field)
+		}
+	}
+	return nil
+}
+
+func (o QueryOptions) has(include string) bool {
+	for _, i := range o.Include {
+		if i == include {
+			return true
+		}
+	}
+	return false
+}
+
+// selectColumns returns the column list for the primary resource. The primary
+// key is always selected because preloads and includes are keyed on it.
+func (o QueryOptions) selectColumns() []string {
+	if len(o.Fields) == 0 {
+		return nil
+	}
+	columns := []string{"id"}
+	for _, field := range o.Fields {
+		if field != "id" {
+			columns = append(columns, field)
+		}
+	}
+	return columns
+}
+
+func (o QueryOptions) scope(db *gorm.DB, preloads map[string]string) *gorm.DB {
+	if columns := o.selectColumns(); columns != nil {
+		db = db.Select(columns)
+	}
+	for include, association := range preloads {
+		if o.has(include) {
+			db = db.Preload(association)
+		}
+	}
+	return db
+}
+
+var teamPreloads = map[string]string{
+	"members":       "Members",
+	"members.teams": "Members.Teams",
+}
+
+var memberPreloads = map[string]string{
+	"teams":         "Teams",
+	"teams.members": "Teams.Members",
+}
+
+func loadTeamIncludes(db *gorm.DB, teams []*models.Team, opts QueryOptions, prefix string) error {
+	if err := attachTeamFeedback(db, teams, opts.has(prefix+"feedback"), opts.has(prefix+"feedback_count")); err != nil {
+		return err
+	}
+
+	if prefix == "" && opts.has("members") {
+		var members []*models.TeamMember
+		for _, team := range teams {
+			for i := range team.Members {
+				members = append(members, &team.Members[i])
+			}
+		}
+		return attachMemberFeedback(db, members, opts.has("members.feedback"), opts.has("members.feedback_count"))
+	}
+	return nil
+}
+
+func loadMemberIncludes(db *gorm.DB, members []*models.TeamMember, opts QueryOptions) error {
+	if err := attachMemberFeedback(db, members, opts.has("feedback"), opts.has("feedback_count")); err != nil {
+		return err
+	}
+
+	if opts.has("teams") {
+		var teams []*models.Team
+		for _, member := range members {
+			for i := range member.Teams {
+				teams = append(teams, &member.Teams[i])
+			}
+		}
+		return loadTeamIncludes(db, teams, opts, "teams.")
+	}
+	return nil
+}
+
+func attachTeamFeedback(db *gorm.DB, teams []*models.Team, withContent, withCount bool) error {
+	ids := make([]uint, 0, len(teams))
+	for _, team := range teams {
+		ids = append(ids, team.ID)
+	}
+
+	feedback, counts, err := loadFeedback(db, "team", ids, withContent, withCount)
+	if err != nil {
+		return err
+	}
+
+	for _, team := range teams {
+		if withContent {
+			team.Feedback = feedback[team.ID]
+		}
+		if withCount {
+			count := counts[team.ID]
+			team.FeedbackCount = &count
+		}
+	}
+	return nil
+}
+
+func attachMemberFeedback(db *gorm.DB, members []*models.TeamMember, withContent, withCount bool) error {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.ID)
+	}
+
+	feedback, counts, err := loadFeedback(db, "member", ids, withContent, withCount)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if withContent {
+			member.Feedback = feedback[member.ID]
+		}
+		if withCount {
+			count := counts[member.ID]
+			member.FeedbackCount = &count
+		}
+	}
+	return nil
+}
+
+// loadFeedback fetches feedback and/or feedback counts for a set of targets
+// with at most one query each, regardless of how many targets are given.
+func loadFeedback(db *gorm.DB, targetType string, ids []uint, withContent, withCount bool) (map[uint][]models.Feedback, map[uint]int64, error) {
+	service := NewFeedbackService(db)
+	feedback := make(map[uint][]models.Feedback)
+	counts := make(map[uint]int64)
+
+	var err error
+	if withContent {
+		if feedback, err = service.FeedbackForTargets(targetType, ids, 0); err != nil {
+			return nil, nil, err
+		}
+	}
+	if withCount {
+		if counts, err = service.CountFeedbackForTargets(targetType, ids); err != nil {
+			return nil, nil, err
+		}
+	}
+	return feedback, counts, nil
+}
@@ -1,17 +1,40 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"coaching-app-backend/audit"
+	"coaching-app-backend/auth"
+	"coaching-app-backend/encryption"
+	"coaching-app-backend/metrics"
 	"coaching-app-backend/models"
+	"coaching-app-backend/oidc"
+	"coaching-app-backend/oidc/oidctest"
+	"coaching-app-backend/tenant"
+	"coaching-app-backend/tracing"
 
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+var testEncryptionKeys, _ = encryption.ParseKeyring("test:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+
 func setupTestDB() *gorm.DB {
 	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	db.AutoMigrate(&models.TeamMember{}, &models.Team{}, &models.TeamAssignment{}, &models.Feedback{})
+	tenant.Register(db)
+	encryption.Register(db, testEncryptionKeys)
+	db.AutoMigrate(&models.Organization{}, &models.TeamMember{}, &models.Team{}, &models.TeamAssignment{}, &models.Feedback{}, &models.AuditEvent{}, &models.User{}, &models.RefreshToken{}, &models.TeamCoach{}, &models.APIKey{}, &models.OIDCLoginState{}, &models.ErasureRecord{}, &models.IdempotencyKey{})
 	return db
 }
 
@@ -90,6 +113,149 @@ func TestTeamMemberServiceDuplicateEmail(t *testing.T) {
 	}
 }
 
+func TestBatchUpsertTeamMembers(t *testing.T) {
+	db := setupTestDB()
+	service := NewTeamMemberService(db)
+
+	team := models.Team{Name: "Dev Team"}
+	existing := models.TeamMember{Name: "Jane Smith", Email: "jane@example.com"}
+	unchanged := models.TeamMember{Name: "Bob Stone", Email: "bob@example.com"}
+	db.Create(&team)
+	db.Create(&existing)
+	db.Create(&unchanged)
+
+	results, err := service.BatchUpsertTeamMembers([]TeamMemberInput{
+		{Name: "John Doe", Email: "john@example.com", TeamIDs: []uint{team.ID}},
+		{Name: "Jane Doe", Email: " Jane@Example.com "},
+		{Name: "Bob Stone", Email: "bob@example.com"},
+		{Name: "No Email"},
+		{Name: "Bad Picture", Email: "bad@example.com", Picture: "not a url"},
+	}, BatchBestEffort)
+	if err != nil {
+		t.Fatalf("Failed to run best-effort batch: %v", err)
+	}
+
+	expected := []BatchStatus{BatchCreated, BatchUpdated, BatchUnchanged, BatchError, BatchError}
+	for i, status := range expected {
+		if results[i].Status != status {
+			t.Errorf("Item %d: expected status %s, got %s", i, status, results[i].Status)
+		}
+	}
+
+	var count int64
+	db.Model(&models.TeamAssignment{}).Where("team_id = ?", team.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 assignment, got %d", count)
+	}
+
+	// A failing item in transactional mode rolls back the whole batch
+	results, err = service.BatchUpsertTeamMembers([]TeamMemberInput{
+		{Name: "Alice Green", Email: "alice@example.com"},
+		{Name: "Carl White", Email: "carl@example.com", TeamIDs: []uint{999}},
+	}, BatchTransactional)
+	if !errors.Is(err, ErrBatchAborted) {
+		t.Fatalf("Expected ErrBatchAborted, got %v", err)
+	}
+	if len(results) != 2 || results[1].Status != BatchError {
+		t.Errorf("Expected the second item to fail, got %+v", results)
+	}
+
+	db.Model(&models.TeamMember{}).Where("email = ?", "alice@example.com").Count(&count)
+	if count != 0 {
+		t.Error("Expected transactional batch to be rolled back")
+	}
+}
+
+func TestCSVServiceImportMembers(t *testing.T) {
+	db := setupTestDB()
+	service := NewCSVService(db)
+
+	team := models.Team{Name: "Design Team"}
+	existing := models.TeamMember{Name: "Jane Smith", Email: "jane@example.com"}
+	db.Create(&team)
+	db.Create(&existing)
+
+	roster := "Full Name,E-Mail,Teams\r\n" +
+		"John Doe,john@example.com,design team\r\n" +
+		"Jane Doe,jane@example.com,\r\n" +
+		"Ghost,ghost@example.com,Missing Team\r\n"
+	mapping := map[string]string{"name": "Full Name", "email": "E-Mail"}
+
+	// The dry run reports every row but saves nothing
+	preview, err := service.ImportMembers(strings.NewReader(roster), mapping, BatchTransactional, true)
+	if err != nil {
+		t.Fatalf("Failed to preview import: %v", err)
+	}
+	if preview.Committed || len(preview.Rows) != 3 {
+		t.Fatalf("Expected uncommitted preview of 3 rows, got %+v", preview)
+	}
+	expected := []BatchStatus{BatchCreated, BatchUpdated, BatchError}
+	for i, status := range expected {
+		if preview.Rows[i].Status != status || preview.Rows[i].Row != i+2 {
+			t.Errorf("Row %d: expected status %s, got %+v", i+2, status, preview.Rows[i])
+		}
+	}
+
+	var count int64
+	db.Model(&models.TeamMember{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected dry run to leave 1 member, got %d", count)
+	}
+
+	// A transactional commit refuses to save anything while a row is invalid
+	_, err = service.ImportMembers(strings.NewReader(roster), mapping, BatchTransactional, false)
+	if !errors.Is(err, ErrBatchAborted) {
+		t.Fatalf("Expected ErrBatchAborted, got %v", err)
+	}
+
+	result, err := service.ImportMembers(strings.NewReader(roster), mapping, BatchBestEffort, false)
+	if err != nil {
+		t.Fatalf("Failed to commit import: %v", err)
+	}
+	if !result.Committed || result.Summary[BatchCreated] != 1 || result.Summary[BatchError] != 1 {
+		t.Errorf("Unexpected import summary: %+v", result.Summary)
+	}
+
+	members, _ := NewTeamService(db).GetTeamMembers(team.ID)
+	if len(members) != 1 || members[0].Email != "john@example.com" {
+		t.Errorf("Expected john to be assigned to the design team, got %+v", members)
+	}
+}
+
+func TestCSVServiceExport(t *testing.T) {
+	db := setupTestDB()
+	service := NewCSVService(db)
+
+	team := models.Team{Name: "Dev Team"}
+	member := models.TeamMember{Name: "=cmd()", Email: "john@example.com"}
+	db.Create(&team)
+	db.Create(&member)
+	db.Model(&team).Association("Members").Append(&member)
+	db.Create(&models.Feedback{Content: "Great team!", TargetType: "team", TargetID: team.ID})
+	db.Create(&models.Feedback{Content: "Nice job!", TargetType: "member", TargetID: member.ID})
+
+	var buf strings.Builder
+	if err := service.ExportMembers(&buf, 0, []string{"email", "name", "teams"}); err != nil {
+		t.Fatalf("Failed to export members: %v", err)
+	}
+	expected := "\ufeffemail,name,teams\r\njohn@example.com,'=cmd(),Dev Team\r\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := service.ExportFeedback(&buf, FeedbackFilter{TargetType: "member"}, []string{"content"}); err != nil {
+		t.Fatalf("Failed to export feedback: %v", err)
+	}
+	if buf.String() != "\ufeffcontent\r\nNice job!\r\n" {
+		t.Errorf("Expected only member feedback, got %q", buf.String())
+	}
+
+	if err := service.ExportTeams(&buf, []string{"secret"}); !errors.Is(err, ErrInvalidQueryOption) {
+		t.Errorf("Expected ErrInvalidQueryOption for unknown column, got %v", err)
+	}
+}
+
 // Team Service Tests
 func TestTeamService(t *testing.T) {
 	db := setupTestDB()
@@ -137,6 +303,86 @@ func TestTeamService(t *testing.T) {
 	}
 }
 
+func TestTeamServiceIncludes(t *testing.T) {
+	db := setupTestDB()
+	service := NewTeamService(db)
+
+	team := models.Team{Name: "Dev Team", Logo: "logo.png"}
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	db.Create(&team)
+	db.Create(&member)
+	db.Model(&team).Association("Members").Append(&member)
+	db.Create(&models.Feedback{Content: "Great team!", TargetType: "team", TargetID: team.ID})
+	db.Create(&models.Feedback{Content: "Nice job!", TargetType: "member", TargetID: member.ID})
+	db.Create(&models.Feedback{Content: "Keep it up!", TargetType: "member", TargetID: member.ID})
+
+	// Without includes no relations are loaded
+	teams, err := service.FindTeams(QueryOptions{})
+	if err != nil {
+		t.Fatalf("Failed to find teams: %v", err)
+	}
+	if len(teams) != 1 || teams[0].Members != nil || teams[0].FeedbackCount != nil {
+		t.Errorf("Expected team without relations, got %+v", teams)
+	}
+
+	// Members, nested feedback counts and team feedback in one call
+	teams, err = service.FindTeams(QueryOptions{
+		Include: []string{"members", "members.feedback_count", "feedback"},
+		Fields:  []string{"name"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to find teams with includes: %v", err)
+	}
+	if len(teams[0].Members) != 1 {
+		t.Fatalf("Expected 1 member, got %d", len(teams[0].Members))
+	}
+	if count := teams[0].Members[0].FeedbackCount; count == nil || *count != 2 {
+		t.Errorf("Expected member feedback count 2, got %v", count)
+	}
+	if len(teams[0].Feedback) != 1 {
+		t.Errorf("Expected 1 team feedback item, got %d", len(teams[0].Feedback))
+	}
+	if teams[0].Logo != "" {
+		t.Errorf("Expected logo not to be selected, got %q", teams[0].Logo)
+	}
+
+	// Unknown includes, fields and over-deep paths are rejected
+	invalid := []QueryOptions{
+		{Include: []string{"owners"}},
+		{Fields: []string{"password"}},
+		{Include: []string{"members.teams.members"}},
+		{Include: []string{"members.feedback_count"}},
+	}
+	for _, opts := range invalid {
+		if _, err := service.FindTeams(opts); !errors.Is(err, ErrInvalidQueryOption) {
+			t.Errorf("Expected ErrInvalidQueryOption for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestTeamMemberServiceIncludes(t *testing.T) {
+	db := setupTestDB()
+	service := NewTeamMemberService(db)
+
+	team := models.Team{Name: "Dev Team"}
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	db.Create(&team)
+	db.Create(&member)
+	db.Model(&team).Association("Members").Append(&member)
+	db.Create(&models.Feedback{Content: "Great team!", TargetType: "team", TargetID: team.ID})
+
+	retrieved, err := service.FindTeamMember(member.ID, QueryOptions{Include: []string{"teams", "teams.feedback_count"}})
+	if err != nil {
+		t.Fatalf("Failed to find team member: %v", err)
+	}
+	if len(retrieved.Teams) != 1 {
+		t.Fatalf("Expected 1 team, got %d", len(retrieved.Teams))
+	}
+	if count := retrieved.Teams[0].FeedbackCount; count == nil || *count != 1 {
+		t.Errorf("Expected team feedback count 1, got %v", count)
+	}
+}
+
 // Feedback Service Tests
 func TestFeedbackService(t *testing.T) {
 	db := setupTestDB()
@@ -219,6 +465,33 @@ func TestFeedbackServiceInvalidTarget(t *testing.T) {
 	}
 }
 
+func TestFeedbackForTargetsLimitsPerTarget(t *testing.T) {
+	db := setupTestDB()
+	service := NewFeedbackService(db)
+
+	start := time.Now().Add(-time.Hour)
+	for i, target := range []uint{1, 1, 1, 2} {
+		db.Create(&models.Feedback{Content: "Feedback " + strconv.Itoa(i), TargetType: "member", TargetID: target, CreatedAt: start.Add(time.Duration(i) * time.Minute)})
+	}
+	db.Create(&models.Feedback{Content: "About a team", TargetType: "team", TargetID: 1})
+
+	grouped, err := service.FeedbackForTargets("member", []uint{1, 2}, 2)
+	if err != nil {
+		t.Fatalf("Failed to load feedback: %v", err)
+	}
+	if len(grouped[1]) != 2 || grouped[1][0].Content != "Feedback 2" || grouped[1][1].Content != "Feedback 1" {
+		t.Errorf("Expected the 2 most recent feedback entries of member 1, got %+v", grouped[1])
+	}
+	if len(grouped[2]) != 1 {
+		t.Errorf("Expected the feedback of member 2, got %+v", grouped[2])
+	}
+
+	all, err := service.FeedbackForTargets("member", []uint{1, 2}, 0)
+	if err != nil || len(all[1]) != 3 {
+		t.Errorf("Expected all feedback without a limit, got %+v, %v", all[1], err)
+	}
+}
+
 // Assignment Service Tests
 func TestAssignmentService(t *testing.T) {
 	db := setupTestDB()
@@ -252,8 +525,8 @@ func TestAssignmentService(t *testing.T) {
 
 	// Test duplicate assignment
 	err = service.AssignMemberToTeam(team.ID, member.ID)
-	if err == nil {
-		t.Error("Expected error for duplicate assignment")
+	if err != nil {
+		t.Errorf("Expected duplicate assignment to succeed, got %v", err)
 	}
 
 	// Test remove assignment
@@ -293,3 +566,1112 @@ func TestAssignmentServiceInvalidIDs(t *testing.T) {
 		t.Error("Expected error for non-existent member")
 	}
 }
+
+func TestFindDuplicateCandidates(t *testing.T) {
+	db := setupTestDB()
+	service := NewTeamMemberService(db)
+
+	db.Create(&models.TeamMember{Name: "John Doe", Email: "john@example.com"})
+	db.Create(&models.TeamMember{Name: "Doe, John", Email: "JOHN@example.com"})
+	db.Create(&models.TeamMember{Name: "Jane Smyth", Email: "jane@example.com"})
+	db.Create(&models.TeamMember{Name: "Jane Smith", Email: "jane.smith@example.com"})
+	db.Create(&models.TeamMember{Name: "Bob", Email: "bob@example.com"})
+	db.Create(&models.TeamMember{Name: "Rob", Email: "rob@example.com"})
+
+	candidates, err := service.FindDuplicateCandidates()
+	if err != nil {
+		t.Fatalf("Failed to find duplicates: %v", err)
+	}
+
+	if len(candidates) != 2 {
+		t.Fatalf("Expected 2 candidate pairs, got %+v", candidates)
+	}
+	if len(candidates[0].Reasons) != 2 {
+		t.Errorf("Expected John Doe to match on email and name, got %v", candidates[0].Reasons)
+	}
+	if candidates[1].Members[0].Name != "Jane Smyth" || candidates[1].Reasons[0] != DuplicateSimilarName {
+		t.Errorf("Expected Jane Smyth and Jane Smith to match on name, got %+v", candidates[1])
+	}
+}
+
+func TestCandidatePairsShareEmailOrWord(t *testing.T) {
+	emails := []string{"ann@example.com", "bob@example.com", "bob.lee@example.com", "ann@example.com"}
+	names := []string{normalizeName("Ann Lee"), normalizeName("Bob Stone"), normalizeName("Lee, Bob"), normalizeName("Annie")}
+
+	pairs := candidatePairs(emails, names)
+	expected := [][2]int{{0, 2}, {0, 3}, {1, 2}}
+	if fmt.Sprint(pairs) != fmt.Sprint(expected) {
+		t.Errorf("Expected pairs %v, got %v", expected, pairs)
+	}
+}
+
+func TestMergeTeamMembers(t *testing.T) {
+	db := setupTestDB()
+	service := NewTeamMemberService(db)
+
+	shared := models.Team{Name: "Shared"}
+	other := models.Team{Name: "Other"}
+	target := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	source := models.TeamMember{Name: "John Doe", Email: "John.Doe@example.com", Picture: "https://example.com/john.png"}
+	db.Create(&shared)
+	db.Create(&other)
+	db.Create(&target)
+	db.Create(&source)
+	db.Create(&models.TeamAssignment{TeamID: shared.ID, TeamMemberID: target.ID})
+	db.Create(&models.TeamAssignment{TeamID: shared.ID, TeamMemberID: source.ID})
+	db.Create(&models.TeamAssignment{TeamID: other.ID, TeamMemberID: source.ID})
+	db.Create(&models.Feedback{Content: "About source", TargetType: "member", TargetID: source.ID})
+	db.Create(&models.Feedback{Content: "By source", TargetType: "team", TargetID: shared.ID, AuthorID: &source.ID})
+
+	result, err := service.MergeTeamMembers(target.ID, source.ID)
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+
+	if result.MovedAssignments != 1 || result.MovedFeedback != 1 || result.MovedAuthorship != 1 {
+		t.Errorf("Unexpected merge result: %+v", result)
+	}
+	if len(result.Member.Teams) != 2 || result.Member.Picture != source.Picture {
+		t.Errorf("Expected target to gain the other team and the picture, got %+v", result.Member)
+	}
+
+	var count int64
+	db.Model(&models.TeamAssignment{}).Where("team_member_id = ?", target.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 assignments without duplicates, got %d", count)
+	}
+	db.Model(&models.TeamMember{}).Where("id = ?", source.ID).Count(&count)
+	if count != 0 {
+		t.Error("Expected source member to be deleted")
+	}
+	db.Model(&models.Feedback{}).Where("target_id = ? AND target_type = ?", target.ID, "member").Count(&count)
+	if count != 1 {
+		t.Errorf("Expected feedback to be re-pointed, got %d", count)
+	}
+	db.Model(&models.AuditEvent{}).Where("action = ? AND entity_id = ?", "team_member.merged", target.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected an audit event, got %d", count)
+	}
+
+	if _, err := service.MergeTeamMembers(target.ID, target.ID); !errors.Is(err, ErrMergeSelf) {
+		t.Errorf("Expected ErrMergeSelf, got %v", err)
+	}
+	if _, err := service.MergeTeamMembers(target.ID, source.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound for a merged source, got %v", err)
+	}
+}
+
+func TestMergeTeamMembersMovesLinkedAccount(t *testing.T) {
+	db := setupTestDB()
+	service := NewTeamMemberService(db)
+
+	target := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	source := models.TeamMember{Name: "John Doe", Email: "john.doe@example.com"}
+	other := models.TeamMember{Name: "Johnny Doe", Email: "johnny@example.com"}
+	db.Create(&target)
+	db.Create(&source)
+	db.Create(&other)
+	account := models.User{Email: "john.doe@example.com", PasswordHash: "x", Role: models.RoleMember, TeamMemberID: &source.ID}
+	db.Create(&account)
+
+	result, err := service.MergeTeamMembers(target.ID, source.ID)
+	if err != nil {
+		t.Fatalf("Failed to merge: %v", err)
+	}
+	if !result.MovedAccount {
+		t.Errorf("Expected the account to be moved, got %+v", result)
+	}
+	db.First(&account, account.ID)
+	if account.TeamMemberID == nil || *account.TeamMemberID != target.ID {
+		t.Errorf("Expected the account to be linked to the target, got %v", account.TeamMemberID)
+	}
+
+	// The target now has an account, so another member with one cannot be
+	// merged into it
+	otherAccount := models.User{Email: "johnny@example.com", PasswordHash: "x", Role: models.RoleMember, TeamMemberID: &other.ID}
+	db.Create(&otherAccount)
+	if _, err := service.MergeTeamMembers(target.ID, other.ID); !errors.Is(err, ErrMergeLinkedAccounts) {
+		t.Errorf("Expected ErrMergeLinkedAccounts, got %v", err)
+	}
+	var count int64
+	db.Model(&models.TeamMember{}).Where("id = ?", other.ID).Count(&count)
+	if count != 1 {
+		t.Error("Expected the rejected merge to keep the source member")
+	}
+}
+
+func newTestTokenIssuer() *auth.TokenIssuer {
+	keys, _ := auth.RandomKeyring()
+	return auth.NewTokenIssuer(keys, auth.DefaultAccessTokenTTL, auth.DefaultRefreshTokenTTL)
+}
+
+func TestAuthServiceLoginAndRefresh(t *testing.T) {
+	db := setupTestDB()
+	tokens := newTestTokenIssuer()
+	service := NewAuthService(db, tokens)
+
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	db.Create(&member)
+
+	user, err := service.CreateUser(UserInput{Email: "john@example.com", Password: "s3cret-password", TeamMemberID: &member.ID})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if user.PasswordHash == "s3cret-password" {
+		t.Error("Expected password to be hashed")
+	}
+	if _, err := service.CreateUser(UserInput{Email: "john@example.com", Password: "another-password"}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+	if _, err := service.CreateUser(UserInput{Email: "other@example.com", Password: "another-password", TeamMemberID: &member.ID}); !errors.Is(err, ErrTeamMemberLinked) {
+		t.Errorf("Expected ErrTeamMemberLinked, got %v", err)
+	}
+
+	if _, err := service.Login(LoginInput{Email: "john@example.com", Password: "wrong-password"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	if _, err := service.Login(LoginInput{Email: "nobody@example.com", Password: "s3cret-password"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for an unknown email, got %v", err)
+	}
+
+	first, err := service.Login(LoginInput{Email: "john@example.com", Password: "s3cret-password"})
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	principal, err := tokens.ParseAccessToken(first.AccessToken)
+	if err != nil || principal.UserID != user.ID || principal.TeamMemberID == nil || *principal.TeamMemberID != member.ID {
+		t.Errorf("Unexpected access token principal %+v: %v", principal, err)
+	}
+
+	second, err := service.Refresh(RefreshInput{RefreshToken: first.RefreshToken})
+	if err != nil {
+		t.Fatalf("Failed to refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Expected the refresh token to rotate")
+	}
+
+	// Replaying a rotated token revokes the whole family, including the
+	// token issued by the legitimate refresh
+	if _, err := service.Refresh(RefreshInput{RefreshToken: first.RefreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected a reused refresh token to be rejected, got %v", err)
+	}
+	if _, err := service.Refresh(RefreshInput{RefreshToken: second.RefreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected the family to be revoked after reuse, got %v", err)
+	}
+
+	third, _ := service.Login(LoginInput{Email: "john@example.com", Password: "s3cret-password"})
+	if err := service.Logout(RefreshInput{RefreshToken: third.RefreshToken}); err != nil {
+		t.Fatalf("Failed to log out: %v", err)
+	}
+	if _, err := service.Refresh(RefreshInput{RefreshToken: third.RefreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected a logged out refresh token to be rejected, got %v", err)
+	}
+}
+
+func TestAuthServiceBootstrapAndLink(t *testing.T) {
+	db := setupTestDB()
+	service := NewAuthService(db, newTestTokenIssuer())
+
+	created, err := service.BootstrapUser(UserInput{Email: "admin@example.com", Password: "admin-password"})
+	if err != nil || !created {
+		t.Fatalf("Expected bootstrap user to be created, got %v %v", created, err)
+	}
+	created, _ = service.BootstrapUser(UserInput{Email: "second@example.com", Password: "admin-password"})
+	if created {
+		t.Error("Expected bootstrap to be skipped once users exist")
+	}
+
+	var admin models.User
+	db.First(&admin)
+	member := models.TeamMember{Name: "Admin", Email: "admin@example.com"}
+	db.Create(&member)
+
+	user, err := service.LinkTeamMember(admin.ID, &member.ID)
+	if err != nil || user.TeamMemberID == nil || *user.TeamMemberID != member.ID {
+		t.Fatalf("Failed to link team member: %+v %v", user, err)
+	}
+	if user, err = service.LinkTeamMember(admin.ID, nil); err != nil || user.TeamMemberID != nil {
+		t.Errorf("Failed to unlink team member: %+v %v", user, err)
+	}
+	missing := uint(999)
+	if _, err := service.LinkTeamMember(admin.ID, &missing); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound for an unknown team member, got %v", err)
+	}
+}
+
+func TestTeamServiceCoaches(t *testing.T) {
+	db := setupTestDB()
+	service := NewTeamService(db)
+	auth := NewAuthService(db, newTestTokenIssuer())
+
+	team := &models.Team{Name: "Development Team"}
+	service.CreateTeam(team)
+	coach, _ := auth.CreateUser(UserInput{Email: "coach@example.com", Password: "coach-password", Role: models.RoleCoach})
+	member, _ := auth.CreateUser(UserInput{Email: "member@example.com", Password: "member-password"})
+
+	if err := service.AddCoach(team.ID, member.ID); !errors.Is(err, ErrNotCoach) {
+		t.Errorf("Expected ErrNotCoach, got %v", err)
+	}
+	if err := service.AddCoach(team.ID, coach.ID); err != nil {
+		t.Fatalf("Failed to add coach: %v", err)
+	}
+	if err := service.AddCoach(team.ID, coach.ID); !errors.Is(err, ErrAlreadyCoaching) {
+		t.Errorf("Expected ErrAlreadyCoaching, got %v", err)
+	}
+
+	coaches, err := service.GetCoaches(team.ID)
+	if err != nil || len(coaches) != 1 || coaches[0].ID != coach.ID {
+		t.Errorf("Expected the coach to be listed, got %+v %v", coaches, err)
+	}
+
+	if err := service.DeleteTeam(team.ID); err != nil {
+		t.Fatalf("Failed to delete team: %v", err)
+	}
+	var count int64
+	db.Model(&models.TeamCoach{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected coach assignments to be deleted with the team, got %d", count)
+	}
+}
+
+func TestAPIKeyService(t *testing.T) {
+	db := setupTestDB()
+	service := NewAPIKeyService(db)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Hour)
+	if _, err := service.CreateAPIKey(APIKeyInput{Name: "Old", Scopes: []string{"members:read"}, ExpiresAt: &past}, 1); !errors.Is(err, ErrAPIKeyExpiry) {
+		t.Errorf("Expected ErrAPIKeyExpiry, got %v", err)
+	}
+
+	created, err := service.CreateAPIKey(APIKeyInput{Name: "HR sync", Scopes: []string{"members:read", "members:write", "members:read"}}, 1)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, APIKeyPrefix) || !strings.HasPrefix(created.Key, created.Prefix) {
+		t.Errorf("Expected key %q to start with %q", created.Key, created.Prefix)
+	}
+	if len(created.Scopes) != 2 {
+		t.Errorf("Expected duplicate scopes to be dropped, got %v", created.Scopes)
+	}
+
+	var stored models.APIKey
+	db.First(&stored, created.ID)
+	if stored.KeyHash == created.Key || strings.Contains(stored.KeyHash, created.Key) {
+		t.Error("Expected only a hash of the key to be stored")
+	}
+	if stored.LastUsedAt != nil {
+		t.Error("Expected an unused key to have no last use")
+	}
+
+	principal, err := service.AuthenticateAPIKey(ctx, created.Key)
+	if err != nil {
+		t.Fatalf("Failed to authenticate API key: %v", err)
+	}
+	if principal.APIKeyID != created.ID || len(principal.Scopes) != 2 || principal.Role != "" {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+	db.First(&stored, created.ID)
+	if stored.LastUsedAt == nil {
+		t.Error("Expected the last use to be recorded")
+	}
+
+	if _, err := service.AuthenticateAPIKey(ctx, created.Key+"x"); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for an unknown key, got %v", err)
+	}
+
+	db.Model(&stored).Update("expires_at", past)
+	if _, err := service.AuthenticateAPIKey(ctx, created.Key); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for an expired key, got %v", err)
+	}
+	db.Model(&stored).Update("expires_at", nil)
+
+	if err := service.RevokeAPIKey(created.ID); err != nil {
+		t.Fatalf("Failed to revoke API key: %v", err)
+	}
+	if _, err := service.AuthenticateAPIKey(ctx, created.Key); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for a revoked key, got %v", err)
+	}
+	if err := service.RevokeAPIKey(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound for an unknown key, got %v", err)
+	}
+}
+
+func newTestOIDCService(t *testing.T, db *gorm.DB, roles OIDCRoleMapping) (*OIDCService, *oidctest.Server) {
+	idp := oidctest.NewServer("coaching-app")
+	t.Cleanup(idp.Close)
+	provider := oidc.NewProvider(oidc.Config{
+		IssuerURL:   idp.URL,
+		ClientID:    "coaching-app",
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+	}, idp.Client())
+	return NewOIDCService(db, newTestTokenIssuer(), provider, roles), idp
+}
+
+// signInAt starts a sign-in and follows it through the provider, returning
+// the code and state it redirects back with
+func signInAt(t *testing.T, service *OIDCService, idp *oidctest.Server) (string, string) {
+	t.Helper()
+	authURL, err := service.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to begin sign-in: %v", err)
+	}
+
+	client := idp.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("Failed to sign in at provider: %v", err)
+	}
+	resp.Body.Close()
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCServiceProvisioning(t *testing.T) {
+	db := setupTestDB()
+	roles, err := ParseOIDCRoleMapping("groups", "coaching-admins:admin, coaches:coach")
+	if err != nil {
+		t.Fatalf("Failed to parse role mapping: %v", err)
+	}
+	service, idp := newTestOIDCService(t, db, roles)
+	tokens := service.auth.tokens
+	ctx := context.Background()
+
+	member := models.TeamMember{Name: "Jane Doe", Email: "jane@example.com"}
+	db.Create(&member)
+
+	// First sign-in creates the account and links the team member by their
+	// email, normalized like the stored one
+	idp.SetUser(map[string]interface{}{"sub": "jane", "email": "Jane@Example.com", "email_verified": true, "groups": []string{"coaches"}})
+	code, state := signInAt(t, service, idp)
+	pair, err := service.Complete(ctx, code, state)
+	if err != nil {
+		t.Fatalf("Failed to complete sign-in: %v", err)
+	}
+	principal, err := tokens.ParseAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to parse access token: %v", err)
+	}
+	if principal.Email != "jane@example.com" || principal.Role != models.RoleCoach {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+	if principal.TeamMemberID == nil || *principal.TeamMemberID != member.ID {
+		t.Errorf("Expected account to be linked to team member %d, got %v", member.ID, principal.TeamMemberID)
+	}
+
+	// The state can only be used once
+	if _, err := service.Complete(ctx, code, state); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Errorf("Expected ErrInvalidOIDCState on reuse, got %v", err)
+	}
+
+	// Later sign-ins find the account by subject, even with a new email, and
+	// follow role changes at the provider
+	idp.SetUser(map[string]interface{}{"sub": "jane", "email": "jane.doe@example.com", "email_verified": true, "groups": []string{"coaches", "coaching-admins"}})
+	code, state = signInAt(t, service, idp)
+	pair, err = service.Complete(ctx, code, state)
+	if err != nil {
+		t.Fatalf("Failed to complete second sign-in: %v", err)
+	}
+	principal, _ = tokens.ParseAccessToken(pair.AccessToken)
+	if principal.Email != "jane@example.com" || principal.Role != models.RoleAdmin {
+		t.Errorf("Unexpected principal on second sign-in %+v", principal)
+	}
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 user, got %d", count)
+	}
+}
+
+func TestOIDCServiceExistingAccounts(t *testing.T) {
+	db := setupTestDB()
+	service, idp := newTestOIDCService(t, db, OIDCRoleMapping{})
+	ctx := context.Background()
+
+	existing, err := service.auth.CreateUser(UserInput{Email: "bob@example.com", Password: "password123", Role: models.RoleTeamLead})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		wantErr error
+	}{
+		{"Unverified email", map[string]interface{}{"sub": "mallory", "email": "bob@example.com", "email_verified": false}, ErrOIDCEmailUnverified},
+		{"Missing email", map[string]interface{}{"sub": "mallory"}, ErrOIDCEmailUnverified},
+		{"Verified email links account", map[string]interface{}{"sub": "bob", "email": "BOB@example.com", "email_verified": true}, nil},
+		{"Email linked to another subject", map[string]interface{}{"sub": "bob-2", "email": "bob@example.com", "email_verified": true}, ErrUserExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.SetUser(tt.claims)
+			code, state := signInAt(t, service, idp)
+			pair, err := service.Complete(ctx, code, state)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			principal, _ := service.auth.tokens.ParseAccessToken(pair.AccessToken)
+			if principal.UserID != existing.ID || principal.Role != models.RoleTeamLead {
+				t.Errorf("Expected the existing account to keep its role without a mapping, got %+v", principal)
+			}
+		})
+	}
+
+	// The linked account can still sign in with its password
+	if _, err := service.auth.Login(LoginInput{Email: "bob@example.com", Password: "password123"}); err != nil {
+		t.Errorf("Expected password login to keep working, got %v", err)
+	}
+}
+
+func TestParseOIDCRoleMapping(t *testing.T) {
+	tests := []struct {
+		mapping string
+		valid   bool
+	}{
+		{"", true},
+		{"admins:admin,leads:Team_Lead", true},
+		{"urn:example:group:coaches:coach", true},
+		{"admins", false},
+		{"admins:owner", false},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseOIDCRoleMapping("groups", tt.mapping)
+		if tt.valid && err != nil {
+			t.Errorf("Expected %q to parse, got %v", tt.mapping, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Expected %q to be rejected", tt.mapping)
+		}
+	}
+
+	mapping, _ := ParseOIDCRoleMapping("groups", "leads:team_lead")
+	if role, _ := mapping.role(map[string]interface{}{"groups": "leads staff"}); role != models.RoleTeamLead {
+		t.Errorf("Expected a space-separated claim to map to team_lead, got %q", role)
+	}
+	if role, _ := mapping.role(map[string]interface{}{}); role != models.RoleMember {
+		t.Errorf("Expected unmapped users to be members, got %q", role)
+	}
+}
+
+func TestAuditEvents(t *testing.T) {
+	db := setupTestDB()
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: 7, Role: models.RoleAdmin})
+	ctx = audit.WithRequest(ctx, audit.Request{ID: "req-1", IP: "192.0.2.10"})
+	teams := NewTeamService(db).WithContext(ctx)
+	assignments := NewAssignmentService(db).WithContext(ctx)
+	members := NewTeamMemberService(db).WithContext(ctx)
+
+	team := &models.Team{Name: "Audited Team"}
+	if err := teams.CreateTeam(team); err != nil {
+		t.Fatalf("Failed to create team: %v", err)
+	}
+	member := &models.TeamMember{Name: "Jane Doe", Email: "jane@example.com"}
+	if err := members.CreateTeamMember(member); err != nil {
+		t.Fatalf("Failed to create member: %v", err)
+	}
+	if err := assignments.AssignMemberToTeam(team.ID, member.ID); err != nil {
+		t.Fatalf("Failed to assign member: %v", err)
+	}
+	if err := assignments.AssignMemberToTeam(team.ID, 999); err == nil {
+		t.Fatal("Expected assigning a missing member to fail")
+	}
+	if err := assignments.RemoveMemberFromTeam(team.ID, member.ID); err != nil {
+		t.Fatalf("Failed to unassign member: %v", err)
+	}
+	if err := assignments.RemoveMemberFromTeam(team.ID, member.ID); err != nil {
+		t.Fatalf("Failed to unassign member again: %v", err)
+	}
+	if err := teams.DeleteTeam(team.ID); err != nil {
+		t.Fatalf("Failed to delete team: %v", err)
+	}
+
+	var events []models.AuditEvent
+	db.Order("id").Find(&events)
+	expected := []string{"team.created", "team_member.created", "team.member_assigned", "team.member_unassigned", "team.deleted"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, event := range events {
+		if event.Action != expected[i] {
+			t.Errorf("Expected event %d to be %s, got %s", i, expected[i], event.Action)
+		}
+		if event.ActorUserID == nil || *event.ActorUserID != 7 || event.RequestID != "req-1" || event.IP != "192.0.2.10" {
+			t.Errorf("Expected event %d to record the actor and request, got %+v", i, event)
+		}
+	}
+
+	deleted := events[4]
+	if deleted.EntityID != team.ID || deleted.After != nil || !strings.Contains(string(deleted.Before), `"Audited Team"`) {
+		t.Errorf("Expected the deleted team in the before snapshot only, got before %s after %s", deleted.Before, deleted.After)
+	}
+}
+
+func TestAuditServiceFindAndExport(t *testing.T) {
+	db := setupTestDB()
+	service := NewAuditService(db)
+	teams := NewTeamService(db)
+
+	for i := 0; i < 5; i++ {
+		teams.CreateTeam(&models.Team{Name: "Team " + strconv.Itoa(i)})
+	}
+	teams.DeleteTeam(1)
+
+	page, err := service.FindEvents(AuditFilter{Action: "team.created"}, 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to find events: %v", err)
+	}
+	if page.Total != 5 || len(page.Events) != 2 || page.Events[0].EntityID != 3 {
+		t.Errorf("Expected the second page of created teams, newest first, got %+v", page)
+	}
+
+	page, _ = service.FindEvents(AuditFilter{EntityType: audit.EntityTeam, EntityID: 1}, 1, 0)
+	if page.Total != 2 || page.PerPage != DefaultAuditPageSize || page.Events[0].Action != "team.deleted" {
+		t.Errorf("Expected both events of team 1, got %+v", page)
+	}
+
+	page, _ = service.FindEvents(AuditFilter{From: time.Now().Add(time.Hour)}, 1, 1000)
+	if page.Total != 0 || page.PerPage != MaxAuditPageSize {
+		t.Errorf("Expected no future events and a clamped page size, got %+v", page)
+	}
+
+	var buf strings.Builder
+	if err := service.ExportEvents(&buf, AuditFilter{Action: "team.deleted"}, []string{"action", "entity_id"}); err != nil {
+		t.Fatalf("Failed to export events: %v", err)
+	}
+	if buf.String() != utf8BOM+"action,entity_id\r\nteam.deleted,1\r\n" {
+		t.Errorf("Unexpected export: %q", buf.String())
+	}
+	if err := service.ExportEvents(&buf, AuditFilter{}, []string{"password"}); !errors.Is(err, ErrInvalidQueryOption) {
+		t.Errorf("Expected ErrInvalidQueryOption, got %v", err)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	db := setupTestDB()
+	db.Create(&models.Organization{ID: models.DefaultOrganizationID, Name: "Default", Slug: "default"})
+	db.Create(&models.Organization{ID: 2, Name: "Other", Slug: "other"})
+	mine := tenant.WithOrganization(context.Background(), models.DefaultOrganizationID)
+	theirs := tenant.WithOrganization(context.Background(), 2)
+
+	team := models.Team{Name: "Dev Team"}
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	if err := NewTeamService(db).WithContext(mine).CreateTeam(&team); err != nil {
+		t.Fatalf("Failed to create team: %v", err)
+	}
+	if err := NewTeamMemberService(db).WithContext(mine).CreateTeamMember(&member); err != nil {
+		t.Fatalf("Failed to create team member: %v", err)
+	}
+	feedback := models.Feedback{Content: "Great work", TargetType: "team", TargetID: team.ID}
+	if err := NewFeedbackService(db).WithContext(mine).CreateFeedback(&feedback); err != nil {
+		t.Fatalf("Failed to create feedback: %v", err)
+	}
+	if err := NewAssignmentService(db).WithContext(mine).AssignMemberToTeam(team.ID, member.ID); err != nil {
+		t.Fatalf("Failed to assign member: %v", err)
+	}
+
+	// Emails are unique per organization only
+	twin := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	if err := NewTeamMemberService(db).WithContext(theirs).CreateTeamMember(&twin); err != nil {
+		t.Fatalf("Expected the same email in another organization to be accepted, got %v", err)
+	}
+	if twin.OrganizationID != 2 {
+		t.Errorf("Expected the member to belong to organization 2, got %d", twin.OrganizationID)
+	}
+	duplicate := models.TeamMember{Name: "Jane Doe", Email: "john@example.com"}
+	if err := NewTeamMemberService(db).WithContext(theirs).CreateTeamMember(&duplicate); err == nil {
+		t.Error("Expected a duplicate email within an organization to be rejected")
+	}
+
+	teams := NewTeamService(db).WithContext(theirs)
+	if all, _ := teams.GetAllTeams(); len(all) != 0 {
+		t.Errorf("Expected no teams of another organization, got %+v", all)
+	}
+	if _, err := teams.GetTeamByID(team.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound reading another organization's team, got %v", err)
+	}
+	// Deleting a team that does not exist is a no-op
+	if err := teams.DeleteTeam(team.ID); err != nil {
+		t.Errorf("Failed to delete team: %v", err)
+	}
+
+	members := NewTeamMemberService(db).WithContext(theirs)
+	if all, _ := members.GetAllTeamMembers(); len(all) != 1 || all[0].ID != twin.ID {
+		t.Errorf("Expected only the organization's own member, got %+v", all)
+	}
+	if _, err := members.MergeTeamMembers(twin.ID, member.ID); err == nil {
+		t.Error("Expected merging another organization's member to fail")
+	}
+	results, _ := members.BatchUpsertTeamMembers([]TeamMemberInput{{Name: "Renamed", Email: "john@example.com", TeamIDs: []uint{team.ID}}}, BatchBestEffort)
+	if results[0].Status != BatchError {
+		t.Errorf("Expected assigning to another organization's team to fail, got %+v", results[0])
+	}
+
+	if err := NewAssignmentService(db).WithContext(theirs).AssignMemberToTeam(team.ID, twin.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound assigning to another organization's team, got %v", err)
+	}
+	if all, _ := NewAssignmentService(db).WithContext(theirs).GetAllAssignments(); len(all) != 0 {
+		t.Errorf("Expected no assignments of another organization, got %+v", all)
+	}
+
+	feedbackService := NewFeedbackService(db).WithContext(theirs)
+	if all, _ := feedbackService.GetAllFeedback(); len(all) != 0 {
+		t.Errorf("Expected no feedback of another organization, got %+v", all)
+	}
+	if err := feedbackService.CreateFeedback(&models.Feedback{Content: "Sneaky", TargetType: "team", TargetID: team.ID}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound giving feedback to another organization's team, got %v", err)
+	}
+
+	// Nothing of the first organization was changed
+	var reloaded models.TeamMember
+	db.First(&reloaded, member.ID)
+	if reloaded.Name != "John Doe" {
+		t.Errorf("Expected the member to be untouched, got %+v", reloaded)
+	}
+	if all, _ := NewTeamService(db).WithContext(mine).FindTeams(QueryOptions{Include: []string{"members"}}); len(all) != 1 || len(all[0].Members) != 1 {
+		t.Errorf("Expected the team and its member to remain, got %+v", all)
+	}
+	if page, _ := NewAuditService(db).WithContext(theirs).FindEvents(AuditFilter{}, 1, 0); page.Total != 1 {
+		t.Errorf("Expected only the organization's own audit event, got %+v", page)
+	}
+}
+
+func TestOrganizationService(t *testing.T) {
+	db := setupTestDB()
+	service := NewOrganizationService(db)
+
+	organization, err := service.CreateOrganization(OrganizationInput{
+		Name:  "Other",
+		Slug:  "other",
+		Admin: &UserInput{Email: "admin@other.example", Password: "admin-password", Role: models.RoleMember},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create organization: %v", err)
+	}
+	if _, err := service.CreateOrganization(OrganizationInput{Name: "Again", Slug: "other"}); !errors.Is(err, ErrOrganizationExists) {
+		t.Errorf("Expected ErrOrganizationExists, got %v", err)
+	}
+
+	var admin models.User
+	db.Where("email = ?", "admin@other.example").First(&admin)
+	if admin.OrganizationID != organization.ID || admin.Role != models.RoleAdmin {
+		t.Errorf("Expected an admin of the new organization, got %+v", admin)
+	}
+
+	for _, slugOrID := range []string{"other", strconv.FormatUint(uint64(organization.ID), 10)} {
+		if id, err := service.ResolveOrganization(context.Background(), slugOrID); err != nil || id != organization.ID {
+			t.Errorf("Expected %q to resolve to %d, got %d: %v", slugOrID, organization.ID, id, err)
+		}
+	}
+	if _, err := service.ResolveOrganization(context.Background(), "missing"); !errors.Is(err, tenant.ErrUnknownOrganization) {
+		t.Errorf("Expected ErrUnknownOrganization, got %v", err)
+	}
+}
+
+func TestFeedbackEncryption(t *testing.T) {
+	db := setupTestDB()
+	service := NewFeedbackService(db)
+	team := models.Team{Name: "Dev Team"}
+	db.Create(&team)
+
+	feedback := models.Feedback{Content: "Needs to speak up in retros", TargetType: "team", TargetID: team.ID}
+	if err := service.CreateFeedback(&feedback); err != nil {
+		t.Fatalf("Failed to create feedback: %v", err)
+	}
+
+	var stored string
+	db.Raw("SELECT content FROM feedbacks WHERE id = ?", feedback.ID).Scan(&stored)
+	if strings.Contains(stored, "retros") {
+		t.Errorf("Expected the content to be stored encrypted, got %q", stored)
+	}
+	var event models.AuditEvent
+	db.Where("entity_type = ?", audit.EntityFeedback).First(&event)
+	if strings.Contains(string(event.After), "retros") || !strings.Contains(string(event.After), encryption.Redacted) {
+		t.Errorf("Expected the audit snapshot to leave the content out, got %s", event.After)
+	}
+
+	all, err := service.GetFeedbackByTarget("team", team.ID)
+	if err != nil || len(all) != 1 || all[0].Content != "Needs to speak up in retros" {
+		t.Errorf("Expected the feedback to be read decrypted, got %+v: %v", all, err)
+	}
+	var buf strings.Builder
+	NewCSVService(db).ExportFeedback(&buf, FeedbackFilter{}, []string{"content"})
+	if !strings.Contains(buf.String(), "Needs to speak up in retros") {
+		t.Errorf("Expected exports to contain the decrypted content, got %q", buf.String())
+	}
+}
+
+func TestParseErasurePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		authored string
+		received string
+		expected ErasurePolicy
+		wantErr  bool
+	}{
+		{"Defaults", "", "", ErasurePolicy{AuthoredFeedback: ErasureRedact, ReceivedFeedback: ErasureKeep}, false},
+		{"Redact everything", "redact", " REDACT ", ErasurePolicy{AuthoredFeedback: ErasureRedact, ReceivedFeedback: ErasureRedact}, false},
+		{"Keep authored", "keep", "", ErasurePolicy{AuthoredFeedback: ErasureKeep, ReceivedFeedback: ErasureKeep}, false},
+		{"Unknown action", "delete", "", ErasurePolicy{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseErasurePolicy(tt.authored, tt.received)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidErasureAction) {
+					t.Errorf("Expected ErrInvalidErasureAction, got %v", err)
+				}
+				return
+			}
+			if err != nil || policy != tt.expected {
+				t.Errorf("Expected %+v, got %+v: %v", tt.expected, policy, err)
+			}
+		})
+	}
+}
+
+func TestPrivacyService(t *testing.T) {
+	db := setupTestDB()
+	admin := models.User{Email: "admin@example.com", PasswordHash: "x", Role: models.RoleAdmin}
+	db.Create(&admin)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: admin.ID, Role: models.RoleAdmin})
+	service := NewPrivacyService(db).WithContext(ctx)
+
+	team := models.Team{Name: "Dev Team"}
+	db.Create(&team)
+	alice := models.TeamMember{Name: "Alice", Email: "alice@example.com", Picture: "https://example.com/alice.png"}
+	bob := models.TeamMember{Name: "Bob", Email: "bob@example.com"}
+	if err := NewTeamMemberService(db).WithContext(ctx).CreateTeamMember(&alice); err != nil {
+		t.Fatalf("Failed to create team member: %v", err)
+	}
+	db.Create(&bob)
+	db.Create(&models.TeamAssignment{TeamID: team.ID, TeamMemberID: alice.ID})
+	subject := "oidc|alice"
+	account := models.User{Email: "alice@example.com", PasswordHash: "x", Role: models.RoleMember, TeamMemberID: &alice.ID, OIDCSubject: &subject}
+	db.Create(&account)
+	db.Create(&models.RefreshToken{UserID: account.ID, TokenHash: "hash", FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)})
+
+	feedback := NewFeedbackService(db)
+	given := models.Feedback{Content: "Bob explains things well", TargetType: "member", TargetID: bob.ID, AuthorID: &alice.ID}
+	received := models.Feedback{Content: "Alice ships fast", TargetType: "member", TargetID: alice.ID, AuthorID: &bob.ID}
+	for _, f := range []*models.Feedback{&given, &received} {
+		if err := feedback.CreateFeedback(f); err != nil {
+			t.Fatalf("Failed to create feedback: %v", err)
+		}
+	}
+
+	export, err := service.ExportTeamMember(alice.ID)
+	if err != nil {
+		t.Fatalf("Failed to export team member: %v", err)
+	}
+	if export.Member.Email != "alice@example.com" || export.Account == nil || export.Account.ID != account.ID {
+		t.Errorf("Expected the export to hold the member and account, got %+v", export)
+	}
+	if len(export.Teams) != 1 || export.Teams[0].TeamName != "Dev Team" {
+		t.Errorf("Expected the export to list the member's team, got %+v", export.Teams)
+	}
+	if len(export.FeedbackGiven) != 1 || export.FeedbackGiven[0].Content != "Bob explains things well" {
+		t.Errorf("Expected the feedback given, decrypted, got %+v", export.FeedbackGiven)
+	}
+	if len(export.FeedbackReceived) != 1 || export.FeedbackReceived[0].Content != "Alice ships fast" {
+		t.Errorf("Expected the feedback received, decrypted, got %+v", export.FeedbackReceived)
+	}
+	var exported int64
+	db.Model(&models.AuditEvent{}).Where("action = ? AND entity_id = ?", "team_member.exported", alice.ID).Count(&exported)
+	if exported != 1 {
+		t.Errorf("Expected the export to be audited, got %d events", exported)
+	}
+
+	if _, err := service.ExportTeamMember(999); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound for an unknown member, got %v", err)
+	}
+
+	record, err := service.EraseTeamMember(alice.ID, "Request by email", DefaultErasurePolicy())
+	if err != nil {
+		t.Fatalf("Failed to erase team member: %v", err)
+	}
+	if record.RequestedByUserID != admin.ID || record.PreviousHash != "" || len(record.Hash) != 64 {
+		t.Errorf("Expected the first record of the chain, requested by the admin, got %+v", record)
+	}
+
+	var erased models.TeamMember
+	db.First(&erased, alice.ID)
+	if erased.Name != ErasedMemberName || strings.Contains(erased.Email, "alice") || erased.Picture != "" || erased.ErasedAt == nil {
+		t.Errorf("Expected the member to be anonymized, got %+v", erased)
+	}
+	var erasedAccount models.User
+	db.First(&erasedAccount, account.ID)
+	if strings.Contains(erasedAccount.Email, "alice") || erasedAccount.PasswordHash != "" || erasedAccount.OIDCSubject != nil {
+		t.Errorf("Expected the account to be anonymized, got %+v", erasedAccount)
+	}
+	var sessions int64
+	db.Model(&models.RefreshToken{}).Where("user_id = ?", account.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("Expected the account's sessions to be revoked, got %d", sessions)
+	}
+
+	var all []models.Feedback
+	db.Order("id").Find(&all)
+	if len(all) != 2 {
+		t.Fatalf("Expected the feedback count to stay the same, got %d", len(all))
+	}
+	if all[0].Content != RedactedContent || all[0].AuthorID == nil || *all[0].AuthorID != alice.ID {
+		t.Errorf("Expected authored feedback to be redacted but kept, got %+v", all[0])
+	}
+	if all[1].Content != "Alice ships fast" {
+		t.Errorf("Expected received feedback to be kept, got %+v", all[1])
+	}
+
+	var events []models.AuditEvent
+	db.Where("entity_type = ? AND entity_id = ?", audit.EntityTeamMember, alice.ID).Find(&events)
+	for _, event := range events {
+		if strings.Contains(string(event.Before)+string(event.After)+string(event.Details), "alice") {
+			t.Errorf("Expected audit event %s to hold no personal data, got %+v", event.Action, event)
+		}
+	}
+	if len(events) != 3 || events[2].Action != "team_member.erased" {
+		t.Errorf("Expected the creation, export and erasure events, got %+v", events)
+	}
+
+	if _, err := service.EraseTeamMember(alice.ID, "Again", DefaultErasurePolicy()); !errors.Is(err, ErrAlreadyErased) {
+		t.Errorf("Expected ErrAlreadyErased, got %v", err)
+	}
+	second, err := service.EraseTeamMember(bob.ID, "Left the company", ErasurePolicy{AuthoredFeedback: ErasureKeep, ReceivedFeedback: ErasureRedact})
+	if err != nil {
+		t.Fatalf("Failed to erase team member: %v", err)
+	}
+	if second.PreviousHash != record.Hash {
+		t.Errorf("Expected the second record to follow the first, got %q", second.PreviousHash)
+	}
+	var redacted models.Feedback
+	db.First(&redacted, given.ID)
+	if redacted.Content != RedactedContent {
+		t.Errorf("Expected feedback received by Bob to stay redacted, got %q", redacted.Content)
+	}
+
+	log, err := service.ErasureLog()
+	if err != nil || !log.Valid || len(log.Records) != 2 {
+		t.Errorf("Expected a valid chain of 2 records, got %+v: %v", log, err)
+	}
+
+	db.Model(&models.ErasureRecord{}).Where("id = ?", record.ID).Update("reason", "Tampered")
+	log, err = service.ErasureLog()
+	if err != nil || log.Valid || log.BrokenAt == nil || *log.BrokenAt != record.ID {
+		t.Errorf("Expected tampering to break the chain at record %d, got %+v: %v", record.ID, log, err)
+	}
+}
+
+func TestEraseTeamMemberScrubsAuditLog(t *testing.T) {
+	db := setupTestDB()
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: 1, Role: models.RoleAdmin})
+	members := NewTeamMemberService(db).WithContext(ctx)
+	teams := NewTeamService(db).WithContext(ctx)
+	assignments := NewAssignmentService(db).WithContext(ctx)
+
+	alice := models.TeamMember{Name: "Alice", Email: "alice@example.com"}
+	duplicate := models.TeamMember{Name: "Alice Old", Email: "alice.old@example.com"}
+	bob := models.TeamMember{Name: "Bob", Email: "bob@example.com"}
+	for _, member := range []*models.TeamMember{&alice, &duplicate, &bob} {
+		if err := members.CreateTeamMember(member); err != nil {
+			t.Fatalf("Failed to create team member: %v", err)
+		}
+	}
+
+	// Deleted teams keep snapshots of their members, and merges the name and
+	// email of the merged member
+	for _, member := range []models.TeamMember{alice, duplicate} {
+		team := models.Team{Name: "Team of " + strconv.FormatUint(uint64(member.ID), 10)}
+		teams.CreateTeam(&team)
+		assignments.AssignMemberToTeam(team.ID, member.ID)
+		assignments.AssignMemberToTeam(team.ID, bob.ID)
+		if err := teams.DeleteTeam(team.ID); err != nil {
+			t.Fatalf("Failed to delete team: %v", err)
+		}
+	}
+	if _, err := members.MergeTeamMembers(alice.ID, duplicate.ID); err != nil {
+		t.Fatalf("Failed to merge team members: %v", err)
+	}
+
+	if _, err := NewPrivacyService(db).WithContext(ctx).EraseTeamMember(alice.ID, "Request by email", DefaultErasurePolicy()); err != nil {
+		t.Fatalf("Failed to erase team member: %v", err)
+	}
+
+	var events []models.AuditEvent
+	db.Order("id").Find(&events)
+	deletedTeams := 0
+	for _, event := range events {
+		content := strings.ToLower(string(event.Before) + string(event.After) + string(event.Details))
+		if strings.Contains(content, "alice") {
+			t.Errorf("Expected audit event %s to hold no personal data, got %s", event.Action, content)
+		}
+		if event.Action == "team.deleted" {
+			deletedTeams++
+			if !strings.Contains(content, strings.ToLower(ErasedMemberName)) || !strings.Contains(content, "bob@example.com") {
+				t.Errorf("Expected only the erased member to be anonymized in the team snapshot, got %s", content)
+			}
+		}
+	}
+	if deletedTeams != 2 {
+		t.Errorf("Expected the team deletions to stay in the log, got %d", deletedTeams)
+	}
+}
+
+func TestEraseTeamMemberScrubsIdempotentResponses(t *testing.T) {
+	db := setupTestDB()
+	alice := models.TeamMember{Name: "Alice", Email: "alice@example.com"}
+	bob := models.TeamMember{Name: "Bob", Email: "bob@example.com"}
+	db.Create(&alice)
+	db.Create(&bob)
+
+	authored := fmt.Sprintf(`{"id":1,"content":"Alice wrote this","target_type":"member","target_id":%d,"author_id":%d}`, bob.ID, alice.ID)
+	received := fmt.Sprintf(`{"id":2,"content":"About Alice","target_type":"member","target_id":%d,"author_id":%d}`, alice.ID, bob.ID)
+	responses := []string{
+		fmt.Sprintf(`{"id":%d,"name":"Alice","email":"alice@example.com","picture":"alice.jpg"}`, alice.ID),
+		authored,
+		received,
+		fmt.Sprintf(`{"id":%d,"name":"Bob","email":"bob@example.com"}`, bob.ID),
+	}
+	for i, body := range responses {
+		record := models.IdempotencyKey{
+			Key:          "key-" + strconv.Itoa(i),
+			Method:       "POST",
+			Path:         "/api/team-members",
+			Completed:    true,
+			StatusCode:   201,
+			ResponseBody: body,
+			ExpiresAt:    time.Now().Add(time.Hour),
+		}
+		if err := db.Create(&record).Error; err != nil {
+			t.Fatalf("Failed to store response: %v", err)
+		}
+	}
+
+	if _, err := NewPrivacyService(db).EraseTeamMember(alice.ID, "Request by email", DefaultErasurePolicy()); err != nil {
+		t.Fatalf("Failed to erase team member: %v", err)
+	}
+
+	var stored []models.IdempotencyKey
+	db.Order("id").Find(&stored)
+	if len(stored) != len(responses) {
+		t.Fatalf("Expected the responses to be kept for replays, got %d", len(stored))
+	}
+	for _, record := range stored {
+		if strings.Contains(record.ResponseBody, "alice") || strings.Contains(record.ResponseBody, "Alice wrote") {
+			t.Errorf("Expected stored response to hold no personal data, got %s", record.ResponseBody)
+		}
+	}
+	// The default policy keeps feedback about the erased member
+	if !strings.Contains(stored[2].ResponseBody, "About Alice") {
+		t.Errorf("Expected received feedback to be kept, got %s", stored[2].ResponseBody)
+	}
+	if stored[3].ResponseBody != responses[3] {
+		t.Errorf("Expected other responses to be unchanged, got %s", stored[3].ResponseBody)
+	}
+}
+
+func TestBusinessMetrics(t *testing.T) {
+	db := setupTestDB()
+	team := models.Team{Name: "Dev Team"}
+	db.Create(&team)
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	db.Create(&member)
+
+	feedback := metrics.FeedbackCreated.WithLabelValues("team")
+	added := metrics.AssignmentChanges.WithLabelValues(metrics.AssignmentAdded)
+	removed := metrics.AssignmentChanges.WithLabelValues(metrics.AssignmentRemoved)
+	feedbackBefore, addedBefore, removedBefore := feedback.Value(), added.Value(), removed.Value()
+
+	NewFeedbackService(db).CreateFeedback(&models.Feedback{Content: "Great sprint", TargetType: "team", TargetID: team.ID})
+	NewFeedbackService(db).CreateFeedback(&models.Feedback{Content: "Unknown team", TargetType: "team", TargetID: 999})
+	assignments := NewAssignmentService(db)
+	assignments.AssignMemberToTeam(team.ID, member.ID)
+	assignments.AssignMemberToTeam(team.ID, member.ID)
+	assignments.RemoveMemberFromTeam(team.ID, member.ID)
+	assignments.RemoveMemberFromTeam(team.ID, member.ID)
+
+	members := NewTeamMemberService(db)
+	inputs := []TeamMemberInput{{Name: "Jane Doe", Email: "jane@example.com", TeamIDs: []uint{team.ID}}}
+	members.PreviewBatchUpsertTeamMembers(inputs, BatchTransactional)
+	members.BatchUpsertTeamMembers(inputs, BatchTransactional)
+
+	tests := []struct {
+		name     string
+		counter  *metrics.Counter
+		before   float64
+		expected float64
+	}{
+		{"Feedback created", feedback, feedbackBefore, 1},
+		{"Assignments added, without previews", added, addedBefore, 2},
+		{"Assignments removed", removed, removedBefore, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.counter.Value() - tt.before; got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTracing(t *testing.T) {
+	db := setupTestDB()
+	if err := tracing.RegisterGorm(db); err != nil {
+		t.Fatalf("Failed to register query tracing: %v", err)
+	}
+	team := models.Team{Name: "Dev Team"}
+	db.Create(&team)
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	db.Create(&member)
+	NewAssignmentService(db).AssignMemberToTeam(team.ID, member.ID)
+
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx, request := tracing.Start(context.Background(), "GET /api/assignments")
+	if _, err := NewAssignmentService(db).WithContext(ctx).GetAllAssignments(); err != nil {
+		t.Fatalf("Failed to get assignments: %v", err)
+	}
+	request.End()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	service, ok := spans["AssignmentService.GetAllAssignments"]
+	if !ok || service.Parent.SpanID() != request.SpanContext().SpanID() {
+		t.Fatalf("Expected a service span under the request span, got %v", exporter.GetSpans())
+	}
+
+	tests := []struct {
+		name   string
+		span   string
+		parent string
+	}{
+		{"Team query", "gorm.query teams", "AssignmentService.GetAllAssignments"},
+		{"Preloaded assignments", "gorm.query team_assignments", "gorm.query teams"},
+		{"Preloaded members", "gorm.query team_members", "gorm.query teams"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span, ok := spans[tt.span]
+			if !ok {
+				t.Fatalf("Expected a %q span, got %v", tt.span, exporter.GetSpans())
+			}
+			if span.Parent.SpanID() != spans[tt.parent].SpanContext.SpanID() {
+				t.Errorf("Expected %q to be a child of %q", tt.span, tt.parent)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/metrics"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
+	"coaching-app-backend/validation"
+
+	"gorm.io/gorm"
+)
+
+// MaxBatchSize caps the number of members accepted in a single batch upsert
+const MaxBatchSize = 1000
+
+var ErrBatchAborted = errors.New("batch aborted, no changes were saved")
+
+// errPreviewRollback unwinds the transaction wrapping a batch preview
+var errPreviewRollback = errors.New("batch preview rollback")
+
+type BatchMode string
+
+const (
+	// BatchTransactional saves every item or none of them
+	BatchTransactional BatchMode = "transactional"
+	// BatchBestEffort saves each item on its own and reports failures per item
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+type BatchStatus string
+
+const (
+	BatchCreated   BatchStatus = "created"
+	BatchUpdated   BatchStatus = "updated"
+	BatchUnchanged BatchStatus = "unchanged"
+	BatchError     BatchStatus = "error"
+)
+
+// TeamMemberInput is one member in a batch upsert, matched to existing
+// members by email. Fields are validated like MemberInput.
+type TeamMemberInput struct {
+	Name    string `json:"name" normalize:"trim" validate:"required,max=255"`
+	Email   string `json:"email" normalize:"trim,lower" validate:"required,email,max=255"`
+	Picture string `json:"picture" normalize:"trim" validate:"omitempty,http_url,max=500"`
+	TeamIDs []uint `json:"team_ids"`
+}
+
+type BatchItemResult struct {
+	Index  int         `json:"index"`
+	Email  string      `json:"email"`
+	Status BatchStatus `json:"status"`
+	ID     uint        `json:"id,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	// assigned counts the teams the member was assigned to
+	assigned int
+}
+
+// BatchUpsertTeamMembers creates or updates members by email and assigns them
+// to the listed teams. In transactional mode the first failing item rolls back
+// the whole batch and ErrBatchAborted is returned alongside the results of the
+// items processed up to and including the failure.
+func (s *TeamMemberService) BatchUpsertTeamMembers(inputs []TeamMemberInput, mode BatchMode) ([]BatchItemResult, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.BatchUpsertTeamMembers")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	results, err := s.batchUpsertTeamMembers(inputs, mode)
+	if err == nil {
+		assigned := 0
+		for _, result := range results {
+			if result.Status != BatchError {
+				assigned += result.assigned
+			}
+		}
+		metrics.AssignmentChanges.WithLabelValues(metrics.AssignmentAdded).Add(float64(assigned))
+	}
+	return results, err
+}
+
+func (s *TeamMemberService) batchUpsertTeamMembers(inputs []TeamMemberInput, mode BatchMode) ([]BatchItemResult, error) {
+	if len(inputs) > MaxBatchSize {
+		return nil, fmt.Errorf("batch size %d exceeds maximum of %d", len(inputs), MaxBatchSize)
+	}
+
+	results := make([]BatchItemResult, len(inputs))
+
+	switch mode {
+	case BatchTransactional:
+		processed := len(inputs)
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			for i, input := range inputs {
+				results[i] = upsertTeamMember(tx, i, input)
+				if results[i].Status == BatchError {
+					processed = i + 1
+					return ErrBatchAborted
+				}
+			}
+			return nil
+		})
+		return results[:processed], err
+	case BatchBestEffort:
+		for i, input := range inputs {
+			s.db.Transaction(func(tx *gorm.DB) error {
+				results[i] = upsertTeamMember(tx, i, input)
+				if results[i].Status == BatchError {
+					return errors.New(results[i].Error)
+				}
+				return nil
+			})
+		}
+		return results, nil
+	default:
+		return nil, fmt.Errorf("unsupported batch mode %q", mode)
+	}
+}
+
+// PreviewBatchUpsertTeamMembers reports what BatchUpsertTeamMembers would do
+// without saving anything, by running the batch inside a transaction that is
+// always rolled back.
+func (s *TeamMemberService) PreviewBatchUpsertTeamMembers(inputs []TeamMemberInput, mode BatchMode) ([]BatchItemResult, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.PreviewBatchUpsertTeamMembers")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var results []BatchItemResult
+	var batchErr error
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		results, batchErr = NewTeamMemberService(tx).batchUpsertTeamMembers(inputs, mode)
+		return errPreviewRollback
+	})
+	if err != nil && !errors.Is(err, errPreviewRollback) {
+		return nil, err
+	}
+	return results, batchErr
+}
+
+func upsertTeamMember(tx *gorm.DB, index int, input TeamMemberInput) BatchItemResult {
+	validationErr := validation.Struct(&input)
+
+	result := BatchItemResult{Index: index, Email: input.Email}
+	fail := func(message string) BatchItemResult {
+		result.Status = BatchError
+		result.Error = message
+		return result
+	}
+
+	if validationErr != nil {
+		return fail(validationErr.Error())
+	}
+
+	// The input is normalized, so the email is compared as it is and the
+	// lookup uses the unique index on it
+	var member models.TeamMember
+	err := tx.Where("email = ?", input.Email).Limit(1).Find(&member).Error
+	if err != nil {
+		return fail("Failed to look up team member")
+	}
+
+	if member.ID == 0 {
+		member = models.TeamMember{Name: input.Name, Email: input.Email, Picture: input.Picture}
+		if err := tx.Create(&member).Error; err != nil {
+			return fail("Failed to create team member")
+		}
+		change := audit.Change{Action: audit.ActionCreated, EntityType: audit.EntityTeamMember, EntityID: member.ID, After: member}
+		if err := audit.Record(tx, change); err != nil {
+			return fail("Failed to record audit event")
+		}
+		result.Status = BatchCreated
+	} else {
+		result.Status = BatchUnchanged
+		updates := map[string]interface{}{}
+		if member.Name != input.Name {
+			updates["name"] = input.Name
+		}
+		// Matched by the database collation, case-insensitive in MySQL, so
+		// this only normalizes older records
+		if member.Email != input.Email {
+			updates["email"] = input.Email
+		}
+		// An empty picture means "not provided" rather than "clear it"
+		if input.Picture != "" && member.Picture != input.Picture {
+			updates["picture"] = input.Picture
+		}
+		if len(updates) > 0 {
+			before := member
+			if err := tx.Model(&member).Updates(updates).Error; err != nil {
+				return fail("Failed to update team member")
+			}
+			change := audit.Change{Action: audit.ActionUpdated, EntityType: audit.EntityTeamMember, EntityID: member.ID, Before: before, After: member}
+			if err := audit.Record(tx, change); err != nil {
+				return fail("Failed to record audit event")
+			}
+			result.Status = BatchUpdated
+		}
+	}
+	result.ID = member.ID
+
+	for _, teamID := range input.TeamIDs {
+		var team models.Team
+		if err := tx.First(&team, teamID).Error; err != nil {
+			return fail(fmt.Sprintf("Team %d not found", teamID))
+		}
+
+		assigned, err := isAssigned(tx, teamID, member.ID)
+		if err != nil {
+			return fail("Failed to look up team assignment")
+		}
+		if assigned {
+			continue
+		}
+
+		if err := assignMember(tx, &team, &member); err != nil {
+			return fail(fmt.Sprintf("Failed to assign member to team %d", teamID))
+		}
+		result.assigned++
+		if result.Status == BatchUnchanged {
+			result.Status = BatchUpdated
+		}
+	}
+
+	return result
+}
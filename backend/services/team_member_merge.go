@@ -0,0 +1,317 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"coaching-app-backend/audit"
+	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMergeSelf = errors.New("a team member cannot be merged into itself")
+	// ErrMergeLinkedAccounts is returned for merges of two members that both
+	// have an account, as a member can only be linked to one
+	ErrMergeLinkedAccounts = errors.New("both team members are linked to an account")
+)
+
+// maxNameDistance is the largest edit distance between two normalized names
+// that are still reported as similar. Shorter names must match exactly.
+const (
+	maxNameDistance    = 2
+	minFuzzyNameLength = 5
+)
+
+const (
+	DuplicateSameEmail   = "same_email"
+	DuplicateSimilarName = "similar_name"
+)
+
+// DuplicateCandidate is a pair of members that probably describe the same
+// person, with the reasons they were matched.
+type DuplicateCandidate struct {
+	Members []models.TeamMember `json:"members"`
+	Reasons []string            `json:"reasons"`
+}
+
+// MergeResult describes what a merge moved onto the target member
+type MergeResult struct {
+	Member           *models.TeamMember `json:"member"`
+	MergedID         uint               `json:"merged_id"`
+	MovedAssignments int                `json:"moved_assignments"`
+	MovedFeedback    int64              `json:"moved_feedback"`
+	MovedAuthorship  int64              `json:"moved_authorship"`
+	MovedAccount     bool               `json:"moved_account"`
+}
+
+// FindDuplicateCandidates reports the pairs of members sharing an email
+// address, ignoring case, or having similar names. Names are compared after
+// lowercasing, dropping punctuation and sorting the words, so "Doe, John"
+// matches "john doe", and small typos are tolerated. Only members sharing an
+// email address or a word of their names are compared, so the search does not
+// grow with the square of the organization's size.
+func (s *TeamMemberService) FindDuplicateCandidates() ([]DuplicateCandidate, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.FindDuplicateCandidates")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var members []models.TeamMember
+	if err := s.db.Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	emails := make([]string, len(members))
+	names := make([]string, len(members))
+	for i, member := range members {
+		emails[i] = strings.ToLower(strings.TrimSpace(member.Email))
+		names[i] = normalizeName(member.Name)
+	}
+
+	candidates := []DuplicateCandidate{}
+	for _, pair := range candidatePairs(emails, names) {
+		i, j := pair[0], pair[1]
+		var reasons []string
+		if emails[i] == emails[j] {
+			reasons = append(reasons, DuplicateSameEmail)
+		}
+		if similarNames(names[i], names[j]) {
+			reasons = append(reasons, DuplicateSimilarName)
+		}
+		if len(reasons) > 0 {
+			candidates = append(candidates, DuplicateCandidate{
+				Members: []models.TeamMember{members[i], members[j]},
+				Reasons: reasons,
+			})
+		}
+	}
+	return candidates, nil
+}
+
+// candidatePairs returns the pairs of indexes of members worth comparing,
+// those with the same normalized email or a word of their normalized names in
+// common, in order
+func candidatePairs(emails, names []string) [][2]int {
+	buckets := map[string][]int{}
+	for i := range emails {
+		buckets["email:"+emails[i]] = append(buckets["email:"+emails[i]], i)
+		words := strings.Fields(names[i])
+		for k, word := range words {
+			// Words are sorted, so repeated words are adjacent
+			if k == 0 || word != words[k-1] {
+				buckets["name:"+word] = append(buckets["name:"+word], i)
+			}
+		}
+	}
+
+	seen := map[[2]int]bool{}
+	pairs := [][2]int{}
+	for _, bucket := range buckets {
+		for a := range bucket {
+			for b := a + 1; b < len(bucket); b++ {
+				pair := [2]int{bucket[a], bucket[b]}
+				if !seen[pair] {
+					seen[pair] = true
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a][0] != pairs[b][0] {
+			return pairs[a][0] < pairs[b][0]
+		}
+		return pairs[a][1] < pairs[b][1]
+	})
+	return pairs
+}
+
+// MergeTeamMembers folds the source member into the target in a single
+// transaction: team assignments move to the target unless it already has
+// them, feedback about or written by the source is re-pointed, the account
+// linked to the source is linked to the target, a missing picture is taken
+// from the source, the source is deleted and an audit event is recorded.
+// Members that both have an account cannot be merged.
+func (s *TeamMemberService) MergeTeamMembers(targetID, sourceID uint) (*MergeResult, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.MergeTeamMembers")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	if targetID == sourceID {
+		return nil, ErrMergeSelf
+	}
+
+	result := &MergeResult{MergedID: sourceID}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var target, source models.TeamMember
+		if err := tx.First(&target, targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&source, sourceID).Error; err != nil {
+			return err
+		}
+		before := target
+
+		var linked []uint
+		err := tx.Model(&models.User{}).Where("team_member_id IN ?", []uint{targetID, sourceID}).Pluck("team_member_id", &linked).Error
+		if err != nil {
+			return err
+		}
+		if len(linked) > 1 {
+			return ErrMergeLinkedAccounts
+		}
+
+		var sourceTeams, targetTeams []uint
+		if err := tx.Model(&models.TeamAssignment{}).Where("team_member_id = ?", sourceID).Pluck("team_id", &sourceTeams).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TeamAssignment{}).Where("team_member_id = ?", targetID).Pluck("team_id", &targetTeams).Error; err != nil {
+			return err
+		}
+
+		existing := make(map[uint]bool, len(targetTeams))
+		for _, teamID := range targetTeams {
+			existing[teamID] = true
+		}
+		for _, teamID := range sourceTeams {
+			if existing[teamID] {
+				continue
+			}
+			if err := tx.Create(&models.TeamAssignment{TeamID: teamID, TeamMemberID: targetID}).Error; err != nil {
+				return err
+			}
+			result.MovedAssignments++
+		}
+		if err := tx.Where("team_member_id = ?", sourceID).Delete(&models.TeamAssignment{}).Error; err != nil {
+			return err
+		}
+
+		moved := tx.Model(&models.Feedback{}).
+			Where("target_type = ? AND target_id = ?", "member", sourceID).
+			Update("target_id", targetID)
+		if moved.Error != nil {
+			return moved.Error
+		}
+		result.MovedFeedback = moved.RowsAffected
+
+		authored := tx.Model(&models.Feedback{}).Where("author_id = ?", sourceID).Update("author_id", targetID)
+		if authored.Error != nil {
+			return authored.Error
+		}
+		result.MovedAuthorship = authored.RowsAffected
+
+		account := tx.Model(&models.User{}).Where("team_member_id = ?", sourceID).Update("team_member_id", targetID)
+		if account.Error != nil {
+			return account.Error
+		}
+		result.MovedAccount = account.RowsAffected > 0
+
+		if target.Picture == "" && source.Picture != "" {
+			if err := tx.Model(&target).Update("picture", source.Picture).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&source).Error; err != nil {
+			return err
+		}
+		deleted := audit.Change{
+			Action:     audit.ActionDeleted,
+			EntityType: audit.EntityTeamMember,
+			EntityID:   source.ID,
+			Before:     source,
+			Details:    map[string]uint{"merged_into": target.ID},
+		}
+		if err := audit.Record(tx, deleted); err != nil {
+			return err
+		}
+
+		if err := tx.Preload("Teams").First(&target, targetID).Error; err != nil {
+			return err
+		}
+		merged := audit.Change{
+			Action:     audit.ActionMerged,
+			EntityType: audit.EntityTeamMember,
+			EntityID:   target.ID,
+			Before:     before,
+			After:      target,
+			Details: map[string]interface{}{
+				"source_id":         source.ID,
+				"source_name":       source.Name,
+				"source_email":      source.Email,
+				"moved_assignments": result.MovedAssignments,
+				"moved_feedback":    result.MovedFeedback,
+				"moved_authorship":  result.MovedAuthorship,
+				"moved_account":     result.MovedAccount,
+			},
+		}
+		if err := audit.Record(tx, merged); err != nil {
+			return err
+		}
+		result.Member = &target
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// normalizeName lowercases a name, drops punctuation and sorts its words
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+func similarNames(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	if len([]rune(a)) < minFuzzyNameLength || len([]rune(b)) < minFuzzyNameLength {
+		return false
+	}
+	return editDistance(a, b) <= maxNameDistance
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+	return result
+}
@@ -1,7 +1,12 @@
 package services
 
 import (
+	"context"
+	"errors"
+
+	"coaching-app-backend/audit"
 	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
 
 	"gorm.io/gorm"
 )
@@ -10,24 +15,96 @@ type TeamMemberService struct {
 	db *gorm.DB
 }
 
+var ErrEmailTaken = errors.New("email is already in use")
+
 func NewTeamMemberService(db *gorm.DB) *TeamMemberService {
 	return &TeamMemberService{db: db}
 }
 
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events
+func (s *TeamMemberService) WithContext(ctx context.Context) *TeamMemberService {
+	return &TeamMemberService{db: s.db.WithContext(ctx)}
+}
+
 func (s *TeamMemberService) CreateTeamMember(member *models.TeamMember) error {
-	return s.db.Create(member).Error
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.CreateTeamMember")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.TeamMember{}).Where("email = ?", member.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEmailTaken
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{Action: audit.ActionCreated, EntityType: audit.EntityTeamMember, EntityID: member.ID, After: member})
+	})
 }
 
 func (s *TeamMemberService) GetAllTeamMembers() ([]models.TeamMember, error) {
-	var members []models.TeamMember
-	err := s.db.Find(&members).Error
-	return members, err
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.GetAllTeamMembers")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.FindTeamMembers(QueryOptions{})
 }
 
 func (s *TeamMemberService) GetTeamMemberByID(id uint) (*models.TeamMember, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.GetTeamMemberByID")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.FindTeamMember(id, QueryOptions{})
+}
+
+// FindTeamMembers lists members, loading only the relations and columns requested in opts
+func (s *TeamMemberService) FindTeamMembers(opts QueryOptions) ([]models.TeamMember, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.FindTeamMembers")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	if err := opts.validate(memberIncludes, memberFields); err != nil {
+		return nil, err
+	}
+
+	var members []models.TeamMember
+	if err := opts.scope(s.db, memberPreloads).Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	refs := make([]*models.TeamMember, len(members))
+	for i := range members {
+		refs[i] = &members[i]
+	}
+	if err := loadMemberIncludes(s.db, refs, opts); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// FindTeamMember fetches a single member, loading only the relations and columns requested in opts
+func (s *TeamMemberService) FindTeamMember(id uint, opts QueryOptions) (*models.TeamMember, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamMemberService.FindTeamMember")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	if err := opts.validate(memberIncludes, memberFields); err != nil {
+		return nil, err
+	}
+
 	var member models.TeamMember
-	err := s.db.First(&member, id).Error
-	if err != nil {
+	if err := opts.scope(s.db, memberPreloads).First(&member, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := loadMemberIncludes(s.db, []*models.TeamMember{&member}, opts); err != nil {
 		return nil, err
 	}
 	return &member, nil
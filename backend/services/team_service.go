@@ -1,11 +1,21 @@
 package services
 
 import (
+	"context"
+	"errors"
+
+	"coaching-app-backend/audit"
 	"coaching-app-backend/models"
+	"coaching-app-backend/tracing"
 
 	"gorm.io/gorm"
 )
 
+var (
+	ErrNotCoach        = errors.New("user does not have the coach role")
+	ErrAlreadyCoaching = errors.New("user already coaches this team")
+)
+
 type TeamService struct {
 	db *gorm.DB
 }
@@ -14,26 +24,93 @@ func NewTeamService(db *gorm.DB) *TeamService {
 	return &TeamService{db: db}
 }
 
+// WithContext returns a copy of the service that runs its queries with ctx,
+// which carries the organization to act for and the actor recorded in audit
+// events
+func (s *TeamService) WithContext(ctx context.Context) *TeamService {
+	return &TeamService{db: s.db.WithContext(ctx)}
+}
+
 func (s *TeamService) CreateTeam(team *models.Team) error {
-	return s.db.Create(team).Error
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.CreateTeam")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(team).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{Action: audit.ActionCreated, EntityType: audit.EntityTeam, EntityID: team.ID, After: team})
+	})
 }
 
 func (s *TeamService) GetAllTeams() ([]models.Team, error) {
-	var teams []models.Team
-	err := s.db.Find(&teams).Error
-	return teams, err
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.GetAllTeams")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.FindTeams(QueryOptions{})
 }
 
 func (s *TeamService) GetTeamByID(id uint) (*models.Team, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.GetTeamByID")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.FindTeam(id, QueryOptions{Include: []string{"members"}})
+}
+
+// FindTeams lists teams, loading only the relations and columns requested in opts
+func (s *TeamService) FindTeams(opts QueryOptions) ([]models.Team, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.FindTeams")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	if err := opts.validate(teamIncludes, teamFields); err != nil {
+		return nil, err
+	}
+
+	var teams []models.Team
+	if err := opts.scope(s.db, teamPreloads).Find(&teams).Error; err != nil {
+		return nil, err
+	}
+
+	refs := make([]*models.Team, len(teams))
+	for i := range teams {
+		refs[i] = &teams[i]
+	}
+	if err := loadTeamIncludes(s.db, refs, opts, ""); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// FindTeam fetches a single team, loading only the relations and columns requested in opts
+func (s *TeamService) FindTeam(id uint, opts QueryOptions) (*models.Team, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.FindTeam")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	if err := opts.validate(teamIncludes, teamFields); err != nil {
+		return nil, err
+	}
+
 	var team models.Team
-	err := s.db.Preload("Members").First(&team, id).Error
-	if err != nil {
+	if err := opts.scope(s.db, teamPreloads).First(&team, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := loadTeamIncludes(s.db, []*models.Team{&team}, opts, ""); err != nil {
 		return nil, err
 	}
 	return &team, nil
 }
 
 func (s *TeamService) GetTeamMembers(teamID uint) ([]models.TeamMember, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.GetTeamMembers")
+	defer span.End()
+	s = s.WithContext(ctx)
+
 	var team models.Team
 	err := s.db.Preload("Members").First(&team, teamID).Error
 	if err != nil {
@@ -43,26 +120,136 @@ func (s *TeamService) GetTeamMembers(teamID uint) ([]models.TeamMember, error) {
 }
 
 func (s *TeamService) RemoveMemberFromTeam(teamID, memberID uint) error {
-	// Remove the association between team and member
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.RemoveMemberFromTeam")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return removeMemberFromTeam(s.db, teamID, memberID)
+}
+
+// DeleteTeam deletes a team with its assignments and coaches. Deleting a team
+// that does not exist is a no-op.
+func (s *TeamService) DeleteTeam(teamID uint) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.DeleteTeam")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		err := tx.Preload("Members").First(&team, teamID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var coachIDs []uint
+		if err := tx.Model(&models.TeamCoach{}).Where("team_id = ?", teamID).Order("user_id").Pluck("user_id", &coachIDs).Error; err != nil {
+			return err
+		}
+
+		// First remove all team assignments and coaches
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamAssignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamCoach{}).Error; err != nil {
+			return err
+		}
+
+		// Then delete the team
+		if err := tx.Delete(&models.Team{}, teamID).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{
+			Action:     audit.ActionDeleted,
+			EntityType: audit.EntityTeam,
+			EntityID:   teamID,
+			Before:     team,
+			Details:    map[string]interface{}{"coach_ids": coachIDs},
+		})
+	})
+}
+
+// GetCoaches lists the users coaching a team
+func (s *TeamService) GetCoaches(teamID uint) ([]models.User, error) {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.GetCoaches")
+	defer span.End()
+	s = s.WithContext(ctx)
+
 	var team models.Team
 	if err := s.db.First(&team, teamID).Error; err != nil {
-		return err
+		return nil, err
 	}
 
-	var member models.TeamMember
-	if err := s.db.First(&member, memberID).Error; err != nil {
+	var coaches []models.User
+	err := s.db.Joins("JOIN team_coaches ON team_coaches.user_id = users.id").
+		Where("team_coaches.team_id = ?", teamID).
+		Order("users.id").
+		Find(&coaches).Error
+	return coaches, err
+}
+
+// AddCoach assigns a user with the coach role to a team
+func (s *TeamService) AddCoach(teamID, userID uint) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.AddCoach")
+	defer span.End()
+	s = s.WithContext(ctx)
+
+	var team models.Team
+	if err := s.db.First(&team, teamID).Error; err != nil {
 		return err
 	}
 
-	return s.db.Model(&team).Association("Members").Delete(&member)
-}
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.Role != models.RoleCoach {
+		return ErrNotCoach
+	}
 
-func (s *TeamService) DeleteTeam(teamID uint) error {
-	// First remove all team assignments
-	if err := s.db.Where("team_id = ?", teamID).Delete(&models.TeamAssignment{}).Error; err != nil {
+	var count int64
+	if err := s.db.Model(&models.TeamCoach{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&count).Error; err != nil {
 		return err
 	}
+	if count > 0 {
+		return ErrAlreadyCoaching
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.TeamCoach{TeamID: teamID, UserID: userID}).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.Change{
+			Action:     audit.ActionCoachAdded,
+			EntityType: audit.EntityTeam,
+			EntityID:   teamID,
+			Details:    map[string]uint{"user_id": userID},
+		})
+	})
+}
+
+func (s *TeamService) RemoveCoach(teamID, userID uint) error {
+	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.RemoveCoach")
+	defer span.End()
+	s = s.WithContext(ctx)
 
-	// Then delete the team
-	return s.db.Delete(&models.Team{}, teamID).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// TeamCoach has no organization of its own, so the team is looked
+		// up first to keep other organizations' coaches out of reach
+		var team models.Team
+		if err := tx.First(&team, teamID).Error; err != nil {
+			return err
+		}
+		removed := tx.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamCoach{})
+		if removed.Error != nil || removed.RowsAffected == 0 {
+			return removed.Error
+		}
+		return audit.Record(tx, audit.Change{
+			Action:     audit.ActionCoachRemoved,
+			EntityType: audit.EntityTeam,
+			EntityID:   teamID,
+			Details:    map[string]uint{"user_id": userID},
+		})
+	})
 }
@@ -0,0 +1,171 @@
+// Package tenant keeps the data of organizations sharing a deployment apart.
+//
+// Requests carry the organization they act for in their context. Register
+// installs GORM callbacks that scope every query, update and delete of a
+// model with an OrganizationID field to the organization of the statement's
+// context, and stamp new records with it. Statements whose context has no
+// organization, such as those made at startup, are not scoped, and the
+// records they create belong to the default organization.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"coaching-app-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrCrossTenant is returned for writes that would place a record in, or move
+// it to, an organization other than the one of the statement's context.
+var ErrCrossTenant = errors.New("record belongs to another organization")
+
+var ErrUnknownOrganization = errors.New("unknown organization")
+
+const (
+	field  = "OrganizationID"
+	column = "organization_id"
+	// scopedSetting marks statements already scoped, as a statement reused
+	// for a count and a find runs the callbacks twice. It holds the statement
+	// itself because preloads copy the settings into statements of their own.
+	scopedSetting = "tenant:scoped"
+)
+
+type organizationKey struct{}
+
+// WithOrganization returns a copy of ctx acting for the organization
+func WithOrganization(ctx context.Context, organizationID uint) context.Context {
+	return context.WithValue(ctx, organizationKey{}, organizationID)
+}
+
+// WithoutOrganization returns a copy of ctx acting for no organization, so its
+// statements are not scoped. It is meant for lookups that find out which
+// organization to act for.
+func WithoutOrganization(ctx context.Context) context.Context {
+	return context.WithValue(ctx, organizationKey{}, uint(0))
+}
+
+// FromContext returns the organization ctx acts for, if any
+func FromContext(ctx context.Context) (uint, bool) {
+	organizationID, ok := ctx.Value(organizationKey{}).(uint)
+	return organizationID, ok && organizationID != 0
+}
+
+// Register installs the tenant callbacks on db
+func Register(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("tenant:create", assign); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:query", scope); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenant:row", scope); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:update", guardUpdate); err != nil {
+		return err
+	}
+	return callbacks.Delete().Before("gorm:delete").Register("tenant:delete", scope)
+}
+
+// organizationField returns the OrganizationID field of the statement's model
+// and the organization of its context, or false if the statement is not
+// scoped.
+func organizationField(db *gorm.DB) (*schema.Field, uint, bool) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Context == nil {
+		return nil, 0, false
+	}
+	organizationID, ok := FromContext(db.Statement.Context)
+	if !ok {
+		return nil, 0, false
+	}
+	f := db.Statement.Schema.LookUpField(field)
+	if f == nil {
+		return nil, 0, false
+	}
+	return f, organizationID, true
+}
+
+func scope(db *gorm.DB) {
+	_, organizationID, ok := organizationField(db)
+	if !ok {
+		return
+	}
+	if scoped, _ := db.Statement.Settings.Load(scopedSetting); scoped == db.Statement {
+		return
+	}
+	db.Statement.Settings.Store(scopedSetting, db.Statement)
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: organizationID},
+	}})
+}
+
+func assign(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	f := db.Statement.Schema.LookUpField(field)
+	if f == nil {
+		return
+	}
+	ctx := db.Statement.Context
+	organizationID, scoped := FromContext(ctx)
+	if !scoped {
+		organizationID = models.DefaultOrganizationID
+	}
+	if onConflict, ok := db.Statement.Clauses["ON CONFLICT"].Expression.(clause.OnConflict); ok && scoped && (onConflict.UpdateAll || len(onConflict.DoUpdates) > 0) {
+		// An upsert could overwrite a row of another organization
+		db.AddError(ErrCrossTenant)
+		return
+	}
+
+	stamp := func(record reflect.Value) {
+		switch value, zero := f.ValueOf(ctx, record); {
+		case zero:
+			db.AddError(f.Set(ctx, record, organizationID))
+		case scoped && value != organizationID:
+			db.AddError(ErrCrossTenant)
+		}
+	}
+
+	switch record := db.Statement.ReflectValue; record.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < record.Len(); i++ {
+			stamp(reflect.Indirect(record.Index(i)))
+		}
+	case reflect.Struct:
+		stamp(record)
+	}
+}
+
+// guardUpdate scopes updates and rejects those that change a record's
+// organization
+func guardUpdate(db *gorm.DB) {
+	f, organizationID, ok := organizationField(db)
+	if !ok {
+		return
+	}
+
+	switch dest := db.Statement.Dest.(type) {
+	case map[string]interface{}:
+		if _, ok := dest[column]; ok {
+			db.AddError(ErrCrossTenant)
+		}
+		if _, ok := dest[field]; ok {
+			db.AddError(ErrCrossTenant)
+		}
+	default:
+		record := reflect.Indirect(reflect.ValueOf(dest))
+		if record.Kind() == reflect.Struct && record.Type() == db.Statement.Schema.ModelType {
+			if value, zero := f.ValueOf(db.Statement.Context, record); !zero && value != organizationID {
+				db.AddError(ErrCrossTenant)
+			}
+		}
+	}
+	scope(db)
+}
@@ -0,0 +1,119 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"coaching-app-backend/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB() *gorm.DB {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	Register(db)
+	db.AutoMigrate(&models.Organization{}, &models.TeamMember{}, &models.Team{}, &models.TeamAssignment{})
+	return db
+}
+
+func TestFromContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected uint
+		ok       bool
+	}{
+		{"No organization", context.Background(), 0, false},
+		{"Zero organization", WithOrganization(context.Background(), 0), 0, false},
+		{"Organization", WithOrganization(context.Background(), 2), 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			organizationID, ok := FromContext(tt.ctx)
+			if organizationID != tt.expected || ok != tt.ok {
+				t.Errorf("Expected %d %v, got %d %v", tt.expected, tt.ok, organizationID, ok)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	db := setupTestDB()
+	other := db.WithContext(WithOrganization(context.Background(), 2))
+
+	unscoped := models.Team{Name: "Default"}
+	db.Create(&unscoped)
+	if unscoped.OrganizationID != models.DefaultOrganizationID {
+		t.Errorf("Expected unscoped records to belong to the default organization, got %d", unscoped.OrganizationID)
+	}
+
+	scoped := []models.Team{{Name: "First"}, {Name: "Second"}}
+	if err := other.Create(&scoped).Error; err != nil {
+		t.Fatalf("Failed to create teams: %v", err)
+	}
+	for _, team := range scoped {
+		if team.OrganizationID != 2 {
+			t.Errorf("Expected team %q to belong to organization 2, got %d", team.Name, team.OrganizationID)
+		}
+	}
+
+	if err := other.Create(&models.Team{Name: "Smuggled", OrganizationID: 1}).Error; !errors.Is(err, ErrCrossTenant) {
+		t.Errorf("Expected ErrCrossTenant creating a record for another organization, got %v", err)
+	}
+	unscoped.Name = "Overwritten"
+	if err := other.Save(&unscoped).Error; !errors.Is(err, ErrCrossTenant) {
+		t.Errorf("Expected ErrCrossTenant saving another organization's record, got %v", err)
+	}
+}
+
+func TestScope(t *testing.T) {
+	db := setupTestDB()
+	mine := db.WithContext(WithOrganization(context.Background(), 1))
+	theirs := db.WithContext(WithOrganization(context.Background(), 2))
+
+	team := models.Team{Name: "Dev Team"}
+	member := models.TeamMember{Name: "John Doe", Email: "john@example.com"}
+	mine.Create(&team)
+	mine.Create(&member)
+	mine.Model(&team).Association("Members").Append(&member)
+	// A member of another organization wrongly assigned to the team
+	intruder := models.TeamMember{Name: "Jane Doe", Email: "jane@example.com"}
+	theirs.Create(&intruder)
+	db.Create(&models.TeamAssignment{TeamID: team.ID, TeamMemberID: intruder.ID})
+
+	var count int64
+	theirs.Model(&models.Team{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no teams of another organization to be counted, got %d", count)
+	}
+	if err := theirs.First(&models.Team{}, team.ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound, got %v", err)
+	}
+
+	var teams []models.Team
+	mine.Preload("Members").Find(&teams)
+	if len(teams) != 1 || len(teams[0].Members) != 1 || teams[0].Members[0].ID != member.ID {
+		t.Errorf("Expected preloads to be scoped too, got %+v", teams)
+	}
+
+	result := theirs.Model(&models.Team{}).Where("id = ?", team.ID).Update("name", "Renamed")
+	if result.Error != nil || result.RowsAffected != 0 {
+		t.Errorf("Expected updates of another organization's record to match nothing, got %d: %v", result.RowsAffected, result.Error)
+	}
+	if err := mine.Model(&team).Update("organization_id", 2).Error; !errors.Is(err, ErrCrossTenant) {
+		t.Errorf("Expected ErrCrossTenant moving a record to another organization, got %v", err)
+	}
+	result = theirs.Delete(&models.Team{}, team.ID)
+	if result.Error != nil || result.RowsAffected != 0 {
+		t.Errorf("Expected deletes of another organization's record to match nothing, got %d: %v", result.RowsAffected, result.Error)
+	}
+
+	var reloaded models.Team
+	db.First(&reloaded, team.ID)
+	if reloaded.Name != "Dev Team" || reloaded.OrganizationID != 1 {
+		t.Errorf("Expected the team to be untouched, got %+v", reloaded)
+	}
+}
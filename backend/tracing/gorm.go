@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	spanSetting    = "tracing:span"
+	contextSetting = "tracing:context"
+)
+
+// rowsAffected is the number of rows a statement returned or changed
+var rowsAffected = attribute.Key("db.rows_affected")
+
+// RegisterGorm installs GORM callbacks recording a span for every statement
+// of db, a child of the span of the statement's context. Spans carry the SQL
+// with its placeholders, never the values bound to them. Statements run while
+// one is executing, such as preloads, are nested under it.
+func RegisterGorm(db *gorm.DB) error {
+	callbacks := db.Callback()
+	processors := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("*").Register, callbacks.Create().After("*").Register},
+		{"query", callbacks.Query().Before("*").Register, callbacks.Query().After("*").Register},
+		{"update", callbacks.Update().Before("*").Register, callbacks.Update().After("*").Register},
+		{"delete", callbacks.Delete().Before("*").Register, callbacks.Delete().After("*").Register},
+		{"row", callbacks.Row().Before("*").Register, callbacks.Row().After("*").Register},
+		{"raw", callbacks.Raw().Before("*").Register, callbacks.Raw().After("*").Register},
+	}
+	for _, p := range processors {
+		operation := p.operation
+		if err := p.before("tracing:before_"+operation, func(db *gorm.DB) { startSpan(db, operation) }); err != nil {
+			return err
+		}
+		if err := p.after("tracing:after_"+operation, func(db *gorm.DB) { endSpan(db, operation) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startSpan(db *gorm.DB, operation string) {
+	parent := db.Statement.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, span := Tracer().Start(parent, spanName(db, operation),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String(db.Dialector.Name()),
+			semconv.DBOperationName(operation),
+		),
+	)
+	db.InstanceSet(spanSetting, span)
+	db.InstanceSet(contextSetting, parent)
+	db.Statement.Context = ctx
+}
+
+func endSpan(db *gorm.DB, operation string) {
+	value, ok := db.InstanceGet(spanSetting)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	// The statement may be reused by the caller, whose context it keeps
+	if parent, ok := db.InstanceGet(contextSetting); ok {
+		db.Statement.Context = parent.(context.Context)
+	}
+
+	// Tables of raw statements are only known once they are built
+	if db.Statement.Table != "" {
+		span.SetName(spanName(db, operation))
+		span.SetAttributes(semconv.DBCollectionName(db.Statement.Table))
+	}
+	if sql := db.Statement.SQL.String(); sql != "" {
+		span.SetAttributes(semconv.DBQueryText(sql))
+	}
+	span.SetAttributes(rowsAffected.Int64(db.RowsAffected))
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+	span.End()
+}
+
+// spanName names the span of a statement by its operation and table, such as
+// "gorm.query teams"
+func spanName(db *gorm.DB, operation string) string {
+	if db.Statement.Table == "" {
+		return "gorm." + operation
+	}
+	return "gorm." + operation + " " + db.Statement.Table
+}
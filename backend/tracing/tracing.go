@@ -0,0 +1,156 @@
+// Package tracing records OpenTelemetry spans for requests, service calls and
+// database statements, so the time taken by a request can be broken down.
+//
+// Setup installs the tracer provider and the W3C trace context propagator;
+// until it is called, spans are not recorded. Services start a span for each
+// of their methods and run their queries with its context, which nests the
+// spans of the statements under it:
+//
+//	ctx, span := tracing.Start(s.db.Statement.Context, "TeamService.GetTeamByID")
+//	defer span.End()
+//	s = s.WithContext(ctx)
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the application's spans
+const instrumentationName = "coaching-app-backend"
+
+// Exporters
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// OTLP protocols
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// Config selects where spans are exported
+type Config struct {
+	// Exporter is none, otlp, stdout or file
+	Exporter string
+	// Protocol is the OTLP protocol, grpc or http/protobuf. The endpoint and
+	// headers are read by the exporter from the standard OTEL_EXPORTER_OTLP_*
+	// variables.
+	Protocol string
+	// File is the path spans are appended to by the file exporter
+	File string
+	// ServiceName names the service in exported spans, unless
+	// OTEL_SERVICE_NAME is set
+	ServiceName string
+}
+
+// Setup installs a tracer provider exporting spans as configured and returns a
+// function flushing and stopping it. Spans written to stdout or a file are
+// written as they end; OTLP exports them in batches.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var option sdktrace.TracerProviderOption
+	var closer io.Closer
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err := newOTLPExporter(ctx, config.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		option = sdktrace.WithBatcher(exporter)
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		option = sdktrace.WithSyncer(exporter)
+	case ExporterFile:
+		if config.File == "" {
+			return nil, fmt.Errorf("the file exporter needs a file to write to")
+		}
+		file, err := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		option = sdktrace.WithSyncer(exporter)
+		closer = file
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q, expected none, otlp, stdout or file", config.Exporter)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = instrumentationName
+	}
+	// Attributes from the environment take precedence over the defaults
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(option, sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+func newOTLPExporter(ctx context.Context, protocol string) (sdktrace.SpanExporter, error) {
+	switch strings.TrimSpace(protocol) {
+	case "", ProtocolHTTP:
+		return otlptracehttp.New(ctx)
+	case ProtocolGRPC:
+		return otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("invalid OTLP protocol %q, expected grpc or http/protobuf", protocol)
+	}
+}
+
+// Tracer returns the tracer of the application's spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span named name as a child of the span of ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// record installs a tracer provider keeping spans in memory for the test
+func record(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+func attributeOf(span tracetest.SpanStub, key string) string {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"Disabled", Config{}, false},
+		{"None", Config{Exporter: ExporterNone}, false},
+		{"Unknown exporter", Config{Exporter: "jaeger"}, true},
+		{"File without path", Config{Exporter: ExporterFile}, true},
+		{"Unknown protocol", Config{Exporter: ExporterOTLP, Protocol: "udp"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("Expected shutdown to succeed, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, File: path, ServiceName: "coaching-test"})
+	if err != nil {
+		t.Fatalf("Failed to set up tracing: %v", err)
+	}
+	_, span := Start(context.Background(), "TeamService.GetTeamByID")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("Failed to shut down tracing: %v", err)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read the trace file: %v", err)
+	}
+	if !strings.Contains(string(written), `"Name":"TeamService.GetTeamByID"`) || !strings.Contains(string(written), "coaching-test") {
+		t.Errorf("Expected the span and service name in the trace file, got %s", written)
+	}
+}
+
+type tracedTeam struct {
+	ID      uint
+	Name    string
+	Members []tracedMember `gorm:"foreignKey:TeamID"`
+}
+
+type tracedMember struct {
+	ID     uint
+	TeamID uint
+	Email  string
+}
+
+func TestRegisterGorm(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db.AutoMigrate(&tracedTeam{}, &tracedMember{})
+	db.Create(&tracedTeam{Name: "Platform", Members: []tracedMember{{Email: "jane@example.com"}}})
+	if err := RegisterGorm(db); err != nil {
+		t.Fatalf("Failed to register callbacks: %v", err)
+	}
+	exporter := record(t)
+
+	ctx, parent := Start(context.Background(), "TeamService.FindTeams")
+	query := db.WithContext(ctx).Where("name = ?", "Platform")
+	var teams []tracedTeam
+	if err := query.Preload("Members").Find(&teams).Error; err != nil {
+		t.Fatalf("Failed to query teams: %v", err)
+	}
+	if query.Statement.Context != ctx {
+		t.Errorf("Expected the statement to keep the caller's context")
+	}
+	db.WithContext(ctx).Exec("SELECT * FROM missing_table")
+	parent.End()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	teamQuery, ok := spans["gorm.query traced_teams"]
+	if !ok {
+		t.Fatalf("Expected a span for the team query, got %v", exporter.GetSpans())
+	}
+	if teamQuery.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Expected the query to be a child of the service span")
+	}
+	if text := attributeOf(teamQuery, "db.query.text"); !strings.Contains(text, "name = ?") || strings.Contains(text, "Platform") {
+		t.Errorf("Expected the SQL with placeholders and without values, got %q", text)
+	}
+	if attributeOf(teamQuery, "db.system") != "sqlite" || attributeOf(teamQuery, "db.rows_affected") != "1" {
+		t.Errorf("Expected the database system and rows, got %v", teamQuery.Attributes)
+	}
+
+	preload, ok := spans["gorm.query traced_members"]
+	if !ok || preload.Parent.SpanID() != teamQuery.SpanContext.SpanID() {
+		t.Errorf("Expected the preload to be nested under the team query, got %v", preload)
+	}
+
+	failed, ok := spans["gorm.raw"]
+	if !ok || failed.Status.Code != codes.Error || len(failed.Events) == 0 {
+		t.Errorf("Expected the failed statement to be marked as failed, got %v", failed)
+	}
+}
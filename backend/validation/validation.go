@@ -0,0 +1,166 @@
+// Package validation normalizes and validates input structs declared with
+// struct tags:
+//
+//	Email string `json:"email" normalize:"trim,lower" validate:"required,email,max=255"`
+//
+// The normalize tag is applied first and accepts "trim" and "lower". The
+// validate tag uses the go-playground/validator rules plus "slug", which
+// accepts lowercase words of letters and digits joined by hyphens. Errors are
+// reported against the JSON field names.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one rule a field failed
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors holds every field error found in a struct
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+var validate = newValidator()
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugPattern.MatchString(fl.Field().String())
+	})
+	return v
+}
+
+// Struct normalizes v, which must be a pointer to a struct, and validates it.
+// It returns Errors listing every failing field, or nil.
+func Struct(v interface{}) error {
+	Normalize(v)
+
+	err := validate.Struct(v)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	result := make(Errors, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		result[i] = FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Message: message(fieldErr),
+		}
+	}
+	return result
+}
+
+// Normalize applies the normalize tags of v, which must be a pointer to a
+// struct. Nested structs, slices of structs and slices of strings are
+// normalized as well.
+func Normalize(v interface{}) {
+	normalizeValue(reflect.ValueOf(v))
+}
+
+func normalizeValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			normalizeValue(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			normalizeValue(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := v.Field(i)
+			if !field.CanSet() {
+				continue
+			}
+			if field.Kind() == reflect.String {
+				field.SetString(applyNormalizers(field.String(), t.Field(i).Tag.Get("normalize")))
+				continue
+			}
+			if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+				for j := 0; j < field.Len(); j++ {
+					field.Index(j).SetString(applyNormalizers(field.Index(j).String(), t.Field(i).Tag.Get("normalize")))
+				}
+				continue
+			}
+			normalizeValue(field)
+		}
+	}
+}
+
+func applyNormalizers(value, tag string) string {
+	if tag == "" {
+		return value
+	}
+	for _, normalizer := range strings.Split(tag, ",") {
+		switch normalizer {
+		case "trim":
+			value = strings.TrimSpace(value)
+		case "lower":
+			value = strings.ToLower(value)
+		}
+	}
+	return value
+}
+
+func message(fieldErr validator.FieldError) string {
+	field := fieldErr.Field()
+	switch fieldErr.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "http_url":
+		return fmt.Sprintf("%s must be an http or https URL", field)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s %s", field, fieldErr.Param(), unit(fieldErr))
+	case "min":
+		return fmt.Sprintf("%s must be at least %s %s", field, fieldErr.Param(), unit(fieldErr))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+	case "slug":
+		return fmt.Sprintf("%s must contain only lowercase letters, digits and single hyphens", field)
+	default:
+		return fmt.Sprintf("%s is invalid", field)
+	}
+}
+
+// unit names what min and max count for a field
+func unit(fieldErr validator.FieldError) string {
+	if fieldErr.Kind() == reflect.Slice {
+		return "items"
+	}
+	return "characters"
+}
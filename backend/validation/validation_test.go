@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testInput struct {
+	Name    string   `json:"name" normalize:"trim" validate:"required,max=10"`
+	Email   string   `json:"email" normalize:"trim,lower" validate:"required,email"`
+	Website string   `json:"website" normalize:"trim" validate:"omitempty,http_url"`
+	Kind    string   `json:"kind" validate:"omitempty,oneof=team member"`
+	Slug    string   `json:"slug" validate:"omitempty,slug"`
+	Tags    []nested `json:"tags"`
+	Labels  []string `json:"labels" normalize:"trim,lower" validate:"max=2,dive,oneof=a b"`
+}
+
+type nested struct {
+	Label string `json:"label" normalize:"trim,lower"`
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  testInput
+		failed map[string]string
+	}{
+		{
+			name:  "Valid input",
+			input: testInput{Name: "John", Email: "john@example.com", Website: "https://example.com", Slug: "unit-42"},
+		},
+		{
+			name:   "Whitespace only counts as missing",
+			input:  testInput{Name: "   ", Email: "john@example.com"},
+			failed: map[string]string{"name": "required"},
+		},
+		{
+			name:   "Every failing field is reported",
+			input:  testInput{Name: strings.Repeat("a", 11), Email: "john@", Website: "javascript:alert(1)", Kind: "other"},
+			failed: map[string]string{"name": "max", "email": "email", "website": "http_url", "kind": "oneof"},
+		},
+		{
+			name:   "Slice elements are validated",
+			input:  testInput{Name: "John", Email: "john@example.com", Labels: []string{" A ", "c"}},
+			failed: map[string]string{"labels[1]": "oneof"},
+		},
+		{
+			name:   "Slice length is validated",
+			input:  testInput{Name: "John", Email: "john@example.com", Labels: []string{"a", "b", "a"}},
+			failed: map[string]string{"labels": "max"},
+		},
+		{
+			name:   "Slugs are validated",
+			input:  testInput{Name: "John", Email: "john@example.com", Slug: "Unit--42"},
+			failed: map[string]string{"slug": "slug"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(&tt.input)
+			if len(tt.failed) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var fieldErrs Errors
+			if !errors.As(err, &fieldErrs) {
+				t.Fatalf("Expected validation errors, got %v", err)
+			}
+			if len(fieldErrs) != len(tt.failed) {
+				t.Errorf("Expected %d field errors, got %v", len(tt.failed), fieldErrs)
+			}
+			for _, fieldErr := range fieldErrs {
+				if tt.failed[fieldErr.Field] != fieldErr.Rule {
+					t.Errorf("Unexpected field error %+v", fieldErr)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	input := testInput{Name: "  John ", Email: " John@Example.COM", Tags: []nested{{Label: " Lead "}}, Labels: []string{" B "}}
+	Normalize(&input)
+
+	if input.Name != "John" {
+		t.Errorf("Expected trimmed name, got %q", input.Name)
+	}
+	if input.Email != "john@example.com" {
+		t.Errorf("Expected lowercased email, got %q", input.Email)
+	}
+	if input.Tags[0].Label != "lead" {
+		t.Errorf("Expected nested values to be normalized, got %q", input.Tags[0].Label)
+	}
+	if input.Labels[0] != "b" {
+		t.Errorf("Expected slice elements to be normalized, got %q", input.Labels[0])
+	}
+}